@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/example/user-service/internal/app"
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/service"
+	"github.com/example/user-service/internal/tenant"
+)
+
+const adminUsage = `usage: user-service admin [flags] <command> [args]
+
+Support tasks on a single account. <user> is a user ID or email address;
+use -org when the email exists in several organizations.
+Every change is recorded in the audit log under the -actor name.
+
+Commands:
+  get <user>
+  activate <user>
+  deactivate <user>
+  reset-password <user>     block password sign-in until a new password is set
+  unlink <user> <provider> <provider_user_id>
+  assign-role <user> <role>
+  revoke-sessions <user>    invalidate every issued token
+
+Flags:
+`
+
+var errUsage = errors.New("usage")
+
+// runAdmin implements the admin subcommand and returns the exit code.
+func runAdmin(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("admin", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), adminUsage)
+		fs.PrintDefaults()
+	}
+	actor := fs.String("actor", os.Getenv("USER"), "operator name recorded in the audit log")
+	output := fs.String("output", "table", "output format, table or json")
+	orgID := fs.String("org", "", "organization ID to look users up in (default: all tenants)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() < 2 || (*output != "table" && *output != "json") {
+		fs.Usage()
+		return 2
+	}
+	if strings.TrimSpace(*actor) == "" {
+		fmt.Fprintln(os.Stderr, "admin: -actor is required when $USER is not set")
+		return 2
+	}
+
+	admin, closeAdmin, err := app.OpenAdmin(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "admin: %v\n", err)
+		return 1
+	}
+	defer closeAdmin()
+	if *orgID != "" {
+		ctx = tenant.With(ctx, *orgID)
+	}
+
+	cmd := adminCommand{
+		admin:   admin,
+		actorID: "cli:" + strings.TrimSpace(*actor),
+		traceID: fmt.Sprintf("admin-%d", time.Now().Unix()),
+	}
+	details, err := cmd.run(ctx, fs.Arg(0), fs.Args()[1:])
+	if errors.Is(err, errUsage) {
+		fs.Usage()
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "admin: %v\n", err)
+		return 1
+	}
+	if *output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(details)
+	} else {
+		err = writeUserTable(os.Stdout, details)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "admin: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+type adminCommand struct {
+	admin   service.AdminService
+	actorID string
+	traceID string
+}
+
+// run executes name against the user in args[0] and returns the user's state
+// afterwards.
+func (c adminCommand) run(ctx context.Context, name string, args []string) (*service.UserDetails, error) {
+	arity := map[string]int{
+		"get": 1, "activate": 1, "deactivate": 1, "reset-password": 1,
+		"unlink": 3, "assign-role": 2, "revoke-sessions": 1,
+	}
+	want, ok := arity[name]
+	if !ok || len(args) != want {
+		return nil, errUsage
+	}
+	user, err := c.admin.FindUser(ctx, args[0])
+	if err != nil {
+		return nil, fmt.Errorf("find user %s: %w", args[0], err)
+	}
+
+	switch name {
+	case "activate":
+		_, err = c.admin.SetActive(ctx, c.traceID, c.actorID, user.ID, true)
+	case "deactivate":
+		_, err = c.admin.SetActive(ctx, c.traceID, c.actorID, user.ID, false)
+	case "reset-password":
+		_, err = c.admin.ForcePasswordReset(ctx, c.traceID, c.actorID, user.ID)
+	case "unlink":
+		err = c.admin.UnlinkIdentity(ctx, c.traceID, c.actorID, user.ID, domain.IdentityProvider(strings.ToLower(args[1])), args[2])
+	case "assign-role":
+		err = c.admin.AssignRole(ctx, c.traceID, c.actorID, user.ID, args[1])
+	case "revoke-sessions":
+		_, err = c.admin.RevokeSessions(ctx, c.traceID, c.actorID, user.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c.admin.Describe(ctx, user.ID)
+}
+
+func writeUserTable(w io.Writer, details *service.UserDetails) error {
+	user := details.User
+	identities := make([]string, 0, len(details.Identities))
+	for _, identity := range details.Identities {
+		identities = append(identities, string(identity.Provider)+":"+identity.ProviderUserID)
+	}
+	username := ""
+	if user.Username != nil {
+		username = *user.Username
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	rows := [][2]string{
+		{"id", user.ID},
+		{"email", user.Email},
+		{"username", username},
+		{"role", details.Role},
+		{"active", fmt.Sprint(user.IsActive)},
+		{"suspended", formatTime(user.SuspendedAt)},
+		{"pending_deletion", formatTime(user.DeletedAt)},
+		{"password_reset_required", fmt.Sprint(user.PasswordResetRequired)},
+		{"tokens_revoked_at", formatTime(user.TokensRevokedAt)},
+		{"identities", strings.Join(identities, ", ")},
+		{"created_at", user.CreatedAt.Format(time.RFC3339)},
+	}
+	for _, row := range rows {
+		fmt.Fprintf(tw, "%s\t%s\n", row[0], row[1])
+	}
+	return tw.Flush()
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/example/user-service/internal/app"
+	"github.com/example/user-service/internal/importer"
+)
+
+const importUsage = `usage: user-service import [flags] <file|->
+
+Bulk loads users from CSV or JSONL. Rejected rows are written to the report
+and do not stop the import. Flags:
+`
+
+// runImport implements the import subcommand and returns the exit code.
+func runImport(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), importUsage)
+		fs.PrintDefaults()
+	}
+	format := fs.String("format", "", "input format, csv or jsonl (default: from the file extension)")
+	batchSize := fs.Int("batch-size", 500, "rows per transaction")
+	dryRun := fs.Bool("dry-run", false, "validate and insert every batch, then roll it back")
+	checkpointPath := fs.String("checkpoint", "", "file recording the last committed row; a rerun resumes after it")
+	reportPath := fs.String("report", "import-errors.jsonl", "JSONL file receiving one line per rejected row")
+	emitEvents := fs.Bool("emit-events", false, "publish user.created for every imported user")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	source := fs.Arg(0)
+	var src io.Reader = os.Stdin
+	if source != "-" {
+		file, err := os.Open(source)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "import: %v\n", err)
+			return 1
+		}
+		defer file.Close()
+		src = file
+	}
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(source)), ".")
+	}
+	// A resumed run appends to the report of the interrupted one.
+	reportFlags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if *checkpointPath != "" {
+		reportFlags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	report, err := os.OpenFile(*reportPath, reportFlags, 0o644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import: %v\n", err)
+		return 1
+	}
+	defer report.Close()
+
+	summary, err := app.RunImport(ctx, src, report, importer.Options{
+		Format:     *format,
+		BatchSize:  *batchSize,
+		DryRun:     *dryRun,
+		EmitEvents: *emitEvents,
+		Checkpoint: *checkpointPath,
+		Source:     source,
+		TraceID:    fmt.Sprintf("import-%d", time.Now().Unix()),
+	})
+	if summary != nil {
+		_ = json.NewEncoder(os.Stdout).Encode(summary)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import: %v\n", err)
+		return 1
+	}
+	return 0
+}
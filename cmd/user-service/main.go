@@ -3,8 +3,12 @@ package main
 import (
 	"context"
 	"log"
+	"os"
 	"os/signal"
 	"syscall"
+	// Profile time zones are validated with time.LoadLocation, which must not
+	// depend on the zoneinfo files of the host image.
+	_ "time/tzdata"
 
 	"github.com/example/user-service/internal/app"
 )
@@ -13,6 +17,21 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	if len(os.Args) > 1 {
+		var run func(context.Context, []string) int
+		switch os.Args[1] {
+		case "import":
+			run = runImport
+		case "admin":
+			run = runAdmin
+		}
+		if run != nil {
+			code := run(ctx, os.Args[2:])
+			stop()
+			os.Exit(code)
+		}
+	}
+
 	application, err := app.New(ctx)
 	if err != nil {
 		log.Fatalf("failed to initialize app: %v", err)
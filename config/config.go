@@ -1,6 +1,7 @@
 package config
 
 import (
+	"errors"
 	"log"
 	"time"
 
@@ -29,6 +30,9 @@ type Config struct {
 	JWTRefreshTTLMinutes time.Duration `env:"JWT_REFRESH_TTL_MINUTES" envDefault:"43200"`
 	JWTIssuer            string        `env:"JWT_ISSUER" envDefault:"user-service"`
 	JWTAudience          string        `env:"JWT_AUDIENCE" envDefault:"frontend"`
+	// JWTGroupsClaim adds the IDs of the user's groups, nested ones included,
+	// to access tokens as the "groups" claim.
+	JWTGroupsClaim bool `env:"JWT_GROUPS_CLAIM" envDefault:"false"`
 
 	GoogleClientID     string `env:"GOOGLE_CLIENT_ID"`
 	GoogleClientSecret string `env:"GOOGLE_CLIENT_SECRET"`
@@ -38,7 +42,62 @@ type Config struct {
 	GithubClientSecret string `env:"GITHUB_CLIENT_SECRET"`
 	GithubRedirectURL  string `env:"GITHUB_REDIRECT_URL"`
 
+	// OAuthLinkPolicy maps a provider to "auto" or "verify". Providers that are
+	// not listed require the account owner to confirm the link.
+	OAuthLinkPolicy map[string]string `env:"OAUTH_LINK_POLICY" envKeyValSeparator:":"`
+	AccountLinkTTL  time.Duration     `env:"ACCOUNT_LINK_TTL" envDefault:"15m"`
+
 	FileStorageURL string `env:"MS_FILESTORAGE_URL" envDefault:"http://ms-filestorage:8000"`
+	// FileStoragePublicURL is the externally visible storage origin. Avatar URLs
+	// set through the API must point at this host.
+	FileStoragePublicURL string `env:"MS_FILESTORAGE_PUBLIC_URL"`
+
+	AvatarMaxBytes       int64 `env:"AVATAR_MAX_BYTES" envDefault:"5242880"`
+	AvatarMaxPixels      int   `env:"AVATAR_MAX_PIXELS" envDefault:"16000000"`
+	AvatarThumbnailSizes []int `env:"AVATAR_THUMBNAIL_SIZES" envDefault:"64,128,256"`
+
+	AvatarWorkers        int           `env:"AVATAR_WORKERS" envDefault:"2"`
+	AvatarQueueSize      int           `env:"AVATAR_QUEUE_SIZE" envDefault:"256"`
+	AvatarIngestAttempts int           `env:"AVATAR_INGEST_ATTEMPTS" envDefault:"5"`
+	AvatarRetryInterval  time.Duration `env:"AVATAR_RETRY_INTERVAL" envDefault:"1s"`
+
+	SuspensionSweepInterval time.Duration `env:"SUSPENSION_SWEEP_INTERVAL" envDefault:"1m"`
+
+	// UsernameReleaseCooldown keeps a released username from being claimed by
+	// another account, so old links cannot be taken over right away.
+	UsernameChangeInterval  time.Duration `env:"USERNAME_CHANGE_INTERVAL" envDefault:"720h"`
+	UsernameReleaseCooldown time.Duration `env:"USERNAME_RELEASE_COOLDOWN" envDefault:"2160h"`
+
+	// SignedTokenSecret keys the HMAC of one-off tokens such as account restore
+	// links. It defaults to JWTSecret and must be set when JWTs use RSA keys.
+	SignedTokenSecret    string        `env:"SIGNED_TOKEN_SECRET"`
+	AccountDeletionGrace time.Duration `env:"ACCOUNT_DELETION_GRACE" envDefault:"720h"`
+	AccountPurgeInterval time.Duration `env:"ACCOUNT_PURGE_INTERVAL" envDefault:"1h"`
+	AccountRestoreTTL    time.Duration `env:"ACCOUNT_RESTORE_TTL" envDefault:"15m"`
+	// PasswordResetTTL is how long an emailed password reset link works.
+	PasswordResetTTL time.Duration `env:"PASSWORD_RESET_TTL" envDefault:"1h"`
+
+	// DataExportTTL is how long a finished export stays downloadable.
+	DataExportTTL          time.Duration `env:"DATA_EXPORT_TTL" envDefault:"72h"`
+	DataExportPollInterval time.Duration `env:"DATA_EXPORT_POLL_INTERVAL" envDefault:"5s"`
+	// DataExportClaimTimeout is how long a worker may hold a running export
+	// before another worker picks it up again.
+	DataExportClaimTimeout time.Duration `env:"DATA_EXPORT_CLAIM_TIMEOUT" envDefault:"15m"`
+	// InvitationTTL is how long an invitation link stays valid; resending
+	// starts a new period.
+	InvitationTTL time.Duration `env:"INVITATION_TTL" envDefault:"168h"`
+	// InvitationRoles are the roles any holder of users:write may invite
+	// with. Other roles require roles:assign.
+	InvitationRoles []string `env:"INVITATION_ROLES" envDefault:"user"`
+
+	// SMSSender is "log" or "file"; both only record the message and stand
+	// in for a real gateway during development.
+	SMSSender    string        `env:"SMS_SENDER" envDefault:"log"`
+	SMSFilePath  string        `env:"SMS_FILE_PATH" envDefault:"sms.log"`
+	PhoneCodeTTL time.Duration `env:"PHONE_CODE_TTL" envDefault:"10m"`
+	// ReauthMaxAge is how recent the sign-in behind an access token must be
+	// for changes to the second factor.
+	ReauthMaxAge time.Duration `env:"REAUTH_MAX_AGE" envDefault:"5m"`
 
 	TarantoolURL string `env:"MS_TARANTOOL_URL"`
 	RBACURL      string `env:"MS_RBAC"`
@@ -50,6 +109,15 @@ type Config struct {
 
 	CORSAllowOrigins string `env:"CORS_ALLOW_ORIGINS" envDefault:"*"`
 	RateLimitPerMin  int    `env:"RATE_LIMIT_PER_MIN" envDefault:"120"`
+
+	// TenantBaseDomain enables tenant resolution from the host: requests to
+	// <slug>.<TenantBaseDomain> act in the organization with that slug.
+	TenantBaseDomain string `env:"TENANT_BASE_DOMAIN"`
+
+	// InternalAPITokens are the shared secrets other services present in the
+	// X-Internal-Token header to call /internal routes. The routes reject
+	// every request while the list is empty.
+	InternalAPITokens []string `env:"INTERNAL_API_TOKENS"`
 }
 
 func Load() (*Config, error) {
@@ -59,6 +127,12 @@ func Load() (*Config, error) {
 		return nil, err
 	}
 	normalizeDurations(cfg)
+	if cfg.SignedTokenSecret == "" {
+		cfg.SignedTokenSecret = cfg.JWTSecret
+	}
+	if cfg.SignedTokenSecret == "" {
+		return nil, errors.New("SIGNED_TOKEN_SECRET must be set when JWT_SECRET is empty")
+	}
 	return cfg, nil
 }
 
@@ -3,6 +3,7 @@ package app
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"time"
@@ -14,12 +15,14 @@ import (
 	"gorm.io/gorm/schema"
 
 	"github.com/example/user-service/config"
+	"github.com/example/user-service/internal/importer"
 	"github.com/example/user-service/internal/ports/broker"
 	"github.com/example/user-service/internal/ports/filestorage"
 	httpport "github.com/example/user-service/internal/ports/http"
 	"github.com/example/user-service/internal/ports/http/handlers"
 	mw "github.com/example/user-service/internal/ports/http/middleware"
 	rbacclient "github.com/example/user-service/internal/ports/rbac"
+	"github.com/example/user-service/internal/ports/sms"
 	"github.com/example/user-service/internal/ports/tarantool"
 	"github.com/example/user-service/internal/repo"
 	"github.com/example/user-service/internal/service"
@@ -31,6 +34,10 @@ type App struct {
 	logger    pkglog.Logger
 	db        *gorm.DB
 	publisher broker.Publisher
+	avatars   *service.AvatarWorker
+	admin     service.AdminService
+	accounts  service.AccountService
+	exports   service.ExportService
 	echo      *echo.Echo
 }
 
@@ -38,12 +45,7 @@ func New(ctx context.Context) (*App, error) {
 	cfg := config.MustLoad()
 	logger := pkglog.New(cfg.AppEnv)
 
-	db, err := gorm.Open(postgres.Open(buildDSN(cfg)), &gorm.Config{
-		Logger: loggerForGorm(cfg),
-		NamingStrategy: schema.NamingStrategy{
-			SingularTable: true,
-		},
-	})
+	db, err := openDB(cfg)
 	if err != nil {
 		return nil, err
 	}
@@ -53,46 +55,77 @@ func New(ctx context.Context) (*App, error) {
 	rbacHTTP := rbacclient.NewHTTPClient(cfg.RBACURL, 3*time.Second)
 	rbacClient := rbacclient.NewCachingClient(rbacHTTP, time.Minute)
 
-	var publisher broker.Publisher
-	switch cfg.MessageBroker {
-	case "nats":
-		publisher, err = broker.NewNATSPublisher(cfg.NATSURL)
-		if err != nil {
-			log.Printf("nats init failed: %v", err)
-		}
-	default:
-		publisher, err = broker.NewRabbitMQPublisher(cfg.RabbitMQURL, cfg.RabbitMQExchange)
-		if err != nil {
-			log.Printf("rabbitmq init failed: %v", err)
-		}
-	}
+	publisher := newPublisher(cfg)
 
 	userRepo := repo.NewUserRepository(db)
 	profileRepo := repo.NewUserProfileRepository(db)
-	providerRepo := repo.NewUserProviderRepository(db)
 	identityRepo := repo.NewUserIdentityRepository(db)
+	linkRepo := repo.NewAccountLinkRepository(db)
+	auditRepo := repo.NewAuditRepository(db)
+	exportRepo := repo.NewDataExportRepository(db)
+	profileSchemaRepo := repo.NewProfileSchemaRepository(db)
+	usernameRepo := repo.NewUsernameHistoryRepository(db)
+	orgRepo := repo.NewOrganizationRepository(db)
+	groupRepo := repo.NewGroupRepository(db)
+	invitationRepo := repo.NewInvitationRepository(db)
+	preferenceRepo := repo.NewPreferenceRepository(db)
+	searchRepo := repo.NewUserSearchRepository(db)
+	emailRepo := repo.NewUserEmailRepository(db)
+	phoneCodeRepo := repo.NewPhoneCodeRepository(db)
+	recoveryCodeRepo := repo.NewRecoveryCodeRepository(db)
 	signer, err := service.NewJWTSigner(cfg)
 	if err != nil {
 		return nil, err
 	}
-	avatarIngestor := service.NewAvatarIngestor(filestorageClient, logger)
-	authService := service.NewAuthService(cfg, logger, userRepo, profileRepo, providerRepo, tarantoolClient, rbacClient, publisher, signer, avatarIngestor)
-	userService := service.NewUserService(userRepo, profileRepo, identityRepo, tarantoolClient)
+	avatarStore := service.NewAvatarStore(cfg, filestorageClient)
+	avatarIngestor := service.NewAvatarIngestor(avatarStore, logger)
+	avatarWorker := service.NewAvatarWorker(cfg, logger, avatarIngestor, profileRepo, publisher)
+	groupService := service.NewGroupService(logger, groupRepo, userRepo, rbacClient, auditRepo, publisher)
+	phoneService := service.NewPhoneService(cfg, logger, userRepo, phoneCodeRepo, newSMSSender(cfg, logger))
+	recoveryCodeService := service.NewRecoveryCodeService(logger, recoveryCodeRepo, userRepo, publisher)
+	authService := service.NewAuthService(cfg, logger, userRepo, profileRepo, identityRepo, linkRepo, tarantoolClient, rbacClient, publisher, signer, avatarWorker, groupService, invitationRepo, phoneService, recoveryCodeService)
+	profileSchemaService := service.NewProfileSchemaService(logger, profileSchemaRepo)
+	userService := service.NewUserService(cfg, userRepo, profileRepo, identityRepo, usernameRepo, tarantoolClient, rbacClient, avatarStore, profileSchemaService)
+	adminService := service.NewAdminService(logger, userRepo, userService, rbacClient, auditRepo, publisher)
+	preferenceService := service.NewPreferenceService(logger, preferenceRepo, publisher)
+	exportService := service.NewExportService(cfg, logger, userRepo, identityRepo, emailRepo, preferenceService, auditRepo, exportRepo)
+	accountService := service.NewAccountService(cfg, logger, userRepo, auditRepo, avatarStore, rbacClient, publisher)
+	orgService := service.NewOrganizationService(logger, orgRepo, userRepo, rbacClient, auditRepo)
+	invitationService := service.NewInvitationService(cfg, logger, invitationRepo, userRepo, orgRepo, rbacClient, publisher)
+	searchService := service.NewSearchService(searchRepo)
+	emailService := service.NewEmailService(logger, userRepo, emailRepo, tarantoolClient)
 
 	authHandler := handlers.NewAuthHandler(authService)
-	userHandler := handlers.NewUserHandler(userService)
+	userHandler := handlers.NewUserHandler(userService, accountService, cfg.AvatarMaxBytes)
+	adminHandler := handlers.NewAdminHandler(adminService, profileSchemaService)
+	exportHandler := handlers.NewExportHandler(exportService)
+	orgHandler := handlers.NewOrganizationHandler(orgService)
+	groupHandler := handlers.NewGroupHandler(groupService)
+	inviteHandler := handlers.NewInvitationHandler(invitationService)
+	prefHandler := handlers.NewPreferenceHandler(preferenceService)
+	searchHandler := handlers.NewSearchHandler(searchService)
+	emailHandler := handlers.NewEmailHandler(emailService)
+	phoneHandler := handlers.NewPhoneHandler(phoneService)
+	recoveryHandler := handlers.NewRecoveryCodeHandler(recoveryCodeService)
 
-	authMW := mw.NewAuthMiddleware(cfg, logger, rbacClient)
+	authMW := mw.NewAuthMiddleware(cfg, logger, rbacClient, service.NewSessionValidator(userRepo))
 	rbacMW := mw.NewRBACMiddleware(rbacClient)
+	tenantMW := mw.NewTenantMiddleware(cfg, orgService)
+	internalMW := mw.NewInternalMiddleware(cfg)
 
 	e := echo.New()
-	router := httpport.NewRouter(cfg, authHandler, userHandler, authMW, rbacMW)
+	router := httpport.NewRouter(cfg, authHandler, userHandler, adminHandler, exportHandler, orgHandler, groupHandler, inviteHandler, prefHandler, searchHandler, emailHandler, phoneHandler, recoveryHandler, tenantMW, internalMW, authMW, rbacMW)
 	router.Setup(e)
 
-	return &App{cfg: cfg, logger: logger, db: db, publisher: publisher, echo: e}, nil
+	return &App{cfg: cfg, logger: logger, db: db, publisher: publisher, avatars: avatarWorker, admin: adminService, accounts: accountService, exports: exportService, echo: e}, nil
 }
 
 func (a *App) Run(ctx context.Context) error {
+	go a.avatars.Run(ctx)
+	go runEvery(ctx, a.cfg.SuspensionSweepInterval, a.liftExpiredSuspensions)
+	go runEvery(ctx, a.cfg.AccountPurgeInterval, a.purgeDeletedAccounts)
+	go runEvery(ctx, a.cfg.DataExportPollInterval, a.processDataExports)
+
 	server := &http.Server{
 		Addr:    ":" + a.cfg.AppPort,
 		Handler: a.echo,
@@ -112,6 +145,137 @@ func (a *App) Close() {
 	}
 }
 
+// runEvery calls job every interval until ctx is cancelled.
+func runEvery(ctx context.Context, interval time.Duration, job func(context.Context)) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			job(ctx)
+		}
+	}
+}
+
+func (a *App) liftExpiredSuspensions(ctx context.Context) {
+	lifted, err := a.admin.LiftExpiredSuspensions(ctx, time.Now().UTC())
+	if err != nil {
+		a.logger.Error().Err(err).Msg("lifting expired suspensions failed")
+	}
+	if lifted > 0 {
+		a.logger.Info().Int("count", lifted).Msg("expired suspensions lifted")
+	}
+}
+
+func (a *App) purgeDeletedAccounts(ctx context.Context) {
+	purged, err := a.accounts.PurgeExpired(ctx, time.Now().UTC())
+	if err != nil {
+		a.logger.Error().Err(err).Msg("purging deleted accounts failed")
+	}
+	if purged > 0 {
+		a.logger.Info().Int("count", purged).Msg("deleted accounts purged")
+	}
+}
+
+func (a *App) processDataExports(ctx context.Context) {
+	if _, err := a.exports.ProcessPending(ctx); err != nil {
+		a.logger.Error().Err(err).Msg("processing data exports failed")
+	}
+	if _, err := a.exports.PurgeExpired(ctx, time.Now().UTC()); err != nil {
+		a.logger.Error().Err(err).Msg("purging expired data exports failed")
+	}
+}
+
+// OpenAdmin builds the admin service for the operator CLI. close releases the
+// broker connection; events are skipped when the broker is unreachable.
+func OpenAdmin(ctx context.Context) (admin service.AdminService, close func(), err error) {
+	cfg := config.MustLoad()
+	logger := pkglog.New(cfg.AppEnv)
+	db, err := openDB(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	tarantoolClient := tarantool.NewHTTPClient(cfg.TarantoolURL, 5*time.Second)
+	filestorageClient := filestorage.NewHTTPClient(cfg.FileStorageURL, 5*time.Second)
+	rbacClient := rbacclient.NewHTTPClient(cfg.RBACURL, 3*time.Second)
+	publisher := newPublisher(cfg)
+
+	userRepo := repo.NewUserRepository(db)
+	schemas := service.NewProfileSchemaService(logger, repo.NewProfileSchemaRepository(db))
+	userService := service.NewUserService(cfg, userRepo, repo.NewUserProfileRepository(db), repo.NewUserIdentityRepository(db), repo.NewUsernameHistoryRepository(db), tarantoolClient, rbacClient, service.NewAvatarStore(cfg, filestorageClient), schemas)
+	admin = service.NewAdminService(logger, userRepo, userService, rbacClient, repo.NewAuditRepository(db), publisher)
+	close = func() {
+		if publisher != nil {
+			_ = publisher.Close()
+		}
+	}
+	return admin, close, nil
+}
+
+// RunImport bulk loads users from src into the configured database. Rejected
+// rows are written to report.
+func RunImport(ctx context.Context, src io.Reader, report io.Writer, opts importer.Options) (*importer.Summary, error) {
+	cfg := config.MustLoad()
+	logger := pkglog.New(cfg.AppEnv)
+	db, err := openDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var publisher broker.Publisher
+	if opts.EmitEvents && !opts.DryRun {
+		if publisher = newPublisher(cfg); publisher == nil {
+			return nil, fmt.Errorf("%s publisher unavailable", cfg.MessageBroker)
+		}
+		defer publisher.Close()
+	}
+	schemas := service.NewProfileSchemaService(logger, repo.NewProfileSchemaRepository(db))
+	return importer.New(logger, importer.NewGormStore(db), schemas, publisher, report).Run(ctx, src, opts)
+}
+
+func openDB(cfg *config.Config) (*gorm.DB, error) {
+	return gorm.Open(postgres.Open(buildDSN(cfg)), &gorm.Config{
+		Logger:         loggerForGorm(cfg),
+		TranslateError: true,
+		NamingStrategy: schema.NamingStrategy{
+			SingularTable: true,
+		},
+	})
+}
+
+// newPublisher connects to the configured broker. Failures are logged and
+// yield a nil publisher so that the service runs without events.
+func newPublisher(cfg *config.Config) broker.Publisher {
+	switch cfg.MessageBroker {
+	case "nats":
+		publisher, err := broker.NewNATSPublisher(cfg.NATSURL)
+		if err != nil {
+			log.Printf("nats init failed: %v", err)
+			return nil
+		}
+		return publisher
+	default:
+		publisher, err := broker.NewRabbitMQPublisher(cfg.RabbitMQURL, cfg.RabbitMQExchange)
+		if err != nil {
+			log.Printf("rabbitmq init failed: %v", err)
+			return nil
+		}
+		return publisher
+	}
+}
+
+// newSMSSender picks the development stand-in named by SMS_SENDER.
+func newSMSSender(cfg *config.Config, logger pkglog.Logger) service.SMSSender {
+	if cfg.SMSSender == "file" {
+		return sms.NewFileSender(cfg.SMSFilePath)
+	}
+	return sms.NewLogSender(logger)
+}
+
 func buildDSN(cfg *config.Config) string {
 	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s", cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName, cfg.DBSSLMode)
 }
@@ -0,0 +1,28 @@
+package domain
+
+import "time"
+
+// AccountLink is a pending request to attach an external identity to an
+// existing account. It is only applied once the account owner proves
+// control of the account.
+type AccountLink struct {
+	ID               string     `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	UserID           string     `gorm:"type:uuid;not null;index" json:"user_id"`
+	Provider         string     `gorm:"column:provider;not null" json:"provider"`
+	ProviderUserID   string     `gorm:"column:provider_user_id;not null" json:"provider_user_id"`
+	Email            string     `gorm:"column:email;not null" json:"email"`
+	Metadata         JSONMap    `gorm:"type:jsonb" json:"metadata"`
+	VerificationUUID string     `gorm:"column:verification_uuid" json:"-"`
+	Attempts         int        `gorm:"column:attempts;not null;default:0" json:"-"`
+	ExpiresAt        time.Time  `gorm:"column:expires_at;not null" json:"expires_at"`
+	ConsumedAt       *time.Time `gorm:"column:consumed_at" json:"consumed_at,omitempty"`
+	CreatedAt        time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (AccountLink) TableName() string {
+	return "account_link"
+}
+
+func (l *AccountLink) IsPending(now time.Time) bool {
+	return l.ConsumedAt == nil && now.Before(l.ExpiresAt)
+}
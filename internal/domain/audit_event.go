@@ -0,0 +1,38 @@
+package domain
+
+import "time"
+
+// Audit actions recorded for a user account.
+const (
+	AuditUserSuspended      = "user.suspended"
+	AuditUserReactivated    = "user.reactivated"
+	AuditDeletionRequested  = "user.deletion_requested"
+	AuditExportRequested    = "user.export_requested"
+	AuditExportDownloaded   = "user.export_downloaded"
+	AuditProfileUpdated     = "user.profile_updated"
+	AuditUserActivated      = "user.activated"
+	AuditUserDeactivated    = "user.deactivated"
+	AuditPasswordReset      = "user.password_reset_required"
+	AuditIdentityUnlinked   = "user.identity_unlinked"
+	AuditRoleAssigned       = "user.role_assigned"
+	AuditSessionsRevoked    = "user.sessions_revoked"
+	AuditOrgRoleChanged     = "user.org_role_changed"
+	AuditGroupMemberAdded   = "user.group_member_added"
+	AuditGroupMemberRemoved = "user.group_member_removed"
+)
+
+// AuditEvent records an action taken on a user account. ActorID is empty for
+// actions performed by the system, such as scheduled jobs.
+type AuditEvent struct {
+	ID        string    `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	UserID    string    `gorm:"type:uuid;not null;index" json:"user_id"`
+	ActorID   string    `gorm:"column:actor_id" json:"actor_id,omitempty"`
+	Action    string    `gorm:"column:action;not null" json:"action"`
+	Metadata  JSONMap   `gorm:"type:jsonb" json:"metadata,omitempty"`
+	TraceID   string    `gorm:"column:trace_id" json:"trace_id,omitempty"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (AuditEvent) TableName() string {
+	return "audit_event"
+}
@@ -0,0 +1,35 @@
+package domain
+
+import "time"
+
+type ExportStatus string
+
+const (
+	ExportPending ExportStatus = "pending"
+	ExportRunning ExportStatus = "running"
+	ExportReady   ExportStatus = "ready"
+	ExportFailed  ExportStatus = "failed"
+)
+
+// DataExport is a subject-access request. The archive is kept in the database
+// rather than public file storage and is only served through a signed link.
+type DataExport struct {
+	ID          string       `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	UserID      string       `gorm:"type:uuid;not null;index" json:"user_id"`
+	RequestedBy string       `gorm:"column:requested_by" json:"requested_by"`
+	Status      ExportStatus `gorm:"column:status;not null" json:"status"`
+	Archive     []byte       `gorm:"column:archive" json:"-"`
+	Error       *string      `gorm:"column:error" json:"error,omitempty"`
+	ExpiresAt   *time.Time   `gorm:"column:expires_at" json:"expires_at,omitempty"`
+	CompletedAt *time.Time   `gorm:"column:completed_at" json:"completed_at,omitempty"`
+	ClaimedAt   *time.Time   `gorm:"column:claimed_at" json:"-"`
+	CreatedAt   time.Time    `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (DataExport) TableName() string {
+	return "data_export"
+}
+
+func (e *DataExport) IsDownloadable(now time.Time) bool {
+	return e.Status == ExportReady && e.ExpiresAt != nil && now.Before(*e.ExpiresAt)
+}
@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	ErrGroupNameInvalid = errors.New("name must be 1-100 characters")
+	ErrGroupRoleInvalid = errors.New("role must be owner or member")
+)
+
+// Group is a named set of users of one tenant. Groups nest: the members of a
+// subgroup are members of every group it is nested in.
+type Group struct {
+	ID          string    `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	OrgID       *string   `gorm:"type:uuid;column:org_id" json:"org_id,omitempty"`
+	Name        string    `gorm:"column:name;not null" json:"name"`
+	Description *string   `gorm:"column:description" json:"description,omitempty"`
+	CreatedBy   string    `gorm:"column:created_by" json:"created_by,omitempty"`
+	CreatedAt   time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+func (Group) TableName() string {
+	return "user_group"
+}
+
+// GroupRole is the role of a direct member. Owners manage the group.
+type GroupRole string
+
+const (
+	GroupRoleOwner  GroupRole = "owner"
+	GroupRoleMember GroupRole = "member"
+)
+
+func (r GroupRole) IsValid() bool {
+	return r == GroupRoleOwner || r == GroupRoleMember
+}
+
+type GroupMember struct {
+	GroupID   string    `gorm:"type:uuid;primaryKey" json:"group_id"`
+	UserID    string    `gorm:"type:uuid;primaryKey" json:"user_id"`
+	Role      GroupRole `gorm:"column:role;not null" json:"role"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (GroupMember) TableName() string {
+	return "group_member"
+}
+
+// GroupNesting makes ChildID a subgroup of ParentID.
+type GroupNesting struct {
+	ParentID  string    `gorm:"type:uuid;primaryKey" json:"parent_id"`
+	ChildID   string    `gorm:"type:uuid;primaryKey" json:"child_id"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (GroupNesting) TableName() string {
+	return "group_nesting"
+}
+
+// NormalizeGroupName trims name and checks its length.
+func NormalizeGroupName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > 100 {
+		return "", ErrGroupNameInvalid
+	}
+	return name, nil
+}
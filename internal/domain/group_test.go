@@ -0,0 +1,17 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeGroupName(t *testing.T) {
+	if got, err := NormalizeGroupName("  Platform team "); err != nil || got != "Platform team" {
+		t.Errorf("NormalizeGroupName = %q, %v; want Platform team", got, err)
+	}
+	for _, input := range []string{"", "   ", strings.Repeat("é", 101)} {
+		if _, err := NormalizeGroupName(input); err != ErrGroupNameInvalid {
+			t.Errorf("NormalizeGroupName(%q) error = %v; want ErrGroupNameInvalid", input, err)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrInvitationRoleInvalid = errors.New("role must be 1-64 characters")
+
+type InvitationStatus string
+
+const (
+	InvitationPending  InvitationStatus = "pending"
+	InvitationAccepted InvitationStatus = "accepted"
+	InvitationRevoked  InvitationStatus = "revoked"
+	InvitationExpired  InvitationStatus = "expired"
+)
+
+func (s InvitationStatus) IsValid() bool {
+	switch s {
+	case InvitationPending, InvitationAccepted, InvitationRevoked, InvitationExpired:
+		return true
+	}
+	return false
+}
+
+// Invitation lets someone without an account sign up as Email in OrgID and
+// start with Role instead of the default role. Sends counts the links sent;
+// only the link of the latest send is accepted.
+type Invitation struct {
+	ID         string     `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	Email      string     `gorm:"column:email;not null" json:"email"`
+	OrgID      *string    `gorm:"type:uuid;column:org_id" json:"org_id,omitempty"`
+	Role       string     `gorm:"column:role;not null" json:"role"`
+	InvitedBy  string     `gorm:"column:invited_by" json:"invited_by"`
+	Sends      int        `gorm:"column:sends;not null;default:1" json:"sends"`
+	LastSentAt time.Time  `gorm:"column:last_sent_at;not null" json:"last_sent_at"`
+	ExpiresAt  time.Time  `gorm:"column:expires_at;not null" json:"expires_at"`
+	AcceptedAt *time.Time `gorm:"column:accepted_at" json:"accepted_at,omitempty"`
+	AcceptedBy *string    `gorm:"column:accepted_by" json:"accepted_by,omitempty"`
+	RevokedAt  *time.Time `gorm:"column:revoked_at" json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (Invitation) TableName() string {
+	return "invitation"
+}
+
+func (i *Invitation) Status(now time.Time) InvitationStatus {
+	switch {
+	case i.AcceptedAt != nil:
+		return InvitationAccepted
+	case i.RevokedAt != nil:
+		return InvitationRevoked
+	case !now.Before(i.ExpiresAt):
+		return InvitationExpired
+	}
+	return InvitationPending
+}
+
+// NormalizeInvitationRole trims role and checks its length. Whether the RBAC
+// service knows the role is only checked when it is assigned.
+func NormalizeInvitationRole(role string) (string, error) {
+	role = strings.TrimSpace(role)
+	if role == "" || len(role) > 64 {
+		return "", ErrInvitationRoleInvalid
+	}
+	return role, nil
+}
@@ -4,20 +4,8 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"fmt"
-	"time"
 )
 
-// UserProvider stores external identity provider linkage for a user.
-type UserProvider struct {
-	ID             string    `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
-	ProviderType   string    `gorm:"type:text;not null" json:"provider_type"`
-	ProviderUserID string    `gorm:"type:text;not null" json:"provider_user_id"`
-	UserID         string    `gorm:"type:uuid;not null;index" json:"user_id"`
-	Metadata       JSONMap   `gorm:"type:jsonb" json:"metadata"`
-	CreatedAt      time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
-	UpdatedAt      time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
-}
-
 // JSONMap provides database marshaling helpers for JSONB columns.
 type JSONMap map[string]interface{}
 
@@ -55,7 +43,3 @@ func (m *JSONMap) Scan(value interface{}) error {
 	*m = data
 	return nil
 }
-
-func (UserProvider) TableName() string {
-	return "user_provider"
-}
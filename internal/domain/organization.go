@@ -0,0 +1,74 @@
+package domain
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	ErrOrgSlugInvalid = errors.New("slug must be 3-40 lowercase letters, digits or hyphens")
+	ErrOrgNameInvalid = errors.New("name must be 1-200 characters")
+	ErrOrgRoleInvalid = errors.New("role must be owner, admin or member")
+)
+
+// Organization is a tenant. Its users, their emails and usernames are
+// isolated from other tenants.
+type Organization struct {
+	ID        string    `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	Slug      string    `gorm:"column:slug;uniqueIndex;not null" json:"slug"`
+	Name      string    `gorm:"column:name;not null" json:"name"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+func (Organization) TableName() string {
+	return "organization"
+}
+
+// OrgRole is a user's role within an organization.
+type OrgRole string
+
+const (
+	OrgRoleOwner  OrgRole = "owner"
+	OrgRoleAdmin  OrgRole = "admin"
+	OrgRoleMember OrgRole = "member"
+)
+
+var orgRoleRank = map[OrgRole]int{OrgRoleMember: 1, OrgRoleAdmin: 2, OrgRoleOwner: 3}
+
+func (r OrgRole) IsValid() bool {
+	return orgRoleRank[r] > 0
+}
+
+// AtLeast reports whether r grants everything min grants.
+func (r OrgRole) AtLeast(min OrgRole) bool {
+	return orgRoleRank[r] >= orgRoleRank[min]
+}
+
+// OrgMembership records the role of a user in the organization it belongs to.
+// Users of an organization without a membership row are members.
+type OrgMembership struct {
+	OrgID     string    `gorm:"type:uuid;primaryKey" json:"org_id"`
+	UserID    string    `gorm:"type:uuid;primaryKey" json:"user_id"`
+	Role      OrgRole   `gorm:"column:role;not null" json:"role"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+func (OrgMembership) TableName() string {
+	return "org_membership"
+}
+
+var orgSlugPattern = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9-]{1,38})[a-z0-9]$`)
+
+// NormalizeOrgSlug lowercases slug and checks that it can be used as a host
+// label.
+func NormalizeOrgSlug(slug string) (string, error) {
+	slug = strings.ToLower(strings.TrimSpace(slug))
+	if !orgSlugPattern.MatchString(slug) || strings.Contains(slug, "--") {
+		return "", ErrOrgSlugInvalid
+	}
+	return slug, nil
+}
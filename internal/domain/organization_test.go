@@ -0,0 +1,26 @@
+package domain
+
+import "testing"
+
+func TestNormalizeOrgSlug(t *testing.T) {
+	if got, err := NormalizeOrgSlug(" Acme-Corp "); err != nil || got != "acme-corp" {
+		t.Errorf("NormalizeOrgSlug = %q, %v; want acme-corp", got, err)
+	}
+	for _, input := range []string{"ab", "-acme", "acme-", "ac--me", "acme.corp", "acme_corp", "a2345678901234567890123456789012345678901"} {
+		if _, err := NormalizeOrgSlug(input); err != ErrOrgSlugInvalid {
+			t.Errorf("NormalizeOrgSlug(%q) error = %v; want ErrOrgSlugInvalid", input, err)
+		}
+	}
+}
+
+func TestOrgRoleAtLeast(t *testing.T) {
+	if !OrgRoleOwner.AtLeast(OrgRoleAdmin) || !OrgRoleAdmin.AtLeast(OrgRoleAdmin) {
+		t.Error("owner and admin must satisfy admin")
+	}
+	if OrgRoleMember.AtLeast(OrgRoleAdmin) || OrgRole("guest").AtLeast(OrgRoleMember) {
+		t.Error("member and unknown roles must not satisfy higher roles")
+	}
+	if OrgRole("guest").IsValid() {
+		t.Error("unknown role reported valid")
+	}
+}
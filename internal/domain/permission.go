@@ -0,0 +1,18 @@
+package domain
+
+// Permissions checked against the RBAC service for administrative endpoints.
+const (
+	PermUsersRead    = "users:read"
+	PermUsersSuspend = "users:suspend"
+	PermUsersExport  = "users:export"
+	PermUsersWrite   = "users:write"
+
+	PermProfileSchemaWrite = "profile_schema:write"
+	// PermOrgsWrite administers every organization regardless of membership.
+	PermOrgsWrite = "orgs:write"
+	// PermGroupsWrite manages every group of the tenant as if an owner.
+	PermGroupsWrite = "groups:write"
+	// PermRolesAssign lets invitations carry roles outside
+	// config.Config.InvitationRoles.
+	PermRolesAssign = "roles:assign"
+)
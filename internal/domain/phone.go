@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrPhoneInvalid = errors.New("phone must be an international number such as +4915112345678")
+
+// NormalizePhone returns raw in E.164 form. The number must carry its country
+// code, either as "+" or as the international prefix "00"; spaces, dashes,
+// dots and parentheses are ignored.
+func NormalizePhone(raw string) (string, error) {
+	var digits strings.Builder
+	raw = strings.TrimSpace(raw)
+	switch {
+	case strings.HasPrefix(raw, "+"):
+		raw = raw[1:]
+	case strings.HasPrefix(raw, "00"):
+		raw = raw[2:]
+	default:
+		return "", ErrPhoneInvalid
+	}
+	for _, r := range raw {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return "", ErrPhoneInvalid
+		}
+	}
+	number := digits.String()
+	if len(number) < 8 || len(number) > 15 || number[0] == '0' {
+		return "", ErrPhoneInvalid
+	}
+	return "+" + number, nil
+}
+
+// PhoneCodePurpose is what a texted code unlocks.
+type PhoneCodePurpose string
+
+const (
+	PhoneCodeVerify PhoneCodePurpose = "verify"
+	PhoneCodeSignIn PhoneCodePurpose = "signin"
+	PhoneCodeMFA    PhoneCodePurpose = "mfa"
+)
+
+// PhoneCode is a one-time code texted to a phone. Like the Tarantool
+// verifications it is addressed by its ID, but it is checked against a
+// 6-digit code; only a hash of the code is stored.
+type PhoneCode struct {
+	ID         string           `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	Purpose    PhoneCodePurpose `gorm:"column:purpose;not null" json:"purpose"`
+	UserID     string           `gorm:"type:uuid;column:user_id;not null" json:"user_id"`
+	Phone      string           `gorm:"column:phone;not null" json:"phone"`
+	CodeHash   string           `gorm:"column:code_hash;not null" json:"-"`
+	Attempts   int              `gorm:"column:attempts;not null;default:0" json:"-"`
+	ExpiresAt  time.Time        `gorm:"column:expires_at;not null" json:"expires_at"`
+	ConsumedAt *time.Time       `gorm:"column:consumed_at" json:"consumed_at,omitempty"`
+	CreatedAt  time.Time        `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (PhoneCode) TableName() string {
+	return "phone_code"
+}
+
+func (c *PhoneCode) IsPending(now time.Time) bool {
+	return c.ConsumedAt == nil && now.Before(c.ExpiresAt)
+}
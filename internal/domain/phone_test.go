@@ -0,0 +1,24 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizePhone(t *testing.T) {
+	valid := map[string]string{
+		"+49 151 1234 5678":   "+4915112345678",
+		"0049-151-12345678":   "+4915112345678",
+		" +1 (415) 555.2671 ": "+14155552671",
+	}
+	for input, want := range valid {
+		if got, err := NormalizePhone(input); err != nil || got != want {
+			t.Errorf("NormalizePhone(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"", "0151 12345678", "+0 151 1234567", "+49 151 abc", "+1234567", "+1234567890123456"} {
+		if _, err := NormalizePhone(input); !errors.Is(err, ErrPhoneInvalid) {
+			t.Errorf("NormalizePhone(%q) error = %v; want ErrPhoneInvalid", input, err)
+		}
+	}
+}
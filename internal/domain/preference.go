@@ -0,0 +1,110 @@
+package domain
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"time"
+)
+
+var (
+	ErrPreferenceUnknown = errors.New("is not a registered preference")
+	ErrPreferenceInvalid = errors.New("has an invalid value")
+)
+
+type PreferenceType string
+
+const (
+	PreferenceBool   PreferenceType = "bool"
+	PreferenceEnum   PreferenceType = "enum"
+	PreferenceString PreferenceType = "string"
+)
+
+// PreferenceDefinition registers a preference key. String preferences must
+// match Pattern; enum preferences must be one of Values.
+type PreferenceDefinition struct {
+	Key         string         `json:"key"`
+	Type        PreferenceType `json:"type"`
+	Default     interface{}    `json:"default"`
+	Values      []string       `json:"values,omitempty"`
+	Pattern     *regexp.Regexp `json:"-"`
+	Description string         `json:"description"`
+}
+
+// preferenceRegistry lists every preference users can set. Add keys here;
+// stored values of removed keys are ignored.
+var preferenceRegistry = []PreferenceDefinition{
+	{Key: "language", Type: PreferenceString, Default: "en", Pattern: regexp.MustCompile(`^[a-z]{2,3}(-[A-Z]{2})?$`), Description: "Language of emails and notifications, as a BCP 47 tag such as en or pt-BR"},
+	{Key: "marketing_opt_in", Type: PreferenceBool, Default: false, Description: "Consent to marketing messages"},
+	{Key: "notifications.email", Type: PreferenceBool, Default: true, Description: "Send notifications by email"},
+	{Key: "notifications.push", Type: PreferenceBool, Default: true, Description: "Send push notifications"},
+	{Key: "notifications.sms", Type: PreferenceBool, Default: false, Description: "Send notifications by SMS"},
+	{Key: "notifications.digest", Type: PreferenceEnum, Default: "weekly", Values: []string{"off", "daily", "weekly"}, Description: "How often to send the activity digest"},
+}
+
+// PreferenceDefinitions returns the registered preferences ordered by key.
+func PreferenceDefinitions() []PreferenceDefinition {
+	definitions := append([]PreferenceDefinition(nil), preferenceRegistry...)
+	sort.Slice(definitions, func(i, j int) bool { return definitions[i].Key < definitions[j].Key })
+	return definitions
+}
+
+func LookupPreference(key string) (PreferenceDefinition, bool) {
+	for _, definition := range preferenceRegistry {
+		if definition.Key == key {
+			return definition, true
+		}
+	}
+	return PreferenceDefinition{}, false
+}
+
+// Validate returns ErrPreferenceInvalid unless value, as decoded from JSON,
+// fits the definition.
+func (d PreferenceDefinition) Validate(value interface{}) error {
+	switch d.Type {
+	case PreferenceBool:
+		if _, ok := value.(bool); ok {
+			return nil
+		}
+	case PreferenceEnum:
+		if s, ok := value.(string); ok {
+			for _, allowed := range d.Values {
+				if s == allowed {
+					return nil
+				}
+			}
+		}
+	case PreferenceString:
+		if s, ok := value.(string); ok && (d.Pattern == nil || d.Pattern.MatchString(s)) {
+			return nil
+		}
+	}
+	return ErrPreferenceInvalid
+}
+
+// UserPreferences holds the preferences a user changed from their defaults.
+type UserPreferences struct {
+	UserID    string    `gorm:"type:uuid;primaryKey" json:"user_id"`
+	Values    JSONMap   `gorm:"type:jsonb;column:preferences;not null" json:"preferences"`
+	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+func (UserPreferences) TableName() string {
+	return "user_preference"
+}
+
+// Resolve returns every registered preference, taking stored values that are
+// still valid and defaults for the rest.
+func (p *UserPreferences) Resolve() map[string]interface{} {
+	resolved := make(map[string]interface{}, len(preferenceRegistry))
+	for _, definition := range preferenceRegistry {
+		resolved[definition.Key] = definition.Default
+		if p == nil {
+			continue
+		}
+		if value, ok := p.Values[definition.Key]; ok && definition.Validate(value) == nil {
+			resolved[definition.Key] = value
+		}
+	}
+	return resolved
+}
@@ -0,0 +1,41 @@
+package domain
+
+import "testing"
+
+func TestPreferenceValidate(t *testing.T) {
+	cases := []struct {
+		key   string
+		value interface{}
+		valid bool
+	}{
+		{"language", "pt-BR", true},
+		{"language", "Portuguese", false},
+		{"marketing_opt_in", true, true},
+		{"marketing_opt_in", "yes", false},
+		{"notifications.digest", "daily", true},
+		{"notifications.digest", "hourly", false},
+	}
+	for _, tc := range cases {
+		definition, ok := LookupPreference(tc.key)
+		if !ok {
+			t.Fatalf("%s is not registered", tc.key)
+		}
+		if err := definition.Validate(tc.value); (err == nil) != tc.valid {
+			t.Errorf("Validate(%s=%v) = %v; want valid %v", tc.key, tc.value, err, tc.valid)
+		}
+	}
+}
+
+func TestUserPreferencesResolve(t *testing.T) {
+	prefs := &UserPreferences{Values: JSONMap{"language": "de", "notifications.digest": "hourly", "removed": 1}}
+	resolved := prefs.Resolve()
+	if resolved["language"] != "de" || resolved["notifications.digest"] != "weekly" || resolved["marketing_opt_in"] != false {
+		t.Errorf("Resolve = %v", resolved)
+	}
+	if _, ok := resolved["removed"]; ok {
+		t.Error("unregistered key resolved")
+	}
+	if len((*UserPreferences)(nil).Resolve()) != len(PreferenceDefinitions()) {
+		t.Error("nil preferences must resolve to every default")
+	}
+}
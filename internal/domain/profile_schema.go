@@ -0,0 +1,17 @@
+package domain
+
+import "time"
+
+// ProfileSchema is an admin-published JSON Schema for UserProfile.Attributes.
+// Every publication gets the next version; the highest version is in force.
+type ProfileSchema struct {
+	ID        string    `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	Version   int       `gorm:"not null;uniqueIndex" json:"version"`
+	Schema    JSONMap   `gorm:"type:jsonb;not null" json:"schema"`
+	CreatedBy string    `gorm:"column:created_by" json:"created_by,omitempty"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (ProfileSchema) TableName() string {
+	return "profile_schema"
+}
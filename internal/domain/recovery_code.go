@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+// RecoveryCodeCount is the number of codes in a generated set.
+const RecoveryCodeCount = 10
+
+// RecoveryCode is a single-use code that stands in for the second factor
+// when the user has lost their device. Only its hash is stored.
+type RecoveryCode struct {
+	ID        string     `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	UserID    string     `gorm:"type:uuid;not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"column:code_hash;not null" json:"-"`
+	UsedAt    *time.Time `gorm:"column:used_at" json:"used_at,omitempty"`
+	CreatedAt time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (RecoveryCode) TableName() string {
+	return "recovery_code"
+}
+
+// NormalizeRecoveryCode ignores case, whitespace and the dash codes are
+// displayed with, so a code is accepted however it was typed back.
+func NormalizeRecoveryCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || unicode.IsSpace(r) {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, code)
+}
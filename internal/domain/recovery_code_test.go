@@ -0,0 +1,17 @@
+package domain
+
+import "testing"
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	cases := map[string]string{
+		"abcde-23456":   "abcde23456",
+		" ABCDE 23456 ": "abcde23456",
+		"abcde23456":    "abcde23456",
+		"":              "",
+	}
+	for in, want := range cases {
+		if got := NormalizeRecoveryCode(in); got != want {
+			t.Errorf("NormalizeRecoveryCode(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -1,23 +1,64 @@
 package domain
 
 import (
+	"fmt"
 	"time"
 )
 
 type User struct {
-	ID           string    `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
-	Email        string    `gorm:"uniqueIndex;not null" json:"email"`
+	ID string `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	// OrgID is the tenant of the user; nil is the default tenant. Email is
+	// unique per tenant.
+	OrgID        *string   `gorm:"type:uuid;column:org_id" json:"org_id,omitempty"`
+	Email        string    `gorm:"not null" json:"email"`
+	Username     *string   `gorm:"column:username" json:"username"`
 	PasswordHash *string   `gorm:"column:password_hash" json:"-"`
 	IsActive     bool      `gorm:"column:is_active;default:true" json:"is_active"`
 	CreatedAt    time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
 	UpdatedAt    time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
-	Profile      *UserProfile
+
+	SuspendedAt      *time.Time `gorm:"column:suspended_at" json:"suspended_at,omitempty"`
+	SuspendedUntil   *time.Time `gorm:"column:suspended_until" json:"suspended_until,omitempty"`
+	SuspensionReason *string    `gorm:"column:suspension_reason" json:"suspension_reason,omitempty"`
+	// TokensRevokedAt invalidates every token issued at or before it.
+	TokensRevokedAt *time.Time `gorm:"column:tokens_revoked_at" json:"-"`
+	// PasswordResetRequired blocks password sign-in until a new password is
+	// set.
+	PasswordResetRequired bool `gorm:"column:password_reset_required;default:false" json:"password_reset_required,omitempty"`
+
+	// UsernameCanonical is the folded form usernames are unique by.
+	UsernameCanonical *string    `gorm:"column:username_canonical" json:"-"`
+	UsernameChangedAt *time.Time `gorm:"column:username_changed_at" json:"-"`
+
+	// Phone is set in E.164 form once verified. PhoneMFA requires a texted
+	// code after password sign-in.
+	Phone           *string    `gorm:"column:phone" json:"phone,omitempty"`
+	PhoneVerifiedAt *time.Time `gorm:"column:phone_verified_at" json:"phone_verified_at,omitempty"`
+	PhoneMFA        bool       `gorm:"column:phone_mfa;default:false" json:"phone_mfa"`
+
+	DeletedAt *time.Time `gorm:"column:deleted_at" json:"deleted_at,omitempty"`
+	PurgeAt   *time.Time `gorm:"column:purge_at" json:"purge_at,omitempty"`
+
+	// Version is incremented on every write; see Revision.
+	Version int64 `gorm:"column:version;not null;default:1" json:"-"`
+
+	Profile *UserProfile
 }
 
 func (User) TableName() string {
 	return "user"
 }
 
+// Revision identifies the stored state of the user together with their
+// profile. It is served as the ETag of user representations.
+func (u *User) Revision() string {
+	var profileVersion int64
+	if u.Profile != nil {
+		profileVersion = u.Profile.Version
+	}
+	return fmt.Sprintf("%d.%d", u.Version, profileVersion)
+}
+
 func (u *User) HasPassword() bool {
 	return u.PasswordHash != nil && *u.PasswordHash != ""
 }
@@ -33,3 +74,87 @@ func (u *User) Activate() {
 func (u *User) Deactivate() {
 	u.IsActive = false
 }
+
+func (u *User) IsSuspended() bool {
+	return u.SuspendedAt != nil
+}
+
+// Suspend deactivates the account and revokes every token issued so far. A
+// nil until keeps the suspension in place until it is lifted explicitly.
+func (u *User) Suspend(reason string, until *time.Time, now time.Time) {
+	u.IsActive = false
+	u.SuspendedAt = &now
+	u.SuspendedUntil = until
+	u.SuspensionReason = &reason
+	u.TokensRevokedAt = &now
+}
+
+func (u *User) Reactivate() {
+	u.IsActive = !u.IsPendingDeletion()
+	u.SuspendedAt = nil
+	u.SuspendedUntil = nil
+	u.SuspensionReason = nil
+}
+
+// RevokeTokens invalidates every token issued so far.
+func (u *User) RevokeTokens(now time.Time) {
+	u.TokensRevokedAt = &now
+}
+
+// RequirePasswordReset blocks password sign-in and revokes every token, so
+// that existing sessions cannot outlive a suspected password compromise.
+func (u *User) RequirePasswordReset(now time.Time) {
+	u.PasswordResetRequired = true
+	u.TokensRevokedAt = &now
+}
+
+// TokenRevoked reports whether a token issued at issuedAt predates the last
+// revocation. JWT timestamps have second precision, so a token issued in the
+// same second as the revocation is treated as revoked.
+func (u *User) TokenRevoked(issuedAt time.Time) bool {
+	return u.TokensRevokedAt != nil && !issuedAt.After(u.TokensRevokedAt.Truncate(time.Second))
+}
+
+// SetUsername stores a normalized username and its canonical form.
+func (u *User) SetUsername(name string, now time.Time) {
+	canonical := CanonicalUsername(name)
+	u.Username = &name
+	u.UsernameCanonical = &canonical
+	u.UsernameChangedAt = &now
+}
+
+// ReleaseUsername returns the hold that keeps the current username for u
+// until cooldown has passed, or nil when u has no username.
+func (u *User) ReleaseUsername(now time.Time, cooldown time.Duration) *UsernameHold {
+	if u.Username == nil || u.UsernameCanonical == nil {
+		return nil
+	}
+	return &UsernameHold{
+		UserID:            u.ID,
+		Username:          *u.Username,
+		UsernameCanonical: *u.UsernameCanonical,
+		ReleasedAt:        now,
+		AvailableAt:       now.Add(cooldown),
+	}
+}
+
+func (u *User) IsPendingDeletion() bool {
+	return u.DeletedAt != nil
+}
+
+// ScheduleDeletion deactivates the account, revokes its tokens and marks it
+// for a hard purge once grace has elapsed.
+func (u *User) ScheduleDeletion(now time.Time, grace time.Duration) {
+	purgeAt := now.Add(grace)
+	u.IsActive = false
+	u.DeletedAt = &now
+	u.PurgeAt = &purgeAt
+	u.TokensRevokedAt = &now
+}
+
+// Restore cancels a pending deletion. A suspended account stays inactive.
+func (u *User) Restore() {
+	u.DeletedAt = nil
+	u.PurgeAt = nil
+	u.IsActive = !u.IsSuspended()
+}
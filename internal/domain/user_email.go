@@ -0,0 +1,30 @@
+package domain
+
+import "time"
+
+// UserEmail is one of a user's addresses. The primary address mirrors
+// User.Email; the others become usable for sign-in once verified. Verified
+// addresses are unique per tenant across all users.
+type UserEmail struct {
+	ID         string     `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	UserID     string     `gorm:"type:uuid;column:user_id;not null" json:"-"`
+	OrgID      *string    `gorm:"type:uuid;column:org_id" json:"-"`
+	Email      string     `gorm:"column:email;not null" json:"email"`
+	IsPrimary  bool       `gorm:"column:is_primary;not null" json:"is_primary"`
+	VerifiedAt *time.Time `gorm:"column:verified_at" json:"verified_at"`
+	CreatedAt  time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (UserEmail) TableName() string {
+	return "user_email"
+}
+
+func (e *UserEmail) IsVerified() bool {
+	return e.VerifiedAt != nil
+}
+
+// PrimaryEmail is the row for the address stored on user. An address that an
+// account was created or changed with counts as verified.
+func PrimaryEmail(user *User, now time.Time) *UserEmail {
+	return &UserEmail{UserID: user.ID, OrgID: user.OrgID, Email: user.Email, IsPrimary: true, VerifiedAt: &now}
+}
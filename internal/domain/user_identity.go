@@ -9,6 +9,8 @@ const (
 	ProviderGitHub IdentityProvider = "github"
 )
 
+// UserIdentity links an account to an external identity provider. It is the
+// single source of truth for both OAuth sign-in and identity management.
 type UserIdentity struct {
 	ID             string           `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
 	UserID         string           `gorm:"type:uuid;not null;index" json:"user_id"`
@@ -17,6 +19,8 @@ type UserIdentity struct {
 	Email          string           `gorm:"column:email;not null" json:"email"`
 	DisplayName    *string          `gorm:"column:display_name" json:"display_name"`
 	AvatarURL      *string          `gorm:"column:avatar_url" json:"avatar_url"`
+	Metadata       JSONMap          `gorm:"type:jsonb" json:"metadata"`
+	LastUsedAt     *time.Time       `gorm:"column:last_used_at" json:"last_used_at"`
 	CreatedAt      time.Time        `gorm:"column:created_at;autoCreateTime" json:"created_at"`
 	UpdatedAt      time.Time        `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
 }
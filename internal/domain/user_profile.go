@@ -3,12 +3,23 @@ package domain
 import "time"
 
 type UserProfile struct {
-	ID          string    `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
-	UserID      string    `gorm:"type:uuid;not null;uniqueIndex" json:"user_id"`
-	DisplayName *string   `gorm:"column:display_name" json:"display_name"`
-	AvatarURL   *string   `gorm:"column:avatar_url" json:"avatar_url"`
-	CreatedAt   time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
-	UpdatedAt   time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	ID               string    `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	UserID           string    `gorm:"type:uuid;not null;uniqueIndex" json:"user_id"`
+	DisplayName      *string   `gorm:"column:display_name" json:"display_name"`
+	AvatarURL        *string   `gorm:"column:avatar_url" json:"avatar_url"`
+	AvatarThumbnails JSONMap   `gorm:"column:avatar_thumbnails;type:jsonb" json:"avatar_thumbnails,omitempty"`
+	FirstName        *string   `gorm:"column:first_name" json:"first_name"`
+	LastName         *string   `gorm:"column:last_name" json:"last_name"`
+	Locale           *string   `gorm:"column:locale" json:"locale"`
+	Timezone         *string   `gorm:"column:timezone" json:"timezone"`
+	Bio              *string   `gorm:"column:bio" json:"bio"`
+	Company          *string   `gorm:"column:company" json:"company"`
+	Attributes       JSONMap   `gorm:"column:attributes;type:jsonb;default:'{}'" json:"attributes"`
+	Visibility       JSONMap   `gorm:"column:visibility;type:jsonb" json:"visibility,omitempty"`
+	CreatedAt        time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	// Version is incremented on every write.
+	Version int64 `gorm:"column:version;not null;default:1" json:"-"`
 }
 
 func (UserProfile) TableName() string {
@@ -21,5 +32,54 @@ func (p *UserProfile) Update(displayName, avatarURL *string) {
 	}
 	if avatarURL != nil {
 		p.AvatarURL = avatarURL
+		p.AvatarThumbnails = nil
 	}
 }
+
+// SetAvatar stores an uploaded avatar together with its thumbnails.
+func (p *UserProfile) SetAvatar(url string, thumbnails map[string]string) {
+	p.AvatarURL = &url
+	p.AvatarThumbnails = make(JSONMap, len(thumbnails))
+	for size, thumbURL := range thumbnails {
+		p.AvatarThumbnails[size] = thumbURL
+	}
+}
+
+// MergedAttributes returns a copy of the custom attributes with patch applied
+// as a JSON merge patch (RFC 7396): a nil value removes the attribute, an
+// object is merged into the object it replaces, and any other value replaces
+// it.
+func (p *UserProfile) MergedAttributes(patch map[string]interface{}) JSONMap {
+	return JSONMap(mergePatch(p.Attributes, patch))
+}
+
+// mergePatch applies patch to target without modifying either.
+func mergePatch(target, patch map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(target)+len(patch))
+	for key, value := range target {
+		merged[key] = value
+	}
+	for key, value := range patch {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+		if object, ok := asObject(value); ok {
+			existing, _ := asObject(merged[key])
+			merged[key] = mergePatch(existing, object)
+			continue
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+func asObject(value interface{}) (map[string]interface{}, bool) {
+	switch object := value.(type) {
+	case map[string]interface{}:
+		return object, true
+	case JSONMap:
+		return object, true
+	}
+	return nil, false
+}
@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergedAttributesFollowsJSONMergePatch(t *testing.T) {
+	profile := &UserProfile{Attributes: JSONMap{
+		"department": "sales",
+		"address":    map[string]interface{}{"city": "Berlin", "zip": "10115"},
+		"tags":       []interface{}{"a", "b"},
+	}}
+
+	merged := profile.MergedAttributes(map[string]interface{}{
+		"department": nil,
+		"address":    map[string]interface{}{"zip": nil, "street": "Main St"},
+		"tags":       []interface{}{"c"},
+		"manager":    map[string]interface{}{"name": "Ada", "phone": nil},
+	})
+
+	want := JSONMap{
+		"address": map[string]interface{}{"city": "Berlin", "street": "Main St"},
+		"tags":    []interface{}{"c"},
+		"manager": map[string]interface{}{"name": "Ada"},
+	}
+	if !reflect.DeepEqual(merged, want) {
+		t.Errorf("MergedAttributes = %v, want %v", merged, want)
+	}
+	if _, ok := profile.Attributes["department"]; !ok {
+		t.Errorf("stored attributes were modified")
+	}
+	if address := profile.Attributes["address"].(map[string]interface{}); address["zip"] != "10115" {
+		t.Errorf("nested stored attributes were modified: %v", address)
+	}
+}
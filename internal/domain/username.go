@@ -0,0 +1,99 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	UsernameMinLength = 3
+	UsernameMaxLength = 30
+)
+
+var (
+	ErrUsernameInvalid  = errors.New("username must be 3-30 letters, digits, dots or underscores and start with a letter")
+	ErrUsernameReserved = errors.New("username is reserved")
+)
+
+// reservedUsernames cannot be claimed because they could pass for the
+// service itself or collide with routes. They are compared in canonical form.
+var reservedUsernames = []string{
+	"admin", "administrator", "root", "system", "support", "help", "security",
+	"abuse", "postmaster", "webmaster", "noreply", "staff", "moderator",
+	"official", "api", "auth", "oauth", "users", "user", "settings",
+	"account", "billing", "null", "undefined", "anonymous", "everyone",
+}
+
+// usernameConfusables folds characters that read alike in most fonts so
+// that "j0hn", "john" and "j.o.h.n" compete for the same handle.
+var usernameConfusables = strings.NewReplacer(
+	"0", "o",
+	"1", "l",
+	"i", "l",
+	"rn", "m",
+	"vv", "w",
+	".", "",
+	"_", "",
+)
+
+// NormalizeUsername returns the display form of a requested username: NFKC
+// normalized, so full-width and other compatibility forms collapse to ASCII,
+// and checked against the allowed alphabet. Case is preserved.
+func NormalizeUsername(raw string) (string, error) {
+	name := norm.NFKC.String(strings.TrimSpace(raw))
+	if len(name) < UsernameMinLength || len(name) > UsernameMaxLength {
+		return "", ErrUsernameInvalid
+	}
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case i == 0:
+			return "", ErrUsernameInvalid
+		case r >= '0' && r <= '9':
+		case r == '.' || r == '_':
+			if prev := name[i-1]; prev == '.' || prev == '_' {
+				return "", ErrUsernameInvalid
+			}
+		default:
+			return "", ErrUsernameInvalid
+		}
+	}
+	if last := name[len(name)-1]; last == '.' || last == '_' {
+		return "", ErrUsernameInvalid
+	}
+	return name, nil
+}
+
+// CanonicalUsername is the key usernames are unique and looked up by. It is
+// the lowercased normalized name with confusable characters folded.
+func CanonicalUsername(name string) string {
+	return usernameConfusables.Replace(strings.ToLower(norm.NFKC.String(strings.TrimSpace(name))))
+}
+
+// IsReservedUsername reports whether canonical matches a reserved name.
+func IsReservedUsername(canonical string) bool {
+	for _, reserved := range reservedUsernames {
+		if canonical == CanonicalUsername(reserved) {
+			return true
+		}
+	}
+	return false
+}
+
+// UsernameHold keeps a released username from being claimed by anyone but
+// its previous owner until AvailableAt.
+type UsernameHold struct {
+	ID                string    `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	UserID            string    `gorm:"type:uuid;not null" json:"user_id"`
+	Username          string    `gorm:"column:username;not null" json:"username"`
+	UsernameCanonical string    `gorm:"column:username_canonical;not null;index" json:"-"`
+	ReleasedAt        time.Time `gorm:"column:released_at;not null" json:"released_at"`
+	AvailableAt       time.Time `gorm:"column:available_at;not null" json:"available_at"`
+}
+
+func (UsernameHold) TableName() string {
+	return "username_history"
+}
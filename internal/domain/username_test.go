@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeUsername(t *testing.T) {
+	valid := map[string]string{
+		"Ada_Lovelace": "Ada_Lovelace",
+		" ada.l ":      "ada.l",
+		"Ａｄａ99":        "Ada99",
+	}
+	for input, want := range valid {
+		got, err := NormalizeUsername(input)
+		if err != nil || got != want {
+			t.Errorf("NormalizeUsername(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"ab", "9lives", "_ada", "ada_", "ada..l", "ada-l", "аdа", "averyveryverylongusernamethatgoeson"} {
+		if _, err := NormalizeUsername(input); !errors.Is(err, ErrUsernameInvalid) {
+			t.Errorf("NormalizeUsername(%q) = %v; want ErrUsernameInvalid", input, err)
+		}
+	}
+}
+
+func TestCanonicalUsernameFoldsConfusables(t *testing.T) {
+	for _, name := range []string{"jOhn", "j0hn", "j.o.h.n", "JOHN"} {
+		if got := CanonicalUsername(name); got != "john" {
+			t.Errorf("CanonicalUsername(%q) = %q; want john", name, got)
+		}
+	}
+	if CanonicalUsername("bill") != CanonicalUsername("b1ii") {
+		t.Error("expected 1, i and l to fold together")
+	}
+	if CanonicalUsername("modern") != CanonicalUsername("modem") {
+		t.Error("expected rn to fold to m")
+	}
+	if !IsReservedUsername(CanonicalUsername("Adm1n")) || IsReservedUsername(CanonicalUsername("ada")) {
+		t.Error("unexpected reserved username result")
+	}
+}
@@ -0,0 +1,94 @@
+package domain
+
+import "time"
+
+// FieldVisibility controls whether other users can see a profile field.
+type FieldVisibility string
+
+const (
+	VisibilityPublic  FieldVisibility = "public"
+	VisibilityPrivate FieldVisibility = "private"
+)
+
+func (v FieldVisibility) IsValid() bool {
+	return v == VisibilityPublic || v == VisibilityPrivate
+}
+
+// DefaultFieldVisibility lists the fields a user can show or hide and their
+// visibility until the user changes it. Identifiers, display name and avatar
+// are always public; account state and timestamps are never shown to others.
+var DefaultFieldVisibility = map[string]FieldVisibility{
+	"email":      VisibilityPrivate,
+	"first_name": VisibilityPublic,
+	"last_name":  VisibilityPublic,
+	"bio":        VisibilityPublic,
+	"company":    VisibilityPublic,
+	"locale":     VisibilityPrivate,
+	"timezone":   VisibilityPrivate,
+	"attributes": VisibilityPrivate,
+}
+
+// PublicUser is what other users see of an account.
+type PublicUser struct {
+	ID               string    `json:"id"`
+	Username         *string   `json:"username,omitempty"`
+	Email            string    `json:"email,omitempty"`
+	DisplayName      *string   `json:"display_name,omitempty"`
+	AvatarURL        *string   `json:"avatar_url,omitempty"`
+	AvatarThumbnails JSONMap   `json:"avatar_thumbnails,omitempty"`
+	FirstName        *string   `json:"first_name,omitempty"`
+	LastName         *string   `json:"last_name,omitempty"`
+	Bio              *string   `json:"bio,omitempty"`
+	Company          *string   `json:"company,omitempty"`
+	Locale           *string   `json:"locale,omitempty"`
+	Timezone         *string   `json:"timezone,omitempty"`
+	Attributes       JSONMap   `json:"attributes,omitempty"`
+	MemberSince      time.Time `json:"member_since"`
+}
+
+// FieldVisible reports whether field is shown to other users. Entries in
+// Visibility override DefaultFieldVisibility.
+func (p *UserProfile) FieldVisible(field string) bool {
+	if p != nil {
+		if v, ok := p.Visibility[field].(string); ok {
+			return FieldVisibility(v) == VisibilityPublic
+		}
+	}
+	return DefaultFieldVisibility[field] == VisibilityPublic
+}
+
+// Public projects the user onto the fields other users may see.
+func (u *User) Public() *PublicUser {
+	p := u.Profile
+	view := &PublicUser{ID: u.ID, Username: u.Username, MemberSince: u.CreatedAt}
+	if p.FieldVisible("email") {
+		view.Email = u.Email
+	}
+	if p == nil {
+		return view
+	}
+	view.DisplayName = p.DisplayName
+	view.AvatarURL = p.AvatarURL
+	view.AvatarThumbnails = p.AvatarThumbnails
+	fields := []struct {
+		name string
+		src  *string
+		dst  **string
+	}{
+		{"first_name", p.FirstName, &view.FirstName},
+		{"last_name", p.LastName, &view.LastName},
+		{"bio", p.Bio, &view.Bio},
+		{"company", p.Company, &view.Company},
+		{"locale", p.Locale, &view.Locale},
+		{"timezone", p.Timezone, &view.Timezone},
+	}
+	for _, field := range fields {
+		if p.FieldVisible(field.name) {
+			*field.dst = field.src
+		}
+	}
+	if p.FieldVisible("attributes") {
+		view.Attributes = p.Attributes
+	}
+	return view
+}
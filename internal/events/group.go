@@ -0,0 +1,23 @@
+package events
+
+import "time"
+
+type GroupMemberEvent struct {
+	Event      string    `json:"event"`
+	GroupID    string    `json:"group_id"`
+	UserID     string    `json:"user_id"`
+	Role       string    `json:"role,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+	TraceID    string    `json:"trace_id"`
+}
+
+func NewGroupMemberEvent(event, groupID, userID, role, traceID string) GroupMemberEvent {
+	return GroupMemberEvent{
+		Event:      event,
+		GroupID:    groupID,
+		UserID:     userID,
+		Role:       role,
+		OccurredAt: time.Now().UTC(),
+		TraceID:    traceID,
+	}
+}
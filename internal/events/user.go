@@ -19,3 +19,99 @@ func NewUserEvent(event, userID, email, traceID string) UserEvent {
 		TraceID:    traceID,
 	}
 }
+
+type UserAvatarEvent struct {
+	UserEvent
+	AvatarURL string `json:"avatar_url"`
+}
+
+func NewUserAvatarEvent(event, userID, avatarURL, traceID string) UserAvatarEvent {
+	return UserAvatarEvent{
+		UserEvent: NewUserEvent(event, userID, "", traceID),
+		AvatarURL: avatarURL,
+	}
+}
+
+type UserSuspendedEvent struct {
+	UserEvent
+	Reason string     `json:"reason"`
+	Until  *time.Time `json:"until,omitempty"`
+}
+
+func NewUserSuspendedEvent(userID, reason string, until *time.Time, traceID string) UserSuspendedEvent {
+	return UserSuspendedEvent{
+		UserEvent: NewUserEvent("user.suspended", userID, "", traceID),
+		Reason:    reason,
+		Until:     until,
+	}
+}
+
+// InvitationEvent asks the mailer to send AcceptURL to Email.
+type InvitationEvent struct {
+	Event        string    `json:"event"`
+	InvitationID string    `json:"invitation_id"`
+	Email        string    `json:"email"`
+	OrgID        string    `json:"org_id,omitempty"`
+	Role         string    `json:"role"`
+	AcceptURL    string    `json:"accept_url"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	OccurredAt   time.Time `json:"occurred_at"`
+	TraceID      string    `json:"trace_id"`
+}
+
+func NewInvitationEvent(invitationID, email, orgID, role, acceptURL string, expiresAt time.Time, traceID string) InvitationEvent {
+	return InvitationEvent{
+		Event:        "user.invited",
+		InvitationID: invitationID,
+		Email:        email,
+		OrgID:        orgID,
+		Role:         role,
+		AcceptURL:    acceptURL,
+		ExpiresAt:    expiresAt,
+		OccurredAt:   time.Now().UTC(),
+		TraceID:      traceID,
+	}
+}
+
+// PasswordResetEvent asks the mailer to send ResetURL to Email.
+type PasswordResetEvent struct {
+	UserEvent
+	ResetURL  string    `json:"reset_url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func NewPasswordResetEvent(userID, email, resetURL string, expiresAt time.Time, traceID string) PasswordResetEvent {
+	return PasswordResetEvent{
+		UserEvent: NewUserEvent("user.password_reset_requested", userID, email, traceID),
+		ResetURL:  resetURL,
+		ExpiresAt: expiresAt,
+	}
+}
+
+// UserPreferencesEvent carries the new value of every preference that
+// changed, defaults included when a preference was reset.
+type UserPreferencesEvent struct {
+	UserEvent
+	Changes map[string]interface{} `json:"changes"`
+}
+
+func NewUserPreferencesEvent(userID string, changes map[string]interface{}, traceID string) UserPreferencesEvent {
+	return UserPreferencesEvent{
+		UserEvent: NewUserEvent("user.preferences_changed", userID, "", traceID),
+		Changes:   changes,
+	}
+}
+
+// RecoveryCodeUsedEvent asks the mailer to tell Email that a recovery code
+// was used to sign in, and how many are left.
+type RecoveryCodeUsedEvent struct {
+	UserEvent
+	Remaining int64 `json:"remaining"`
+}
+
+func NewRecoveryCodeUsedEvent(userID, email string, remaining int64, traceID string) RecoveryCodeUsedEvent {
+	return RecoveryCodeUsedEvent{
+		UserEvent: NewUserEvent("user.recovery_code_used", userID, email, traceID),
+		Remaining: remaining,
+	}
+}
@@ -0,0 +1,289 @@
+// Package importer bulk loads users exported from another system. Input is
+// read as a stream, written in batched transactions and every rejected row
+// is written to a JSONL report instead of stopping the run.
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/events"
+	"github.com/example/user-service/internal/ports/broker"
+	"github.com/example/user-service/internal/service"
+	pkglog "github.com/example/user-service/pkg/log"
+	"github.com/example/user-service/pkg/passwordhash"
+	"github.com/example/user-service/pkg/patch"
+)
+
+const defaultBatchSize = 500
+
+type Options struct {
+	Format    string
+	BatchSize int
+	// DryRun validates and inserts every batch, then rolls it back. No
+	// checkpoint is written and no events are published.
+	DryRun bool
+	// EmitEvents publishes user.created for every committed user.
+	EmitEvents bool
+	// Checkpoint is the path of the file recording the last committed row.
+	// A rerun with the same Source continues after that row. Empty disables
+	// checkpointing.
+	Checkpoint string
+	// Source names the input in the checkpoint so that a checkpoint is not
+	// applied to a different file.
+	Source  string
+	TraceID string
+}
+
+type Summary struct {
+	Read     int `json:"read"`
+	Skipped  int `json:"skipped"`
+	Imported int `json:"imported"`
+	Failed   int `json:"failed"`
+}
+
+// ReportEntry is one line of the error report.
+type ReportEntry struct {
+	Row   int    `json:"row"`
+	Email string `json:"email,omitempty"`
+	Error string `json:"error"`
+}
+
+type checkpoint struct {
+	Source string `json:"source"`
+	Row    int    `json:"row"`
+}
+
+type Importer struct {
+	logger    pkglog.Logger
+	store     Store
+	schemas   service.ProfileSchemaService
+	publisher broker.Publisher
+	report    *json.Encoder
+}
+
+// New returns an importer. schemas validates profile attributes and may be
+// nil, in which case records with attributes are rejected. publisher is only
+// used with Options.EmitEvents.
+func New(logger pkglog.Logger, store Store, schemas service.ProfileSchemaService, publisher broker.Publisher, report io.Writer) *Importer {
+	return &Importer{logger: logger, store: store, schemas: schemas, publisher: publisher, report: json.NewEncoder(report)}
+}
+
+func (im *Importer) Run(ctx context.Context, src io.Reader, opts Options) (*Summary, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	if opts.EmitEvents && !opts.DryRun && im.publisher == nil {
+		return nil, errors.New("events requested but no publisher configured")
+	}
+	reader, err := NewReader(opts.Format, src)
+	if err != nil {
+		return nil, err
+	}
+	resumeAfter, err := readCheckpoint(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &Summary{}
+	var batch []Row
+	for {
+		row, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return summary, err
+		}
+		summary.Read++
+		if row.Number <= resumeAfter {
+			summary.Skipped++
+			continue
+		}
+		batch = append(batch, row)
+		if len(batch) == opts.BatchSize {
+			if err := im.flush(ctx, batch, opts, summary); err != nil {
+				return summary, err
+			}
+			batch = batch[:0]
+		}
+	}
+	if len(batch) > 0 {
+		if err := im.flush(ctx, batch, opts, summary); err != nil {
+			return summary, err
+		}
+	}
+	return summary, nil
+}
+
+// flush writes one batch. Rows failing validation or insertion are reported
+// and skipped; an error from the transaction itself aborts the run, leaving
+// the checkpoint at the previous batch.
+func (im *Importer) flush(ctx context.Context, rows []Row, opts Options, summary *Summary) error {
+	tx, err := im.store.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	var created []*domain.User
+	var failures []ReportEntry
+	for i := range rows {
+		row := &rows[i]
+		err := row.Err
+		if err == nil {
+			err = im.validate(ctx, &row.Record)
+		}
+		if err == nil {
+			user, identities := newUser(&row.Record, now)
+			if err = tx.Insert(ctx, user, identities); err == nil {
+				created = append(created, user)
+				continue
+			}
+		}
+		failures = append(failures, ReportEntry{Row: row.Number, Email: row.Record.Email, Error: err.Error()})
+	}
+
+	if opts.DryRun {
+		if err := tx.Rollback(); err != nil {
+			return err
+		}
+	} else if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit rows %d-%d: %w", rows[0].Number, rows[len(rows)-1].Number, err)
+	}
+
+	for _, failure := range failures {
+		if err := im.report.Encode(failure); err != nil {
+			return fmt.Errorf("write report: %w", err)
+		}
+	}
+	summary.Imported += len(created)
+	summary.Failed += len(failures)
+	im.logger.Info().Str("trace_id", opts.TraceID).Int("last_row", rows[len(rows)-1].Number).Int("imported", len(created)).Int("failed", len(failures)).Msg("import batch done")
+	if opts.DryRun {
+		return nil
+	}
+	if err := writeCheckpoint(opts, rows[len(rows)-1].Number); err != nil {
+		return err
+	}
+	if opts.EmitEvents {
+		for _, user := range created {
+			_ = im.publisher.Publish(ctx, "user.created", events.NewUserEvent("user.created", user.ID, user.Email, opts.TraceID))
+		}
+	}
+	return nil
+}
+
+// validate applies the rules of the public API to a record and normalizes
+// it in place.
+func (im *Importer) validate(ctx context.Context, rec *Record) error {
+	rec.Email = strings.TrimSpace(rec.Email)
+	if !strings.Contains(rec.Email, "@") {
+		return errors.New("email is invalid")
+	}
+	if rec.PasswordHash != "" {
+		if err := passwordhash.Validate(rec.PasswordHash); err != nil {
+			return fmt.Errorf("password_hash: %w", err)
+		}
+	}
+	if rec.Username != "" {
+		name, err := domain.NormalizeUsername(rec.Username)
+		if err != nil {
+			return err
+		}
+		if domain.IsReservedUsername(domain.CanonicalUsername(name)) {
+			return domain.ErrUsernameReserved
+		}
+		rec.Username = name
+	}
+	for _, link := range rec.Identities {
+		if !domain.IdentityProvider(strings.ToLower(link.Provider)).IsValid() || link.ProviderUserID == "" {
+			return fmt.Errorf("identity %s:%s is invalid", link.Provider, link.ProviderUserID)
+		}
+	}
+	if rec.PasswordHash == "" && len(rec.Identities) == 0 {
+		return errors.New("user needs a password_hash or an identity to sign in")
+	}
+
+	profile := &rec.Profile
+	update := service.ProfileUpdate{
+		FirstName: patch.FromPointer(profile.FirstName),
+		LastName:  patch.FromPointer(profile.LastName),
+		Locale:    patch.FromPointer(profile.Locale),
+		Timezone:  patch.FromPointer(profile.Timezone),
+		Bio:       patch.FromPointer(profile.Bio),
+		Company:   patch.FromPointer(profile.Company),
+	}
+	fieldErrs := service.NormalizeProfileUpdate(&update)
+	if len(profile.Attributes) > 0 {
+		if im.schemas == nil {
+			return errors.New("attributes: no profile schema configured")
+		}
+		attrErrs, err := im.schemas.ValidateAttributes(ctx, profile.Attributes)
+		if err != nil {
+			return err
+		}
+		for _, fieldErr := range attrErrs {
+			fieldErr.Field = "attributes." + fieldErr.Field
+			fieldErrs = append(fieldErrs, fieldErr)
+		}
+	}
+	if len(fieldErrs) > 0 {
+		return &service.ValidationError{Fields: fieldErrs}
+	}
+	profile.FirstName, profile.LastName = nonEmpty(update.FirstName.Value), nonEmpty(update.LastName.Value)
+	profile.Locale, profile.Timezone = nonEmpty(update.Locale.Value), nonEmpty(update.Timezone.Value)
+	profile.Bio, profile.Company = nonEmpty(update.Bio.Value), nonEmpty(update.Company.Value)
+	return nil
+}
+
+func nonEmpty(value *string) *string {
+	if value == nil || *value == "" {
+		return nil
+	}
+	return value
+}
+
+func readCheckpoint(opts Options) (int, error) {
+	if opts.Checkpoint == "" || opts.DryRun {
+		return 0, nil
+	}
+	data, err := os.ReadFile(opts.Checkpoint)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return 0, fmt.Errorf("read checkpoint: %w", err)
+	}
+	if cp.Source != opts.Source {
+		return 0, fmt.Errorf("checkpoint %s belongs to %q, not %q", opts.Checkpoint, cp.Source, opts.Source)
+	}
+	return cp.Row, nil
+}
+
+// writeCheckpoint replaces the checkpoint atomically so that a crash never
+// leaves a truncated file behind.
+func writeCheckpoint(opts Options, row int) error {
+	if opts.Checkpoint == "" {
+		return nil
+	}
+	data, err := json.Marshal(checkpoint{Source: opts.Source, Row: row})
+	if err != nil {
+		return err
+	}
+	tmp := opts.Checkpoint + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, opts.Checkpoint)
+}
@@ -0,0 +1,218 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported input formats.
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// maxLineBytes bounds a single JSONL line.
+const maxLineBytes = 1 << 20
+
+// Record is one user to import.
+type Record struct {
+	Email string `json:"email"`
+	// Username is optional and follows the rules of PUT /users/me/username.
+	Username string `json:"username,omitempty"`
+	// PasswordHash is a bcrypt or argon2 PHC hash. Users without one can only
+	// sign in through a linked provider.
+	PasswordHash string           `json:"password_hash,omitempty"`
+	IsActive     *bool            `json:"is_active,omitempty"`
+	CreatedAt    *time.Time       `json:"created_at,omitempty"`
+	Profile      RecordProfile    `json:"profile"`
+	Identities   []RecordIdentity `json:"identities,omitempty"`
+}
+
+type RecordProfile struct {
+	DisplayName *string                `json:"display_name,omitempty"`
+	FirstName   *string                `json:"first_name,omitempty"`
+	LastName    *string                `json:"last_name,omitempty"`
+	Locale      *string                `json:"locale,omitempty"`
+	Timezone    *string                `json:"timezone,omitempty"`
+	Bio         *string                `json:"bio,omitempty"`
+	Company     *string                `json:"company,omitempty"`
+	Attributes  map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// RecordIdentity links the user to an OAuth provider account. Email defaults
+// to the user's email.
+type RecordIdentity struct {
+	Provider       string `json:"provider"`
+	ProviderUserID string `json:"provider_user_id"`
+	Email          string `json:"email,omitempty"`
+}
+
+// Row is a parsed input record. Number counts records from 1, excluding the
+// CSV header. Err is set when the record could not be parsed.
+type Row struct {
+	Number int
+	Record Record
+	Err    error
+}
+
+// Reader yields rows until io.EOF.
+type Reader interface {
+	Next() (Row, error)
+}
+
+// NewReader returns a reader for format.
+func NewReader(format string, src io.Reader) (Reader, error) {
+	switch format {
+	case FormatJSONL:
+		scanner := bufio.NewScanner(src)
+		scanner.Buffer(make([]byte, 64*1024), maxLineBytes)
+		return &jsonlReader{scanner: scanner}, nil
+	case FormatCSV:
+		return newCSVReader(src)
+	}
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+type jsonlReader struct {
+	scanner *bufio.Scanner
+	number  int
+}
+
+func (r *jsonlReader) Next() (Row, error) {
+	for r.scanner.Scan() {
+		line := strings.TrimSpace(r.scanner.Text())
+		if line == "" {
+			continue
+		}
+		r.number++
+		row := Row{Number: r.number}
+		dec := json.NewDecoder(strings.NewReader(line))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&row.Record); err != nil {
+			row.Err = fmt.Errorf("invalid json: %w", err)
+		}
+		return row, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return Row{}, err
+	}
+	return Row{}, io.EOF
+}
+
+// CSV columns. identities holds provider:provider_user_id pairs separated by
+// "|", for example "google:1234|github:987".
+var csvColumns = map[string]bool{
+	"email": true, "username": true, "password_hash": true, "is_active": true,
+	"created_at": true, "display_name": true, "first_name": true,
+	"last_name": true, "locale": true, "timezone": true, "bio": true,
+	"company": true, "identities": true,
+}
+
+type csvReader struct {
+	reader  *csv.Reader
+	columns []string
+	number  int
+}
+
+func newCSVReader(src io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(src)
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read csv header: %w", err)
+	}
+	columns := make([]string, len(header))
+	hasEmail := false
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !csvColumns[name] {
+			return nil, fmt.Errorf("unknown csv column %q", name)
+		}
+		hasEmail = hasEmail || name == "email"
+		columns[i] = name
+	}
+	if !hasEmail {
+		return nil, errors.New("csv header has no email column")
+	}
+	return &csvReader{reader: reader, columns: columns}, nil
+}
+
+func (r *csvReader) Next() (Row, error) {
+	fields, err := r.reader.Read()
+	if err == io.EOF {
+		return Row{}, io.EOF
+	}
+	r.number++
+	row := Row{Number: r.number}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		row.Err = err
+		return row, nil
+	}
+	if err != nil {
+		return Row{}, err
+	}
+	for i, value := range fields {
+		if err := r.set(&row.Record, r.columns[i], strings.TrimSpace(value)); err != nil {
+			row.Err = fmt.Errorf("%s: %w", r.columns[i], err)
+			break
+		}
+	}
+	return row, nil
+}
+
+func (r *csvReader) set(rec *Record, column, value string) error {
+	if value == "" {
+		return nil
+	}
+	switch column {
+	case "email":
+		rec.Email = value
+	case "username":
+		rec.Username = value
+	case "password_hash":
+		rec.PasswordHash = value
+	case "is_active":
+		active, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("must be true or false")
+		}
+		rec.IsActive = &active
+	case "created_at":
+		ts, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return errors.New("must be an RFC 3339 timestamp")
+		}
+		rec.CreatedAt = &ts
+	case "display_name":
+		rec.Profile.DisplayName = &value
+	case "first_name":
+		rec.Profile.FirstName = &value
+	case "last_name":
+		rec.Profile.LastName = &value
+	case "locale":
+		rec.Profile.Locale = &value
+	case "timezone":
+		rec.Profile.Timezone = &value
+	case "bio":
+		rec.Profile.Bio = &value
+	case "company":
+		rec.Profile.Company = &value
+	case "identities":
+		for _, pair := range strings.Split(value, "|") {
+			provider, providerUserID, ok := strings.Cut(strings.TrimSpace(pair), ":")
+			if !ok || providerUserID == "" {
+				return fmt.Errorf("%q is not provider:provider_user_id", pair)
+			}
+			rec.Identities = append(rec.Identities, RecordIdentity{Provider: provider, ProviderUserID: providerUserID})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/repo"
+)
+
+// ErrDuplicate is returned for a record whose email, username or provider
+// account already exists.
+var ErrDuplicate = errors.New("email, username or identity already exists")
+
+// Store opens the batches records are written in.
+type Store interface {
+	Begin(ctx context.Context) (Batch, error)
+}
+
+// Batch is a transaction. A failed Insert is rolled back on its own and leaves
+// the batch usable for the following records.
+type Batch interface {
+	Insert(ctx context.Context, user *domain.User, identities []domain.UserIdentity) error
+	Commit() error
+	Rollback() error
+}
+
+type gormStore struct {
+	db *gorm.DB
+}
+
+func NewGormStore(db *gorm.DB) Store {
+	return &gormStore{db: db}
+}
+
+func (s *gormStore) Begin(ctx context.Context) (Batch, error) {
+	tx := s.db.WithContext(ctx).Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	if err := repo.ApplyTenant(ctx, tx); err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	return &gormBatch{tx: tx}, nil
+}
+
+type gormBatch struct {
+	tx *gorm.DB
+}
+
+const rowSavePoint = "import_row"
+
+func (b *gormBatch) Insert(ctx context.Context, user *domain.User, identities []domain.UserIdentity) error {
+	if err := b.tx.SavePoint(rowSavePoint).Error; err != nil {
+		return err
+	}
+	if err := b.insert(user, identities); err != nil {
+		if rollbackErr := b.tx.RollbackTo(rowSavePoint).Error; rollbackErr != nil {
+			return rollbackErr
+		}
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrDuplicate
+		}
+		return err
+	}
+	return nil
+}
+
+func (b *gormBatch) insert(user *domain.User, identities []domain.UserIdentity) error {
+	profile := user.Profile
+	user.Profile = nil
+	defer func() { user.Profile = profile }()
+
+	active := user.IsActive
+	if err := b.tx.Create(user).Error; err != nil {
+		return err
+	}
+	// is_active defaults to true in the database, which Create does not
+	// override with a false zero value.
+	if !active {
+		if err := b.tx.Model(user).Update("is_active", false).Error; err != nil {
+			return err
+		}
+		user.IsActive = false
+	}
+	if err := b.tx.Create(domain.PrimaryEmail(user, user.CreatedAt)).Error; err != nil {
+		return err
+	}
+	if profile != nil {
+		profile.UserID = user.ID
+		if err := b.tx.Create(profile).Error; err != nil {
+			return err
+		}
+	}
+	for i := range identities {
+		identities[i].UserID = user.ID
+		if err := b.tx.Create(&identities[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *gormBatch) Commit() error {
+	return b.tx.Commit().Error
+}
+
+func (b *gormBatch) Rollback() error {
+	return b.tx.Rollback().Error
+}
+
+// newUser maps a validated record onto the rows to insert.
+func newUser(rec *Record, now time.Time) (*domain.User, []domain.UserIdentity) {
+	user := &domain.User{Email: strings.ToLower(rec.Email), IsActive: true}
+	if rec.IsActive != nil {
+		user.IsActive = *rec.IsActive
+	}
+	if rec.CreatedAt != nil {
+		user.CreatedAt = rec.CreatedAt.UTC()
+	}
+	if rec.PasswordHash != "" {
+		user.SetPasswordHash(rec.PasswordHash)
+	}
+	if rec.Username != "" {
+		user.SetUsername(rec.Username, now)
+	}
+	user.Profile = &domain.UserProfile{
+		DisplayName: rec.Profile.DisplayName,
+		FirstName:   rec.Profile.FirstName,
+		LastName:    rec.Profile.LastName,
+		Locale:      rec.Profile.Locale,
+		Timezone:    rec.Profile.Timezone,
+		Bio:         rec.Profile.Bio,
+		Company:     rec.Profile.Company,
+		Attributes:  rec.Profile.Attributes,
+	}
+	identities := make([]domain.UserIdentity, 0, len(rec.Identities))
+	for _, link := range rec.Identities {
+		email := link.Email
+		if email == "" {
+			email = user.Email
+		}
+		identities = append(identities, domain.UserIdentity{
+			Provider:       domain.IdentityProvider(strings.ToLower(link.Provider)),
+			ProviderUserID: link.ProviderUserID,
+			Email:          strings.ToLower(email),
+		})
+	}
+	return user, identities
+}
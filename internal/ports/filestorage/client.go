@@ -7,12 +7,15 @@ import (
 	"fmt"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"path"
 	"time"
 )
 
 type Client interface {
 	Upload(ctx context.Context, fileName, contentType string, data []byte) (string, error)
+	// Delete removes a previously uploaded file. Missing files are not an error.
+	Delete(ctx context.Context, fileURL string) error
 }
 
 type httpClient struct {
@@ -75,3 +78,25 @@ func (c *httpClient) Upload(ctx context.Context, fileName, contentType string, d
 
 	return resp.URL, nil
 }
+
+func (c *httpClient) Delete(ctx context.Context, fileURL string) error {
+	endpoint := fmt.Sprintf("%s/files?%s", c.baseURL, url.Values{"url": {fileURL}}.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, endpoint, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if res.StatusCode >= 400 {
+		return fmt.Errorf("filestorage error: status %d", res.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/example/user-service/internal/domain"
+	authmw "github.com/example/user-service/internal/ports/http/middleware"
+	"github.com/example/user-service/internal/repo"
+	"github.com/example/user-service/internal/service"
+	res "github.com/example/user-service/pkg/http"
+)
+
+type AdminHandler struct {
+	admin   service.AdminService
+	schemas service.ProfileSchemaService
+}
+
+func NewAdminHandler(admin service.AdminService, schemas service.ProfileSchemaService) *AdminHandler {
+	return &AdminHandler{admin: admin, schemas: schemas}
+}
+
+func (h *AdminHandler) RegisterRoutes(g *echo.Group, rbac *authmw.RBACMiddleware) {
+	g.GET("/users", h.ListUsers, rbac.RequirePermission(domain.PermUsersRead))
+	g.GET("/users/:id", h.GetUser, rbac.RequirePermission(domain.PermUsersRead))
+	g.POST("/users/:id/suspend", h.Suspend, rbac.RequirePermission(domain.PermUsersSuspend))
+	g.POST("/users/:id/unsuspend", h.Unsuspend, rbac.RequirePermission(domain.PermUsersSuspend))
+	g.PATCH("/users/:id/profile", h.UpdateProfile, rbac.RequirePermission(domain.PermUsersWrite))
+	g.GET("/profile-schema", h.GetProfileSchema, rbac.RequirePermission(domain.PermUsersRead))
+	g.PUT("/profile-schema", h.PublishProfileSchema, rbac.RequirePermission(domain.PermProfileSchemaWrite))
+}
+
+type suspendRequest struct {
+	Reason string     `json:"reason"`
+	Until  *time.Time `json:"until"`
+}
+
+// ListUsers supports the query parameters email_prefix, is_active, provider,
+// created_from, created_to (RFC 3339), sort (created_at, -created_at, email,
+// -email), cursor and limit.
+func (h *AdminHandler) ListUsers(c echo.Context) error {
+	filter, err := parseUserListFilter(c)
+	if err != nil {
+		return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", err.Error(), requestIDFromCtx(c), nil)
+	}
+	page, err := h.admin.ListUsers(c.Request().Context(), filter)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidFilter) || errors.Is(err, service.ErrUnsupportedProvider) || errors.Is(err, repo.ErrInvalidCursor) {
+			return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", err.Error(), requestIDFromCtx(c), nil)
+		}
+		return res.ErrorJSON(c, http.StatusInternalServerError, "list_failed", err.Error(), requestIDFromCtx(c), nil)
+	}
+	return res.JSON(c, http.StatusOK, page)
+}
+
+func (h *AdminHandler) GetUser(c echo.Context) error {
+	user, err := h.admin.GetUser(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return res.ErrorJSON(c, http.StatusNotFound, "not_found", "user not found", requestIDFromCtx(c), nil)
+		}
+		return res.ErrorJSON(c, http.StatusInternalServerError, "lookup_failed", err.Error(), requestIDFromCtx(c), nil)
+	}
+	return res.JSON(c, http.StatusOK, user)
+}
+
+func (h *AdminHandler) Suspend(c echo.Context) error {
+	var req suspendRequest
+	if err := c.Bind(&req); err != nil {
+		return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", "invalid payload", requestIDFromCtx(c), nil)
+	}
+	user, err := h.admin.Suspend(c.Request().Context(), requestIDFromCtx(c), actorID(c), c.Param("id"), req.Reason, req.Until)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrSuspensionReasonRequired), errors.Is(err, service.ErrInvalidSuspensionExpiry):
+			return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", err.Error(), requestIDFromCtx(c), nil)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return res.ErrorJSON(c, http.StatusNotFound, "not_found", "user not found", requestIDFromCtx(c), nil)
+		case errors.Is(err, repo.ErrVersionConflict):
+			return versionConflictJSON(c)
+		}
+		return res.ErrorJSON(c, http.StatusInternalServerError, "suspend_failed", err.Error(), requestIDFromCtx(c), nil)
+	}
+	return res.JSON(c, http.StatusOK, user)
+}
+
+func (h *AdminHandler) Unsuspend(c echo.Context) error {
+	user, err := h.admin.Unsuspend(c.Request().Context(), requestIDFromCtx(c), actorID(c), c.Param("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUserNotSuspended):
+			return res.ErrorJSON(c, http.StatusConflict, "not_suspended", err.Error(), requestIDFromCtx(c), nil)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return res.ErrorJSON(c, http.StatusNotFound, "not_found", "user not found", requestIDFromCtx(c), nil)
+		case errors.Is(err, repo.ErrVersionConflict):
+			return versionConflictJSON(c)
+		}
+		return res.ErrorJSON(c, http.StatusInternalServerError, "unsuspend_failed", err.Error(), requestIDFromCtx(c), nil)
+	}
+	return res.JSON(c, http.StatusOK, user)
+}
+
+func (h *AdminHandler) UpdateProfile(c echo.Context) error {
+	req := new(updateProfileRequest)
+	if err := bindPatch(c, req); err != nil {
+		return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", "invalid payload", requestIDFromCtx(c), nil)
+	}
+	profile, err := h.admin.UpdateProfile(c.Request().Context(), requestIDFromCtx(c), actorID(c), c.Param("id"), req.toUpdate())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return res.ErrorJSON(c, http.StatusNotFound, "not_found", "user not found", requestIDFromCtx(c), nil)
+		}
+		return profileUpdateErrorJSON(c, err)
+	}
+	return res.JSON(c, http.StatusOK, profile)
+}
+
+func (h *AdminHandler) GetProfileSchema(c echo.Context) error {
+	schema, err := h.schemas.Current(c.Request().Context())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return res.ErrorJSON(c, http.StatusNotFound, "not_found", "no profile schema published", requestIDFromCtx(c), nil)
+		}
+		return res.ErrorJSON(c, http.StatusInternalServerError, "lookup_failed", err.Error(), requestIDFromCtx(c), nil)
+	}
+	return res.JSON(c, http.StatusOK, schema)
+}
+
+// PublishProfileSchema stores the request body as the next schema version.
+func (h *AdminHandler) PublishProfileSchema(c echo.Context) error {
+	raw, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", "invalid payload", requestIDFromCtx(c), nil)
+	}
+	schema, err := h.schemas.Publish(c.Request().Context(), requestIDFromCtx(c), actorID(c), raw)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidProfileSchema) {
+			return res.ErrorJSON(c, http.StatusBadRequest, "invalid_schema", err.Error(), requestIDFromCtx(c), nil)
+		}
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return res.ErrorJSON(c, http.StatusConflict, "conflict", "another schema version was published concurrently", requestIDFromCtx(c), nil)
+		}
+		return res.ErrorJSON(c, http.StatusInternalServerError, "publish_failed", err.Error(), requestIDFromCtx(c), nil)
+	}
+	return res.JSON(c, http.StatusCreated, schema)
+}
+
+// actorID returns the authenticated caller recorded in audit events.
+func actorID(c echo.Context) string {
+	id, _ := c.Get("user_id").(string)
+	return id
+}
+
+func parseUserListFilter(c echo.Context) (repo.UserListFilter, error) {
+	filter := repo.UserListFilter{
+		EmailPrefix: strings.TrimSpace(c.QueryParam("email_prefix")),
+		Provider:    strings.ToLower(strings.TrimSpace(c.QueryParam("provider"))),
+		Cursor:      c.QueryParam("cursor"),
+	}
+	if raw := c.QueryParam("is_active"); raw != "" {
+		active, err := strconv.ParseBool(raw)
+		if err != nil {
+			return filter, errors.New("is_active must be true or false")
+		}
+		filter.IsActive = &active
+	}
+	if raw := c.QueryParam("created_from"); raw != "" {
+		ts, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return filter, errors.New("created_from must be an RFC 3339 timestamp")
+		}
+		filter.CreatedFrom = &ts
+	}
+	if raw := c.QueryParam("created_to"); raw != "" {
+		ts, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return filter, errors.New("created_to must be an RFC 3339 timestamp")
+		}
+		filter.CreatedTo = &ts
+	}
+	if sort := c.QueryParam("sort"); sort != "" {
+		filter.Descending = strings.HasPrefix(sort, "-")
+		filter.SortBy = strings.TrimPrefix(sort, "-")
+	} else {
+		filter.Descending = true
+	}
+	if raw := c.QueryParam("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			return filter, errors.New("limit must be a positive integer")
+		}
+		filter.Limit = limit
+	}
+	return filter, nil
+}
@@ -1,10 +1,12 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
 
+	"github.com/example/user-service/internal/domain"
 	"github.com/example/user-service/internal/service"
 	res "github.com/example/user-service/pkg/http"
 )
@@ -26,11 +28,21 @@ type signupResponse struct {
 	UUID string `json:"uuid"`
 }
 
+// signinRequest identifies the account by email or username. Email may also
+// carry a username for clients that only have a single login field.
 type signinRequest struct {
 	Email    string `json:"email"`
+	Username string `json:"username"`
 	Password string `json:"password"`
 }
 
+func (r *signinRequest) login() string {
+	if r.Email != "" {
+		return r.Email
+	}
+	return r.Username
+}
+
 type signinResponse struct {
 	Tokens *service.Tokens `json:"tokens"`
 }
@@ -51,6 +63,46 @@ type oauthCallbackRequest struct {
 	DisplayName    *string                `json:"display_name"`
 	AvatarURL      *string                `json:"avatar_url"`
 	Metadata       map[string]interface{} `json:"metadata"`
+	// InvitationToken signs up an invited user instead of a regular one.
+	InvitationToken string `json:"invitation_token"`
+}
+
+type restoreAccountRequest struct {
+	RestoreToken string `json:"restore_token"`
+}
+
+type acceptInvitationRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type passwordForgotRequest struct {
+	Email string `json:"email"`
+}
+
+type passwordResetRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type smsSignInRequest struct {
+	Phone string `json:"phone"`
+}
+
+type mfaVerifyRequest struct {
+	ChallengeID string `json:"challenge_id"`
+	Code        string `json:"code"`
+}
+
+type recoverySignInRequest struct {
+	ChallengeID  string `json:"challenge_id"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type accountLinkConfirmRequest struct {
+	LinkID   string `json:"link_id"`
+	Code     string `json:"code"`
+	Password string `json:"password"`
 }
 
 func (h *AuthHandler) RegisterRoutes(g *echo.Group) {
@@ -59,6 +111,15 @@ func (h *AuthHandler) RegisterRoutes(g *echo.Group) {
 	g.POST("/signin", h.SignIn)
 	g.POST("/oauth/callback", h.HandleOAuthCallback)
 	g.POST("/oauth/:provider/callback", h.OAuthCallback)
+	g.POST("/oauth/link/confirm", h.ConfirmAccountLink)
+	g.POST("/restore", h.RestoreAccount)
+	g.POST("/invitations/accept", h.AcceptInvitation)
+	g.POST("/sms/start", h.StartSMSSignIn)
+	g.POST("/sms/verify", h.VerifySMSSignIn)
+	g.POST("/mfa/verify", h.VerifyMFA)
+	g.POST("/recovery", h.SignInWithRecoveryCode)
+	g.POST("/password/forgot", h.RequestPasswordReset)
+	g.POST("/password/reset", h.ResetPassword)
 }
 
 func (h *AuthHandler) Signup(c echo.Context) error {
@@ -94,8 +155,18 @@ func (h *AuthHandler) SignIn(c echo.Context) error {
 	if err := c.Bind(req); err != nil {
 		return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", "invalid payload", requestIDFromCtx(c), nil)
 	}
-	user, tokens, err := h.auth.SignIn(c.Request().Context(), requestIDFromCtx(c), req.Email, req.Password)
+	user, tokens, err := h.auth.SignIn(c.Request().Context(), requestIDFromCtx(c), req.login(), req.Password)
 	if err != nil {
+		var pendingErr *service.AccountPendingDeletionError
+		if errors.As(err, &pendingErr) {
+			return pendingDeletionJSON(c, pendingErr)
+		}
+		if errors.Is(err, service.ErrPasswordResetRequired) {
+			return res.ErrorJSON(c, http.StatusForbidden, "password_reset_required", err.Error(), requestIDFromCtx(c), nil)
+		}
+		if mfaErr := mfaRequiredJSON(c, err); mfaErr != nil {
+			return mfaErr
+		}
 		status := http.StatusUnauthorized
 		return res.ErrorJSON(c, status, "signin_failed", err.Error(), requestIDFromCtx(c), nil)
 	}
@@ -125,20 +196,220 @@ func (h *AuthHandler) processOAuthCallback(c echo.Context, provider string) erro
 		requestIDFromCtx(c),
 		effectiveProvider,
 		service.OAuthUserInfo{
-			ProviderType:   effectiveProvider,
-			ProviderUserID: req.ProviderUserID,
-			Email:          req.Email,
-			DisplayName:    req.DisplayName,
-			AvatarURL:      req.AvatarURL,
-			Metadata:       req.Metadata,
+			ProviderType:    effectiveProvider,
+			ProviderUserID:  req.ProviderUserID,
+			Email:           req.Email,
+			DisplayName:     req.DisplayName,
+			AvatarURL:       req.AvatarURL,
+			Metadata:        req.Metadata,
+			InvitationToken: req.InvitationToken,
 		},
 	)
 	if err != nil {
+		var linkErr *service.AccountLinkRequiredError
+		if errors.As(err, &linkErr) {
+			return res.ErrorJSON(c, http.StatusConflict, "account_link_required", err.Error(), requestIDFromCtx(c), map[string]interface{}{
+				"link_id":    linkErr.LinkID,
+				"methods":    linkErr.Methods,
+				"expires_at": linkErr.ExpiresAt,
+			})
+		}
+		var pendingErr *service.AccountPendingDeletionError
+		if errors.As(err, &pendingErr) {
+			return pendingDeletionJSON(c, pendingErr)
+		}
+		if mfaErr := mfaRequiredJSON(c, err); mfaErr != nil {
+			return mfaErr
+		}
+		if errors.Is(err, service.ErrInvitationInvalid) || errors.Is(err, service.ErrInvitationAccountExists) || errors.Is(err, service.ErrInvitationEmailMismatch) {
+			return invitationAcceptErrorJSON(c, err)
+		}
+		if errors.Is(err, service.ErrIdentityOtherTenant) {
+			return res.ErrorJSON(c, http.StatusConflict, "identity_other_organization", err.Error(), requestIDFromCtx(c), nil)
+		}
 		return res.ErrorJSON(c, http.StatusBadRequest, "oauth_callback_failed", err.Error(), requestIDFromCtx(c), nil)
 	}
 	return c.JSON(http.StatusOK, map[string]interface{}{"user": user, "tokens": tokens})
 }
 
+func (h *AuthHandler) ConfirmAccountLink(c echo.Context) error {
+	req := new(accountLinkConfirmRequest)
+	if err := c.Bind(req); err != nil {
+		return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", "invalid payload", requestIDFromCtx(c), nil)
+	}
+	user, tokens, err := h.auth.ConfirmAccountLink(c.Request().Context(), requestIDFromCtx(c), req.LinkID, req.Code, req.Password)
+	if err != nil {
+		if mfaErr := mfaRequiredJSON(c, err); mfaErr != nil {
+			return mfaErr
+		}
+		status := http.StatusBadRequest
+		if errors.Is(err, service.ErrInvalidCredentials) {
+			status = http.StatusUnauthorized
+		} else if errors.Is(err, service.ErrAccountLinkExpired) {
+			status = http.StatusGone
+		}
+		return res.ErrorJSON(c, status, "account_link_failed", err.Error(), requestIDFromCtx(c), nil)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"user": user, "tokens": tokens})
+}
+
+func (h *AuthHandler) RestoreAccount(c echo.Context) error {
+	req := new(restoreAccountRequest)
+	if err := c.Bind(req); err != nil {
+		return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", "invalid payload", requestIDFromCtx(c), nil)
+	}
+	user, tokens, err := h.auth.RestoreAccount(c.Request().Context(), requestIDFromCtx(c), req.RestoreToken)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, service.ErrRestoreTokenInvalid) {
+			status = http.StatusUnauthorized
+		} else if errors.Is(err, service.ErrUserInactive) {
+			status = http.StatusForbidden
+		}
+		return res.ErrorJSON(c, status, "restore_failed", err.Error(), requestIDFromCtx(c), nil)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"user": user, "tokens": tokens})
+}
+
+// AcceptInvitation signs up the invitee named by the emailed token.
+func (h *AuthHandler) AcceptInvitation(c echo.Context) error {
+	req := new(acceptInvitationRequest)
+	if err := c.Bind(req); err != nil {
+		return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", "invalid payload", requestIDFromCtx(c), nil)
+	}
+	user, tokens, err := h.auth.AcceptInvitation(c.Request().Context(), requestIDFromCtx(c), req.Token, req.Password)
+	if err != nil {
+		return invitationAcceptErrorJSON(c, err)
+	}
+	return c.JSON(http.StatusCreated, map[string]interface{}{"user": user, "tokens": tokens})
+}
+
+func invitationAcceptErrorJSON(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, service.ErrInvitationInvalid):
+		return res.ErrorJSON(c, http.StatusGone, "invitation_invalid", err.Error(), requestIDFromCtx(c), nil)
+	case errors.Is(err, service.ErrInvitationAccountExists):
+		return res.ErrorJSON(c, http.StatusConflict, "account_exists", err.Error(), requestIDFromCtx(c), nil)
+	case errors.Is(err, service.ErrInvitationEmailMismatch):
+		return res.ErrorJSON(c, http.StatusForbidden, "invitation_email_mismatch", err.Error(), requestIDFromCtx(c), nil)
+	}
+	return res.ErrorJSON(c, http.StatusBadRequest, "invitation_accept_failed", err.Error(), requestIDFromCtx(c), nil)
+}
+
+// RequestPasswordReset answers 202 whether or not the email belongs to an
+// account.
+func (h *AuthHandler) RequestPasswordReset(c echo.Context) error {
+	req := new(passwordForgotRequest)
+	if err := c.Bind(req); err != nil {
+		return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", "invalid payload", requestIDFromCtx(c), nil)
+	}
+	if err := h.auth.RequestPasswordReset(c.Request().Context(), requestIDFromCtx(c), req.Email); err != nil {
+		return res.ErrorJSON(c, http.StatusInternalServerError, "reset_failed", err.Error(), requestIDFromCtx(c), nil)
+	}
+	return c.NoContent(http.StatusAccepted)
+}
+
+func (h *AuthHandler) ResetPassword(c echo.Context) error {
+	req := new(passwordResetRequest)
+	if err := c.Bind(req); err != nil {
+		return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", "invalid payload", requestIDFromCtx(c), nil)
+	}
+	err := h.auth.ResetPassword(c.Request().Context(), requestIDFromCtx(c), req.Token, req.Password)
+	if errors.Is(err, service.ErrResetTokenInvalid) {
+		return res.ErrorJSON(c, http.StatusUnauthorized, "reset_token_invalid", err.Error(), requestIDFromCtx(c), nil)
+	}
+	if err != nil {
+		return res.ErrorJSON(c, http.StatusBadRequest, "reset_failed", err.Error(), requestIDFromCtx(c), nil)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *AuthHandler) StartSMSSignIn(c echo.Context) error {
+	req := new(smsSignInRequest)
+	if err := c.Bind(req); err != nil {
+		return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", "invalid payload", requestIDFromCtx(c), nil)
+	}
+	uuid, err := h.auth.StartSMSSignIn(c.Request().Context(), requestIDFromCtx(c), req.Phone)
+	if err != nil {
+		return phoneErrorJSON(c, err)
+	}
+	return res.JSON(c, http.StatusAccepted, map[string]string{"uuid": uuid})
+}
+
+func (h *AuthHandler) VerifySMSSignIn(c echo.Context) error {
+	req := new(codeVerificationRequest)
+	if err := c.Bind(req); err != nil {
+		return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", "invalid payload", requestIDFromCtx(c), nil)
+	}
+	user, tokens, err := h.auth.VerifySMSSignIn(c.Request().Context(), requestIDFromCtx(c), req.UUID, req.Code)
+	return codeSignInJSON(c, user, tokens, err)
+}
+
+func (h *AuthHandler) VerifyMFA(c echo.Context) error {
+	req := new(mfaVerifyRequest)
+	if err := c.Bind(req); err != nil {
+		return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", "invalid payload", requestIDFromCtx(c), nil)
+	}
+	user, tokens, err := h.auth.VerifyMFA(c.Request().Context(), requestIDFromCtx(c), req.ChallengeID, req.Code)
+	return codeSignInJSON(c, user, tokens, err)
+}
+
+// SignInWithRecoveryCode completes a sign-in that answered mfa_required with a
+// recovery code in place of the texted one.
+func (h *AuthHandler) SignInWithRecoveryCode(c echo.Context) error {
+	req := new(recoverySignInRequest)
+	if err := c.Bind(req); err != nil {
+		return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", "invalid payload", requestIDFromCtx(c), nil)
+	}
+	user, tokens, err := h.auth.SignInWithRecoveryCode(c.Request().Context(), requestIDFromCtx(c), req.ChallengeID, req.RecoveryCode)
+	switch {
+	case errors.Is(err, service.ErrRecoveryCodeInvalid):
+		return res.ErrorJSON(c, http.StatusUnauthorized, "invalid_recovery_code", err.Error(), requestIDFromCtx(c), nil)
+	case errors.Is(err, service.ErrRecoveryCodesUnavailable):
+		return res.ErrorJSON(c, http.StatusNotImplemented, "recovery_unavailable", err.Error(), requestIDFromCtx(c), nil)
+	}
+	return codeSignInJSON(c, user, tokens, err)
+}
+
+// codeSignInJSON answers a sign-in completed with a texted code.
+func codeSignInJSON(c echo.Context, user *domain.User, tokens *service.Tokens, err error) error {
+	if err != nil {
+		var pendingErr *service.AccountPendingDeletionError
+		if errors.As(err, &pendingErr) {
+			return pendingDeletionJSON(c, pendingErr)
+		}
+		return res.ErrorJSON(c, http.StatusUnauthorized, "signin_failed", err.Error(), requestIDFromCtx(c), nil)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"user": user, "tokens": tokens})
+}
+
+// mfaRequiredJSON writes the second-factor challenge shared by every sign-in
+// path. It returns nil when err is neither a challenge nor a refusal to text
+// one.
+func mfaRequiredJSON(c echo.Context, err error) error {
+	var mfaErr *service.MFARequiredError
+	if errors.As(err, &mfaErr) {
+		return res.ErrorJSON(c, http.StatusUnauthorized, "mfa_required", err.Error(), requestIDFromCtx(c), map[string]interface{}{
+			"challenge_id": mfaErr.ChallengeID,
+			"methods":      mfaErr.Methods,
+			"expires_at":   mfaErr.ExpiresAt,
+		})
+	}
+	if errors.Is(err, service.ErrPhoneCodeLimit) {
+		return res.ErrorJSON(c, http.StatusTooManyRequests, "too_many_codes", err.Error(), requestIDFromCtx(c), nil)
+	}
+	return nil
+}
+
+// pendingDeletionJSON tells a client that just authenticated that the account
+// awaits purge and how to restore it.
+func pendingDeletionJSON(c echo.Context, err *service.AccountPendingDeletionError) error {
+	return res.ErrorJSON(c, http.StatusConflict, "account_pending_deletion", err.Error(), requestIDFromCtx(c), map[string]interface{}{
+		"restore_token": err.RestoreToken,
+		"purge_at":      err.PurgeAt,
+	})
+}
+
 func requestIDFromCtx(c echo.Context) string {
 	if reqID := c.Response().Header().Get(echo.HeaderXRequestID); reqID != "" {
 		return reqID
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/example/user-service/internal/repo"
+	"github.com/example/user-service/internal/service"
+	res "github.com/example/user-service/pkg/http"
+)
+
+type EmailHandler struct {
+	emails service.EmailService
+}
+
+func NewEmailHandler(emails service.EmailService) *EmailHandler {
+	return &EmailHandler{emails: emails}
+}
+
+type addEmailRequest struct {
+	Email string `json:"email"`
+}
+
+type verifyEmailRequest struct {
+	UUID string `json:"uuid"`
+	Code string `json:"code"`
+}
+
+func (h *EmailHandler) RegisterUserRoutes(g *echo.Group) {
+	g.GET("/me/emails", h.List)
+	g.POST("/me/emails", h.Add)
+	g.POST("/me/emails/verify", h.Verify)
+	g.POST("/me/emails/:id/primary", h.MakePrimary)
+	g.DELETE("/me/emails/:id", h.Remove)
+}
+
+func (h *EmailHandler) List(c echo.Context) error {
+	emails, err := h.emails.List(c.Request().Context(), c.Get("user_id").(string))
+	if err != nil {
+		return emailErrorJSON(c, err)
+	}
+	return res.JSON(c, http.StatusOK, map[string]interface{}{"emails": emails})
+}
+
+func (h *EmailHandler) Add(c echo.Context) error {
+	req := new(addEmailRequest)
+	if err := c.Bind(req); err != nil {
+		return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", "invalid payload", requestIDFromCtx(c), nil)
+	}
+	uuid, err := h.emails.Add(c.Request().Context(), c.Get("user_id").(string), req.Email)
+	if err != nil {
+		return emailErrorJSON(c, err)
+	}
+	return res.JSON(c, http.StatusAccepted, map[string]string{"uuid": uuid})
+}
+
+func (h *EmailHandler) Verify(c echo.Context) error {
+	req := new(verifyEmailRequest)
+	if err := c.Bind(req); err != nil {
+		return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", "invalid payload", requestIDFromCtx(c), nil)
+	}
+	email, err := h.emails.Verify(c.Request().Context(), c.Get("user_id").(string), req.UUID, req.Code)
+	if err != nil {
+		return emailErrorJSON(c, err)
+	}
+	return res.JSON(c, http.StatusOK, email)
+}
+
+func (h *EmailHandler) MakePrimary(c echo.Context) error {
+	user, err := h.emails.MakePrimary(c.Request().Context(), c.Get("user_id").(string), c.Param("id"))
+	if err != nil {
+		return emailErrorJSON(c, err)
+	}
+	return res.JSON(c, http.StatusOK, user)
+}
+
+func (h *EmailHandler) Remove(c echo.Context) error {
+	if err := h.emails.Remove(c.Request().Context(), c.Get("user_id").(string), c.Param("id")); err != nil {
+		return emailErrorJSON(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func emailErrorJSON(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, service.ErrEmailNotFound):
+		return res.ErrorJSON(c, http.StatusNotFound, "not_found", err.Error(), requestIDFromCtx(c), nil)
+	case errors.Is(err, service.ErrEmailTaken):
+		return res.ErrorJSON(c, http.StatusConflict, "email_taken", err.Error(), requestIDFromCtx(c), nil)
+	case errors.Is(err, service.ErrEmailUnverified), errors.Is(err, service.ErrEmailPrimary), errors.Is(err, service.ErrEmailLimit):
+		return res.ErrorJSON(c, http.StatusConflict, "email_conflict", err.Error(), requestIDFromCtx(c), nil)
+	case errors.Is(err, repo.ErrVersionConflict):
+		return versionConflictJSON(c)
+	}
+	return res.ErrorJSON(c, http.StatusBadRequest, "email_failed", err.Error(), requestIDFromCtx(c), nil)
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/example/user-service/internal/domain"
+	authmw "github.com/example/user-service/internal/ports/http/middleware"
+	"github.com/example/user-service/internal/service"
+	res "github.com/example/user-service/pkg/http"
+)
+
+type ExportHandler struct {
+	exports service.ExportService
+}
+
+func NewExportHandler(exports service.ExportService) *ExportHandler {
+	return &ExportHandler{exports: exports}
+}
+
+type exportResponse struct {
+	ID          string              `json:"id"`
+	UserID      string              `json:"user_id"`
+	Status      domain.ExportStatus `json:"status"`
+	Error       *string             `json:"error,omitempty"`
+	DownloadURL string              `json:"download_url,omitempty"`
+	ExpiresAt   *time.Time          `json:"expires_at,omitempty"`
+	CompletedAt *time.Time          `json:"completed_at,omitempty"`
+	CreatedAt   time.Time           `json:"created_at"`
+}
+
+// RegisterRoutes mounts the signed download link. It must not sit behind the
+// auth middleware: the token in the link is the credential.
+func (h *ExportHandler) RegisterRoutes(g *echo.Group) {
+	g.GET("/download", h.Download)
+}
+
+func (h *ExportHandler) RegisterUserRoutes(g *echo.Group) {
+	g.POST("/me/export", h.RequestOwn)
+	g.GET("/me/export/:export_id", h.GetOwn)
+}
+
+func (h *ExportHandler) RegisterAdminRoutes(g *echo.Group, rbac *authmw.RBACMiddleware) {
+	g.POST("/users/:id/export", h.RequestForUser, rbac.RequirePermission(domain.PermUsersExport))
+	g.GET("/exports/:export_id", h.Get, rbac.RequirePermission(domain.PermUsersExport))
+}
+
+func (h *ExportHandler) RequestOwn(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+	return h.request(c, userID)
+}
+
+func (h *ExportHandler) RequestForUser(c echo.Context) error {
+	return h.request(c, c.Param("id"))
+}
+
+func (h *ExportHandler) GetOwn(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+	export, err := h.exports.GetExport(c.Request().Context(), c.Param("export_id"))
+	if err != nil || export.UserID != userID {
+		return res.ErrorJSON(c, http.StatusNotFound, "not_found", "export not found", requestIDFromCtx(c), nil)
+	}
+	return res.JSON(c, http.StatusOK, h.toResponse(export))
+}
+
+func (h *ExportHandler) Get(c echo.Context) error {
+	export, err := h.exports.GetExport(c.Request().Context(), c.Param("export_id"))
+	if err != nil {
+		return res.ErrorJSON(c, http.StatusNotFound, "not_found", "export not found", requestIDFromCtx(c), nil)
+	}
+	return res.JSON(c, http.StatusOK, h.toResponse(export))
+}
+
+func (h *ExportHandler) Download(c echo.Context) error {
+	export, err := h.exports.Download(c.Request().Context(), requestIDFromCtx(c), c.QueryParam("token"))
+	if err != nil {
+		if errors.Is(err, service.ErrExportLinkInvalid) {
+			return res.ErrorJSON(c, http.StatusGone, "export_unavailable", err.Error(), requestIDFromCtx(c), nil)
+		}
+		return res.ErrorJSON(c, http.StatusInternalServerError, "download_failed", err.Error(), requestIDFromCtx(c), nil)
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="user-data-%s.zip"`, export.ID))
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return c.Blob(http.StatusOK, "application/zip", export.Archive)
+}
+
+func (h *ExportHandler) request(c echo.Context, userID string) error {
+	export, err := h.exports.RequestExport(c.Request().Context(), requestIDFromCtx(c), actorID(c), userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return res.ErrorJSON(c, http.StatusNotFound, "not_found", "user not found", requestIDFromCtx(c), nil)
+		}
+		return res.ErrorJSON(c, http.StatusInternalServerError, "export_failed", err.Error(), requestIDFromCtx(c), nil)
+	}
+	return res.JSON(c, http.StatusAccepted, h.toResponse(export))
+}
+
+func (h *ExportHandler) toResponse(export *domain.DataExport) exportResponse {
+	return exportResponse{
+		ID:          export.ID,
+		UserID:      export.UserID,
+		Status:      export.Status,
+		Error:       export.Error,
+		DownloadURL: h.exports.DownloadURL(export),
+		ExpiresAt:   export.ExpiresAt,
+		CompletedAt: export.CompletedAt,
+		CreatedAt:   export.CreatedAt,
+	}
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/example/user-service/internal/domain"
+	authmw "github.com/example/user-service/internal/ports/http/middleware"
+	"github.com/example/user-service/internal/service"
+	res "github.com/example/user-service/pkg/http"
+)
+
+type GroupHandler struct {
+	groups service.GroupService
+}
+
+func NewGroupHandler(groups service.GroupService) *GroupHandler {
+	return &GroupHandler{groups: groups}
+}
+
+// RegisterRoutes registers the group routes. Changes are authorized by the
+// service against the caller's ownership of the group.
+func (h *GroupHandler) RegisterRoutes(g *echo.Group) {
+	g.POST("", h.Create)
+	g.GET("/:id", h.Get)
+	g.PATCH("/:id", h.Update)
+	g.DELETE("/:id", h.Delete)
+	g.GET("/:id/members", h.ListMembers)
+	g.PUT("/:id/members/:user_id", h.AddMember)
+	g.DELETE("/:id/members/:user_id", h.RemoveMember)
+	g.GET("/:id/subgroups", h.ListSubgroups)
+	g.PUT("/:id/subgroups/:child_id", h.AddSubgroup)
+	g.DELETE("/:id/subgroups/:child_id", h.RemoveSubgroup)
+}
+
+func (h *GroupHandler) RegisterUserRoutes(g *echo.Group) {
+	g.GET("/me/groups", h.ListOwn)
+}
+
+func (h *GroupHandler) RegisterAdminRoutes(g *echo.Group, rbac *authmw.RBACMiddleware) {
+	g.GET("/users/:id/groups", h.ListForUser, rbac.RequirePermission(domain.PermUsersRead))
+}
+
+type groupRequest struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+}
+
+type groupMemberRequest struct {
+	Role domain.GroupRole `json:"role"`
+}
+
+func (h *GroupHandler) Create(c echo.Context) error {
+	var req groupRequest
+	if err := c.Bind(&req); err != nil || req.Name == nil {
+		return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", "invalid payload", requestIDFromCtx(c), nil)
+	}
+	group, err := h.groups.Create(c.Request().Context(), requestIDFromCtx(c), actorID(c), *req.Name, req.Description)
+	if err != nil {
+		return groupErrorJSON(c, err)
+	}
+	return res.JSON(c, http.StatusCreated, group)
+}
+
+func (h *GroupHandler) Get(c echo.Context) error {
+	group, err := h.groups.Get(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return groupErrorJSON(c, err)
+	}
+	return res.JSON(c, http.StatusOK, group)
+}
+
+func (h *GroupHandler) Update(c echo.Context) error {
+	var req groupRequest
+	if err := c.Bind(&req); err != nil {
+		return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", "invalid payload", requestIDFromCtx(c), nil)
+	}
+	group, err := h.groups.Update(c.Request().Context(), requestIDFromCtx(c), actorID(c), c.Param("id"), req.Name, req.Description)
+	if err != nil {
+		return groupErrorJSON(c, err)
+	}
+	return res.JSON(c, http.StatusOK, group)
+}
+
+func (h *GroupHandler) Delete(c echo.Context) error {
+	if err := h.groups.Delete(c.Request().Context(), requestIDFromCtx(c), actorID(c), c.Param("id")); err != nil {
+		return groupErrorJSON(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListMembers pages with the offset and limit query parameters;
+// effective=true adds the members of nested subgroups.
+func (h *GroupHandler) ListMembers(c echo.Context) error {
+	offset, limit := pageParams(c)
+	members, err := h.groups.ListMembers(c.Request().Context(), actorID(c), c.Param("id"), c.QueryParam("effective") == "true", offset, limit)
+	if err != nil {
+		return groupErrorJSON(c, err)
+	}
+	return res.JSON(c, http.StatusOK, map[string]interface{}{"members": members})
+}
+
+func (h *GroupHandler) AddMember(c echo.Context) error {
+	var req groupMemberRequest
+	if err := c.Bind(&req); err != nil {
+		return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", "invalid payload", requestIDFromCtx(c), nil)
+	}
+	member, err := h.groups.AddMember(c.Request().Context(), requestIDFromCtx(c), actorID(c), c.Param("id"), c.Param("user_id"), req.Role)
+	if err != nil {
+		return groupErrorJSON(c, err)
+	}
+	return res.JSON(c, http.StatusOK, member)
+}
+
+func (h *GroupHandler) RemoveMember(c echo.Context) error {
+	if err := h.groups.RemoveMember(c.Request().Context(), requestIDFromCtx(c), actorID(c), c.Param("id"), c.Param("user_id")); err != nil {
+		return groupErrorJSON(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *GroupHandler) ListSubgroups(c echo.Context) error {
+	groups, err := h.groups.ListSubgroups(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return groupErrorJSON(c, err)
+	}
+	return res.JSON(c, http.StatusOK, map[string]interface{}{"groups": groups})
+}
+
+func (h *GroupHandler) AddSubgroup(c echo.Context) error {
+	if err := h.groups.AddSubgroup(c.Request().Context(), requestIDFromCtx(c), actorID(c), c.Param("id"), c.Param("child_id")); err != nil {
+		return groupErrorJSON(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *GroupHandler) RemoveSubgroup(c echo.Context) error {
+	if err := h.groups.RemoveSubgroup(c.Request().Context(), requestIDFromCtx(c), actorID(c), c.Param("id"), c.Param("child_id")); err != nil {
+		return groupErrorJSON(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *GroupHandler) ListOwn(c echo.Context) error {
+	return h.listUserGroups(c, actorID(c))
+}
+
+func (h *GroupHandler) ListForUser(c echo.Context) error {
+	return h.listUserGroups(c, c.Param("id"))
+}
+
+// listUserGroups pages like ListMembers; effective=true adds the groups the
+// user's groups are nested in.
+func (h *GroupHandler) listUserGroups(c echo.Context, userID string) error {
+	offset, limit := pageParams(c)
+	groups, err := h.groups.ListUserGroups(c.Request().Context(), userID, c.QueryParam("effective") == "true", offset, limit)
+	if err != nil {
+		return groupErrorJSON(c, err)
+	}
+	return res.JSON(c, http.StatusOK, map[string]interface{}{"groups": groups})
+}
+
+func pageParams(c echo.Context) (offset, limit int) {
+	offset, _ = strconv.Atoi(c.QueryParam("offset"))
+	limit, _ = strconv.Atoi(c.QueryParam("limit"))
+	return offset, limit
+}
+
+func groupErrorJSON(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrGroupNameInvalid), errors.Is(err, domain.ErrGroupRoleInvalid):
+		return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", err.Error(), requestIDFromCtx(c), nil)
+	case errors.Is(err, service.ErrGroupForbidden):
+		return res.ErrorJSON(c, http.StatusForbidden, "forbidden", err.Error(), requestIDFromCtx(c), nil)
+	case errors.Is(err, service.ErrGroupNotFound), errors.Is(err, service.ErrGroupUserAbsent),
+		errors.Is(err, service.ErrGroupMemberNotFound), errors.Is(err, service.ErrSubgroupNotFound):
+		return res.ErrorJSON(c, http.StatusNotFound, "not_found", err.Error(), requestIDFromCtx(c), nil)
+	case errors.Is(err, service.ErrGroupLastOwner):
+		return res.ErrorJSON(c, http.StatusConflict, "last_owner", err.Error(), requestIDFromCtx(c), nil)
+	case errors.Is(err, service.ErrGroupCycle):
+		return res.ErrorJSON(c, http.StatusConflict, "group_cycle", err.Error(), requestIDFromCtx(c), nil)
+	}
+	return res.ErrorJSON(c, http.StatusInternalServerError, "group_failed", err.Error(), requestIDFromCtx(c), nil)
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/example/user-service/internal/domain"
+	authmw "github.com/example/user-service/internal/ports/http/middleware"
+	"github.com/example/user-service/internal/service"
+	res "github.com/example/user-service/pkg/http"
+)
+
+type InvitationHandler struct {
+	invitations service.InvitationService
+}
+
+func NewInvitationHandler(invitations service.InvitationService) *InvitationHandler {
+	return &InvitationHandler{invitations: invitations}
+}
+
+func (h *InvitationHandler) RegisterAdminRoutes(g *echo.Group, rbac *authmw.RBACMiddleware) {
+	g.POST("/invitations", h.Create, rbac.RequirePermission(domain.PermUsersWrite))
+	g.GET("/invitations", h.List, rbac.RequirePermission(domain.PermUsersRead))
+	g.POST("/invitations/:id/resend", h.Resend, rbac.RequirePermission(domain.PermUsersWrite))
+	g.DELETE("/invitations/:id", h.Revoke, rbac.RequirePermission(domain.PermUsersWrite))
+}
+
+type createInvitationRequest struct {
+	Email string `json:"email"`
+	OrgID string `json:"org_id"`
+	Role  string `json:"role"`
+}
+
+func (h *InvitationHandler) Create(c echo.Context) error {
+	var req createInvitationRequest
+	if err := c.Bind(&req); err != nil {
+		return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", "invalid payload", requestIDFromCtx(c), nil)
+	}
+	invitation, err := h.invitations.Create(c.Request().Context(), requestIDFromCtx(c), actorID(c), req.Email, req.OrgID, req.Role)
+	if err != nil {
+		return invitationErrorJSON(c, err)
+	}
+	return res.JSON(c, http.StatusCreated, invitation)
+}
+
+// List pages with the offset and limit query parameters and filters by the
+// status query parameter.
+func (h *InvitationHandler) List(c echo.Context) error {
+	offset, limit := pageParams(c)
+	invitations, err := h.invitations.List(c.Request().Context(), domain.InvitationStatus(c.QueryParam("status")), offset, limit)
+	if err != nil {
+		return invitationErrorJSON(c, err)
+	}
+	return res.JSON(c, http.StatusOK, map[string]interface{}{"invitations": invitations})
+}
+
+func (h *InvitationHandler) Resend(c echo.Context) error {
+	invitation, err := h.invitations.Resend(c.Request().Context(), requestIDFromCtx(c), actorID(c), c.Param("id"))
+	if err != nil {
+		return invitationErrorJSON(c, err)
+	}
+	return res.JSON(c, http.StatusOK, invitation)
+}
+
+func (h *InvitationHandler) Revoke(c echo.Context) error {
+	invitation, err := h.invitations.Revoke(c.Request().Context(), requestIDFromCtx(c), actorID(c), c.Param("id"))
+	if err != nil {
+		return invitationErrorJSON(c, err)
+	}
+	return res.JSON(c, http.StatusOK, invitation)
+}
+
+func invitationErrorJSON(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, service.ErrInvitationNotFound), errors.Is(err, service.ErrOrgNotFound):
+		return res.ErrorJSON(c, http.StatusNotFound, "not_found", err.Error(), requestIDFromCtx(c), nil)
+	case errors.Is(err, service.ErrInvitationExists), errors.Is(err, service.ErrInvitationAccountExists):
+		return res.ErrorJSON(c, http.StatusConflict, "invitation_conflict", err.Error(), requestIDFromCtx(c), nil)
+	case errors.Is(err, service.ErrInvitationRoleForbidden), errors.Is(err, service.ErrInvitationOrgForbidden):
+		return res.ErrorJSON(c, http.StatusForbidden, "forbidden", err.Error(), requestIDFromCtx(c), nil)
+	case errors.Is(err, service.ErrInvitationClosed):
+		return res.ErrorJSON(c, http.StatusConflict, "invitation_closed", err.Error(), requestIDFromCtx(c), nil)
+	}
+	return res.ErrorJSON(c, http.StatusBadRequest, "invitation_failed", err.Error(), requestIDFromCtx(c), nil)
+}
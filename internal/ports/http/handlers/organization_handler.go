@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/example/user-service/internal/domain"
+	authmw "github.com/example/user-service/internal/ports/http/middleware"
+	"github.com/example/user-service/internal/service"
+	res "github.com/example/user-service/pkg/http"
+)
+
+type OrganizationHandler struct {
+	orgs service.OrganizationService
+}
+
+func NewOrganizationHandler(orgs service.OrganizationService) *OrganizationHandler {
+	return &OrganizationHandler{orgs: orgs}
+}
+
+// RegisterRoutes registers the organization routes. Reads and role changes
+// are authorized by the service against the caller's role in the
+// organization.
+func (h *OrganizationHandler) RegisterRoutes(g *echo.Group, rbac *authmw.RBACMiddleware) {
+	g.POST("", h.Create, rbac.RequirePermission(domain.PermOrgsWrite))
+	g.GET("/:id", h.Get)
+	g.GET("/:id/members", h.ListMembers)
+	g.PUT("/:id/members/:user_id", h.SetMemberRole)
+}
+
+type createOrganizationRequest struct {
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+}
+
+type memberRoleRequest struct {
+	Role domain.OrgRole `json:"role"`
+}
+
+func (h *OrganizationHandler) Create(c echo.Context) error {
+	var req createOrganizationRequest
+	if err := c.Bind(&req); err != nil {
+		return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", "invalid payload", requestIDFromCtx(c), nil)
+	}
+	org, err := h.orgs.Create(c.Request().Context(), requestIDFromCtx(c), actorID(c), req.Slug, req.Name)
+	if err != nil {
+		return organizationErrorJSON(c, err)
+	}
+	return res.JSON(c, http.StatusCreated, org)
+}
+
+func (h *OrganizationHandler) Get(c echo.Context) error {
+	org, err := h.orgs.Get(c.Request().Context(), actorID(c), c.Param("id"))
+	if err != nil {
+		return organizationErrorJSON(c, err)
+	}
+	return res.JSON(c, http.StatusOK, org)
+}
+
+// ListMembers pages with the offset and limit query parameters.
+func (h *OrganizationHandler) ListMembers(c echo.Context) error {
+	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	members, err := h.orgs.ListMembers(c.Request().Context(), actorID(c), c.Param("id"), offset, limit)
+	if err != nil {
+		return organizationErrorJSON(c, err)
+	}
+	return res.JSON(c, http.StatusOK, map[string]interface{}{"members": members})
+}
+
+func (h *OrganizationHandler) SetMemberRole(c echo.Context) error {
+	var req memberRoleRequest
+	if err := c.Bind(&req); err != nil {
+		return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", "invalid payload", requestIDFromCtx(c), nil)
+	}
+	membership, err := h.orgs.SetMemberRole(c.Request().Context(), requestIDFromCtx(c), actorID(c), c.Param("id"), c.Param("user_id"), req.Role)
+	if err != nil {
+		return organizationErrorJSON(c, err)
+	}
+	return res.JSON(c, http.StatusOK, membership)
+}
+
+func organizationErrorJSON(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrOrgSlugInvalid), errors.Is(err, domain.ErrOrgNameInvalid), errors.Is(err, domain.ErrOrgRoleInvalid):
+		return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", err.Error(), requestIDFromCtx(c), nil)
+	case errors.Is(err, service.ErrOrgForbidden):
+		return res.ErrorJSON(c, http.StatusForbidden, "forbidden", err.Error(), requestIDFromCtx(c), nil)
+	case errors.Is(err, service.ErrOrgNotFound), errors.Is(err, service.ErrOrgUserAbsent):
+		return res.ErrorJSON(c, http.StatusNotFound, "not_found", err.Error(), requestIDFromCtx(c), nil)
+	case errors.Is(err, service.ErrOrgSlugTaken):
+		return res.ErrorJSON(c, http.StatusConflict, "slug_taken", err.Error(), requestIDFromCtx(c), nil)
+	case errors.Is(err, service.ErrOrgLastOwner):
+		return res.ErrorJSON(c, http.StatusConflict, "last_owner", err.Error(), requestIDFromCtx(c), nil)
+	}
+	return res.ErrorJSON(c, http.StatusInternalServerError, "organization_failed", err.Error(), requestIDFromCtx(c), nil)
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/example/user-service/internal/domain"
+	authmw "github.com/example/user-service/internal/ports/http/middleware"
+	"github.com/example/user-service/internal/repo"
+	"github.com/example/user-service/internal/service"
+	res "github.com/example/user-service/pkg/http"
+)
+
+type PhoneHandler struct {
+	phones service.PhoneService
+}
+
+func NewPhoneHandler(phones service.PhoneService) *PhoneHandler {
+	return &PhoneHandler{phones: phones}
+}
+
+type setPhoneRequest struct {
+	Phone string `json:"phone"`
+}
+
+type phoneMFARequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// RegisterUserRoutes requires a recent sign-in for every change that could
+// move or drop the second factor.
+func (h *PhoneHandler) RegisterUserRoutes(g *echo.Group, auth *authmw.AuthMiddleware) {
+	g.PUT("/me/phone", h.Start, auth.RequireRecentSignIn)
+	g.POST("/me/phone/verify", h.Verify)
+	g.DELETE("/me/phone", h.Remove, auth.RequireRecentSignIn)
+	g.PUT("/me/phone/mfa", h.SetMFA, auth.RequireRecentSignIn)
+}
+
+func (h *PhoneHandler) Start(c echo.Context) error {
+	req := new(setPhoneRequest)
+	if err := c.Bind(req); err != nil {
+		return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", "invalid payload", requestIDFromCtx(c), nil)
+	}
+	uuid, err := h.phones.StartVerification(c.Request().Context(), c.Get("user_id").(string), req.Phone)
+	if err != nil {
+		return phoneErrorJSON(c, err)
+	}
+	return res.JSON(c, http.StatusAccepted, map[string]string{"uuid": uuid})
+}
+
+func (h *PhoneHandler) Verify(c echo.Context) error {
+	req := new(codeVerificationRequest)
+	if err := c.Bind(req); err != nil {
+		return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", "invalid payload", requestIDFromCtx(c), nil)
+	}
+	user, err := h.phones.Verify(c.Request().Context(), c.Get("user_id").(string), req.UUID, req.Code)
+	if err != nil {
+		return phoneErrorJSON(c, err)
+	}
+	return res.JSON(c, http.StatusOK, user)
+}
+
+func (h *PhoneHandler) Remove(c echo.Context) error {
+	user, err := h.phones.Remove(c.Request().Context(), c.Get("user_id").(string))
+	if err != nil {
+		return phoneErrorJSON(c, err)
+	}
+	return res.JSON(c, http.StatusOK, user)
+}
+
+func (h *PhoneHandler) SetMFA(c echo.Context) error {
+	req := new(phoneMFARequest)
+	if err := c.Bind(req); err != nil {
+		return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", "invalid payload", requestIDFromCtx(c), nil)
+	}
+	user, err := h.phones.SetMFA(c.Request().Context(), c.Get("user_id").(string), req.Enabled)
+	if err != nil {
+		return phoneErrorJSON(c, err)
+	}
+	return res.JSON(c, http.StatusOK, user)
+}
+
+func phoneErrorJSON(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrPhoneInvalid):
+		return res.ErrorJSON(c, http.StatusBadRequest, "invalid_phone", err.Error(), requestIDFromCtx(c), nil)
+	case errors.Is(err, service.ErrPhoneTaken):
+		return res.ErrorJSON(c, http.StatusConflict, "phone_taken", err.Error(), requestIDFromCtx(c), nil)
+	case errors.Is(err, service.ErrPhoneRequired):
+		return res.ErrorJSON(c, http.StatusConflict, "phone_required", err.Error(), requestIDFromCtx(c), nil)
+	case errors.Is(err, service.ErrPhoneCodeLimit):
+		return res.ErrorJSON(c, http.StatusTooManyRequests, "too_many_codes", err.Error(), requestIDFromCtx(c), nil)
+	case errors.Is(err, service.ErrLastLoginMethod):
+		return res.ErrorJSON(c, http.StatusConflict, "last_login_method", err.Error(), requestIDFromCtx(c), nil)
+	case errors.Is(err, repo.ErrVersionConflict):
+		return versionConflictJSON(c)
+	}
+	return res.ErrorJSON(c, http.StatusBadRequest, "phone_failed", err.Error(), requestIDFromCtx(c), nil)
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/example/user-service/internal/service"
+	res "github.com/example/user-service/pkg/http"
+)
+
+type PreferenceHandler struct {
+	prefs service.PreferenceService
+}
+
+func NewPreferenceHandler(prefs service.PreferenceService) *PreferenceHandler {
+	return &PreferenceHandler{prefs: prefs}
+}
+
+func (h *PreferenceHandler) RegisterUserRoutes(g *echo.Group) {
+	g.GET("/me/preferences", h.GetOwn)
+	g.PATCH("/me/preferences", h.UpdateOwn)
+	g.GET("/me/preferences/definitions", h.Definitions)
+}
+
+// RegisterInternalRoutes registers the routes for other services.
+func (h *PreferenceHandler) RegisterInternalRoutes(g *echo.Group) {
+	g.POST("/preferences/batch", h.Batch)
+}
+
+type preferenceBatchRequest struct {
+	UserIDs []string `json:"user_ids"`
+	Keys    []string `json:"keys"`
+}
+
+func (h *PreferenceHandler) GetOwn(c echo.Context) error {
+	prefs, err := h.prefs.Get(c.Request().Context(), actorID(c))
+	if err != nil {
+		return preferenceErrorJSON(c, err)
+	}
+	return res.JSON(c, http.StatusOK, map[string]interface{}{"preferences": prefs})
+}
+
+// UpdateOwn takes a JSON object of preferences to set; null resets a
+// preference to its default.
+func (h *PreferenceHandler) UpdateOwn(c echo.Context) error {
+	var changes map[string]interface{}
+	if err := c.Bind(&changes); err != nil {
+		return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", "invalid payload", requestIDFromCtx(c), nil)
+	}
+	prefs, err := h.prefs.Update(c.Request().Context(), requestIDFromCtx(c), actorID(c), changes)
+	if err != nil {
+		return preferenceErrorJSON(c, err)
+	}
+	return res.JSON(c, http.StatusOK, map[string]interface{}{"preferences": prefs})
+}
+
+func (h *PreferenceHandler) Definitions(c echo.Context) error {
+	return res.JSON(c, http.StatusOK, map[string]interface{}{"definitions": h.prefs.Definitions()})
+}
+
+func (h *PreferenceHandler) Batch(c echo.Context) error {
+	var req preferenceBatchRequest
+	if err := c.Bind(&req); err != nil {
+		return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", "invalid payload", requestIDFromCtx(c), nil)
+	}
+	prefs, err := h.prefs.GetMany(c.Request().Context(), req.UserIDs, req.Keys)
+	if err != nil {
+		return preferenceErrorJSON(c, err)
+	}
+	return res.JSON(c, http.StatusOK, map[string]interface{}{"preferences": prefs})
+}
+
+func preferenceErrorJSON(c echo.Context, err error) error {
+	var validationErr *service.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return res.ErrorJSON(c, http.StatusUnprocessableEntity, "validation_failed", "preferences are invalid", requestIDFromCtx(c), map[string]interface{}{
+			"fields": validationErr.Fields,
+		})
+	case errors.Is(err, service.ErrPreferenceBatchTooLarge):
+		return res.ErrorJSON(c, http.StatusBadRequest, "batch_too_large", err.Error(), requestIDFromCtx(c), nil)
+	}
+	return res.ErrorJSON(c, http.StatusInternalServerError, "preferences_failed", err.Error(), requestIDFromCtx(c), nil)
+}
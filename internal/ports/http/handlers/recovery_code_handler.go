@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	authmw "github.com/example/user-service/internal/ports/http/middleware"
+	"github.com/example/user-service/internal/service"
+	res "github.com/example/user-service/pkg/http"
+)
+
+type RecoveryCodeHandler struct {
+	codes service.RecoveryCodeService
+}
+
+func NewRecoveryCodeHandler(codes service.RecoveryCodeService) *RecoveryCodeHandler {
+	return &RecoveryCodeHandler{codes: codes}
+}
+
+// RegisterUserRoutes requires a recent sign-in to regenerate the codes, which
+// would otherwise let a stolen session mint its own second factor.
+func (h *RecoveryCodeHandler) RegisterUserRoutes(g *echo.Group, auth *authmw.AuthMiddleware) {
+	g.GET("/me/recovery-codes", h.Status)
+	g.POST("/me/recovery-codes", h.Generate, auth.RequireRecentSignIn)
+}
+
+// Status reports how many unused codes are left; the codes themselves are
+// only shown once, when generated.
+func (h *RecoveryCodeHandler) Status(c echo.Context) error {
+	remaining, err := h.codes.Remaining(c.Request().Context(), c.Get("user_id").(string))
+	if err != nil {
+		return res.ErrorJSON(c, http.StatusInternalServerError, "recovery_codes_failed", err.Error(), requestIDFromCtx(c), nil)
+	}
+	return res.JSON(c, http.StatusOK, map[string]int64{"remaining": remaining})
+}
+
+// Generate issues a new set of codes and invalidates the previous one.
+func (h *RecoveryCodeHandler) Generate(c echo.Context) error {
+	codes, err := h.codes.Generate(c.Request().Context(), requestIDFromCtx(c), c.Get("user_id").(string))
+	if err != nil {
+		return res.ErrorJSON(c, http.StatusInternalServerError, "recovery_codes_failed", err.Error(), requestIDFromCtx(c), nil)
+	}
+	return res.JSON(c, http.StatusCreated, map[string][]string{"codes": codes})
+}
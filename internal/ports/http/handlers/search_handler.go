@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/example/user-service/internal/domain"
+	authmw "github.com/example/user-service/internal/ports/http/middleware"
+	"github.com/example/user-service/internal/service"
+	res "github.com/example/user-service/pkg/http"
+)
+
+type SearchHandler struct {
+	search service.SearchService
+}
+
+func NewSearchHandler(search service.SearchService) *SearchHandler {
+	return &SearchHandler{search: search}
+}
+
+func (h *SearchHandler) RegisterAdminRoutes(g *echo.Group, rbac *authmw.RBACMiddleware) {
+	g.GET("/users/search", h.Search, rbac.RequirePermission(domain.PermUsersRead))
+}
+
+// RegisterInternalRoutes registers the search for other services, which is
+// not tenant scoped.
+func (h *SearchHandler) RegisterInternalRoutes(g *echo.Group) {
+	g.GET("/users/search", h.Search)
+}
+
+// Search takes the term in q and an optional limit.
+func (h *SearchHandler) Search(c echo.Context) error {
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	hits, err := h.search.Search(c.Request().Context(), c.QueryParam("q"), limit)
+	if err != nil {
+		if errors.Is(err, service.ErrSearchTermInvalid) {
+			return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", err.Error(), requestIDFromCtx(c), nil)
+		}
+		return res.ErrorJSON(c, http.StatusInternalServerError, "search_failed", err.Error(), requestIDFromCtx(c), nil)
+	}
+	return res.JSON(c, http.StatusOK, map[string]interface{}{"users": hits})
+}
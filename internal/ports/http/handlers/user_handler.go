@@ -1,27 +1,70 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 
 	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/repo"
 	"github.com/example/user-service/internal/service"
 	res "github.com/example/user-service/pkg/http"
+	"github.com/example/user-service/pkg/patch"
 )
 
+// avatarFormOverhead allows for the multipart boundaries and headers around an
+// avatar of the maximum size.
+const avatarFormOverhead = 64 * 1024
+
 type UserHandler struct {
-	users service.UserService
+	users          service.UserService
+	accounts       service.AccountService
+	maxAvatarBytes int64
 }
 
-func NewUserHandler(users service.UserService) *UserHandler {
-	return &UserHandler{users: users}
+func NewUserHandler(users service.UserService, accounts service.AccountService, maxAvatarBytes int64) *UserHandler {
+	return &UserHandler{users: users, accounts: accounts, maxAvatarBytes: maxAvatarBytes}
 }
 
+// updateProfileRequest is a JSON Merge Patch (RFC 7396) of the profile.
 type updateProfileRequest struct {
-	DisplayName *string `json:"display_name"`
-	AvatarURL   *string `json:"avatar_url"`
+	DisplayName patch.Field[string]                 `json:"display_name"`
+	AvatarURL   patch.Field[string]                 `json:"avatar_url"`
+	FirstName   patch.Field[string]                 `json:"first_name"`
+	LastName    patch.Field[string]                 `json:"last_name"`
+	Locale      patch.Field[string]                 `json:"locale"`
+	Timezone    patch.Field[string]                 `json:"timezone"`
+	Bio         patch.Field[string]                 `json:"bio"`
+	Company     patch.Field[string]                 `json:"company"`
+	Attributes  patch.Field[map[string]interface{}] `json:"attributes"`
+	Visibility  patch.Field[map[string]*string]     `json:"visibility"`
+}
+
+func (r *updateProfileRequest) toUpdate() service.ProfileUpdate {
+	return service.ProfileUpdate{
+		DisplayName: r.DisplayName,
+		AvatarURL:   r.AvatarURL,
+		FirstName:   r.FirstName,
+		LastName:    r.LastName,
+		Locale:      r.Locale,
+		Timezone:    r.Timezone,
+		Bio:         r.Bio,
+		Company:     r.Company,
+		Attributes:  r.Attributes,
+		Visibility:  r.Visibility,
+	}
+}
+
+type changeUsernameRequest struct {
+	Username string `json:"username"`
 }
 
 type changeEmailStartRequest struct {
@@ -41,12 +84,27 @@ type attachIdentityRequest struct {
 	AvatarURL      *string `json:"avatar_url"`
 }
 
+type identityResponse struct {
+	ID             string                  `json:"id"`
+	Provider       domain.IdentityProvider `json:"provider"`
+	ProviderUserID string                  `json:"provider_user_id"`
+	Email          string                  `json:"email"`
+	DisplayName    *string                 `json:"display_name,omitempty"`
+	LinkedAt       time.Time               `json:"linked_at"`
+	LastUsedAt     *time.Time              `json:"last_used_at"`
+}
+
 func (h *UserHandler) RegisterRoutes(g *echo.Group) {
 	g.GET("/me", h.GetMe)
 	g.GET("/:id", h.GetByID)
+	g.GET("/by-username/:name", h.GetByUsername)
 	g.PATCH("/me", h.UpdateProfile)
+	g.DELETE("/me", h.DeleteMe)
+	g.PUT("/me/avatar", h.UploadAvatar)
+	g.PUT("/me/username", h.ChangeUsername)
 	g.POST("/me/change-email/start", h.StartChangeEmail)
 	g.POST("/me/change-email/verify", h.VerifyChangeEmail)
+	g.GET("/me/identities", h.ListIdentities)
 	g.POST("/me/identities", h.AttachIdentity)
 	g.DELETE("/me/identities/:provider/:provider_user_id", h.RemoveIdentity)
 }
@@ -57,9 +115,62 @@ func (h *UserHandler) GetMe(c echo.Context) error {
 	if err != nil {
 		return res.ErrorJSON(c, http.StatusNotFound, "not_found", "user not found", requestIDFromCtx(c), nil)
 	}
+	if notModified(c, user.Revision()) {
+		return c.NoContent(http.StatusNotModified)
+	}
 	return res.JSON(c, http.StatusOK, user)
 }
 
+func (h *UserHandler) GetByUsername(c echo.Context) error {
+	requester := c.Get("user_id").(string)
+	user, err := h.users.GetByUsername(c.Request().Context(), requester, c.Param("name"))
+	if err != nil {
+		return res.ErrorJSON(c, http.StatusNotFound, "not_found", "user not found", requestIDFromCtx(c), nil)
+	}
+	if notModified(c, user.Revision) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	return res.JSON(c, http.StatusOK, user)
+}
+
+func (h *UserHandler) ChangeUsername(c echo.Context) error {
+	req := new(changeUsernameRequest)
+	if err := c.Bind(req); err != nil {
+		return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", "invalid payload", requestIDFromCtx(c), nil)
+	}
+	userID := c.Get("user_id").(string)
+	user, err := h.users.ChangeUsername(c.Request().Context(), userID, req.Username)
+	if err != nil {
+		var tooSoon *service.UsernameChangeTooSoonError
+		switch {
+		case errors.Is(err, domain.ErrUsernameInvalid), errors.Is(err, domain.ErrUsernameReserved):
+			return res.ErrorJSON(c, http.StatusBadRequest, "invalid_username", err.Error(), requestIDFromCtx(c), nil)
+		case errors.Is(err, service.ErrUsernameTaken):
+			return res.ErrorJSON(c, http.StatusConflict, "username_taken", err.Error(), requestIDFromCtx(c), nil)
+		case errors.As(err, &tooSoon):
+			c.Response().Header().Set("Retry-After", strconv.Itoa(int(time.Until(tooSoon.RetryAt).Seconds())+1))
+			return res.ErrorJSON(c, http.StatusTooManyRequests, "username_change_too_soon", err.Error(), requestIDFromCtx(c), map[string]interface{}{
+				"retry_at": tooSoon.RetryAt,
+			})
+		case errors.Is(err, repo.ErrVersionConflict):
+			return versionConflictJSON(c)
+		}
+		return res.ErrorJSON(c, http.StatusInternalServerError, "update_failed", err.Error(), requestIDFromCtx(c), nil)
+	}
+	return res.JSON(c, http.StatusOK, user)
+}
+
+// DeleteMe schedules the caller's account for deletion. It can be restored by
+// signing in again until purge_at.
+func (h *UserHandler) DeleteMe(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+	user, err := h.accounts.RequestDeletion(c.Request().Context(), requestIDFromCtx(c), userID)
+	if err != nil {
+		return res.ErrorJSON(c, http.StatusInternalServerError, "delete_failed", err.Error(), requestIDFromCtx(c), nil)
+	}
+	return res.JSON(c, http.StatusAccepted, map[string]interface{}{"purge_at": user.PurgeAt})
+}
+
 func (h *UserHandler) GetByID(c echo.Context) error {
 	userID := c.Param("id")
 	requester := c.Get("user_id").(string)
@@ -67,18 +178,86 @@ func (h *UserHandler) GetByID(c echo.Context) error {
 	if err != nil {
 		return res.ErrorJSON(c, http.StatusNotFound, "not_found", "user not found", requestIDFromCtx(c), nil)
 	}
+	if notModified(c, user.Revision) {
+		return c.NoContent(http.StatusNotModified)
+	}
 	return res.JSON(c, http.StatusOK, user)
 }
 
+// UpdateProfile honours If-Match with the ETag of GET /users/me; without it
+// the update is applied to whatever is current.
 func (h *UserHandler) UpdateProfile(c echo.Context) error {
 	req := new(updateProfileRequest)
-	if err := c.Bind(req); err != nil {
+	if err := bindPatch(c, req); err != nil {
 		return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", "invalid payload", requestIDFromCtx(c), nil)
 	}
+	update := req.toUpdate()
+	revisions, ok := ifMatchRevisions(c.Request().Header.Get("If-Match"))
+	if !ok {
+		return res.ErrorJSON(c, http.StatusPreconditionFailed, "precondition_failed", service.ErrRevisionMismatch.Error(), requestIDFromCtx(c), nil)
+	}
+	update.Revisions = revisions
 	userID := c.Get("user_id").(string)
-	profile, err := h.users.UpdateProfile(c.Request().Context(), userID, req.DisplayName, req.AvatarURL)
+	profile, err := h.users.UpdateProfile(c.Request().Context(), userID, update)
 	if err != nil {
-		return res.ErrorJSON(c, http.StatusInternalServerError, "update_failed", err.Error(), requestIDFromCtx(c), nil)
+		return profileUpdateErrorJSON(c, err)
+	}
+	return res.JSON(c, http.StatusOK, profile)
+}
+
+// profileUpdateErrorJSON maps profile update failures shared by the
+// self-service and admin endpoints.
+func profileUpdateErrorJSON(c echo.Context, err error) error {
+	var validationErr *service.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return res.ErrorJSON(c, http.StatusUnprocessableEntity, "validation_failed", "profile is invalid", requestIDFromCtx(c), map[string]interface{}{
+			"fields": validationErr.Fields,
+		})
+	case errors.Is(err, service.ErrAvatarURLNotAllowed):
+		return res.ErrorJSON(c, http.StatusBadRequest, "invalid_avatar_url", err.Error(), requestIDFromCtx(c), nil)
+	case errors.Is(err, service.ErrRevisionMismatch):
+		return res.ErrorJSON(c, http.StatusPreconditionFailed, "precondition_failed", err.Error(), requestIDFromCtx(c), nil)
+	}
+	return res.ErrorJSON(c, http.StatusInternalServerError, "update_failed", err.Error(), requestIDFromCtx(c), nil)
+}
+
+func (h *UserHandler) UploadAvatar(c echo.Context) error {
+	if h.maxAvatarBytes > 0 {
+		// Cap the body before it is parsed so an oversized upload is never
+		// buffered in full.
+		c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, h.maxAvatarBytes+avatarFormOverhead)
+	}
+	file, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return res.ErrorJSON(c, http.StatusRequestEntityTooLarge, "avatar_too_large", service.ErrImageTooLarge.Error(), requestIDFromCtx(c), nil)
+		}
+		return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", "file is required", requestIDFromCtx(c), nil)
+	}
+	if h.maxAvatarBytes > 0 && file.Size > h.maxAvatarBytes {
+		return res.ErrorJSON(c, http.StatusRequestEntityTooLarge, "avatar_too_large", service.ErrImageTooLarge.Error(), requestIDFromCtx(c), nil)
+	}
+	src, err := file.Open()
+	if err != nil {
+		return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", "unreadable file", requestIDFromCtx(c), nil)
+	}
+	defer src.Close()
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return res.ErrorJSON(c, http.StatusBadRequest, "bad_request", "unreadable file", requestIDFromCtx(c), nil)
+	}
+	userID := c.Get("user_id").(string)
+	profile, err := h.users.UploadAvatar(c.Request().Context(), userID, data)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUnsupportedImage):
+			return res.ErrorJSON(c, http.StatusUnsupportedMediaType, "unsupported_image", err.Error(), requestIDFromCtx(c), nil)
+		case errors.Is(err, service.ErrImageTooLarge):
+			return res.ErrorJSON(c, http.StatusRequestEntityTooLarge, "avatar_too_large", err.Error(), requestIDFromCtx(c), nil)
+		}
+		return res.ErrorJSON(c, http.StatusInternalServerError, "upload_failed", err.Error(), requestIDFromCtx(c), nil)
 	}
 	return res.JSON(c, http.StatusOK, profile)
 }
@@ -104,6 +283,9 @@ func (h *UserHandler) VerifyChangeEmail(c echo.Context) error {
 	userID := c.Get("user_id").(string)
 	user, err := h.users.VerifyEmailChange(c.Request().Context(), userID, req.UUID, req.Code)
 	if err != nil {
+		if errors.Is(err, repo.ErrVersionConflict) {
+			return versionConflictJSON(c)
+		}
 		return res.ErrorJSON(c, http.StatusBadRequest, "change_email_failed", err.Error(), requestIDFromCtx(c), nil)
 	}
 	return res.JSON(c, http.StatusOK, user)
@@ -123,12 +305,91 @@ func (h *UserHandler) AttachIdentity(c echo.Context) error {
 	return res.JSON(c, http.StatusCreated, map[string]interface{}{"identity": identity, "profile": profile})
 }
 
+func (h *UserHandler) ListIdentities(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+	identities, err := h.users.ListIdentities(c.Request().Context(), userID)
+	if err != nil {
+		return res.ErrorJSON(c, http.StatusInternalServerError, "list_failed", err.Error(), requestIDFromCtx(c), nil)
+	}
+	out := make([]identityResponse, 0, len(identities))
+	for _, identity := range identities {
+		out = append(out, identityResponse{
+			ID:             identity.ID,
+			Provider:       identity.Provider,
+			ProviderUserID: identity.ProviderUserID,
+			Email:          identity.Email,
+			DisplayName:    identity.DisplayName,
+			LinkedAt:       identity.CreatedAt,
+			LastUsedAt:     identity.LastUsedAt,
+		})
+	}
+	return res.JSON(c, http.StatusOK, out)
+}
+
 func (h *UserHandler) RemoveIdentity(c echo.Context) error {
 	provider := domain.IdentityProvider(strings.ToLower(c.Param("provider")))
 	providerUserID := c.Param("provider_user_id")
 	userID := c.Get("user_id").(string)
 	if err := h.users.RemoveIdentity(c.Request().Context(), userID, provider, providerUserID); err != nil {
+		if errors.Is(err, service.ErrLastLoginMethod) {
+			return res.ErrorJSON(c, http.StatusConflict, "last_login_method", err.Error(), requestIDFromCtx(c), nil)
+		}
 		return res.ErrorJSON(c, http.StatusBadRequest, "detach_failed", err.Error(), requestIDFromCtx(c), nil)
 	}
 	return res.JSON(c, http.StatusOK, map[string]string{"status": "detached"})
 }
+
+// bindPatch decodes a merge patch document sent as application/json or as
+// application/merge-patch+json, which the default binder does not know.
+func bindPatch(c echo.Context, dst interface{}) error {
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	if mediaType != patch.MediaType {
+		return c.Bind(dst)
+	}
+	return json.NewDecoder(c.Request().Body).Decode(dst)
+}
+
+// versionConflictJSON answers a write that lost a race with a concurrent update
+// of the same user; the client may retry it.
+func versionConflictJSON(c echo.Context) error {
+	return res.ErrorJSON(c, http.StatusConflict, "conflict", "user was modified concurrently, retry the request", requestIDFromCtx(c), nil)
+}
+
+// notModified sets the ETag of a representation and reports whether the
+// request's If-None-Match already names it, so the handler can answer 304.
+func notModified(c echo.Context, revision string) bool {
+	etag := `"` + revision + `"`
+	c.Response().Header().Set("ETag", etag)
+	for _, tag := range entityTags(c.Request().Header.Get("If-None-Match")) {
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchRevisions returns the revisions an If-Match header accepts. An empty
+// header or "*" places no condition. ok is false when the header can never
+// match, as weak tags never do.
+func ifMatchRevisions(header string) (revisions []string, ok bool) {
+	tags := entityTags(header)
+	if len(tags) == 0 || slices.Contains(tags, "*") {
+		return nil, true
+	}
+	for _, tag := range tags {
+		if len(tag) >= 2 && strings.HasPrefix(tag, `"`) && strings.HasSuffix(tag, `"`) {
+			revisions = append(revisions, tag[1:len(tag)-1])
+		}
+	}
+	return revisions, len(revisions) > 0
+}
+
+func entityTags(header string) []string {
+	var tags []string
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
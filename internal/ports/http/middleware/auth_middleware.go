@@ -1,28 +1,38 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 
 	"github.com/example/user-service/config"
 	rbacclient "github.com/example/user-service/internal/ports/rbac"
+	"github.com/example/user-service/internal/tenant"
 	res "github.com/example/user-service/pkg/http"
 	pkglog "github.com/example/user-service/pkg/log"
 )
 
+// SessionValidator rejects tokens of suspended users and tokens issued before
+// a revocation.
+type SessionValidator interface {
+	ValidateSession(ctx context.Context, userID string, issuedAt time.Time) error
+}
+
 type AuthMiddleware struct {
-	cfg    *config.Config
-	logger pkglog.Logger
-	rbac   rbacclient.Client
-	hmac   []byte
-	key    interface{}
+	cfg      *config.Config
+	logger   pkglog.Logger
+	rbac     rbacclient.Client
+	sessions SessionValidator
+	hmac     []byte
+	key      interface{}
 }
 
-func NewAuthMiddleware(cfg *config.Config, logger pkglog.Logger, rbac rbacclient.Client) *AuthMiddleware {
-	mw := &AuthMiddleware{cfg: cfg, logger: logger, rbac: rbac}
+func NewAuthMiddleware(cfg *config.Config, logger pkglog.Logger, rbac rbacclient.Client, sessions SessionValidator) *AuthMiddleware {
+	mw := &AuthMiddleware{cfg: cfg, logger: logger, rbac: rbac, sessions: sessions}
 	if cfg.JWTSecret != "" {
 		mw.hmac = []byte(cfg.JWTSecret)
 	}
@@ -57,7 +67,24 @@ func (a *AuthMiddleware) Handler(next echo.HandlerFunc) echo.HandlerFunc {
 		if subject == "" {
 			return res.ErrorJSON(c, http.StatusUnauthorized, "unauthorized", "invalid subject", requestIDFromCtx(c), nil)
 		}
+		// The token names the tenant of its user. A tenant named by the request
+		// must agree with it.
+		claimedOrg, _ := claims["org_id"].(string)
+		if requested, ok := c.Get("tenant_id").(string); ok && requested != claimedOrg {
+			return res.ErrorJSON(c, http.StatusForbidden, "tenant_mismatch", "token belongs to another tenant", requestIDFromCtx(c), nil)
+		}
+		c.SetRequest(c.Request().WithContext(tenant.With(c.Request().Context(), claimedOrg)))
+		var issuedAt time.Time
+		if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+			issuedAt = iat.Time
+		}
+		if a.sessions != nil {
+			if err := a.sessions.ValidateSession(c.Request().Context(), subject, issuedAt); err != nil {
+				return res.ErrorJSON(c, http.StatusUnauthorized, "unauthorized", "session no longer valid", requestIDFromCtx(c), nil)
+			}
+		}
 		c.Set("user_id", subject)
+		c.Set("issued_at", issuedAt)
 		if a.rbac != nil {
 			if role, err := a.rbac.GetRoleByUserID(c.Request().Context(), subject); err == nil {
 				c.Set("role", role)
@@ -70,6 +97,20 @@ func (a *AuthMiddleware) Handler(next echo.HandlerFunc) echo.HandlerFunc {
 	}
 }
 
+// RequireRecentSignIn guards changes that would let a stolen session weaken
+// the account, such as turning the second factor off. Access tokens are only
+// issued by a complete sign-in, so their issue time is when the user last
+// authenticated; older tokens get 401 reauthentication_required.
+func (a *AuthMiddleware) RequireRecentSignIn(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		issuedAt, _ := c.Get("issued_at").(time.Time)
+		if issuedAt.IsZero() || time.Since(issuedAt) > a.cfg.ReauthMaxAge {
+			return res.ErrorJSON(c, http.StatusUnauthorized, "reauthentication_required", "sign in again to make this change", requestIDFromCtx(c), nil)
+		}
+		return next(c)
+	}
+}
+
 func (a *AuthMiddleware) keyFunc(token *jwt.Token) (interface{}, error) {
 	if a.hmac != nil {
 		return a.hmac, nil
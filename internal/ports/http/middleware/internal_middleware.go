@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/example/user-service/config"
+	res "github.com/example/user-service/pkg/http"
+)
+
+// InternalHeader carries the shared token of service-to-service calls.
+const InternalHeader = "X-Internal-Token"
+
+// InternalMiddleware admits other services holding one of the configured
+// internal API tokens. Internal calls act for no user and no tenant.
+type InternalMiddleware struct {
+	tokens [][]byte
+}
+
+func NewInternalMiddleware(cfg *config.Config) *InternalMiddleware {
+	m := &InternalMiddleware{}
+	for _, token := range cfg.InternalAPITokens {
+		if token != "" {
+			m.tokens = append(m.tokens, []byte(token))
+		}
+	}
+	return m
+}
+
+func (m *InternalMiddleware) Handler(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		presented := []byte(c.Request().Header.Get(InternalHeader))
+		if len(presented) == 0 {
+			return res.ErrorJSON(c, http.StatusUnauthorized, "unauthorized", "missing internal token", requestIDFromCtx(c), nil)
+		}
+		for _, token := range m.tokens {
+			if subtle.ConstantTimeCompare(presented, token) == 1 {
+				return next(c)
+			}
+		}
+		return res.ErrorJSON(c, http.StatusUnauthorized, "unauthorized", "invalid internal token", requestIDFromCtx(c), nil)
+	}
+}
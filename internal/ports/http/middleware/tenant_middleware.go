@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/example/user-service/config"
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/service"
+	"github.com/example/user-service/internal/tenant"
+	res "github.com/example/user-service/pkg/http"
+)
+
+// TenantHeader names the organization, by ID or slug, a request acts in.
+const TenantHeader = "X-Tenant"
+
+// TenantResolver finds an organization by ID or slug and returns
+// service.ErrOrgNotFound for unknown references.
+type TenantResolver interface {
+	Resolve(ctx context.Context, ref string) (*domain.Organization, error)
+}
+
+// TenantMiddleware scopes the request context to a tenant. The tenant is taken
+// from TenantHeader, then from the host; requests naming neither act in the
+// default tenant. AuthMiddleware later checks it against the token.
+type TenantMiddleware struct {
+	cfg      *config.Config
+	resolver TenantResolver
+}
+
+func NewTenantMiddleware(cfg *config.Config, resolver TenantResolver) *TenantMiddleware {
+	return &TenantMiddleware{cfg: cfg, resolver: resolver}
+}
+
+func (m *TenantMiddleware) Handler(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+		ref := strings.TrimSpace(c.Request().Header.Get(TenantHeader))
+		if ref == "" {
+			ref = m.slugFromHost(c.Request().Host)
+		}
+		orgID := tenant.Default
+		if ref != "" {
+			org, err := m.resolver.Resolve(ctx, ref)
+			if errors.Is(err, service.ErrOrgNotFound) {
+				return res.ErrorJSON(c, http.StatusNotFound, "tenant_not_found", "unknown tenant", requestIDFromCtx(c), nil)
+			}
+			if err != nil {
+				return res.ErrorJSON(c, http.StatusInternalServerError, "internal_error", "tenant lookup failed", requestIDFromCtx(c), nil)
+			}
+			orgID = org.ID
+			c.Set("tenant_id", orgID)
+		}
+		c.SetRequest(c.Request().WithContext(tenant.With(ctx, orgID)))
+		return next(c)
+	}
+}
+
+// slugFromHost returns the first label of hosts directly below the tenant
+// base domain.
+func (m *TenantMiddleware) slugFromHost(host string) string {
+	if m.cfg.TenantBaseDomain == "" {
+		return ""
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	slug, ok := strings.CutSuffix(host, "."+strings.ToLower(m.cfg.TenantBaseDomain))
+	if !ok || slug == "" || strings.Contains(slug, ".") {
+		return ""
+	}
+	return slug
+}
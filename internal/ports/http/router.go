@@ -13,15 +13,27 @@ import (
 )
 
 type Router struct {
-	cfg         *config.Config
-	authHandler *handlers.AuthHandler
-	userHandler *handlers.UserHandler
-	authMW      *authmw.AuthMiddleware
-	rbacMW      *authmw.RBACMiddleware
+	cfg             *config.Config
+	authHandler     *handlers.AuthHandler
+	userHandler     *handlers.UserHandler
+	adminHandler    *handlers.AdminHandler
+	exportHandler   *handlers.ExportHandler
+	orgHandler      *handlers.OrganizationHandler
+	groupHandler    *handlers.GroupHandler
+	inviteHandler   *handlers.InvitationHandler
+	prefHandler     *handlers.PreferenceHandler
+	searchHandler   *handlers.SearchHandler
+	emailHandler    *handlers.EmailHandler
+	phoneHandler    *handlers.PhoneHandler
+	recoveryHandler *handlers.RecoveryCodeHandler
+	tenantMW        *authmw.TenantMiddleware
+	internalMW      *authmw.InternalMiddleware
+	authMW          *authmw.AuthMiddleware
+	rbacMW          *authmw.RBACMiddleware
 }
 
-func NewRouter(cfg *config.Config, authHandler *handlers.AuthHandler, userHandler *handlers.UserHandler, authMW *authmw.AuthMiddleware, rbacMW *authmw.RBACMiddleware) *Router {
-	return &Router{cfg: cfg, authHandler: authHandler, userHandler: userHandler, authMW: authMW, rbacMW: rbacMW}
+func NewRouter(cfg *config.Config, authHandler *handlers.AuthHandler, userHandler *handlers.UserHandler, adminHandler *handlers.AdminHandler, exportHandler *handlers.ExportHandler, orgHandler *handlers.OrganizationHandler, groupHandler *handlers.GroupHandler, inviteHandler *handlers.InvitationHandler, prefHandler *handlers.PreferenceHandler, searchHandler *handlers.SearchHandler, emailHandler *handlers.EmailHandler, phoneHandler *handlers.PhoneHandler, recoveryHandler *handlers.RecoveryCodeHandler, tenantMW *authmw.TenantMiddleware, internalMW *authmw.InternalMiddleware, authMW *authmw.AuthMiddleware, rbacMW *authmw.RBACMiddleware) *Router {
+	return &Router{cfg: cfg, authHandler: authHandler, userHandler: userHandler, adminHandler: adminHandler, exportHandler: exportHandler, orgHandler: orgHandler, groupHandler: groupHandler, inviteHandler: inviteHandler, prefHandler: prefHandler, searchHandler: searchHandler, emailHandler: emailHandler, phoneHandler: phoneHandler, recoveryHandler: recoveryHandler, tenantMW: tenantMW, internalMW: internalMW, authMW: authMW, rbacMW: rbacMW}
 }
 
 func (r *Router) Setup(e *echo.Echo) {
@@ -30,20 +42,46 @@ func (r *Router) Setup(e *echo.Echo) {
 	e.Use(middleware.RequestID())
 	e.Use(middleware.Logger())
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowOrigins: []string{r.cfg.CORSAllowOrigins},
-		AllowHeaders: []string{echo.HeaderAuthorization, echo.HeaderContentType, echo.HeaderXRequestedWith},
-		AllowMethods: []string{http.MethodGet, http.MethodPost, http.MethodPatch, http.MethodDelete, http.MethodOptions},
+		AllowOrigins:  []string{r.cfg.CORSAllowOrigins},
+		AllowHeaders:  []string{echo.HeaderAuthorization, echo.HeaderContentType, echo.HeaderXRequestedWith, authmw.TenantHeader, "If-Match", "If-None-Match"},
+		AllowMethods:  []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions},
+		ExposeHeaders: []string{"ETag"},
 	}))
 	e.GET("/health", func(c echo.Context) error {
 		return res.JSON(c, http.StatusOK, map[string]string{"status": "ok"})
 	})
 
-	authGroup := e.Group("/auth")
+	authGroup := e.Group("/auth", r.tenantMW.Handler)
 	r.authHandler.RegisterRoutes(authGroup)
 
-	userGroup := e.Group("/users", r.authMW.Handler)
+	userGroup := e.Group("/users", r.tenantMW.Handler, r.authMW.Handler)
 	r.userHandler.RegisterRoutes(userGroup)
+	r.exportHandler.RegisterUserRoutes(userGroup)
+	r.groupHandler.RegisterUserRoutes(userGroup)
+	r.prefHandler.RegisterUserRoutes(userGroup)
+	r.emailHandler.RegisterUserRoutes(userGroup)
+	r.phoneHandler.RegisterUserRoutes(userGroup, r.authMW)
+	r.recoveryHandler.RegisterUserRoutes(userGroup, r.authMW)
 
-	adminGroup := e.Group("/admin/users", r.authMW.Handler, r.rbacMW.RequireRole("moderator"))
-	adminGroup.GET("", r.userHandler.GetByID)
+	// Download links are signed for one user and are not tenant scoped.
+	exportGroup := e.Group("/exports")
+	r.exportHandler.RegisterRoutes(exportGroup)
+
+	adminGroup := e.Group("/admin", r.tenantMW.Handler, r.authMW.Handler)
+	r.adminHandler.RegisterRoutes(adminGroup, r.rbacMW)
+	r.exportHandler.RegisterAdminRoutes(adminGroup, r.rbacMW)
+	r.groupHandler.RegisterAdminRoutes(adminGroup, r.rbacMW)
+	r.inviteHandler.RegisterAdminRoutes(adminGroup, r.rbacMW)
+	r.searchHandler.RegisterAdminRoutes(adminGroup, r.rbacMW)
+
+	orgGroup := e.Group("/orgs", r.tenantMW.Handler, r.authMW.Handler)
+	r.orgHandler.RegisterRoutes(orgGroup, r.rbacMW)
+
+	groupGroup := e.Group("/groups", r.tenantMW.Handler, r.authMW.Handler)
+	r.groupHandler.RegisterRoutes(groupGroup)
+
+	// Service-to-service routes read across tenants.
+	internalGroup := e.Group("/internal", r.internalMW.Handler)
+	r.prefHandler.RegisterInternalRoutes(internalGroup)
+	r.searchHandler.RegisterInternalRoutes(internalGroup)
 }
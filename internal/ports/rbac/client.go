@@ -19,6 +19,7 @@ type Client interface {
 	CheckPermission(ctx context.Context, userID, permission string) (bool, error)
 	CheckRole(ctx context.Context, userID, role string) (bool, error)
 	AssignRole(ctx context.Context, userID, role string) error
+	RevokeRoles(ctx context.Context, userID string) error
 }
 
 type httpClient struct {
@@ -96,6 +97,15 @@ func (c *httpClient) AssignRole(ctx context.Context, userID, role string) error
 	return c.post(ctx, "/assign_role", payload)
 }
 
+func (c *httpClient) RevokeRoles(ctx context.Context, userID string) error {
+	payload := map[string]interface{}{
+		"value": map[string]string{
+			"user_id": userID,
+		},
+	}
+	return c.post(ctx, "/revoke_roles", payload)
+}
+
 func (c *httpClient) get(ctx context.Context, path string, params url.Values, out interface{}) error {
 	op := func() error {
 		endpoint := fmt.Sprintf("%s%s?%s", c.baseURL, path, params.Encode())
@@ -223,3 +233,7 @@ func (c *cachingClient) CheckRole(ctx context.Context, userID, role string) (boo
 func (c *cachingClient) AssignRole(ctx context.Context, userID, role string) error {
 	return c.delegate.AssignRole(ctx, userID, role)
 }
+
+func (c *cachingClient) RevokeRoles(ctx context.Context, userID string) error {
+	return c.delegate.RevokeRoles(ctx, userID)
+}
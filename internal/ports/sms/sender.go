@@ -0,0 +1,55 @@
+// Package sms holds development stand-ins for an SMS gateway. They satisfy
+// service.SMSSender without delivering anything.
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	pkglog "github.com/example/user-service/pkg/log"
+)
+
+// LogSender writes every message to the service log.
+type LogSender struct {
+	logger pkglog.Logger
+}
+
+func NewLogSender(logger pkglog.Logger) *LogSender {
+	return &LogSender{logger: logger}
+}
+
+func (s *LogSender) Send(ctx context.Context, phone, message string) error {
+	s.logger.Info().Str("phone", phone).Str("sms", message).Msg("sms not delivered, logged instead")
+	return nil
+}
+
+// FileSender appends every message to a JSON lines file.
+type FileSender struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileSender(path string) *FileSender {
+	return &FileSender{path: path}
+}
+
+func (s *FileSender) Send(ctx context.Context, phone, message string) error {
+	line, err := json.Marshal(map[string]interface{}{"to": phone, "message": message, "sent_at": time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
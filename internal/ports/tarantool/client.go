@@ -16,6 +16,8 @@ type Client interface {
 	VerifyRegistration(ctx context.Context, uuid, code string) (*VerificationResult, error)
 	StartEmailChange(ctx context.Context, userID, email string) (string, error)
 	VerifyEmailChange(ctx context.Context, uuid, code string) (*VerificationResult, error)
+	StartAccountLink(ctx context.Context, userID, email string) (string, error)
+	VerifyAccountLink(ctx context.Context, uuid, code string) (*VerificationResult, error)
 }
 
 type VerificationResult struct {
@@ -80,6 +82,26 @@ func (c *httpClient) VerifyEmailChange(ctx context.Context, uuid, code string) (
 	return &VerificationResult{Email: resp.Email}, nil
 }
 
+func (c *httpClient) StartAccountLink(ctx context.Context, userID, email string) (string, error) {
+	payload := map[string]interface{}{"value": map[string]string{"user_id": userID, "email": email}}
+	var resp response
+	if err := c.postWithRetry(ctx, "/start-account-link", payload, &resp); err != nil {
+		return "", err
+	}
+	return resp.UUID, nil
+}
+
+func (c *httpClient) VerifyAccountLink(ctx context.Context, uuid, code string) (*VerificationResult, error) {
+	payload := map[string]interface{}{"value": map[string]string{"uuid": uuid, "code": code}}
+	var resp struct {
+		Email string `json:"email"`
+	}
+	if err := c.postWithRetry(ctx, "/verify-account-link", payload, &resp); err != nil {
+		return nil, err
+	}
+	return &VerificationResult{Email: resp.Email}, nil
+}
+
 func (c *httpClient) postWithRetry(ctx context.Context, path string, payload interface{}, out interface{}) error {
 	op := func() error {
 		reqBody, err := json.Marshal(payload)
@@ -0,0 +1,61 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/user-service/internal/domain"
+)
+
+type AccountLinkRepository interface {
+	Create(ctx context.Context, link *domain.AccountLink) error
+	FindByID(ctx context.Context, id string) (*domain.AccountLink, error)
+	// ClaimAttempt counts one attempt at the link and reports false, without
+	// counting, once max attempts were made or the link was consumed.
+	ClaimAttempt(ctx context.Context, id string, max int) (bool, error)
+	// Consume marks the link as used. It returns gorm.ErrRecordNotFound when
+	// the link was already consumed by a concurrent request.
+	Consume(ctx context.Context, id string, at time.Time) error
+}
+
+type gormAccountLinkRepository struct {
+	db *gorm.DB
+}
+
+func NewAccountLinkRepository(db *gorm.DB) AccountLinkRepository {
+	return &gormAccountLinkRepository{db: db}
+}
+
+func (r *gormAccountLinkRepository) Create(ctx context.Context, link *domain.AccountLink) error {
+	return r.db.WithContext(ctx).Create(link).Error
+}
+
+func (r *gormAccountLinkRepository) FindByID(ctx context.Context, id string) (*domain.AccountLink, error) {
+	var link domain.AccountLink
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&link).Error; err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (r *gormAccountLinkRepository) ClaimAttempt(ctx context.Context, id string, max int) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&domain.AccountLink{}).
+		Where("id = ? AND attempts < ? AND consumed_at IS NULL", id, max).
+		UpdateColumn("attempts", gorm.Expr("attempts + 1"))
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *gormAccountLinkRepository) Consume(ctx context.Context, id string, at time.Time) error {
+	result := r.db.WithContext(ctx).Model(&domain.AccountLink{}).
+		Where("id = ? AND consumed_at IS NULL", id).
+		UpdateColumn("consumed_at", at)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package repo
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"github.com/example/user-service/internal/domain"
+)
+
+type AuditRepository interface {
+	Record(ctx context.Context, event *domain.AuditEvent) error
+	ListByUserID(ctx context.Context, userID string) ([]domain.AuditEvent, error)
+}
+
+type gormAuditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &gormAuditRepository{db: db}
+}
+
+func (r *gormAuditRepository) Record(ctx context.Context, event *domain.AuditEvent) error {
+	return r.db.WithContext(ctx).Create(event).Error
+}
+
+func (r *gormAuditRepository) ListByUserID(ctx context.Context, userID string) ([]domain.AuditEvent, error) {
+	var events []domain.AuditEvent
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at ASC").Find(&events).Error
+	return events, err
+}
@@ -0,0 +1,23 @@
+package repo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursor := encodeCursor("2024-01-02T03:04:05.123456Z", "0b7c2f8e-1111-4c3e-9b9a-2a1d5f0e9c11")
+
+	decoded, err := decodeCursor(cursor)
+	require.NoError(t, err)
+	require.Equal(t, "2024-01-02T03:04:05.123456Z", decoded.Value)
+	require.Equal(t, "0b7c2f8e-1111-4c3e-9b9a-2a1d5f0e9c11", decoded.ID)
+}
+
+func TestDecodeCursorRejectsGarbage(t *testing.T) {
+	for _, cursor := range []string{"not base64!", "e30", "bnVsbA"} {
+		_, err := decodeCursor(cursor)
+		require.ErrorIs(t, err, ErrInvalidCursor, cursor)
+	}
+}
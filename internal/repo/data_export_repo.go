@@ -0,0 +1,69 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/user-service/internal/domain"
+)
+
+type DataExportRepository interface {
+	Create(ctx context.Context, export *domain.DataExport) error
+	Update(ctx context.Context, export *domain.DataExport) error
+	FindByID(ctx context.Context, id string) (*domain.DataExport, error)
+	// ListPending lists pending exports and running ones claimed before
+	// staleBefore, whose worker is presumed gone.
+	ListPending(ctx context.Context, staleBefore time.Time, limit int) ([]domain.DataExport, error)
+	// Claim moves a pending or stale running export to running as of now. It
+	// returns false when another worker claimed it first.
+	Claim(ctx context.Context, id string, now, staleBefore time.Time) (bool, error)
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+type gormDataExportRepository struct {
+	db *gorm.DB
+}
+
+func NewDataExportRepository(db *gorm.DB) DataExportRepository {
+	return &gormDataExportRepository{db: db}
+}
+
+func (r *gormDataExportRepository) Create(ctx context.Context, export *domain.DataExport) error {
+	return r.db.WithContext(ctx).Create(export).Error
+}
+
+func (r *gormDataExportRepository) Update(ctx context.Context, export *domain.DataExport) error {
+	return r.db.WithContext(ctx).Save(export).Error
+}
+
+func (r *gormDataExportRepository) FindByID(ctx context.Context, id string) (*domain.DataExport, error) {
+	var export domain.DataExport
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&export).Error; err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+func (r *gormDataExportRepository) ListPending(ctx context.Context, staleBefore time.Time, limit int) ([]domain.DataExport, error) {
+	var exports []domain.DataExport
+	err := r.db.WithContext(ctx).Omit("archive").
+		Where("status = ? OR (status = ? AND claimed_at < ?)", domain.ExportPending, domain.ExportRunning, staleBefore).
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&exports).Error
+	return exports, err
+}
+
+func (r *gormDataExportRepository) Claim(ctx context.Context, id string, now, staleBefore time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&domain.DataExport{}).
+		Where("id = ? AND (status = ? OR (status = ? AND claimed_at < ?))", id, domain.ExportPending, domain.ExportRunning, staleBefore).
+		UpdateColumns(map[string]interface{}{"status": domain.ExportRunning, "claimed_at": now})
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *gormDataExportRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("expires_at IS NOT NULL AND expires_at <= ?", now).Delete(&domain.DataExport{})
+	return result.RowsAffected, result.Error
+}
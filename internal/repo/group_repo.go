@@ -0,0 +1,273 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/tenant"
+)
+
+var (
+	// ErrGroupCycle is returned when nesting would make a group its own subgroup.
+	ErrGroupCycle = errors.New("group nesting would create a cycle")
+	// ErrGroupLastOwner is returned when a change would leave a group without
+	// an owner.
+	ErrGroupLastOwner = errors.New("group would lose its last owner")
+)
+
+type GroupRepository interface {
+	// Create stores the group in the tenant of ctx together with its first
+	// owner.
+	Create(ctx context.Context, group *domain.Group, owner *domain.GroupMember) error
+	Update(ctx context.Context, group *domain.Group) error
+	Delete(ctx context.Context, id string) error
+	FindByID(ctx context.Context, id string) (*domain.Group, error)
+	FindMember(ctx context.Context, groupID, userID string) (*domain.GroupMember, error)
+	// SaveMember adds a direct member or changes its role. created reports
+	// whether the user was not a direct member before. Demoting the last
+	// owner fails with ErrGroupLastOwner.
+	SaveMember(ctx context.Context, member *domain.GroupMember) (created bool, err error)
+	// DeleteMember returns gorm.ErrRecordNotFound for users that are not
+	// direct members and ErrGroupLastOwner for the last owner.
+	DeleteMember(ctx context.Context, groupID, userID string) error
+	// ListMembers pages through the direct members, or with effective through
+	// every user of the group and its nested subgroups.
+	ListMembers(ctx context.Context, groupID string, effective bool, offset, limit int) ([]GroupMemberEntry, error)
+	// ListUserGroups pages through the groups userID is a direct member of,
+	// or with effective also the groups those are nested in.
+	ListUserGroups(ctx context.Context, userID string, effective bool, offset, limit int) ([]domain.Group, error)
+	// Nest makes childID a subgroup of parentID and returns ErrGroupCycle when
+	// parentID is already nested in childID.
+	Nest(ctx context.Context, parentID, childID string) error
+	// Unnest returns gorm.ErrRecordNotFound when childID is not a direct
+	// subgroup of parentID.
+	Unnest(ctx context.Context, parentID, childID string) error
+	ListSubgroups(ctx context.Context, groupID string) ([]domain.Group, error)
+}
+
+// GroupMemberEntry is a user of a group. Inherited members belong to a nested
+// subgroup only and have no role in this group.
+type GroupMemberEntry struct {
+	UserID    string           `json:"user_id"`
+	Email     string           `json:"email"`
+	Username  *string          `json:"username,omitempty"`
+	Role      domain.GroupRole `json:"role,omitempty"`
+	Inherited bool             `json:"inherited"`
+	CreatedAt time.Time        `json:"created_at"`
+	// Visibility is the member's profile visibility, which decides whether
+	// Email is shown to other users.
+	Visibility domain.JSONMap `json:"-"`
+}
+
+type gormGroupRepository struct {
+	db *gorm.DB
+}
+
+func NewGroupRepository(db *gorm.DB) GroupRepository {
+	return &gormGroupRepository{db: db}
+}
+
+func (r *gormGroupRepository) Create(ctx context.Context, group *domain.Group, owner *domain.GroupMember) error {
+	if orgID, scoped := tenant.From(ctx); scoped && orgID != tenant.Default && group.OrgID == nil {
+		group.OrgID = &orgID
+	}
+	return inTenant(ctx, r.db, func(tx *gorm.DB) error {
+		return tx.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(group).Error; err != nil {
+				return err
+			}
+			owner.GroupID = group.ID
+			return tx.Create(owner).Error
+		})
+	})
+}
+
+func (r *gormGroupRepository) Update(ctx context.Context, group *domain.Group) error {
+	return inTenant(ctx, r.db, func(tx *gorm.DB) error {
+		return tx.Save(group).Error
+	})
+}
+
+func (r *gormGroupRepository) Delete(ctx context.Context, id string) error {
+	return inTenant(ctx, r.db, func(tx *gorm.DB) error {
+		return tx.Scopes(tenantScope(ctx, "org_id")).Delete(&domain.Group{}, "id = ?", id).Error
+	})
+}
+
+func (r *gormGroupRepository) FindByID(ctx context.Context, id string) (*domain.Group, error) {
+	var group domain.Group
+	err := inTenant(ctx, r.db, func(tx *gorm.DB) error {
+		return tx.Scopes(tenantScope(ctx, "org_id")).Where("id = ?", id).First(&group).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func (r *gormGroupRepository) FindMember(ctx context.Context, groupID, userID string) (*domain.GroupMember, error) {
+	var member domain.GroupMember
+	if err := r.db.WithContext(ctx).Where("group_id = ? AND user_id = ?", groupID, userID).First(&member).Error; err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+func (r *gormGroupRepository) SaveMember(ctx context.Context, member *domain.GroupMember) (bool, error) {
+	var created bool
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if member.Role != domain.GroupRoleOwner {
+			if err := keepOwner(tx, member.GroupID, member.UserID); err != nil {
+				return err
+			}
+		}
+		var existing domain.GroupMember
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("group_id = ? AND user_id = ?", member.GroupID, member.UserID).
+			First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			created = true
+			return tx.Create(member).Error
+		}
+		if err != nil {
+			return err
+		}
+		member.CreatedAt = existing.CreatedAt
+		return tx.Model(&existing).Update("role", member.Role).Error
+	})
+	return created, err
+}
+
+func (r *gormGroupRepository) DeleteMember(ctx context.Context, groupID, userID string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := keepOwner(tx, groupID, userID); err != nil {
+			return err
+		}
+		result := tx.Delete(&domain.GroupMember{}, "group_id = ? AND user_id = ?", groupID, userID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// keepOwner fails with ErrGroupLastOwner when userID is the only owner of the
+// group. The owner rows stay locked until tx ends, so two owners demoting or
+// removing each other are serialised and the second sees the first's change.
+func keepOwner(tx *gorm.DB, groupID, userID string) error {
+	var owners []string
+	err := tx.Model(&domain.GroupMember{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("group_id = ? AND role = ?", groupID, domain.GroupRoleOwner).
+		Order("user_id").Pluck("user_id", &owners).Error
+	if err != nil {
+		return err
+	}
+	if len(owners) == 1 && owners[0] == userID {
+		return ErrGroupLastOwner
+	}
+	return nil
+}
+
+// subtreeCTE lists a group and every group nested in it, directly or not.
+// UNION drops repeated rows, which also ends the recursion on cycles.
+const subtreeCTE = `WITH RECURSIVE subtree(id) AS (
+	SELECT CAST(? AS uuid)
+	UNION
+	SELECT n.child_id FROM group_nesting n JOIN subtree s ON n.parent_id = s.id
+)`
+
+func (r *gormGroupRepository) ListMembers(ctx context.Context, groupID string, effective bool, offset, limit int) ([]GroupMemberEntry, error) {
+	var members []GroupMemberEntry
+	err := inTenant(ctx, r.db, func(tx *gorm.DB) error {
+		if !effective {
+			return tx.Table("group_member m").
+				Select(`u.id AS user_id, u.email, u.username, m.role, false AS inherited, m.created_at, p.visibility`).
+				Joins(`JOIN "user" u ON u.id = m.user_id`).
+				Joins(`LEFT JOIN user_profile p ON p.user_id = u.id`).
+				Where("m.group_id = ?", groupID).
+				Order("m.created_at ASC, u.id ASC").
+				Offset(offset).
+				Limit(limit).
+				Scan(&members).Error
+		}
+		return tx.Raw(subtreeCTE+`
+			SELECT u.id AS user_id, u.email, u.username, d.role, d.user_id IS NULL AS inherited, u.created_at, p.visibility
+			FROM "user" u
+			LEFT JOIN group_member d ON d.group_id = ? AND d.user_id = u.id
+			LEFT JOIN user_profile p ON p.user_id = u.id
+			WHERE u.id IN (SELECT gm.user_id FROM group_member gm JOIN subtree s ON gm.group_id = s.id)
+			ORDER BY u.created_at ASC, u.id ASC
+			OFFSET ? LIMIT ?`, groupID, groupID, offset, limit).
+			Scan(&members).Error
+	})
+	return members, err
+}
+
+func (r *gormGroupRepository) ListUserGroups(ctx context.Context, userID string, effective bool, offset, limit int) ([]domain.Group, error) {
+	var groups []domain.Group
+	err := inTenant(ctx, r.db, func(tx *gorm.DB) error {
+		query := tx.Model(&domain.Group{}).Scopes(tenantScope(ctx, "org_id"))
+		if effective {
+			query = query.Where(`id IN (WITH RECURSIVE ancestors(id) AS (
+				SELECT group_id FROM group_member WHERE user_id = ?
+				UNION
+				SELECT n.parent_id FROM group_nesting n JOIN ancestors a ON n.child_id = a.id
+			) SELECT id FROM ancestors)`, userID)
+		} else {
+			query = query.Where("id IN (SELECT group_id FROM group_member WHERE user_id = ?)", userID)
+		}
+		return query.Order("name ASC, id ASC").Offset(offset).Limit(limit).Find(&groups).Error
+	})
+	return groups, err
+}
+
+func (r *gormGroupRepository) Nest(ctx context.Context, parentID, childID string) error {
+	if parentID == childID {
+		return ErrGroupCycle
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Serialize nesting so that two concurrent calls cannot each pass the
+		// cycle check and together form a cycle.
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext('group_nesting'))").Error; err != nil {
+			return err
+		}
+		var cycle bool
+		if err := tx.Raw(subtreeCTE+` SELECT EXISTS (SELECT 1 FROM subtree WHERE id = ?)`, childID, parentID).Scan(&cycle).Error; err != nil {
+			return err
+		}
+		if cycle {
+			return ErrGroupCycle
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&domain.GroupNesting{ParentID: parentID, ChildID: childID}).Error
+	})
+}
+
+func (r *gormGroupRepository) Unnest(ctx context.Context, parentID, childID string) error {
+	result := r.db.WithContext(ctx).Delete(&domain.GroupNesting{}, "parent_id = ? AND child_id = ?", parentID, childID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *gormGroupRepository) ListSubgroups(ctx context.Context, groupID string) ([]domain.Group, error) {
+	var groups []domain.Group
+	err := inTenant(ctx, r.db, func(tx *gorm.DB) error {
+		return tx.Scopes(tenantScope(ctx, "org_id")).
+			Where("id IN (SELECT child_id FROM group_nesting WHERE parent_id = ?)", groupID).
+			Order("name ASC, id ASC").
+			Find(&groups).Error
+	})
+	return groups, err
+}
@@ -0,0 +1,117 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/user-service/internal/domain"
+)
+
+// ErrInvitationClosed is returned by AcceptNewUser when the invitation was
+// accepted or revoked in the meantime.
+var ErrInvitationClosed = errors.New("invitation already accepted or revoked")
+
+type InvitationRepository interface {
+	Create(ctx context.Context, invitation *domain.Invitation) error
+	Update(ctx context.Context, invitation *domain.Invitation) error
+	// FindByID is not tenant scoped: accepting an invitation happens before
+	// the invitee belongs to its tenant.
+	FindByID(ctx context.Context, id string) (*domain.Invitation, error)
+	// FindPending returns the open, unexpired invitation for email in the
+	// tenant of ctx.
+	FindPending(ctx context.Context, email string, now time.Time) (*domain.Invitation, error)
+	// List pages through the invitations of the tenant of ctx, newest first,
+	// optionally only those with status.
+	List(ctx context.Context, status domain.InvitationStatus, now time.Time, offset, limit int) ([]domain.Invitation, error)
+	// AcceptNewUser consumes the open invitation id and creates user, with
+	// its Profile and identities, in the tenant of ctx, all in one
+	// transaction. grant runs before the commit, so a failure there leaves
+	// neither an account nor a consumed invitation behind. Nothing is created
+	// when the invitation is no longer open (ErrInvitationClosed).
+	AcceptNewUser(ctx context.Context, id string, user *domain.User, identities []domain.UserIdentity, now time.Time, grant func(userID string) error) error
+}
+
+type gormInvitationRepository struct {
+	db *gorm.DB
+}
+
+func NewInvitationRepository(db *gorm.DB) InvitationRepository {
+	return &gormInvitationRepository{db: db}
+}
+
+func (r *gormInvitationRepository) Create(ctx context.Context, invitation *domain.Invitation) error {
+	return r.db.WithContext(ctx).Create(invitation).Error
+}
+
+func (r *gormInvitationRepository) Update(ctx context.Context, invitation *domain.Invitation) error {
+	return r.db.WithContext(ctx).Save(invitation).Error
+}
+
+func (r *gormInvitationRepository) FindByID(ctx context.Context, id string) (*domain.Invitation, error) {
+	var invitation domain.Invitation
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&invitation).Error; err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (r *gormInvitationRepository) FindPending(ctx context.Context, email string, now time.Time) (*domain.Invitation, error) {
+	var invitation domain.Invitation
+	err := r.db.WithContext(ctx).Scopes(tenantScope(ctx, "org_id"), pendingInvitations(now)).
+		Where("email = ?", email).
+		First(&invitation).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (r *gormInvitationRepository) List(ctx context.Context, status domain.InvitationStatus, now time.Time, offset, limit int) ([]domain.Invitation, error) {
+	query := r.db.WithContext(ctx).Scopes(tenantScope(ctx, "org_id"))
+	switch status {
+	case domain.InvitationPending:
+		query = query.Scopes(pendingInvitations(now))
+	case domain.InvitationAccepted:
+		query = query.Where("accepted_at IS NOT NULL")
+	case domain.InvitationRevoked:
+		query = query.Where("revoked_at IS NOT NULL")
+	case domain.InvitationExpired:
+		query = query.Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at <= ?", now)
+	}
+	var invitations []domain.Invitation
+	err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&invitations).Error
+	return invitations, err
+}
+
+func (r *gormInvitationRepository) AcceptNewUser(ctx context.Context, id string, user *domain.User, identities []domain.UserIdentity, now time.Time, grant func(userID string) error) error {
+	return inTenant(ctx, r.db, func(tx *gorm.DB) error {
+		claimed := tx.Model(&domain.Invitation{}).
+			Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", id).
+			Update("accepted_at", now)
+		if claimed.Error != nil {
+			return claimed.Error
+		}
+		if claimed.RowsAffected != 1 {
+			return ErrInvitationClosed
+		}
+		if err := createUser(ctx, tx, user, identities); err != nil {
+			return err
+		}
+		if err := tx.Model(&domain.Invitation{}).Where("id = ?", id).Update("accepted_by", user.ID).Error; err != nil {
+			return err
+		}
+		if grant == nil {
+			return nil
+		}
+		return grant(user.ID)
+	})
+}
+
+func pendingInvitations(now time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", now)
+	}
+}
@@ -0,0 +1,105 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/example/user-service/internal/domain"
+)
+
+type OrganizationRepository interface {
+	Create(ctx context.Context, org *domain.Organization) error
+	FindByID(ctx context.Context, id string) (*domain.Organization, error)
+	FindBySlug(ctx context.Context, slug string) (*domain.Organization, error)
+	// FindMembership returns gorm.ErrRecordNotFound for users without an
+	// explicit role.
+	FindMembership(ctx context.Context, orgID, userID string) (*domain.OrgMembership, error)
+	SaveMembership(ctx context.Context, membership *domain.OrgMembership) error
+	CountMembersWithRole(ctx context.Context, orgID string, role domain.OrgRole) (int64, error)
+	// ListMembers returns the users of the organization with their effective
+	// role, oldest first.
+	ListMembers(ctx context.Context, orgID string, offset, limit int) ([]OrgMember, error)
+}
+
+// OrgMember is a user of an organization and its role there.
+type OrgMember struct {
+	UserID    string         `json:"user_id"`
+	Email     string         `json:"email"`
+	Username  *string        `json:"username,omitempty"`
+	Role      domain.OrgRole `json:"role"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+type gormOrganizationRepository struct {
+	db *gorm.DB
+}
+
+func NewOrganizationRepository(db *gorm.DB) OrganizationRepository {
+	return &gormOrganizationRepository{db: db}
+}
+
+func (r *gormOrganizationRepository) Create(ctx context.Context, org *domain.Organization) error {
+	return r.db.WithContext(ctx).Create(org).Error
+}
+
+func (r *gormOrganizationRepository) FindByID(ctx context.Context, id string) (*domain.Organization, error) {
+	var org domain.Organization
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&org).Error; err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+func (r *gormOrganizationRepository) FindBySlug(ctx context.Context, slug string) (*domain.Organization, error) {
+	var org domain.Organization
+	if err := r.db.WithContext(ctx).Where("slug = ?", slug).First(&org).Error; err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+func (r *gormOrganizationRepository) FindMembership(ctx context.Context, orgID, userID string) (*domain.OrgMembership, error) {
+	var membership domain.OrgMembership
+	err := inTenant(ctx, r.db, func(tx *gorm.DB) error {
+		return tx.Where("org_id = ? AND user_id = ?", orgID, userID).First(&membership).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &membership, nil
+}
+
+func (r *gormOrganizationRepository) SaveMembership(ctx context.Context, membership *domain.OrgMembership) error {
+	return inTenant(ctx, r.db, func(tx *gorm.DB) error {
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "org_id"}, {Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"role", "updated_at"}),
+		}).Create(membership).Error
+	})
+}
+
+func (r *gormOrganizationRepository) CountMembersWithRole(ctx context.Context, orgID string, role domain.OrgRole) (int64, error) {
+	var count int64
+	err := inTenant(ctx, r.db, func(tx *gorm.DB) error {
+		return tx.Model(&domain.OrgMembership{}).Where("org_id = ? AND role = ?", orgID, role).Count(&count).Error
+	})
+	return count, err
+}
+
+func (r *gormOrganizationRepository) ListMembers(ctx context.Context, orgID string, offset, limit int) ([]OrgMember, error) {
+	var members []OrgMember
+	err := inTenant(ctx, r.db, func(tx *gorm.DB) error {
+		return tx.Table(`"user" u`).
+			Select(`u.id AS user_id, u.email, u.username, COALESCE(m.role, ?) AS role, u.created_at`, domain.OrgRoleMember).
+			Joins(`LEFT JOIN org_membership m ON m.org_id = u.org_id AND m.user_id = u.id`).
+			Where("u.org_id = ?", orgID).
+			Order("u.created_at ASC, u.id ASC").
+			Offset(offset).
+			Limit(limit).
+			Scan(&members).Error
+	})
+	return members, err
+}
@@ -0,0 +1,71 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/user-service/internal/domain"
+)
+
+type PhoneCodeRepository interface {
+	Create(ctx context.Context, code *domain.PhoneCode) error
+	FindByID(ctx context.Context, id string) (*domain.PhoneCode, error)
+	// CountSince counts the codes texted to phone at or after since.
+	CountSince(ctx context.Context, phone string, since time.Time) (int64, error)
+	// ClaimAttempt counts one attempt at the code and reports false, without
+	// counting, once max attempts were made or the code was consumed.
+	ClaimAttempt(ctx context.Context, id string, max int) (bool, error)
+	// Consume marks the code as used. It returns gorm.ErrRecordNotFound when
+	// the code was already consumed by a concurrent request.
+	Consume(ctx context.Context, id string, at time.Time) error
+}
+
+type gormPhoneCodeRepository struct {
+	db *gorm.DB
+}
+
+func NewPhoneCodeRepository(db *gorm.DB) PhoneCodeRepository {
+	return &gormPhoneCodeRepository{db: db}
+}
+
+func (r *gormPhoneCodeRepository) Create(ctx context.Context, code *domain.PhoneCode) error {
+	return r.db.WithContext(ctx).Create(code).Error
+}
+
+func (r *gormPhoneCodeRepository) FindByID(ctx context.Context, id string) (*domain.PhoneCode, error) {
+	var code domain.PhoneCode
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&code).Error; err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+func (r *gormPhoneCodeRepository) CountSince(ctx context.Context, phone string, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&domain.PhoneCode{}).
+		Where("phone = ? AND created_at >= ?", phone, since).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *gormPhoneCodeRepository) ClaimAttempt(ctx context.Context, id string, max int) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&domain.PhoneCode{}).
+		Where("id = ? AND attempts < ? AND consumed_at IS NULL", id, max).
+		UpdateColumn("attempts", gorm.Expr("attempts + 1"))
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *gormPhoneCodeRepository) Consume(ctx context.Context, id string, at time.Time) error {
+	result := r.db.WithContext(ctx).Model(&domain.PhoneCode{}).
+		Where("id = ? AND consumed_at IS NULL", id).
+		UpdateColumn("consumed_at", at)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
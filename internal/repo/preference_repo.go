@@ -0,0 +1,67 @@
+package repo
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/example/user-service/internal/domain"
+)
+
+type PreferenceRepository interface {
+	// Find returns gorm.ErrRecordNotFound for users that never changed a
+	// preference.
+	Find(ctx context.Context, userID string) (*domain.UserPreferences, error)
+	// FindMany skips users without stored preferences.
+	FindMany(ctx context.Context, userIDs []string) ([]domain.UserPreferences, error)
+	// Modify applies fn to the stored preferences of userID under a row lock
+	// and saves the result.
+	Modify(ctx context.Context, userID string, fn func(*domain.UserPreferences) error) (*domain.UserPreferences, error)
+}
+
+type gormPreferenceRepository struct {
+	db *gorm.DB
+}
+
+func NewPreferenceRepository(db *gorm.DB) PreferenceRepository {
+	return &gormPreferenceRepository{db: db}
+}
+
+func (r *gormPreferenceRepository) Find(ctx context.Context, userID string) (*domain.UserPreferences, error) {
+	var prefs domain.UserPreferences
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&prefs).Error; err != nil {
+		return nil, err
+	}
+	return &prefs, nil
+}
+
+func (r *gormPreferenceRepository) FindMany(ctx context.Context, userIDs []string) ([]domain.UserPreferences, error) {
+	var prefs []domain.UserPreferences
+	err := r.db.WithContext(ctx).Where("user_id IN ?", userIDs).Find(&prefs).Error
+	return prefs, err
+}
+
+func (r *gormPreferenceRepository) Modify(ctx context.Context, userID string, fn func(*domain.UserPreferences) error) (*domain.UserPreferences, error) {
+	var prefs domain.UserPreferences
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		seed := &domain.UserPreferences{UserID: userID, Values: domain.JSONMap{}}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(seed).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userID).First(&prefs).Error; err != nil {
+			return err
+		}
+		if prefs.Values == nil {
+			prefs.Values = domain.JSONMap{}
+		}
+		if err := fn(&prefs); err != nil {
+			return err
+		}
+		return tx.Save(&prefs).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &prefs, nil
+}
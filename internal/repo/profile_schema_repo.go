@@ -0,0 +1,46 @@
+package repo
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"github.com/example/user-service/internal/domain"
+)
+
+type ProfileSchemaRepository interface {
+	// Latest returns the schema with the highest version or
+	// gorm.ErrRecordNotFound when none was published yet.
+	Latest(ctx context.Context) (*domain.ProfileSchema, error)
+	// Create stores schema under the next free version number.
+	Create(ctx context.Context, schema *domain.ProfileSchema) error
+}
+
+type gormProfileSchemaRepository struct {
+	db *gorm.DB
+}
+
+func NewProfileSchemaRepository(db *gorm.DB) ProfileSchemaRepository {
+	return &gormProfileSchemaRepository{db: db}
+}
+
+func (r *gormProfileSchemaRepository) Latest(ctx context.Context) (*domain.ProfileSchema, error) {
+	var schema domain.ProfileSchema
+	if err := r.db.WithContext(ctx).Order("version DESC").First(&schema).Error; err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+func (r *gormProfileSchemaRepository) Create(ctx context.Context, schema *domain.ProfileSchema) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var current int
+		if err := tx.Model(&domain.ProfileSchema{}).Select("COALESCE(MAX(version), 0)").Scan(&current).Error; err != nil {
+			return err
+		}
+		// Concurrent publishers race for the same number; the unique index
+		// makes the loser fail with gorm.ErrDuplicatedKey.
+		schema.Version = current + 1
+		return tx.Create(schema).Error
+	})
+}
@@ -0,0 +1,61 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/user-service/internal/domain"
+)
+
+type RecoveryCodeRepository interface {
+	// Replace deletes every code of userID, used or not, and stores codes.
+	Replace(ctx context.Context, userID string, codes []domain.RecoveryCode) error
+	CountUnused(ctx context.Context, userID string) (int64, error)
+	// Use marks the unused code of userID with codeHash as used. It returns
+	// gorm.ErrRecordNotFound when there is none, including when a concurrent
+	// request used it first.
+	Use(ctx context.Context, userID, codeHash string, at time.Time) error
+}
+
+type gormRecoveryCodeRepository struct {
+	db *gorm.DB
+}
+
+func NewRecoveryCodeRepository(db *gorm.DB) RecoveryCodeRepository {
+	return &gormRecoveryCodeRepository{db: db}
+}
+
+func (r *gormRecoveryCodeRepository) Replace(ctx context.Context, userID string, codes []domain.RecoveryCode) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&domain.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+func (r *gormRecoveryCodeRepository) CountUnused(ctx context.Context, userID string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&domain.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *gormRecoveryCodeRepository) Use(ctx context.Context, userID, codeHash string, at time.Time) error {
+	result := r.db.WithContext(ctx).Model(&domain.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		UpdateColumn("used_at", at)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
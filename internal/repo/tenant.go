@@ -0,0 +1,59 @@
+package repo
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"github.com/example/user-service/internal/tenant"
+)
+
+// defaultTenantSetting is the app.tenant value of the default tenant; the
+// row-level security policies of migration 0015 match it against NULL
+// org_id. allTenantsSetting lets unscoped contexts see every tenant; the
+// policies deny everything while app.tenant is unset (migration 0024).
+const (
+	defaultTenantSetting = "default"
+	allTenantsSetting    = "*"
+)
+
+// inTenant runs fn in a transaction that sets app.tenant for the tenant of
+// ctx, so that the row-level security policies apply to every statement fn
+// issues.
+func inTenant(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := ApplyTenant(ctx, tx); err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
+
+// ApplyTenant sets app.tenant on the open transaction tx. Unscoped contexts,
+// those of background jobs and CLI commands, get access to every tenant.
+func ApplyTenant(ctx context.Context, tx *gorm.DB) error {
+	setting := allTenantsSetting
+	if orgID, scoped := tenant.From(ctx); scoped {
+		setting = orgID
+		if orgID == tenant.Default {
+			setting = defaultTenantSetting
+		}
+	}
+	return tx.Exec("SELECT set_config('app.tenant', ?, true)", setting).Error
+}
+
+// tenantScope filters users by the tenant of ctx. It repeats the row-level
+// security policy so that isolation holds for roles that bypass it.
+func tenantScope(ctx context.Context, column string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		orgID, scoped := tenant.From(ctx)
+		switch {
+		case !scoped:
+			return db
+		case orgID == tenant.Default:
+			return db.Where(column + " IS NULL")
+		default:
+			return db.Where(column+" = ?", orgID)
+		}
+	}
+}
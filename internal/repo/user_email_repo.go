@@ -0,0 +1,150 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/example/user-service/internal/domain"
+)
+
+type UserEmailRepository interface {
+	// List returns the primary address first, then the others by age.
+	List(ctx context.Context, userID string) ([]domain.UserEmail, error)
+	FindByID(ctx context.Context, userID, id string) (*domain.UserEmail, error)
+	// Add stores an unverified secondary address.
+	Add(ctx context.Context, email *domain.UserEmail) error
+	// MarkVerified returns gorm.ErrDuplicatedKey when another user of the
+	// tenant verified the address first.
+	MarkVerified(ctx context.Context, userID, email string, at time.Time) (*domain.UserEmail, error)
+	// SetPrimary stores the verified address id on the user; the previous
+	// primary address stays as a secondary one.
+	SetPrimary(ctx context.Context, userID, id string) (*domain.User, error)
+	// Delete removes a secondary address. It returns gorm.ErrRecordNotFound
+	// for unknown and primary addresses.
+	Delete(ctx context.Context, userID, id string) error
+}
+
+type gormUserEmailRepository struct {
+	db *gorm.DB
+}
+
+func NewUserEmailRepository(db *gorm.DB) UserEmailRepository {
+	return &gormUserEmailRepository{db: db}
+}
+
+func (r *gormUserEmailRepository) List(ctx context.Context, userID string) ([]domain.UserEmail, error) {
+	var emails []domain.UserEmail
+	err := inTenant(ctx, r.db, func(tx *gorm.DB) error {
+		return tx.Scopes(tenantScope(ctx, "org_id")).
+			Where("user_id = ?", userID).
+			Order("is_primary DESC, created_at, id").
+			Find(&emails).Error
+	})
+	return emails, err
+}
+
+func (r *gormUserEmailRepository) FindByID(ctx context.Context, userID, id string) (*domain.UserEmail, error) {
+	var email domain.UserEmail
+	err := inTenant(ctx, r.db, func(tx *gorm.DB) error {
+		return tx.Scopes(tenantScope(ctx, "org_id")).Where("id = ? AND user_id = ?", id, userID).First(&email).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &email, nil
+}
+
+func (r *gormUserEmailRepository) Add(ctx context.Context, email *domain.UserEmail) error {
+	return inTenant(ctx, r.db, func(tx *gorm.DB) error {
+		return tx.Create(email).Error
+	})
+}
+
+func (r *gormUserEmailRepository) MarkVerified(ctx context.Context, userID, address string, at time.Time) (*domain.UserEmail, error) {
+	var email domain.UserEmail
+	err := inTenant(ctx, r.db, func(tx *gorm.DB) error {
+		return tx.Transaction(func(tx *gorm.DB) error {
+			err := tx.Scopes(tenantScope(ctx, "org_id")).
+				Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("user_id = ? AND email = ?", userID, address).
+				First(&email).Error
+			if err != nil || email.IsVerified() {
+				return err
+			}
+			email.VerifiedAt = &at
+			return tx.Model(&email).Update("verified_at", at).Error
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &email, nil
+}
+
+func (r *gormUserEmailRepository) SetPrimary(ctx context.Context, userID, id string) (*domain.User, error) {
+	var user domain.User
+	err := inTenant(ctx, r.db, func(tx *gorm.DB) error {
+		return tx.Transaction(func(tx *gorm.DB) error {
+			err := tx.Scopes(tenantScope(ctx, "org_id")).
+				Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("id = ?", userID).
+				First(&user).Error
+			if err != nil {
+				return err
+			}
+			var email domain.UserEmail
+			err = tx.Where("id = ? AND user_id = ? AND verified_at IS NOT NULL", id, userID).First(&email).Error
+			if err != nil {
+				return err
+			}
+			if email.IsPrimary {
+				return nil
+			}
+			user.Email = email.Email
+			if err := updateVersioned(tx, &user, &user.Version); err != nil {
+				return err
+			}
+			return syncPrimaryEmail(tx, &user, time.Now())
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *gormUserEmailRepository) Delete(ctx context.Context, userID, id string) error {
+	return inTenant(ctx, r.db, func(tx *gorm.DB) error {
+		result := tx.Scopes(tenantScope(ctx, "org_id")).
+			Where("id = ? AND user_id = ? AND NOT is_primary", id, userID).
+			Delete(&domain.UserEmail{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// syncPrimaryEmail makes user.Email the primary address row of user. It must
+// run in the transaction that changed the email.
+func syncPrimaryEmail(tx *gorm.DB, user *domain.User, now time.Time) error {
+	demoted := tx.Model(&domain.UserEmail{}).
+		Where("user_id = ? AND is_primary AND email <> ?", user.ID, user.Email).
+		Update("is_primary", false)
+	if demoted.Error != nil || demoted.RowsAffected == 0 {
+		return demoted.Error
+	}
+	promoted := tx.Model(&domain.UserEmail{}).
+		Where("user_id = ? AND email = ?", user.ID, user.Email).
+		Updates(map[string]interface{}{"is_primary": true, "verified_at": gorm.Expr("COALESCE(verified_at, ?)", now)})
+	if promoted.Error != nil || promoted.RowsAffected > 0 {
+		return promoted.Error
+	}
+	return tx.Create(domain.PrimaryEmail(user, now)).Error
+}
@@ -2,17 +2,33 @@ package repo
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/example/user-service/internal/domain"
 )
 
+// ErrIdentityInOtherTenant is returned by FindByProviderUserID for an
+// identity linked to a user outside the tenant of ctx.
+var ErrIdentityInOtherTenant = errors.New("identity linked to a user in another tenant")
+
 type UserIdentityRepository interface {
 	Create(ctx context.Context, identity *domain.UserIdentity) error
+	Update(ctx context.Context, identity *domain.UserIdentity) error
+	// FindByProviderUserID searches every tenant, since a provider account
+	// can be linked only once, and fails with ErrIdentityInOtherTenant when
+	// the owning user is not in the tenant of ctx.
 	FindByProviderUserID(ctx context.Context, provider domain.IdentityProvider, providerUserID string) (*domain.UserIdentity, error)
 	FindByUserAndProvider(ctx context.Context, userID string, provider domain.IdentityProvider) (*domain.UserIdentity, error)
-	Delete(ctx context.Context, identity *domain.UserIdentity) error
+	FindByUserID(ctx context.Context, userID string) ([]domain.UserIdentity, error)
+	Touch(ctx context.Context, id string, at time.Time) error
+	// Delete removes identity once check, when not nil, approves its user
+	// and the number of identities the user keeps. The user row stays locked
+	// until the delete commits, so concurrent removals are serialised.
+	Delete(ctx context.Context, identity *domain.UserIdentity, check func(user *domain.User, remaining int64) error) error
 }
 
 type gormUserIdentityRepository struct {
@@ -27,9 +43,26 @@ func (r *gormUserIdentityRepository) Create(ctx context.Context, identity *domai
 	return r.db.WithContext(ctx).Create(identity).Error
 }
 
+func (r *gormUserIdentityRepository) Update(ctx context.Context, identity *domain.UserIdentity) error {
+	return r.db.WithContext(ctx).Save(identity).Error
+}
+
 func (r *gormUserIdentityRepository) FindByProviderUserID(ctx context.Context, provider domain.IdentityProvider, providerUserID string) (*domain.UserIdentity, error) {
 	var identity domain.UserIdentity
-	if err := r.db.WithContext(ctx).Where("provider = ? AND provider_user_id = ?", provider, providerUserID).First(&identity).Error; err != nil {
+	err := inTenant(ctx, r.db, func(tx *gorm.DB) error {
+		if err := tx.Where("provider = ? AND provider_user_id = ?", provider, providerUserID).First(&identity).Error; err != nil {
+			return err
+		}
+		var owners int64
+		if err := tx.Model(&domain.User{}).Scopes(tenantScope(ctx, "org_id")).Where("id = ?", identity.UserID).Count(&owners).Error; err != nil {
+			return err
+		}
+		if owners == 0 {
+			return ErrIdentityInOtherTenant
+		}
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 	return &identity, nil
@@ -43,6 +76,34 @@ func (r *gormUserIdentityRepository) FindByUserAndProvider(ctx context.Context,
 	return &identity, nil
 }
 
-func (r *gormUserIdentityRepository) Delete(ctx context.Context, identity *domain.UserIdentity) error {
-	return r.db.WithContext(ctx).Delete(identity).Error
+func (r *gormUserIdentityRepository) FindByUserID(ctx context.Context, userID string) ([]domain.UserIdentity, error) {
+	var identities []domain.UserIdentity
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&identities).Error; err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+func (r *gormUserIdentityRepository) Touch(ctx context.Context, id string, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&domain.UserIdentity{}).Where("id = ?", id).UpdateColumn("last_used_at", at).Error
+}
+
+func (r *gormUserIdentityRepository) Delete(ctx context.Context, identity *domain.UserIdentity, check func(user *domain.User, remaining int64) error) error {
+	return inTenant(ctx, r.db, func(tx *gorm.DB) error {
+		if check != nil {
+			var user domain.User
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(tenantScope(ctx, "org_id")).
+				Where("id = ?", identity.UserID).First(&user).Error; err != nil {
+				return err
+			}
+			var remaining int64
+			if err := tx.Model(&domain.UserIdentity{}).Where("user_id = ? AND id <> ?", identity.UserID, identity.ID).Count(&remaining).Error; err != nil {
+				return err
+			}
+			if err := check(&user, remaining); err != nil {
+				return err
+			}
+		}
+		return tx.Delete(identity).Error
+	})
 }
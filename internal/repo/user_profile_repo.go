@@ -10,6 +10,8 @@ import (
 
 type UserProfileRepository interface {
 	Create(ctx context.Context, profile *domain.UserProfile) error
+	// Update fails with ErrVersionConflict when the profile changed since it
+	// was read.
 	Update(ctx context.Context, profile *domain.UserProfile) error
 	FindByUserID(ctx context.Context, userID string) (*domain.UserProfile, error)
 }
@@ -27,7 +29,7 @@ func (r *gormUserProfileRepository) Create(ctx context.Context, profile *domain.
 }
 
 func (r *gormUserProfileRepository) Update(ctx context.Context, profile *domain.UserProfile) error {
-	return r.db.WithContext(ctx).Save(profile).Error
+	return updateVersioned(r.db.WithContext(ctx), profile, &profile.Version)
 }
 
 func (r *gormUserProfileRepository) FindByUserID(ctx context.Context, userID string) (*domain.UserProfile, error) {
@@ -2,19 +2,65 @@ package repo
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/tenant"
 )
 
 type UserRepository interface {
 	Create(ctx context.Context, user *domain.User) error
 	Update(ctx context.Context, user *domain.User) error
+	// UpdateUsername is Update that also records hold, when not nil, in the
+	// same transaction.
+	UpdateUsername(ctx context.Context, user *domain.User, hold *domain.UsernameHold) error
+	// UpdateLocked loads user id under a row lock, lets apply change it given
+	// the number of identities linked to it, and stores the result. An error
+	// from apply leaves the user untouched.
+	UpdateLocked(ctx context.Context, id string, apply func(user *domain.User, identities int64) error) (*domain.User, error)
 	FindByEmail(ctx context.Context, email string) (*domain.User, error)
+	// FindByUsername looks a user up by domain.CanonicalUsername.
+	FindByUsername(ctx context.Context, canonical string) (*domain.User, error)
 	FindByID(ctx context.Context, id string) (*domain.User, error)
-	Delete(ctx context.Context, id string) error
+	// FindByPhone looks a user up by verified phone in E.164 form.
+	FindByPhone(ctx context.Context, phone string) (*domain.User, error)
+	// Delete records hold, when not nil, in the same transaction so the
+	// username of a purged account stays reserved.
+	Delete(ctx context.Context, id string, hold *domain.UsernameHold) error
 	List(ctx context.Context, offset, limit int) ([]domain.User, int64, error)
+	ListFiltered(ctx context.Context, filter UserListFilter) (*UserPage, error)
+	ListExpiredSuspensions(ctx context.Context, now time.Time, limit int) ([]domain.User, error)
+	ListPurgeable(ctx context.Context, now time.Time, limit int) ([]domain.User, error)
+}
+
+// Sort columns supported by ListFiltered.
+const (
+	UserSortCreatedAt = "created_at"
+	UserSortEmail     = "email"
+)
+
+// UserListFilter narrows and orders an admin user listing. Pagination is
+// keyset based: Cursor is the NextCursor of the previous page.
+type UserListFilter struct {
+	EmailPrefix string
+	IsActive    *bool
+	Provider    string
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	SortBy      string
+	Descending  bool
+	Cursor      string
+	Limit       int
+}
+
+type UserPage struct {
+	Users      []domain.User `json:"users"`
+	NextCursor string        `json:"next_cursor,omitempty"`
 }
 
 type gormUserRepository struct {
@@ -25,43 +71,239 @@ func NewUserRepository(db *gorm.DB) UserRepository {
 	return &gormUserRepository{db: db}
 }
 
+// Create places the user in the tenant of ctx unless OrgID is already set.
 func (r *gormUserRepository) Create(ctx context.Context, user *domain.User) error {
-	return r.db.WithContext(ctx).Create(user).Error
+	return inTenant(ctx, r.db, func(tx *gorm.DB) error {
+		return createUser(ctx, tx, user, nil)
+	})
 }
 
+// createUser inserts user with its primary email, its Profile when set and
+// identities on tx.
+func createUser(ctx context.Context, tx *gorm.DB, user *domain.User, identities []domain.UserIdentity) error {
+	if orgID, scoped := tenant.From(ctx); scoped && orgID != tenant.Default && user.OrgID == nil {
+		user.OrgID = &orgID
+	}
+	profile := user.Profile
+	user.Profile = nil
+	defer func() { user.Profile = profile }()
+
+	return tx.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(domain.PrimaryEmail(user, user.CreatedAt)).Error; err != nil {
+			return err
+		}
+		if profile != nil {
+			profile.UserID = user.ID
+			if err := tx.Create(profile).Error; err != nil {
+				return err
+			}
+		}
+		for i := range identities {
+			identities[i].UserID = user.ID
+			if err := tx.Create(&identities[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Update fails with ErrVersionConflict when the user changed since it was
+// read. It keeps the previous primary address as a verified secondary one
+// when the email changes.
 func (r *gormUserRepository) Update(ctx context.Context, user *domain.User) error {
-	return r.db.WithContext(ctx).Save(user).Error
+	return r.UpdateUsername(ctx, user, nil)
 }
 
-func (r *gormUserRepository) FindByEmail(ctx context.Context, email string) (*domain.User, error) {
+func (r *gormUserRepository) UpdateUsername(ctx context.Context, user *domain.User, hold *domain.UsernameHold) error {
+	return inTenant(ctx, r.db, func(tx *gorm.DB) error {
+		return tx.Transaction(func(tx *gorm.DB) error {
+			if err := updateVersioned(tx, user, &user.Version); err != nil {
+				return err
+			}
+			if err := syncPrimaryEmail(tx, user, time.Now()); err != nil {
+				return err
+			}
+			return recordHold(tx, hold)
+		})
+	})
+}
+
+func (r *gormUserRepository) UpdateLocked(ctx context.Context, id string, apply func(user *domain.User, identities int64) error) (*domain.User, error) {
 	var user domain.User
-	if err := r.db.WithContext(ctx).Preload("Profile").Where("email = ?", email).First(&user).Error; err != nil {
+	err := inTenant(ctx, r.db, func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(tenantScope(ctx, "org_id")).
+			Preload("Profile").Where("id = ?", id).First(&user).Error; err != nil {
+			return err
+		}
+		var identities int64
+		if err := tx.Model(&domain.UserIdentity{}).Where("user_id = ?", id).Count(&identities).Error; err != nil {
+			return err
+		}
+		if err := apply(&user, identities); err != nil {
+			return err
+		}
+		if err := updateVersioned(tx, &user, &user.Version); err != nil {
+			return err
+		}
+		return syncPrimaryEmail(tx, &user, time.Now())
+	})
+	if err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
+// FindByEmail matches the primary address and verified secondary ones.
+func (r *gormUserRepository) FindByEmail(ctx context.Context, email string) (*domain.User, error) {
+	return r.findOne(ctx, "email = ? OR id IN (SELECT user_id FROM user_email WHERE email = ? AND verified_at IS NOT NULL)", email, email)
+}
+
+func (r *gormUserRepository) FindByUsername(ctx context.Context, canonical string) (*domain.User, error) {
+	return r.findOne(ctx, "username_canonical = ?", canonical)
+}
+
 func (r *gormUserRepository) FindByID(ctx context.Context, id string) (*domain.User, error) {
+	return r.findOne(ctx, "id = ?", id)
+}
+
+func (r *gormUserRepository) FindByPhone(ctx context.Context, phone string) (*domain.User, error) {
+	return r.findOne(ctx, "phone = ?", phone)
+}
+
+func (r *gormUserRepository) findOne(ctx context.Context, query string, args ...interface{}) (*domain.User, error) {
 	var user domain.User
-	if err := r.db.WithContext(ctx).Preload("Profile").Where("id = ?", id).First(&user).Error; err != nil {
+	err := inTenant(ctx, r.db, func(tx *gorm.DB) error {
+		return tx.Scopes(tenantScope(ctx, "org_id")).Preload("Profile").Where(query, args...).First(&user).Error
+	})
+	if err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
-func (r *gormUserRepository) Delete(ctx context.Context, id string) error {
-	return r.db.WithContext(ctx).Delete(&domain.User{}, "id = ?", id).Error
+func (r *gormUserRepository) Delete(ctx context.Context, id string, hold *domain.UsernameHold) error {
+	return inTenant(ctx, r.db, func(tx *gorm.DB) error {
+		if err := tx.Scopes(tenantScope(ctx, "org_id")).Delete(&domain.User{}, "id = ?", id).Error; err != nil {
+			return err
+		}
+		return recordHold(tx, hold)
+	})
 }
 
 func (r *gormUserRepository) List(ctx context.Context, offset, limit int) ([]domain.User, int64, error) {
 	var users []domain.User
 	var count int64
-	query := r.db.WithContext(ctx).Model(&domain.User{})
-	if err := query.Count(&count).Error; err != nil {
-		return nil, 0, err
-	}
-	if err := query.Preload("Profile").Offset(offset).Limit(limit).Order("created_at DESC").Find(&users).Error; err != nil {
+	err := inTenant(ctx, r.db, func(tx *gorm.DB) error {
+		query := tx.Model(&domain.User{}).Scopes(tenantScope(ctx, "org_id"))
+		if err := query.Count(&count).Error; err != nil {
+			return err
+		}
+		return query.Preload("Profile").Offset(offset).Limit(limit).Order("created_at DESC").Find(&users).Error
+	})
+	if err != nil {
 		return nil, 0, err
 	}
 	return users, count, nil
 }
+
+func (r *gormUserRepository) ListFiltered(ctx context.Context, filter UserListFilter) (*UserPage, error) {
+	sortColumn := UserSortCreatedAt
+	if filter.SortBy == UserSortEmail {
+		sortColumn = UserSortEmail
+	}
+	direction, comparator := "ASC", ">"
+	if filter.Descending {
+		direction, comparator = "DESC", "<"
+	}
+
+	var cursor *keysetCursor
+	var cursorValue interface{}
+	if filter.Cursor != "" {
+		decoded, err := decodeCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		cursor, cursorValue = decoded, decoded.Value
+		if sortColumn == UserSortCreatedAt {
+			ts, err := time.Parse(time.RFC3339Nano, decoded.Value)
+			if err != nil {
+				return nil, ErrInvalidCursor
+			}
+			cursorValue = ts
+		}
+	}
+
+	var users []domain.User
+	err := inTenant(ctx, r.db, func(tx *gorm.DB) error {
+		query := tx.Model(&domain.User{}).Scopes(tenantScope(ctx, "org_id"))
+		if filter.EmailPrefix != "" {
+			query = query.Where(`email LIKE ? ESCAPE '\'`, escapeLike(strings.ToLower(filter.EmailPrefix))+"%")
+		}
+		if filter.IsActive != nil {
+			query = query.Where("is_active = ?", *filter.IsActive)
+		}
+		if filter.Provider != "" {
+			query = query.Where(`EXISTS (SELECT 1 FROM user_identity ui WHERE ui.user_id = "user".id AND ui.provider = ?)`, filter.Provider)
+		}
+		if filter.CreatedFrom != nil {
+			query = query.Where("created_at >= ?", *filter.CreatedFrom)
+		}
+		if filter.CreatedTo != nil {
+			query = query.Where("created_at < ?", *filter.CreatedTo)
+		}
+		if cursor != nil {
+			query = query.Where(fmt.Sprintf(`(%s, id) %s (?, ?)`, sortColumn, comparator), cursorValue, cursor.ID)
+		}
+		return query.Preload("Profile").
+			Order(fmt.Sprintf("%s %s, id %s", sortColumn, direction, direction)).
+			Limit(filter.Limit + 1).
+			Find(&users).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page := &UserPage{Users: users}
+	if len(users) > filter.Limit {
+		page.Users = users[:filter.Limit]
+		last := page.Users[len(page.Users)-1]
+		value := last.Email
+		if sortColumn == UserSortCreatedAt {
+			value = last.CreatedAt.UTC().Format(time.RFC3339Nano)
+		}
+		page.NextCursor = encodeCursor(value, last.ID)
+	}
+	return page, nil
+}
+
+func (r *gormUserRepository) ListExpiredSuspensions(ctx context.Context, now time.Time, limit int) ([]domain.User, error) {
+	var users []domain.User
+	err := inTenant(ctx, r.db, func(tx *gorm.DB) error {
+		return tx.Where("suspended_at IS NOT NULL AND suspended_until IS NOT NULL AND suspended_until <= ?", now).
+			Order("suspended_until ASC").
+			Limit(limit).
+			Find(&users).Error
+	})
+	return users, err
+}
+
+func (r *gormUserRepository) ListPurgeable(ctx context.Context, now time.Time, limit int) ([]domain.User, error) {
+	var users []domain.User
+	err := inTenant(ctx, r.db, func(tx *gorm.DB) error {
+		return tx.Preload("Profile").
+			Where("deleted_at IS NOT NULL AND purge_at <= ?", now).
+			Order("purge_at ASC").
+			Limit(limit).
+			Find(&users).Error
+	})
+	return users, err
+}
+
+// escapeLike neutralises LIKE wildcards so client input is matched literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,12 @@
+package repo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEscapeLike(t *testing.T) {
+	require.Equal(t, `a\%b\_c\\d`, escapeLike(`a%b_c\d`))
+	require.Equal(t, "plain@example.com", escapeLike("plain@example.com"))
+}
@@ -0,0 +1,89 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/user-service/internal/domain"
+)
+
+// searchTimeout bounds a single search so that no input can tie up a
+// connection.
+const searchTimeout = "2s"
+
+// UserSearchRepository ranks users by trigram similarity of their email,
+// username and display name.
+type UserSearchRepository interface {
+	// Search expects a lowercase term of at least three characters, the
+	// shortest that trigram indexes can serve.
+	Search(ctx context.Context, term string, limit int) ([]UserSearchHit, error)
+}
+
+type UserSearchHit struct {
+	UserID      string    `json:"user_id"`
+	OrgID       *string   `json:"org_id,omitempty"`
+	Email       string    `json:"email"`
+	Username    *string   `json:"username,omitempty"`
+	DisplayName *string   `json:"display_name,omitempty"`
+	IsActive    bool      `json:"is_active"`
+	CreatedAt   time.Time `json:"created_at"`
+	Score       float64   `json:"score"`
+}
+
+type gormUserSearchRepository struct {
+	db *gorm.DB
+}
+
+func NewUserSearchRepository(db *gorm.DB) UserSearchRepository {
+	return &gormUserSearchRepository{db: db}
+}
+
+// searchQuery matches with the indexable trigram operators and a substring
+// LIKE whose wildcards are escaped, then ranks by the best similarity of any
+// field. Exact email matches rank first. Usernames are compared in their
+// canonical form, so the term is canonicalized the same way for them.
+const searchQuery = `
+SELECT u.id AS user_id, u.org_id, u.email, u.username, p.display_name, u.is_active, u.created_at,
+	GREATEST(
+		CASE WHEN u.email = @term THEN 1 ELSE 0 END,
+		similarity(u.email, @term),
+		word_similarity(@term, u.email),
+		COALESCE(similarity(u.username_canonical, @username), 0),
+		COALESCE(word_similarity(@term, lower(p.display_name)), 0)
+	) AS score
+FROM "user" u
+LEFT JOIN user_profile p ON p.user_id = u.id
+WHERE (
+	u.email % @term
+	OR @term <% u.email
+	OR u.email LIKE @contains ESCAPE '\'
+	OR u.username_canonical % @username
+	OR u.username_canonical LIKE @username_contains ESCAPE '\'
+	OR @term <% lower(p.display_name)
+	OR lower(p.display_name) LIKE @contains ESCAPE '\'
+)`
+
+func (r *gormUserSearchRepository) Search(ctx context.Context, term string, limit int) ([]UserSearchHit, error) {
+	var hits []UserSearchHit
+	username := domain.CanonicalUsername(term)
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT set_config('statement_timeout', ?, true)", searchTimeout).Error; err != nil {
+			return err
+		}
+		return inTenant(ctx, tx, func(tx *gorm.DB) error {
+			return tx.Table("(?) AS hits", tx.Raw(searchQuery, map[string]interface{}{
+				"term":              term,
+				"contains":          "%" + escapeLike(term) + "%",
+				"username":          username,
+				"username_contains": "%" + escapeLike(username) + "%",
+			})).
+				Scopes(tenantScope(ctx, "org_id")).
+				Order("score DESC, user_id ASC").
+				Limit(limit).
+				Scan(&hits).Error
+		})
+	})
+	return hits, err
+}
@@ -0,0 +1,41 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/user-service/internal/domain"
+)
+
+type UsernameHistoryRepository interface {
+	// IsHeld reports whether canonical was released by a user other than
+	// userID and its cooldown has not ended by now.
+	IsHeld(ctx context.Context, canonical, userID string, now time.Time) (bool, error)
+}
+
+type gormUsernameHistoryRepository struct {
+	db *gorm.DB
+}
+
+func NewUsernameHistoryRepository(db *gorm.DB) UsernameHistoryRepository {
+	return &gormUsernameHistoryRepository{db: db}
+}
+
+// recordHold stores hold on tx; holds are written by UserRepository together
+// with the rename or purge that releases the username.
+func recordHold(tx *gorm.DB, hold *domain.UsernameHold) error {
+	if hold == nil {
+		return nil
+	}
+	return tx.Create(hold).Error
+}
+
+func (r *gormUsernameHistoryRepository) IsHeld(ctx context.Context, canonical, userID string, now time.Time) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&domain.UsernameHold{}).
+		Where("username_canonical = ? AND user_id <> ? AND available_at > ?", canonical, userID, now).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,28 @@
+package repo
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrVersionConflict reports that a row was written by someone else since it
+// was read.
+var ErrVersionConflict = errors.New("record was modified concurrently")
+
+// updateVersioned writes every column of model only if the row is still at
+// *version, and advances *version on success.
+func updateVersioned(tx *gorm.DB, model interface{}, version *int64) error {
+	read := *version
+	*version = read + 1
+	result := tx.Model(model).Where("version = ?", read).Select("*").Omit(clause.Associations).Updates(model)
+	if result.Error == nil && result.RowsAffected == 0 {
+		result.Error = ErrVersionConflict
+	}
+	if result.Error != nil {
+		*version = read
+		return result.Error
+	}
+	return nil
+}
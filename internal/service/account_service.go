@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/example/user-service/config"
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/events"
+	"github.com/example/user-service/internal/ports/broker"
+	"github.com/example/user-service/internal/ports/rbac"
+	"github.com/example/user-service/internal/repo"
+	pkglog "github.com/example/user-service/pkg/log"
+)
+
+const purgeBatchSize = 100
+
+// AccountService handles self-service account deletion and the hard purge
+// that follows the grace period.
+type AccountService interface {
+	RequestDeletion(ctx context.Context, traceID, userID string) (*domain.User, error)
+	// PurgeExpired erases accounts whose grace period ended at or before now
+	// and returns how many were purged. An account that fails is logged and
+	// left for the next run without holding up the others.
+	PurgeExpired(ctx context.Context, now time.Time) (int, error)
+}
+
+type accountService struct {
+	cfg       *config.Config
+	logger    pkglog.Logger
+	users     repo.UserRepository
+	audit     repo.AuditRepository
+	avatars   AvatarStore
+	rbac      rbac.Client
+	publisher broker.Publisher
+}
+
+func NewAccountService(cfg *config.Config, logger pkglog.Logger, users repo.UserRepository, audit repo.AuditRepository, avatars AvatarStore, rbacClient rbac.Client, publisher broker.Publisher) AccountService {
+	return &accountService{
+		cfg:       cfg,
+		logger:    logger,
+		users:     users,
+		audit:     audit,
+		avatars:   avatars,
+		rbac:      rbacClient,
+		publisher: publisher,
+	}
+}
+
+func (s *accountService) RequestDeletion(ctx context.Context, traceID, userID string) (*domain.User, error) {
+	user, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsPendingDeletion() {
+		return user, nil
+	}
+	user.ScheduleDeletion(time.Now().UTC(), s.cfg.AccountDeletionGrace)
+	if err := s.users.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	s.logger.Info().Str("trace_id", traceID).Str("user_id", user.ID).Time("purge_at", *user.PurgeAt).Msg("account deletion scheduled")
+	recordAudit(ctx, s.audit, s.logger, &domain.AuditEvent{
+		UserID:   user.ID,
+		ActorID:  user.ID,
+		Action:   domain.AuditDeletionRequested,
+		Metadata: domain.JSONMap{"purge_at": user.PurgeAt.Format(time.RFC3339)},
+		TraceID:  traceID,
+	})
+	return user, nil
+}
+
+func (s *accountService) PurgeExpired(ctx context.Context, now time.Time) (int, error) {
+	purged := 0
+	failed := map[string]bool{}
+	for {
+		// Accounts that failed stay purgeable, so the batch is widened to
+		// look past them.
+		limit := purgeBatchSize + len(failed)
+		users, err := s.users.ListPurgeable(ctx, now, limit)
+		if err != nil {
+			return purged, err
+		}
+		attempted := 0
+		for i := range users {
+			if failed[users[i].ID] {
+				continue
+			}
+			attempted++
+			if err := s.purge(ctx, &users[i]); err != nil {
+				// The account stays scheduled and is retried on the next run;
+				// every purge step is idempotent.
+				s.logger.Error().Err(err).Str("user_id", users[i].ID).Msg("account purge failed")
+				failed[users[i].ID] = true
+				continue
+			}
+			purged++
+		}
+		if len(users) < limit || attempted == 0 {
+			break
+		}
+	}
+	if len(failed) > 0 {
+		return purged, fmt.Errorf("%d accounts could not be purged", len(failed))
+	}
+	return purged, nil
+}
+
+func (s *accountService) purge(ctx context.Context, user *domain.User) error {
+	if user.Profile != nil && s.avatars != nil {
+		if err := s.avatars.Remove(ctx, user.Profile); err != nil {
+			return err
+		}
+	}
+	if s.rbac != nil {
+		if err := s.rbac.RevokeRoles(ctx, user.ID); err != nil {
+			return err
+		}
+	}
+	// Profile, identities and pending account links cascade with the user row;
+	// the username stays held so the handle cannot be taken over right away.
+	hold := user.ReleaseUsername(time.Now().UTC(), s.cfg.UsernameReleaseCooldown)
+	if err := s.users.Delete(ctx, user.ID, hold); err != nil {
+		return err
+	}
+	s.logger.Info().Str("user_id", user.ID).Msg("account purged")
+	if s.publisher != nil {
+		_ = s.publisher.Publish(ctx, "user.deleted", events.NewUserEvent("user.deleted", user.ID, user.Email, ""))
+	}
+	return nil
+}
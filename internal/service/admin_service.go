@@ -0,0 +1,327 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/events"
+	"github.com/example/user-service/internal/ports/broker"
+	"github.com/example/user-service/internal/ports/rbac"
+	"github.com/example/user-service/internal/repo"
+	pkglog "github.com/example/user-service/pkg/log"
+)
+
+const (
+	defaultAdminPageSize = 50
+	maxAdminPageSize     = 200
+	suspensionSweepBatch = 100
+)
+
+var (
+	ErrInvalidFilter            = errors.New("invalid filter")
+	ErrSuspensionReasonRequired = errors.New("suspension reason is required")
+	ErrInvalidSuspensionExpiry  = errors.New("suspension expiry must be in the future")
+	ErrUserNotSuspended         = errors.New("user is not suspended")
+	ErrUserNotActivatable       = errors.New("suspended or deleted users cannot be activated")
+	ErrRoleRequired             = errors.New("role is required")
+)
+
+// UserDetails is a user with the linked identities and RBAC role support
+// staff need to diagnose an account.
+type UserDetails struct {
+	User       *domain.User          `json:"user"`
+	Identities []domain.UserIdentity `json:"identities"`
+	Role       string                `json:"role"`
+}
+
+type AdminService interface {
+	ListUsers(ctx context.Context, filter repo.UserListFilter) (*repo.UserPage, error)
+	GetUser(ctx context.Context, userID string) (*domain.User, error)
+	// FindUser looks a user up by email when ref contains "@" and by ID
+	// otherwise.
+	FindUser(ctx context.Context, ref string) (*domain.User, error)
+	// Describe returns the user together with identities and role. A role
+	// lookup failure leaves Role empty.
+	Describe(ctx context.Context, userID string) (*UserDetails, error)
+	Suspend(ctx context.Context, traceID, actorID, userID, reason string, until *time.Time) (*domain.User, error)
+	Unsuspend(ctx context.Context, traceID, actorID, userID string) (*domain.User, error)
+	// UpdateProfile edits a user's profile with the same validation as
+	// self-service edits.
+	UpdateProfile(ctx context.Context, traceID, actorID, userID string, update ProfileUpdate) (*domain.UserProfile, error)
+	// SetActive activates or deactivates an account. Suspended accounts and
+	// accounts pending deletion cannot be activated this way.
+	SetActive(ctx context.Context, traceID, actorID, userID string, active bool) (*domain.User, error)
+	// ForcePasswordReset blocks password sign-in until the user sets a new
+	// password and revokes every issued token.
+	ForcePasswordReset(ctx context.Context, traceID, actorID, userID string) (*domain.User, error)
+	UnlinkIdentity(ctx context.Context, traceID, actorID, userID string, provider domain.IdentityProvider, providerUserID string) error
+	AssignRole(ctx context.Context, traceID, actorID, userID, role string) error
+	// RevokeSessions invalidates every token issued to the user so far.
+	RevokeSessions(ctx context.Context, traceID, actorID, userID string) (*domain.User, error)
+	// LiftExpiredSuspensions reactivates users whose suspension expired at or
+	// before now and returns how many were reactivated. A user that fails is
+	// logged and left for the next run without holding up the others.
+	LiftExpiredSuspensions(ctx context.Context, now time.Time) (int, error)
+}
+
+type adminService struct {
+	logger    pkglog.Logger
+	users     repo.UserRepository
+	profiles  UserService
+	rbac      rbac.Client
+	audit     repo.AuditRepository
+	publisher broker.Publisher
+}
+
+func NewAdminService(logger pkglog.Logger, users repo.UserRepository, profiles UserService, rbacClient rbac.Client, audit repo.AuditRepository, publisher broker.Publisher) AdminService {
+	return &adminService{logger: logger, users: users, profiles: profiles, rbac: rbacClient, audit: audit, publisher: publisher}
+}
+
+func (s *adminService) ListUsers(ctx context.Context, filter repo.UserListFilter) (*repo.UserPage, error) {
+	switch filter.SortBy {
+	case "":
+		filter.SortBy = repo.UserSortCreatedAt
+	case repo.UserSortCreatedAt, repo.UserSortEmail:
+	default:
+		return nil, ErrInvalidFilter
+	}
+	if filter.Provider != "" && !domain.IdentityProvider(filter.Provider).IsValid() {
+		return nil, ErrUnsupportedProvider
+	}
+	if filter.CreatedFrom != nil && filter.CreatedTo != nil && !filter.CreatedFrom.Before(*filter.CreatedTo) {
+		return nil, ErrInvalidFilter
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAdminPageSize
+	}
+	if filter.Limit > maxAdminPageSize {
+		filter.Limit = maxAdminPageSize
+	}
+	return s.users.ListFiltered(ctx, filter)
+}
+
+func (s *adminService) GetUser(ctx context.Context, userID string) (*domain.User, error) {
+	return s.users.FindByID(ctx, userID)
+}
+
+func (s *adminService) FindUser(ctx context.Context, ref string) (*domain.User, error) {
+	ref = strings.TrimSpace(ref)
+	if strings.Contains(ref, "@") {
+		return s.users.FindByEmail(ctx, strings.ToLower(ref))
+	}
+	return s.users.FindByID(ctx, ref)
+}
+
+func (s *adminService) Describe(ctx context.Context, userID string) (*UserDetails, error) {
+	user, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	identities, err := s.profiles.ListIdentities(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	details := &UserDetails{User: user, Identities: identities}
+	if s.rbac != nil {
+		role, err := s.rbac.GetRoleByUserID(ctx, userID)
+		if err != nil {
+			s.logger.Warn().Err(err).Str("user_id", userID).Msg("role lookup failed")
+		}
+		details.Role = role
+	}
+	return details, nil
+}
+
+func (s *adminService) Suspend(ctx context.Context, traceID, actorID, userID, reason string, until *time.Time) (*domain.User, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrSuspensionReasonRequired
+	}
+	now := time.Now().UTC()
+	if until != nil {
+		if !until.After(now) {
+			return nil, ErrInvalidSuspensionExpiry
+		}
+		utc := until.UTC()
+		until = &utc
+	}
+	user, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	user.Suspend(reason, until, now)
+	if err := s.users.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	s.logger.Info().Str("trace_id", traceID).Str("user_id", user.ID).Msg("user suspended")
+	metadata := domain.JSONMap{"reason": reason}
+	if until != nil {
+		metadata["until"] = until.Format(time.RFC3339)
+	}
+	recordAudit(ctx, s.audit, s.logger, &domain.AuditEvent{UserID: user.ID, ActorID: actorID, Action: domain.AuditUserSuspended, Metadata: metadata, TraceID: traceID})
+	if s.publisher != nil {
+		_ = s.publisher.Publish(ctx, "user.suspended", events.NewUserSuspendedEvent(user.ID, reason, until, traceID))
+	}
+	return user, nil
+}
+
+func (s *adminService) Unsuspend(ctx context.Context, traceID, actorID, userID string) (*domain.User, error) {
+	user, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsSuspended() {
+		return nil, ErrUserNotSuspended
+	}
+	if err := s.reactivate(ctx, traceID, actorID, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *adminService) UpdateProfile(ctx context.Context, traceID, actorID, userID string, update ProfileUpdate) (*domain.UserProfile, error) {
+	if _, err := s.users.FindByID(ctx, userID); err != nil {
+		return nil, err
+	}
+	profile, err := s.profiles.UpdateProfile(ctx, userID, update)
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Info().Str("trace_id", traceID).Str("user_id", userID).Msg("profile updated by admin")
+	// Only field names are audited; profile values stay out of the log.
+	recordAudit(ctx, s.audit, s.logger, &domain.AuditEvent{UserID: userID, ActorID: actorID, Action: domain.AuditProfileUpdated, Metadata: domain.JSONMap{"fields": update.fieldNames()}, TraceID: traceID})
+	return profile, nil
+}
+
+func (s *adminService) SetActive(ctx context.Context, traceID, actorID, userID string, active bool) (*domain.User, error) {
+	user, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsActive == active {
+		return user, nil
+	}
+	action, routingKey := domain.AuditUserDeactivated, "user.deactivated"
+	if active {
+		if user.IsSuspended() || user.IsPendingDeletion() {
+			return nil, ErrUserNotActivatable
+		}
+		user.Activate()
+		action, routingKey = domain.AuditUserActivated, "user.activated"
+	} else {
+		user.Deactivate()
+	}
+	if err := s.users.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	s.logger.Info().Str("trace_id", traceID).Str("user_id", user.ID).Bool("active", active).Msg("user activation changed")
+	recordAudit(ctx, s.audit, s.logger, &domain.AuditEvent{UserID: user.ID, ActorID: actorID, Action: action, TraceID: traceID})
+	if s.publisher != nil {
+		_ = s.publisher.Publish(ctx, routingKey, events.NewUserEvent(routingKey, user.ID, user.Email, traceID))
+	}
+	return user, nil
+}
+
+func (s *adminService) ForcePasswordReset(ctx context.Context, traceID, actorID, userID string) (*domain.User, error) {
+	user, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	user.RequirePasswordReset(time.Now().UTC())
+	if err := s.users.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	s.logger.Info().Str("trace_id", traceID).Str("user_id", user.ID).Msg("password reset required")
+	recordAudit(ctx, s.audit, s.logger, &domain.AuditEvent{UserID: user.ID, ActorID: actorID, Action: domain.AuditPasswordReset, TraceID: traceID})
+	return user, nil
+}
+
+func (s *adminService) UnlinkIdentity(ctx context.Context, traceID, actorID, userID string, provider domain.IdentityProvider, providerUserID string) error {
+	if err := s.profiles.RemoveIdentity(ctx, userID, provider, providerUserID); err != nil {
+		return err
+	}
+	s.logger.Info().Str("trace_id", traceID).Str("user_id", userID).Str("provider", string(provider)).Msg("identity unlinked by admin")
+	recordAudit(ctx, s.audit, s.logger, &domain.AuditEvent{UserID: userID, ActorID: actorID, Action: domain.AuditIdentityUnlinked, Metadata: domain.JSONMap{"provider": string(provider), "provider_user_id": providerUserID}, TraceID: traceID})
+	return nil
+}
+
+func (s *adminService) AssignRole(ctx context.Context, traceID, actorID, userID, role string) error {
+	role = strings.TrimSpace(role)
+	if role == "" {
+		return ErrRoleRequired
+	}
+	if _, err := s.users.FindByID(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.rbac.AssignRole(ctx, userID, role); err != nil {
+		return err
+	}
+	s.logger.Info().Str("trace_id", traceID).Str("user_id", userID).Str("role", role).Msg("role assigned")
+	recordAudit(ctx, s.audit, s.logger, &domain.AuditEvent{UserID: userID, ActorID: actorID, Action: domain.AuditRoleAssigned, Metadata: domain.JSONMap{"role": role}, TraceID: traceID})
+	return nil
+}
+
+func (s *adminService) RevokeSessions(ctx context.Context, traceID, actorID, userID string) (*domain.User, error) {
+	user, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	user.RevokeTokens(time.Now().UTC())
+	if err := s.users.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	s.logger.Info().Str("trace_id", traceID).Str("user_id", user.ID).Msg("sessions revoked")
+	recordAudit(ctx, s.audit, s.logger, &domain.AuditEvent{UserID: user.ID, ActorID: actorID, Action: domain.AuditSessionsRevoked, TraceID: traceID})
+	return user, nil
+}
+
+func (s *adminService) LiftExpiredSuspensions(ctx context.Context, now time.Time) (int, error) {
+	lifted := 0
+	failed := map[string]bool{}
+	for {
+		// Users that failed stay listed, so the batch is widened to look
+		// past them.
+		limit := suspensionSweepBatch + len(failed)
+		users, err := s.users.ListExpiredSuspensions(ctx, now, limit)
+		if err != nil {
+			return lifted, err
+		}
+		attempted := 0
+		for i := range users {
+			if failed[users[i].ID] {
+				continue
+			}
+			attempted++
+			if err := s.reactivate(ctx, "", "", &users[i]); err != nil {
+				s.logger.Error().Err(err).Str("user_id", users[i].ID).Msg("suspension lift failed")
+				failed[users[i].ID] = true
+				continue
+			}
+			lifted++
+		}
+		if len(users) < limit || attempted == 0 {
+			break
+		}
+	}
+	if len(failed) > 0 {
+		return lifted, fmt.Errorf("%d suspensions could not be lifted", len(failed))
+	}
+	return lifted, nil
+}
+
+func (s *adminService) reactivate(ctx context.Context, traceID, actorID string, user *domain.User) error {
+	user.Reactivate()
+	if err := s.users.Update(ctx, user); err != nil {
+		return err
+	}
+	s.logger.Info().Str("trace_id", traceID).Str("user_id", user.ID).Msg("user reactivated")
+	recordAudit(ctx, s.audit, s.logger, &domain.AuditEvent{UserID: user.ID, ActorID: actorID, Action: domain.AuditUserReactivated, TraceID: traceID})
+	if s.publisher != nil {
+		_ = s.publisher.Publish(ctx, "user.reactivated", events.NewUserEvent("user.reactivated", user.ID, user.Email, traceID))
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package service
+
+import (
+	"context"
+
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/repo"
+	pkglog "github.com/example/user-service/pkg/log"
+)
+
+// recordAudit stores an audit event. Failing to audit is logged but never
+// fails the action that was audited.
+func recordAudit(ctx context.Context, audit repo.AuditRepository, logger pkglog.Logger, event *domain.AuditEvent) {
+	if audit == nil {
+		return
+	}
+	if err := audit.Record(ctx, event); err != nil {
+		logger.Error().Err(err).Str("trace_id", event.TraceID).Str("user_id", event.UserID).Str("action", event.Action).Msg("audit event not recorded")
+	}
+}
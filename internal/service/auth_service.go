@@ -9,7 +9,9 @@ import (
 	"net/http"
 	"net/mail"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
@@ -22,20 +24,125 @@ import (
 	"github.com/example/user-service/internal/ports/tarantool"
 	"github.com/example/user-service/internal/repo"
 	pkglog "github.com/example/user-service/pkg/log"
+	"github.com/example/user-service/pkg/passwordhash"
+	"github.com/example/user-service/pkg/signedtoken"
 )
 
 var (
-	ErrInvalidCredentials = errors.New("invalid credentials")
-	ErrUserInactive       = errors.New("user inactive")
+	ErrInvalidCredentials     = errors.New("invalid credentials")
+	ErrUserInactive           = errors.New("user inactive")
+	ErrAccountLinkRequired    = errors.New("account link confirmation required")
+	ErrAccountLinkExpired     = errors.New("account link expired or already used")
+	ErrAccountLinkUnavailable = errors.New("account link confirmation method unavailable")
+	ErrUnsupportedProvider    = errors.New("unsupported provider")
+	ErrIdentityTaken          = errors.New("identity already linked to another user")
+	ErrIdentityOtherTenant    = errors.New("identity already linked to an account in another organization")
+	ErrProviderAlreadyLinked  = errors.New("provider already linked to this account")
+	ErrAccountPendingDeletion = errors.New("account is scheduled for deletion")
+	ErrRestoreTokenInvalid    = errors.New("restore token invalid or expired")
+	ErrPasswordResetRequired  = errors.New("password reset required")
+	ErrResetTokenInvalid      = errors.New("password reset link invalid or expired")
+	ErrMFARequired            = errors.New("second factor required")
+	ErrSMSSignInUnavailable   = errors.New("sms sign-in not configured")
 )
 
-const defaultUserRole = "user"
+const (
+	defaultUserRole     = "user"
+	maxAccountLinkTries = 5
+	restoreTokenPurpose = "account-restore"
+	resetTokenPurpose   = "password-reset"
+)
+
+// Link policies for OAuth sign-ins whose email matches an existing account.
+const (
+	LinkPolicyAuto   = "auto"
+	LinkPolicyVerify = "verify"
+)
+
+// Ownership proofs accepted by ConfirmAccountLink.
+const (
+	LinkMethodCode     = "code"
+	LinkMethodPassword = "password"
+)
+
+// AccountLinkRequiredError is returned by HandleOAuthCallback when the
+// provider email belongs to an existing account that has to approve the link.
+type AccountLinkRequiredError struct {
+	LinkID    string
+	Methods   []string
+	ExpiresAt time.Time
+}
+
+func (e *AccountLinkRequiredError) Error() string {
+	return ErrAccountLinkRequired.Error()
+}
+
+func (e *AccountLinkRequiredError) Unwrap() error {
+	return ErrAccountLinkRequired
+}
+
+// MFARequiredError is returned by every sign-in path when the account
+// requires a second factor. The code texted for ChallengeID is exchanged via VerifyMFA. Methods
+// includes "recovery_code" while the user has unused recovery codes, which
+// SignInWithRecoveryCode accepts for ChallengeID instead.
+type MFARequiredError struct {
+	ChallengeID string
+	Methods     []string
+	ExpiresAt   time.Time
+}
+
+func (e *MFARequiredError) Error() string {
+	return ErrMFARequired.Error()
+}
+
+func (e *MFARequiredError) Unwrap() error {
+	return ErrMFARequired
+}
+
+// AccountPendingDeletionError is returned when a user authenticates during the
+// deletion grace period. RestoreToken can be exchanged via RestoreAccount.
+type AccountPendingDeletionError struct {
+	RestoreToken string
+	PurgeAt      time.Time
+}
+
+func (e *AccountPendingDeletionError) Error() string {
+	return ErrAccountPendingDeletion.Error()
+}
+
+func (e *AccountPendingDeletionError) Unwrap() error {
+	return ErrAccountPendingDeletion
+}
 
 type AuthService interface {
 	StartSignup(ctx context.Context, traceID, email, password string) (string, error)
 	VerifySignup(ctx context.Context, traceID, uuid, code string) (*domain.User, *Tokens, error)
-	SignIn(ctx context.Context, traceID, email, password string) (*domain.User, *Tokens, error)
+	// SignIn authenticates with an email address or a username.
+	SignIn(ctx context.Context, traceID, login, password string) (*domain.User, *Tokens, error)
 	HandleOAuthCallback(ctx context.Context, traceID, provider string, info OAuthUserInfo) (*domain.User, *Tokens, error)
+	ConfirmAccountLink(ctx context.Context, traceID, linkID, code, password string) (*domain.User, *Tokens, error)
+	RestoreAccount(ctx context.Context, traceID, restoreToken string) (*domain.User, *Tokens, error)
+	// AcceptInvitation creates the invited account with password. The
+	// emailed link proves the address, so no verification code is sent.
+	AcceptInvitation(ctx context.Context, traceID, token, password string) (*domain.User, *Tokens, error)
+	// StartSMSSignIn texts a sign-in code to the verified phone and returns
+	// the code ID. Unknown numbers get an ID that never verifies.
+	StartSMSSignIn(ctx context.Context, traceID, phone string) (string, error)
+	VerifySMSSignIn(ctx context.Context, traceID, codeID, code string) (*domain.User, *Tokens, error)
+	// VerifyMFA completes a SignIn that returned *MFARequiredError.
+	VerifyMFA(ctx context.Context, traceID, challengeID, code string) (*domain.User, *Tokens, error)
+	// SignInWithRecoveryCode completes a sign-in that returned
+	// *MFARequiredError with a recovery code in place of the texted one. The
+	// recovery code is used up.
+	SignInWithRecoveryCode(ctx context.Context, traceID, challengeID, code string) (*domain.User, *Tokens, error)
+	// RequestPasswordReset publishes user.password_reset_requested with a
+	// reset link for the account of email. Unknown addresses are ignored
+	// so the result does not reveal which accounts exist.
+	RequestPasswordReset(ctx context.Context, traceID, email string) error
+	// ResetPassword sets the password of the account a reset link was issued
+	// for, clears PasswordResetRequired and revokes every token. The link
+	// stops working once used.
+	ResetPassword(ctx context.Context, traceID, token, password string) error
 }
 
 type OAuthProvider string
@@ -46,17 +153,23 @@ const (
 )
 
 type authService struct {
-	cfg        *config.Config
-	logger     pkglog.Logger
-	users      repo.UserRepository
-	profiles   repo.UserProfileRepository
-	providers  repo.UserProviderRepository
-	tarantool  tarantool.Client
-	rbac       rbac.Client
-	publisher  broker.Publisher
-	jwtSigner  JWTSigner
-	avatars    AvatarIngestor
-	httpClient *http.Client
+	cfg         *config.Config
+	logger      pkglog.Logger
+	users       repo.UserRepository
+	profiles    repo.UserProfileRepository
+	identities  repo.UserIdentityRepository
+	links       repo.AccountLinkRepository
+	tarantool   tarantool.Client
+	rbac        rbac.Client
+	publisher   broker.Publisher
+	jwtSigner   JWTSigner
+	avatars     AvatarQueue
+	groups      GroupService
+	invitations repo.InvitationRepository
+	phones      PhoneService
+	recovery    RecoveryCodeService
+	signer      *signedtoken.Signer
+	httpClient  *http.Client
 }
 
 func NewAuthService(
@@ -64,25 +177,36 @@ func NewAuthService(
 	logger pkglog.Logger,
 	users repo.UserRepository,
 	profiles repo.UserProfileRepository,
-	providers repo.UserProviderRepository,
+	identities repo.UserIdentityRepository,
+	links repo.AccountLinkRepository,
 	tarantool tarantool.Client,
 	rbacClient rbac.Client,
 	publisher broker.Publisher,
 	jwtSigner JWTSigner,
-	avatars AvatarIngestor,
+	avatars AvatarQueue,
+	groups GroupService,
+	invitations repo.InvitationRepository,
+	phones PhoneService,
+	recovery RecoveryCodeService,
 ) AuthService {
 	return &authService{
-		cfg:        cfg,
-		logger:     logger,
-		users:      users,
-		profiles:   profiles,
-		providers:  providers,
-		tarantool:  tarantool,
-		rbac:       rbacClient,
-		publisher:  publisher,
-		jwtSigner:  jwtSigner,
-		avatars:    avatars,
-		httpClient: http.DefaultClient,
+		cfg:         cfg,
+		logger:      logger,
+		users:       users,
+		profiles:    profiles,
+		identities:  identities,
+		links:       links,
+		tarantool:   tarantool,
+		rbac:        rbacClient,
+		publisher:   publisher,
+		jwtSigner:   jwtSigner,
+		avatars:     avatars,
+		groups:      groups,
+		invitations: invitations,
+		phones:      phones,
+		recovery:    recovery,
+		signer:      signedtoken.NewSigner([]byte(cfg.SignedTokenSecret)),
+		httpClient:  http.DefaultClient,
 	}
 }
 
@@ -93,6 +217,8 @@ type OAuthUserInfo struct {
 	DisplayName    *string
 	AvatarURL      *string
 	Metadata       map[string]interface{}
+	// InvitationToken, when set, creates the account from that invitation.
+	InvitationToken string
 }
 
 type oauthProfile struct {
@@ -168,32 +294,106 @@ func (s *authService) VerifySignup(ctx context.Context, traceID, uuid, code stri
 	if err != nil {
 		return nil, nil, err
 	}
-	tokens, err := s.issueTokens(user, role)
+	tokens, err := s.issueTokens(ctx, user, role)
 	if err != nil {
 		return nil, nil, err
 	}
 	return user, tokens, nil
 }
 
-func (s *authService) SignIn(ctx context.Context, traceID, email, password string) (*domain.User, *Tokens, error) {
-	user, err := s.users.FindByEmail(ctx, strings.ToLower(email))
+func (s *authService) SignIn(ctx context.Context, traceID, login, password string) (*domain.User, *Tokens, error) {
+	user, err := s.checkCredentials(ctx, traceID, login, password)
 	if err != nil {
-		return nil, nil, ErrInvalidCredentials
+		return nil, nil, err
 	}
-	if !user.IsActive {
-		return nil, nil, ErrUserInactive
+	return s.signInOrChallenge(ctx, traceID, user)
+}
+
+// SignInWithRecoveryCode redeems the challenge rather than the password, so
+// accounts that sign in through a provider alone can use their codes too.
+func (s *authService) SignInWithRecoveryCode(ctx context.Context, traceID, challengeID, code string) (*domain.User, *Tokens, error) {
+	if s.recovery == nil || s.phones == nil {
+		return nil, nil, ErrRecoveryCodesUnavailable
+	}
+	var user *domain.User
+	_, err := s.phones.RedeemCode(ctx, domain.PhoneCodeMFA, challengeID, func(challenge *domain.PhoneCode) error {
+		found, err := s.users.FindByID(ctx, challenge.UserID)
+		if err != nil {
+			return ErrInvalidCredentials
+		}
+		if !found.IsActive && !found.IsPendingDeletion() {
+			return ErrUserInactive
+		}
+		if err := s.recovery.Use(ctx, traceID, found, code); err != nil {
+			return err
+		}
+		user = found
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.completeSignIn(ctx, traceID, user)
+}
+
+// checkCredentials authenticates login, an email address or a username,
+// with password. It stops short of any second factor.
+func (s *authService) checkCredentials(ctx context.Context, traceID, login, password string) (*domain.User, error) {
+	var user *domain.User
+	var err error
+	if strings.Contains(login, "@") {
+		user, err = s.users.FindByEmail(ctx, strings.ToLower(strings.TrimSpace(login)))
+	} else {
+		user, err = s.users.FindByUsername(ctx, domain.CanonicalUsername(login))
+	}
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	if !user.IsActive && !user.IsPendingDeletion() {
+		return nil, ErrUserInactive
 	}
 	if !user.HasPassword() {
-		return nil, nil, ErrInvalidCredentials
+		return nil, ErrInvalidCredentials
 	}
-	if err := bcrypt.CompareHashAndPassword([]byte(*user.PasswordHash), []byte(password)); err != nil {
-		return nil, nil, ErrInvalidCredentials
+	if !s.checkPassword(ctx, traceID, user, password) {
+		return nil, ErrInvalidCredentials
+	}
+	if user.PasswordResetRequired {
+		return nil, ErrPasswordResetRequired
+	}
+	return user, nil
+}
+
+// signInOrChallenge finishes a first-factor sign-in. Accounts with phone MFA
+// get a texted challenge instead of tokens, whichever way the first factor
+// was proven.
+func (s *authService) signInOrChallenge(ctx context.Context, traceID string, user *domain.User) (*domain.User, *Tokens, error) {
+	if !user.PhoneMFA || s.phones == nil {
+		return s.completeSignIn(ctx, traceID, user)
+	}
+	challenge, err := s.phones.SendCode(ctx, user, domain.PhoneCodeMFA)
+	if err != nil {
+		return nil, nil, err
+	}
+	methods := []string{"sms"}
+	if s.recovery != nil {
+		if remaining, err := s.recovery.Remaining(ctx, user.ID); err == nil && remaining > 0 {
+			methods = append(methods, "recovery_code")
+		}
+	}
+	return nil, nil, &MFARequiredError{ChallengeID: challenge.ID, Methods: methods, ExpiresAt: challenge.ExpiresAt}
+}
+
+// completeSignIn issues tokens once user has been authenticated.
+func (s *authService) completeSignIn(ctx context.Context, traceID string, user *domain.User) (*domain.User, *Tokens, error) {
+	if user.IsPendingDeletion() {
+		return nil, nil, s.pendingDeletion(user)
 	}
 	role, err := s.resolveRole(ctx, user.ID)
 	if err != nil {
 		return nil, nil, err
 	}
-	tokens, err := s.issueTokens(user, role)
+	tokens, err := s.issueTokens(ctx, user, role)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -201,6 +401,82 @@ func (s *authService) SignIn(ctx context.Context, traceID, email, password strin
 	return user, tokens, nil
 }
 
+func (s *authService) StartSMSSignIn(ctx context.Context, traceID, phone string) (string, error) {
+	if s.phones == nil {
+		return "", ErrSMSSignInUnavailable
+	}
+	phone, err := domain.NormalizePhone(phone)
+	if err != nil {
+		return "", err
+	}
+	user, err := s.users.FindByPhone(ctx, phone)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return decoyCodeID()
+	}
+	if err != nil {
+		return "", err
+	}
+	code, err := s.phones.SendCode(ctx, user, domain.PhoneCodeSignIn)
+	if err != nil {
+		return "", err
+	}
+	return code.ID, nil
+}
+
+func (s *authService) VerifySMSSignIn(ctx context.Context, traceID, codeID, code string) (*domain.User, *Tokens, error) {
+	return s.signInWithCode(ctx, traceID, domain.PhoneCodeSignIn, codeID, code)
+}
+
+func (s *authService) VerifyMFA(ctx context.Context, traceID, challengeID, code string) (*domain.User, *Tokens, error) {
+	return s.signInWithCode(ctx, traceID, domain.PhoneCodeMFA, challengeID, code)
+}
+
+// signInWithCode completes a sign-in with a texted code. The code only counts
+// while it was sent to the phone the user still has.
+func (s *authService) signInWithCode(ctx context.Context, traceID string, purpose domain.PhoneCodePurpose, codeID, code string) (*domain.User, *Tokens, error) {
+	if s.phones == nil {
+		return nil, nil, ErrSMSSignInUnavailable
+	}
+	checked, err := s.phones.CheckCode(ctx, purpose, codeID, code)
+	if err != nil {
+		return nil, nil, err
+	}
+	user, err := s.users.FindByID(ctx, checked.UserID)
+	if err != nil {
+		return nil, nil, ErrInvalidCredentials
+	}
+	if user.Phone == nil || *user.Phone != checked.Phone {
+		return nil, nil, ErrPhoneCodeInvalid
+	}
+	if !user.IsActive && !user.IsPendingDeletion() {
+		return nil, nil, ErrUserInactive
+	}
+	return s.completeSignIn(ctx, traceID, user)
+}
+
+// checkPassword verifies password against the stored hash. Imported argon2
+// hashes are replaced by bcrypt on the first successful check; failing to
+// store the new hash does not fail the sign-in.
+func (s *authService) checkPassword(ctx context.Context, traceID string, user *domain.User, password string) bool {
+	ok, err := passwordhash.Verify(*user.PasswordHash, password)
+	if err != nil {
+		s.logger.Error().Err(err).Str("trace_id", traceID).Str("user_id", user.ID).Msg("stored password hash unusable")
+		return false
+	}
+	if !ok || !passwordhash.NeedsUpgrade(*user.PasswordHash) {
+		return ok
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err == nil {
+		user.SetPasswordHash(string(hash))
+		err = s.users.Update(ctx, user)
+	}
+	if err != nil {
+		s.logger.Error().Err(err).Str("trace_id", traceID).Str("user_id", user.ID).Msg("password hash upgrade failed")
+	}
+	return true
+}
+
 func (s *authService) HandleOAuthCallback(ctx context.Context, traceID, provider string, info OAuthUserInfo) (*domain.User, *Tokens, error) {
 	providerType := strings.TrimSpace(provider)
 	if providerType == "" {
@@ -212,27 +488,37 @@ func (s *authService) HandleOAuthCallback(ctx context.Context, traceID, provider
 	if err := validateEmail(info.Email); err != nil {
 		return nil, nil, err
 	}
+	identityProvider := domain.IdentityProvider(strings.ToLower(providerType))
+	if !identityProvider.IsValid() {
+		return nil, nil, ErrUnsupportedProvider
+	}
+	providerType = string(identityProvider)
 	info.ProviderType = providerType
+	if info.InvitationToken != "" {
+		return s.acceptInvitationWithIdentity(ctx, traceID, identityProvider, info)
+	}
 
-	linkedProvider, err := s.providers.FindByProvider(ctx, providerType, info.ProviderUserID)
-	if err == nil && linkedProvider != nil {
-		user, err := s.users.FindByID(ctx, linkedProvider.UserID)
+	linkedIdentity, err := s.identities.FindByProviderUserID(ctx, identityProvider, info.ProviderUserID)
+	if errors.Is(err, repo.ErrIdentityInOtherTenant) {
+		// Provider accounts are linked once across all tenants; signing in
+		// here must neither reach the other account nor create a new one.
+		return nil, nil, ErrIdentityOtherTenant
+	}
+	if err == nil && linkedIdentity != nil {
+		user, err := s.users.FindByID(ctx, linkedIdentity.UserID)
 		if err != nil {
 			return nil, nil, err
 		}
-		if !user.IsActive {
+		if !user.IsActive && !user.IsPendingDeletion() {
 			return nil, nil, ErrUserInactive
 		}
-		role, err := s.resolveRole(ctx, user.ID)
-		if err != nil {
-			return nil, nil, err
-		}
-		tokens, err := s.issueTokens(user, role)
-		if err != nil {
-			return nil, nil, err
+		if err := s.identities.Touch(ctx, linkedIdentity.ID, time.Now().UTC()); err != nil {
+			s.logger.Warn().Err(err).Str("trace_id", traceID).Str("identity_id", linkedIdentity.ID).Msg("identity last use not recorded")
 		}
 		s.logger.Info().Str("trace_id", traceID).Str("user_id", user.ID).Msg("oauth user found")
-		return user, tokens, nil
+		// Restore offers for accounts pending deletion also wait for the
+		// second factor.
+		return s.signInOrChallenge(ctx, traceID, user)
 	}
 	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		return nil, nil, err
@@ -251,56 +537,428 @@ func (s *authService) HandleOAuthCallback(ctx context.Context, traceID, provider
 		if err := s.users.Create(ctx, user); err != nil {
 			return nil, nil, err
 		}
-		profile := &domain.UserProfile{UserID: user.ID, DisplayName: info.DisplayName, AvatarURL: info.AvatarURL}
+		profile := &domain.UserProfile{UserID: user.ID, DisplayName: info.DisplayName}
 		if err := s.profiles.Create(ctx, profile); err != nil {
 			return nil, nil, err
 		}
+		if info.AvatarURL != nil && *info.AvatarURL != "" && s.avatars != nil {
+			// Provider avatars are copied into filestorage in the background so
+			// sign-in never waits on the provider CDN.
+			s.avatars.Enqueue(AvatarJob{TraceID: traceID, UserID: user.ID, SourceURL: *info.AvatarURL})
+		}
 	}
 
 	if !user.IsActive {
 		return nil, nil, ErrUserInactive
 	}
 
-	providerRecord := &domain.UserProvider{
-		ProviderType:   providerType,
-		ProviderUserID: info.ProviderUserID,
+	if !created && s.linkPolicy(providerType) != LinkPolicyAuto {
+		return nil, nil, s.startAccountLink(ctx, traceID, user, info)
+	}
+
+	identity := &domain.UserIdentity{
 		UserID:         user.ID,
+		Provider:       identityProvider,
+		ProviderUserID: info.ProviderUserID,
+		Email:          normalizedEmail,
+		DisplayName:    info.DisplayName,
+		AvatarURL:      info.AvatarURL,
 		Metadata:       info.Metadata,
 	}
-	if err := s.providers.Create(ctx, providerRecord); err != nil {
-		if !errors.Is(err, gorm.ErrDuplicatedKey) {
+	if err := s.linkIdentity(ctx, identity); err != nil {
+		return nil, nil, err
+	}
+
+	if created {
+		if err := s.assignDefaultRole(ctx, user.ID); err != nil {
 			return nil, nil, err
 		}
+	}
 
-		// Another request linked the provider concurrently; fetch the existing link.
-		existingProvider, findErr := s.providers.FindByProvider(ctx, providerType, info.ProviderUserID)
-		if findErr != nil {
-			return nil, nil, findErr
+	s.logger.Info().Str("trace_id", traceID).Str("provider", providerType).Str("user_id", user.ID).Msg("oauth callback processed")
+	return s.signInOrChallenge(ctx, traceID, user)
+}
+
+func (s *authService) ConfirmAccountLink(ctx context.Context, traceID, linkID, code, password string) (*domain.User, *Tokens, error) {
+	link, err := s.links.FindByID(ctx, linkID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrAccountLinkExpired
 		}
-		providerRecord = existingProvider
+		return nil, nil, err
+	}
+	if !link.IsPending(time.Now().UTC()) || link.Attempts >= maxAccountLinkTries {
+		return nil, nil, ErrAccountLinkExpired
+	}
+	user, err := s.users.FindByID(ctx, link.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !user.IsActive {
+		return nil, nil, ErrUserInactive
 	}
 
-	if created {
-		if err := s.assignDefaultRole(ctx, user.ID); err != nil {
+	switch {
+	case password != "":
+		if !user.HasPassword() || user.PasswordResetRequired {
+			return nil, nil, ErrAccountLinkUnavailable
+		}
+	case code != "":
+		code = strings.TrimSpace(code)
+		if err := validateVerificationCode(code); err != nil {
+			return nil, nil, err
+		}
+		if link.VerificationUUID == "" {
+			return nil, nil, ErrAccountLinkUnavailable
+		}
+	default:
+		return nil, nil, errors.New("code or password required")
+	}
+
+	// The attempt is counted before the check, so concurrent guesses cannot
+	// get past the limit and every failed check counts.
+	claimed, err := s.links.ClaimAttempt(ctx, link.ID, maxAccountLinkTries)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !claimed {
+		return nil, nil, ErrAccountLinkExpired
+	}
+	if password != "" {
+		if !s.checkPassword(ctx, "", user, password) {
+			return nil, nil, ErrInvalidCredentials
+		}
+	} else {
+		result, err := s.tarantool.VerifyAccountLink(ctx, link.VerificationUUID, code)
+		if err != nil {
 			return nil, nil, err
 		}
+		if !strings.EqualFold(result.Email, user.Email) {
+			return nil, nil, ErrInvalidCredentials
+		}
+	}
+
+	if err := s.links.Consume(ctx, link.ID, time.Now().UTC()); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrAccountLinkExpired
+		}
+		return nil, nil, err
+	}
+
+	identity := &domain.UserIdentity{
+		UserID:         user.ID,
+		Provider:       domain.IdentityProvider(link.Provider),
+		ProviderUserID: link.ProviderUserID,
+		Email:          link.Email,
+		Metadata:       link.Metadata,
+	}
+	if err := s.linkIdentity(ctx, identity); err != nil {
+		return nil, nil, err
 	}
 
+	s.logger.Info().Str("trace_id", traceID).Str("provider", link.Provider).Str("user_id", user.ID).Msg("account link confirmed")
+	return s.signInOrChallenge(ctx, traceID, user)
+}
+
+func (s *authService) RestoreAccount(ctx context.Context, traceID, restoreToken string) (*domain.User, *Tokens, error) {
+	subject, err := s.signer.Verify(restoreTokenPurpose, restoreToken, time.Now())
+	if err != nil {
+		return nil, nil, ErrRestoreTokenInvalid
+	}
+	userID, deletedAt, ok := strings.Cut(subject, ":")
+	if !ok {
+		return nil, nil, ErrRestoreTokenInvalid
+	}
+	user, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrRestoreTokenInvalid
+		}
+		return nil, nil, err
+	}
+	// The token is bound to one deletion request so it cannot undo a later one.
+	if !user.IsPendingDeletion() || strconv.FormatInt(user.DeletedAt.UnixMicro(), 10) != deletedAt {
+		return nil, nil, ErrRestoreTokenInvalid
+	}
+	user.Restore()
+	if err := s.users.Update(ctx, user); err != nil {
+		return nil, nil, err
+	}
+	s.logger.Info().Str("trace_id", traceID).Str("user_id", user.ID).Msg("account restored")
+	if !user.IsActive {
+		return nil, nil, ErrUserInactive
+	}
 	role, err := s.resolveRole(ctx, user.ID)
 	if err != nil {
 		return nil, nil, err
 	}
+	tokens, err := s.issueTokens(ctx, user, role)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, tokens, nil
+}
+
+func (s *authService) RequestPasswordReset(ctx context.Context, traceID, email string) error {
+	user, err := s.users.FindByEmail(ctx, strings.ToLower(strings.TrimSpace(email)))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !user.IsActive || user.IsPendingDeletion() {
+		return nil
+	}
+	expires := time.Now().Add(s.cfg.PasswordResetTTL)
+	token := s.signer.Sign(resetTokenPurpose, resetSubject(user), expires)
+	resetURL := strings.TrimRight(s.cfg.AppPublicURL, "/") + "/password/reset?" + url.Values{"token": {token}}.Encode()
+	s.logger.Info().Str("trace_id", traceID).Str("user_id", user.ID).Msg("password reset requested")
+	if s.publisher != nil {
+		_ = s.publisher.Publish(ctx, "user.password_reset_requested", events.NewPasswordResetEvent(user.ID, user.Email, resetURL, expires, traceID))
+	}
+	return nil
+}
+
+func (s *authService) ResetPassword(ctx context.Context, traceID, token, password string) error {
+	if err := validatePassword(password); err != nil {
+		return err
+	}
+	subject, err := s.signer.Verify(resetTokenPurpose, token, time.Now())
+	if err != nil {
+		return ErrResetTokenInvalid
+	}
+	userID, _, ok := strings.Cut(subject, ":")
+	if !ok {
+		return ErrResetTokenInvalid
+	}
+	user, err := s.users.FindByID(ctx, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrResetTokenInvalid
+	}
+	if err != nil {
+		return err
+	}
+	if resetSubject(user) != subject {
+		return ErrResetTokenInvalid
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	user.SetPasswordHash(string(hash))
+	user.PasswordResetRequired = false
+	user.RevokeTokens(time.Now().UTC())
+	if err := s.users.Update(ctx, user); err != nil {
+		if errors.Is(err, repo.ErrVersionConflict) {
+			return ErrResetTokenInvalid
+		}
+		return err
+	}
+	s.logger.Info().Str("trace_id", traceID).Str("user_id", user.ID).Msg("password reset")
+	if s.publisher != nil {
+		_ = s.publisher.Publish(ctx, "user.password_changed", events.NewUserEvent("user.password_changed", user.ID, user.Email, traceID))
+	}
+	return nil
+}
+
+// resetSubject binds a reset link to the current version of the user, so
+// that it stops working once the password, or anything else on the user,
+// changes.
+func resetSubject(user *domain.User) string {
+	return user.ID + ":" + strconv.FormatInt(user.Version, 10)
+}
 
-	tokens, err := s.issueTokens(user, role)
+func (s *authService) AcceptInvitation(ctx context.Context, traceID, token, password string) (*domain.User, *Tokens, error) {
+	if err := validatePassword(password); err != nil {
+		return nil, nil, err
+	}
+	invitation, ctx, err := s.openInvitation(ctx, token)
 	if err != nil {
 		return nil, nil, err
 	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, nil, err
+	}
+	user := &domain.User{Email: invitation.Email, IsActive: true, Profile: &domain.UserProfile{}}
+	user.SetPasswordHash(string(hash))
+	return s.completeInvitation(ctx, traceID, invitation, user, nil)
+}
 
-	s.logger.Info().Str("trace_id", traceID).Str("provider", providerType).Str("user_id", user.ID).Msg("oauth callback processed")
+// acceptInvitationWithIdentity creates the invited account from an OAuth
+// sign-in. The provider must report the invited email.
+func (s *authService) acceptInvitationWithIdentity(ctx context.Context, traceID string, provider domain.IdentityProvider, info OAuthUserInfo) (*domain.User, *Tokens, error) {
+	invitation, ctx, err := s.openInvitation(ctx, info.InvitationToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	email := strings.ToLower(strings.TrimSpace(info.Email))
+	if email != invitation.Email {
+		return nil, nil, ErrInvitationEmailMismatch
+	}
+	if _, err := s.identities.FindByProviderUserID(ctx, provider, info.ProviderUserID); err == nil {
+		return nil, nil, ErrIdentityTaken
+	} else if errors.Is(err, repo.ErrIdentityInOtherTenant) {
+		return nil, nil, ErrIdentityOtherTenant
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, err
+	}
+	now := time.Now().UTC()
+	user := &domain.User{Email: email, IsActive: true, Profile: &domain.UserProfile{DisplayName: info.DisplayName}}
+	identity := domain.UserIdentity{
+		Provider:       provider,
+		ProviderUserID: info.ProviderUserID,
+		Email:          email,
+		DisplayName:    info.DisplayName,
+		AvatarURL:      info.AvatarURL,
+		Metadata:       info.Metadata,
+		LastUsedAt:     &now,
+	}
+	user, tokens, err := s.completeInvitation(ctx, traceID, invitation, user, []domain.UserIdentity{identity})
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.AvatarURL != nil && *info.AvatarURL != "" && s.avatars != nil {
+		s.avatars.Enqueue(AvatarJob{TraceID: traceID, UserID: user.ID, SourceURL: *info.AvatarURL})
+	}
+	return user, tokens, nil
+}
+
+// openInvitation checks token and returns its invitation together with ctx
+// moved into the tenant the invitee joins.
+func (s *authService) openInvitation(ctx context.Context, token string) (*domain.Invitation, context.Context, error) {
+	if s.invitations == nil {
+		return nil, ctx, ErrInvitationInvalid
+	}
+	invitation, err := openInvitation(ctx, s.signer, s.invitations, token)
+	if err != nil {
+		return nil, ctx, err
+	}
+	return invitation, invitationTenant(ctx, stringOrEmpty(invitation.OrgID)), nil
+}
+
+// completeInvitation consumes the invitation and creates user, with its
+// profile and identities, in one step, gives the new user the invited role
+// and signs the user in. An invitation revoked or accepted concurrently
+// creates nothing; the per-tenant unique email index keeps two acceptances
+// for the same address from both creating an account.
+func (s *authService) completeInvitation(ctx context.Context, traceID string, invitation *domain.Invitation, user *domain.User, identities []domain.UserIdentity) (*domain.User, *Tokens, error) {
+	if _, err := s.users.FindByEmail(ctx, user.Email); err == nil {
+		return nil, nil, ErrInvitationAccountExists
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, err
+	}
+	grant := func(userID string) error {
+		if s.rbac == nil {
+			return nil
+		}
+		return s.rbac.AssignRole(ctx, userID, invitation.Role)
+	}
+	if err := s.invitations.AcceptNewUser(ctx, invitation.ID, user, identities, time.Now().UTC(), grant); err != nil {
+		switch {
+		case errors.Is(err, repo.ErrInvitationClosed):
+			return nil, nil, ErrInvitationInvalid
+		case errors.Is(err, gorm.ErrDuplicatedKey):
+			return nil, nil, ErrInvitationAccountExists
+		}
+		return nil, nil, err
+	}
+	if s.publisher != nil {
+		_ = s.publisher.Publish(ctx, "user.created", events.NewUserEvent("user.created", user.ID, user.Email, traceID))
+	}
+	role, err := s.resolveRole(ctx, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	tokens, err := s.issueTokens(ctx, user, role)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.logger.Info().Str("trace_id", traceID).Str("invitation_id", invitation.ID).Str("user_id", user.ID).Msg("invitation accepted")
 	return user, tokens, nil
 }
 
-func (s *authService) issueTokens(user *domain.User, role string) (*Tokens, error) {
+// pendingDeletion offers an authenticated user whose account awaits purge a
+// short-lived token to restore it.
+func (s *authService) pendingDeletion(user *domain.User) error {
+	subject := user.ID + ":" + strconv.FormatInt(user.DeletedAt.UnixMicro(), 10)
+	expires := time.Now().Add(s.cfg.AccountRestoreTTL)
+	if user.PurgeAt != nil && user.PurgeAt.Before(expires) {
+		expires = *user.PurgeAt
+	}
+	pending := &AccountPendingDeletionError{RestoreToken: s.signer.Sign(restoreTokenPurpose, subject, expires)}
+	if user.PurgeAt != nil {
+		pending.PurgeAt = *user.PurgeAt
+	}
+	return pending
+}
+
+// linkIdentity stores a new identity. A concurrent request that already linked
+// the same identity to the same user is treated as success.
+func (s *authService) linkIdentity(ctx context.Context, identity *domain.UserIdentity) error {
+	now := time.Now().UTC()
+	identity.LastUsedAt = &now
+	err := s.identities.Create(ctx, identity)
+	if err == nil || !errors.Is(err, gorm.ErrDuplicatedKey) {
+		return err
+	}
+	existing, findErr := s.identities.FindByProviderUserID(ctx, identity.Provider, identity.ProviderUserID)
+	if findErr != nil {
+		if errors.Is(findErr, gorm.ErrRecordNotFound) {
+			return ErrProviderAlreadyLinked
+		}
+		if errors.Is(findErr, repo.ErrIdentityInOtherTenant) {
+			return ErrIdentityOtherTenant
+		}
+		return findErr
+	}
+	if existing.UserID != identity.UserID {
+		return ErrIdentityTaken
+	}
+	*identity = *existing
+	return nil
+}
+
+// startAccountLink records a pending link for an existing account and sends
+// the owner a confirmation code. The identity is attached by ConfirmAccountLink.
+func (s *authService) startAccountLink(ctx context.Context, traceID string, user *domain.User, info OAuthUserInfo) error {
+	link := &domain.AccountLink{
+		UserID:         user.ID,
+		Provider:       info.ProviderType,
+		ProviderUserID: info.ProviderUserID,
+		Email:          strings.ToLower(info.Email),
+		Metadata:       info.Metadata,
+		ExpiresAt:      time.Now().UTC().Add(s.cfg.AccountLinkTTL),
+	}
+	methods := make([]string, 0, 2)
+	if uuid, err := s.tarantool.StartAccountLink(ctx, user.ID, user.Email); err != nil {
+		s.logger.Warn().Err(err).Str("trace_id", traceID).Str("user_id", user.ID).Msg("account link code not sent")
+	} else {
+		link.VerificationUUID = uuid
+		methods = append(methods, LinkMethodCode)
+	}
+	if user.HasPassword() {
+		methods = append(methods, LinkMethodPassword)
+	}
+	if len(methods) == 0 {
+		return ErrAccountLinkUnavailable
+	}
+	if err := s.links.Create(ctx, link); err != nil {
+		return err
+	}
+	s.logger.Info().Str("trace_id", traceID).Str("provider", info.ProviderType).Str("user_id", user.ID).Msg("account link pending")
+	return &AccountLinkRequiredError{LinkID: link.ID, Methods: methods, ExpiresAt: link.ExpiresAt}
+}
+
+func (s *authService) linkPolicy(provider string) string {
+	if policy, ok := s.cfg.OAuthLinkPolicy[provider]; ok && strings.EqualFold(policy, LinkPolicyAuto) {
+		return LinkPolicyAuto
+	}
+	return LinkPolicyVerify
+}
+
+func (s *authService) issueTokens(ctx context.Context, user *domain.User, role string) (*Tokens, error) {
 	if role == "" {
 		role = defaultUserRole
 	}
@@ -309,6 +967,16 @@ func (s *authService) issueTokens(user *domain.User, role string) (*Tokens, erro
 		"role":  role,
 		"id":    user.ID,
 	}
+	if user.OrgID != nil {
+		claims["org_id"] = *user.OrgID
+	}
+	if s.cfg.JWTGroupsClaim && s.groups != nil {
+		groupIDs, err := s.groups.GroupIDs(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		claims["groups"] = groupIDs
+	}
 	access, err := s.jwtSigner.SignAccessToken(user.ID, claims, s.cfg.JWTTTLMinutes)
 	if err != nil {
 		return nil, err
@@ -357,8 +1025,13 @@ func validatePassword(password string) error {
 }
 
 func validateVerificationCode(code string) error {
-	if len(code) != 4 {
-		return errors.New("verification code must contain 4 digits")
+	return validateDigitCode(code, 4)
+}
+
+// validateDigitCode checks that code consists of exactly digits digits.
+func validateDigitCode(code string, digits int) error {
+	if len(code) != digits {
+		return fmt.Errorf("verification code must contain %d digits", digits)
 	}
 	for _, r := range code {
 		if r < '0' || r > '9' {
@@ -676,10 +1349,27 @@ func (s *authService) upsertOAuthUser(ctx context.Context, profile *oauthProfile
 			avatar := profile.AvatarURL
 			avatarPtr = &avatar
 		}
-		user.Profile.Update(dnPtr, avatarPtr)
-		if err := s.profiles.Update(ctx, user.Profile); err != nil {
+		if err := s.updateOAuthProfile(ctx, user, dnPtr, avatarPtr); err != nil {
 			return nil, err
 		}
 	}
 	return user, nil
 }
+
+// updateOAuthProfile copies the provider's name and picture onto the profile.
+// If the profile was edited since it was read, the copy is re-applied to the
+// fresh version instead of overwriting that edit.
+func (s *authService) updateOAuthProfile(ctx context.Context, user *domain.User, displayName, avatarURL *string) error {
+	for attempt := 1; ; attempt++ {
+		user.Profile.Update(displayName, avatarURL)
+		err := s.profiles.Update(ctx, user.Profile)
+		if !errors.Is(err, repo.ErrVersionConflict) || attempt == maxProfileUpdateAttempts {
+			return err
+		}
+		profile, err := s.profiles.FindByUserID(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		user.Profile = profile
+	}
+}
@@ -2,77 +2,50 @@ package service
 
 import (
 	"context"
-	"fmt"
-	"io"
-	"net/http"
-	"net/url"
-	"path"
-	"strings"
 	"time"
 
-	"github.com/example/user-service/internal/ports/filestorage"
 	pkglog "github.com/example/user-service/pkg/log"
+	"github.com/example/user-service/pkg/safehttp"
 )
 
 type AvatarIngestor interface {
-	Ingest(ctx context.Context, traceID, avatarURL string) (string, error)
+	Ingest(ctx context.Context, traceID, userID, avatarURL string) (*StoredAvatar, error)
 }
 
 type avatarIngestor struct {
-	storage filestorage.Client
+	avatars AvatarStore
 	logger  pkglog.Logger
-	client  *http.Client
-	limit   int64
+	fetcher *safehttp.Fetcher
 }
 
-func NewAvatarIngestor(storage filestorage.Client, logger pkglog.Logger) AvatarIngestor {
+func NewAvatarIngestor(avatars AvatarStore, logger pkglog.Logger) AvatarIngestor {
 	return &avatarIngestor{
-		storage: storage,
+		avatars: avatars,
 		logger:  logger,
-		client:  &http.Client{Timeout: 5 * time.Second},
-		limit:   5 * 1024 * 1024,
+		fetcher: safehttp.NewFetcher(safehttp.Options{
+			AllowedSchemes:      []string{"https"},
+			AllowedContentTypes: []string{"image/jpeg", "image/png", "image/gif"},
+			MaxRedirects:        3,
+			MaxBytes:            5 * 1024 * 1024,
+			Timeout:             5 * time.Second,
+		}),
 	}
 }
 
-func (a *avatarIngestor) Ingest(ctx context.Context, traceID, avatarURL string) (string, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, avatarURL, nil)
+// Ingest copies a remote avatar into filestorage. The URL may come from client
+// input, so the fetch goes through safehttp which blocks internal addresses,
+// and the image goes through AvatarStore like an upload does.
+func (a *avatarIngestor) Ingest(ctx context.Context, traceID, userID, avatarURL string) (*StoredAvatar, error) {
+	fetched, err := a.fetcher.Get(ctx, avatarURL)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	res, err := a.client.Do(req)
+	stored, err := a.avatars.Store(ctx, userID, fetched.Body)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	defer res.Body.Close()
 
-	if res.StatusCode >= 400 {
-		return "", fmt.Errorf("avatar fetch failed: status %d", res.StatusCode)
-	}
-
-	body, err := io.ReadAll(io.LimitReader(res.Body, a.limit))
-	if err != nil {
-		return "", err
-	}
-
-	fileName := deriveFileName(avatarURL)
-	uploadedURL, err := a.storage.Upload(ctx, fileName, res.Header.Get("Content-Type"), body)
-	if err != nil {
-		return "", err
-	}
-
-	a.logger.Info().Str("trace_id", traceID).Str("avatar", avatarURL).Str("stored_url", uploadedURL).Msg("avatar ingested")
-	return uploadedURL, nil
-}
-
-func deriveFileName(rawURL string) string {
-	parsed, err := url.Parse(rawURL)
-	if err != nil {
-		return "avatar"
-	}
-	base := path.Base(parsed.Path)
-	if base == "." || base == "/" || strings.TrimSpace(base) == "" {
-		return "avatar"
-	}
-	return base
+	a.logger.Info().Str("trace_id", traceID).Str("avatar", avatarURL).Str("stored_url", stored.URL).Msg("avatar ingested")
+	return stored, nil
 }
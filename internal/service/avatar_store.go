@@ -0,0 +1,213 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // register GIF decoder for uploads
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/example/user-service/config"
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/ports/filestorage"
+)
+
+var (
+	ErrUnsupportedImage    = errors.New("unsupported image type")
+	ErrImageTooLarge       = errors.New("image exceeds size limit")
+	ErrAvatarURLNotAllowed = errors.New("avatar url must point to our file storage")
+)
+
+// StoredAvatar holds the canonical URLs of an uploaded avatar keyed by
+// thumbnail edge length in pixels.
+type StoredAvatar struct {
+	URL        string
+	Thumbnails map[string]string
+}
+
+// AvatarStore validates, re-encodes and uploads avatar images.
+type AvatarStore interface {
+	Store(ctx context.Context, userID string, data []byte) (*StoredAvatar, error)
+	IsOwnURL(rawURL string) bool
+	// Remove deletes every avatar file of profile held in our file storage.
+	Remove(ctx context.Context, profile *domain.UserProfile) error
+}
+
+type avatarStore struct {
+	storage     filestorage.Client
+	maxBytes    int64
+	maxPixels   int
+	thumbnails  []int
+	storageHost string
+}
+
+func NewAvatarStore(cfg *config.Config, storage filestorage.Client) AvatarStore {
+	publicURL := cfg.FileStoragePublicURL
+	if publicURL == "" {
+		publicURL = cfg.FileStorageURL
+	}
+	host := ""
+	if parsed, err := url.Parse(publicURL); err == nil {
+		host = strings.ToLower(parsed.Hostname())
+	}
+	return &avatarStore{
+		storage:     storage,
+		maxBytes:    cfg.AvatarMaxBytes,
+		maxPixels:   cfg.AvatarMaxPixels,
+		thumbnails:  cfg.AvatarThumbnailSizes,
+		storageHost: host,
+	}
+}
+
+func (s *avatarStore) Store(ctx context.Context, userID string, data []byte) (*StoredAvatar, error) {
+	if s.maxBytes > 0 && int64(len(data)) > s.maxBytes {
+		return nil, ErrImageTooLarge
+	}
+	// Trust the magic bytes, never the client supplied content type.
+	switch http.DetectContentType(data) {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return nil, ErrUnsupportedImage
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, ErrUnsupportedImage
+	}
+	if s.maxPixels > 0 && cfg.Width*cfg.Height > s.maxPixels {
+		return nil, ErrImageTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+
+	// Re-encoding drops EXIF and any other metadata carried by the upload.
+	original, contentType, ext, err := encodeAvatar(img, format)
+	if err != nil {
+		return nil, err
+	}
+	originalURL, err := s.storage.Upload(ctx, fmt.Sprintf("%s-original.%s", userID, ext), contentType, original)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := &StoredAvatar{URL: originalURL, Thumbnails: make(map[string]string, len(s.thumbnails))}
+	for _, size := range s.thumbnails {
+		if size <= 0 {
+			continue
+		}
+		thumb, thumbType, thumbExt, err := encodeAvatar(squareThumbnail(img, size), format)
+		if err != nil {
+			return nil, err
+		}
+		thumbURL, err := s.storage.Upload(ctx, fmt.Sprintf("%s-%d.%s", userID, size, thumbExt), thumbType, thumb)
+		if err != nil {
+			return nil, err
+		}
+		stored.Thumbnails[strconv.Itoa(size)] = thumbURL
+	}
+	return stored, nil
+}
+
+func (s *avatarStore) IsOwnURL(rawURL string) bool {
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || s.storageHost == "" {
+		return false
+	}
+	if parsed.Scheme != "https" && parsed.Scheme != "http" {
+		return false
+	}
+	return strings.ToLower(parsed.Hostname()) == s.storageHost
+}
+
+func (s *avatarStore) Remove(ctx context.Context, profile *domain.UserProfile) error {
+	var urls []string
+	if profile.AvatarURL != nil {
+		urls = append(urls, *profile.AvatarURL)
+	}
+	for _, thumb := range profile.AvatarThumbnails {
+		if thumbURL, ok := thumb.(string); ok {
+			urls = append(urls, thumbURL)
+		}
+	}
+	for _, fileURL := range urls {
+		if !s.IsOwnURL(fileURL) {
+			continue
+		}
+		if err := s.storage.Delete(ctx, fileURL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeAvatar writes JPEG sources back as JPEG and everything else as PNG so
+// transparency survives.
+func encodeAvatar(img image.Image, format string) ([]byte, string, string, error) {
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+			return nil, "", "", err
+		}
+		return buf.Bytes(), "image/jpeg", "jpg", nil
+	}
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, "", "", err
+	}
+	return buf.Bytes(), "image/png", "png", nil
+}
+
+// squareThumbnail center-crops img to a square and box-filters it to size x size.
+func squareThumbnail(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	offX := bounds.Min.X + (bounds.Dx()-side)/2
+	offY := bounds.Min.Y + (bounds.Dy()-side)/2
+
+	src := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(src, src.Bounds(), img, image.Pt(offX, offY), draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		y0 := y * side / size
+		y1 := (y + 1) * side / size
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < size; x++ {
+			x0 := x * side / size
+			x1 := (x + 1) * side / size
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var r, g, b, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := src.RGBAAt(sx, sy)
+					r += uint32(c.R)
+					g += uint32(c.G)
+					b += uint32(c.B)
+					a += uint32(c.A)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: uint8(a / n)})
+		}
+	}
+	return dst
+}
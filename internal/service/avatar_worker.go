@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/cenkalti/backoff/v4"
+
+	"github.com/example/user-service/config"
+	"github.com/example/user-service/internal/events"
+	"github.com/example/user-service/internal/ports/broker"
+	"github.com/example/user-service/internal/repo"
+	pkglog "github.com/example/user-service/pkg/log"
+	"github.com/example/user-service/pkg/safehttp"
+)
+
+// AvatarJob asks the worker to copy a provider avatar into filestorage.
+type AvatarJob struct {
+	TraceID   string
+	UserID    string
+	SourceURL string
+}
+
+// AvatarQueue accepts avatar ingestion jobs without blocking the caller.
+type AvatarQueue interface {
+	Enqueue(job AvatarJob) bool
+}
+
+type AvatarWorker struct {
+	cfg       *config.Config
+	logger    pkglog.Logger
+	ingestor  AvatarIngestor
+	profiles  repo.UserProfileRepository
+	publisher broker.Publisher
+	jobs      chan AvatarJob
+}
+
+func NewAvatarWorker(cfg *config.Config, logger pkglog.Logger, ingestor AvatarIngestor, profiles repo.UserProfileRepository, publisher broker.Publisher) *AvatarWorker {
+	size := cfg.AvatarQueueSize
+	if size <= 0 {
+		size = 1
+	}
+	return &AvatarWorker{
+		cfg:       cfg,
+		logger:    logger,
+		ingestor:  ingestor,
+		profiles:  profiles,
+		publisher: publisher,
+		jobs:      make(chan AvatarJob, size),
+	}
+}
+
+// Enqueue schedules a job and reports false when the queue is full.
+func (w *AvatarWorker) Enqueue(job AvatarJob) bool {
+	select {
+	case w.jobs <- job:
+		return true
+	default:
+		w.logger.Warn().Str("trace_id", job.TraceID).Str("user_id", job.UserID).Msg("avatar queue full, job dropped")
+		return false
+	}
+}
+
+// Run processes jobs until ctx is cancelled.
+func (w *AvatarWorker) Run(ctx context.Context) {
+	workers := w.cfg.AvatarWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-w.jobs:
+					w.process(ctx, job)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (w *AvatarWorker) process(ctx context.Context, job AvatarJob) {
+	var stored *StoredAvatar
+	op := func() error {
+		avatar, err := w.ingestor.Ingest(ctx, job.TraceID, job.UserID, job.SourceURL)
+		if err != nil {
+			if safehttp.IsPolicyViolation(err) || errors.Is(err, ErrUnsupportedImage) || errors.Is(err, ErrImageTooLarge) {
+				return backoff.Permanent(err)
+			}
+			w.logger.Warn().Err(err).Str("trace_id", job.TraceID).Str("user_id", job.UserID).Msg("avatar ingest attempt failed")
+			return err
+		}
+		stored = avatar
+		return nil
+	}
+
+	bo := backoff.NewExponentialBackOff()
+	bo.InitialInterval = w.cfg.AvatarRetryInterval
+	bo.MaxElapsedTime = 0
+	attempts := w.cfg.AvatarIngestAttempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+	if err := backoff.Retry(op, backoff.WithContext(backoff.WithMaxRetries(bo, uint64(attempts-1)), ctx)); err != nil {
+		w.logger.Error().Err(err).Str("trace_id", job.TraceID).Str("user_id", job.UserID).Msg("avatar ingest failed")
+		return
+	}
+
+	for attempt := 1; ; attempt++ {
+		profile, err := w.profiles.FindByUserID(ctx, job.UserID)
+		if err != nil {
+			w.logger.Error().Err(err).Str("trace_id", job.TraceID).Str("user_id", job.UserID).Msg("avatar profile lookup failed")
+			return
+		}
+		if profile.AvatarURL != nil {
+			// The user picked an avatar while the job was running; keep their choice.
+			return
+		}
+		profile.SetAvatar(stored.URL, stored.Thumbnails)
+		err = w.profiles.Update(ctx, profile)
+		if errors.Is(err, repo.ErrVersionConflict) && attempt < maxProfileUpdateAttempts {
+			// The profile was edited meanwhile; re-check the avatar on the fresh copy.
+			continue
+		}
+		if err != nil {
+			w.logger.Error().Err(err).Str("trace_id", job.TraceID).Str("user_id", job.UserID).Msg("avatar profile update failed")
+			return
+		}
+		break
+	}
+	if w.publisher != nil {
+		_ = w.publisher.Publish(ctx, "user.avatar_updated", events.NewUserAvatarEvent("user.avatar_updated", job.UserID, stored.URL, job.TraceID))
+	}
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/ports/tarantool"
+	"github.com/example/user-service/internal/repo"
+	pkglog "github.com/example/user-service/pkg/log"
+)
+
+// MaxUserEmails caps the addresses of one user, the primary one included.
+const MaxUserEmails = 10
+
+var (
+	ErrEmailTaken      = errors.New("email address already in use")
+	ErrEmailNotFound   = errors.New("email address not found")
+	ErrEmailUnverified = errors.New("email address not verified")
+	ErrEmailPrimary    = errors.New("primary email address cannot be removed")
+	ErrEmailLimit      = errors.New("too many email addresses")
+)
+
+// EmailService manages the secondary addresses of a user. Secondary
+// addresses are confirmed with the same code flow as an email change.
+type EmailService interface {
+	List(ctx context.Context, userID string) ([]domain.UserEmail, error)
+	// Add sends a verification code to a new address and returns the ID of
+	// the verification. Adding a pending address again sends a new code.
+	Add(ctx context.Context, userID, email string) (string, error)
+	Verify(ctx context.Context, userID, uuid, code string) (*domain.UserEmail, error)
+	// MakePrimary promotes a verified address; the previous primary address
+	// stays as a secondary one.
+	MakePrimary(ctx context.Context, userID, id string) (*domain.User, error)
+	Remove(ctx context.Context, userID, id string) error
+}
+
+type emailService struct {
+	logger    pkglog.Logger
+	users     repo.UserRepository
+	emails    repo.UserEmailRepository
+	tarantool tarantool.Client
+}
+
+func NewEmailService(logger pkglog.Logger, users repo.UserRepository, emails repo.UserEmailRepository, tarantoolClient tarantool.Client) EmailService {
+	return &emailService{logger: logger, users: users, emails: emails, tarantool: tarantoolClient}
+}
+
+func (s *emailService) List(ctx context.Context, userID string) ([]domain.UserEmail, error) {
+	return s.emails.List(ctx, userID)
+}
+
+func (s *emailService) Add(ctx context.Context, userID, email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if err := validateEmail(email); err != nil {
+		return "", err
+	}
+	own, err := s.emails.List(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	pending := false
+	for _, existing := range own {
+		if existing.Email == email {
+			if existing.IsVerified() {
+				return "", ErrEmailTaken
+			}
+			pending = true
+		}
+	}
+	if !pending {
+		if len(own) >= MaxUserEmails {
+			return "", ErrEmailLimit
+		}
+		if _, err := s.users.FindByEmail(ctx, email); err == nil {
+			return "", ErrEmailTaken
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", err
+		}
+		user, err := s.users.FindByID(ctx, userID)
+		if err != nil {
+			return "", err
+		}
+		if err := s.emails.Add(ctx, &domain.UserEmail{UserID: userID, OrgID: user.OrgID, Email: email}); err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return "", ErrEmailTaken
+			}
+			return "", err
+		}
+	}
+	return s.tarantool.StartEmailChange(ctx, userID, email)
+}
+
+func (s *emailService) Verify(ctx context.Context, userID, uuid, code string) (*domain.UserEmail, error) {
+	code = strings.TrimSpace(code)
+	if err := validateVerificationCode(code); err != nil {
+		return nil, err
+	}
+	result, err := s.tarantool.VerifyEmailChange(ctx, uuid, code)
+	if err != nil {
+		return nil, err
+	}
+	email, err := s.emails.MarkVerified(ctx, userID, strings.ToLower(strings.TrimSpace(result.Email)), time.Now())
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, ErrEmailNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return nil, ErrEmailTaken
+	case err != nil:
+		return nil, err
+	}
+	return email, nil
+}
+
+func (s *emailService) MakePrimary(ctx context.Context, userID, id string) (*domain.User, error) {
+	email, err := s.find(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if !email.IsVerified() {
+		return nil, ErrEmailUnverified
+	}
+	user, err := s.emails.SetPrimary(ctx, userID, id)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, ErrEmailNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return nil, ErrEmailTaken
+	case err != nil:
+		return nil, err
+	}
+	s.logger.Info().Str("user_id", userID).Msg("primary email changed")
+	return user, nil
+}
+
+func (s *emailService) Remove(ctx context.Context, userID, id string) error {
+	email, err := s.find(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if email.IsPrimary {
+		return ErrEmailPrimary
+	}
+	if err := s.emails.Delete(ctx, userID, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrEmailNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *emailService) find(ctx context.Context, userID, id string) (*domain.UserEmail, error) {
+	if !uuidPattern.MatchString(id) {
+		return nil, ErrEmailNotFound
+	}
+	email, err := s.emails.FindByID(ctx, userID, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrEmailNotFound
+	}
+	return email, err
+}
@@ -0,0 +1,316 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/example/user-service/config"
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/repo"
+	pkglog "github.com/example/user-service/pkg/log"
+	"github.com/example/user-service/pkg/signedtoken"
+)
+
+const (
+	// ExportFormatVersion is bumped whenever the archive layout changes in a
+	// way consumers have to know about.
+	ExportFormatVersion = 1
+	exportFileName      = "user-data.json"
+	exportTokenPurpose  = "data-export"
+	exportBatchSize     = 10
+)
+
+var (
+	ErrExportNotReady    = errors.New("export is not ready")
+	ErrExportLinkInvalid = errors.New("export link invalid or expired")
+)
+
+// ExportArchive is the JSON document stored in every export zip. Emails
+// lists the primary and every secondary address; Preferences holds every
+// registered preference, defaults included.
+type ExportArchive struct {
+	FormatVersion int                    `json:"format_version"`
+	GeneratedAt   time.Time              `json:"generated_at"`
+	User          ExportUser             `json:"user"`
+	Profile       *domain.UserProfile    `json:"profile"`
+	Emails        []domain.UserEmail     `json:"emails"`
+	Identities    []domain.UserIdentity  `json:"identities"`
+	Preferences   map[string]interface{} `json:"preferences"`
+	Sessions      ExportSessions         `json:"sessions"`
+	AuditEvents   []domain.AuditEvent    `json:"audit_events"`
+}
+
+// ExportUser lists the account fields of the archive explicitly so the format
+// does not drift with domain.User.
+type ExportUser struct {
+	ID               string     `json:"id"`
+	Email            string     `json:"email"`
+	Username         *string    `json:"username"`
+	HasPassword      bool       `json:"has_password"`
+	IsActive         bool       `json:"is_active"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	SuspendedAt      *time.Time `json:"suspended_at"`
+	SuspendedUntil   *time.Time `json:"suspended_until"`
+	SuspensionReason *string    `json:"suspension_reason"`
+	DeletedAt        *time.Time `json:"deleted_at"`
+	PurgeAt          *time.Time `json:"purge_at"`
+	Phone            *string    `json:"phone"`
+	PhoneVerifiedAt  *time.Time `json:"phone_verified_at"`
+	PhoneMFA         bool       `json:"phone_mfa"`
+}
+
+// ExportSessions describes sign-in state. Access tokens are stateless JWTs,
+// so the service only knows when tokens were last revoked and when each
+// identity was last used to sign in.
+type ExportSessions struct {
+	TokensRevokedAt *time.Time          `json:"tokens_revoked_at"`
+	LastSignIns     []ExportIdentityUse `json:"last_sign_ins"`
+}
+
+type ExportIdentityUse struct {
+	Provider   domain.IdentityProvider `json:"provider"`
+	LastUsedAt *time.Time              `json:"last_used_at"`
+}
+
+type ExportService interface {
+	RequestExport(ctx context.Context, traceID, actorID, userID string) (*domain.DataExport, error)
+	// GetExport returns gorm.ErrRecordNotFound for exports of users outside
+	// the tenant of ctx.
+	GetExport(ctx context.Context, exportID string) (*domain.DataExport, error)
+	// DownloadURL returns the signed link for a ready export.
+	DownloadURL(export *domain.DataExport) string
+	Download(ctx context.Context, traceID, token string) (*domain.DataExport, error)
+	ProcessPending(ctx context.Context) (int, error)
+	PurgeExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+type exportService struct {
+	cfg         *config.Config
+	logger      pkglog.Logger
+	users       repo.UserRepository
+	identities  repo.UserIdentityRepository
+	emails      repo.UserEmailRepository
+	preferences PreferenceService
+	audit       repo.AuditRepository
+	exports     repo.DataExportRepository
+	links       *signedtoken.Signer
+}
+
+func NewExportService(cfg *config.Config, logger pkglog.Logger, users repo.UserRepository, identities repo.UserIdentityRepository, emails repo.UserEmailRepository, preferences PreferenceService, audit repo.AuditRepository, exports repo.DataExportRepository) ExportService {
+	return &exportService{
+		cfg:         cfg,
+		logger:      logger,
+		users:       users,
+		identities:  identities,
+		emails:      emails,
+		preferences: preferences,
+		audit:       audit,
+		exports:     exports,
+		links:       signedtoken.NewSigner([]byte(cfg.SignedTokenSecret)),
+	}
+}
+
+func (s *exportService) RequestExport(ctx context.Context, traceID, actorID, userID string) (*domain.DataExport, error) {
+	if _, err := s.users.FindByID(ctx, userID); err != nil {
+		return nil, err
+	}
+	export := &domain.DataExport{UserID: userID, RequestedBy: actorID, Status: domain.ExportPending}
+	if err := s.exports.Create(ctx, export); err != nil {
+		return nil, err
+	}
+	recordAudit(ctx, s.audit, s.logger, &domain.AuditEvent{
+		UserID:   userID,
+		ActorID:  actorID,
+		Action:   domain.AuditExportRequested,
+		Metadata: domain.JSONMap{"export_id": export.ID},
+		TraceID:  traceID,
+	})
+	s.logger.Info().Str("trace_id", traceID).Str("user_id", userID).Str("export_id", export.ID).Msg("data export requested")
+	return export, nil
+}
+
+func (s *exportService) GetExport(ctx context.Context, exportID string) (*domain.DataExport, error) {
+	export, err := s.exports.FindByID(ctx, exportID)
+	if err != nil {
+		return nil, err
+	}
+	// Exports carry no tenant of their own; the user lookup is tenant scoped.
+	if _, err := s.users.FindByID(ctx, export.UserID); err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+func (s *exportService) DownloadURL(export *domain.DataExport) string {
+	if export.Status != domain.ExportReady || export.ExpiresAt == nil {
+		return ""
+	}
+	token := s.links.Sign(exportTokenPurpose, export.ID, *export.ExpiresAt)
+	return strings.TrimRight(s.cfg.AppPublicURL, "/") + "/exports/download?" + url.Values{"token": {token}}.Encode()
+}
+
+func (s *exportService) Download(ctx context.Context, traceID, token string) (*domain.DataExport, error) {
+	now := time.Now()
+	exportID, err := s.links.Verify(exportTokenPurpose, token, now)
+	if err != nil {
+		return nil, ErrExportLinkInvalid
+	}
+	export, err := s.exports.FindByID(ctx, exportID)
+	if err != nil {
+		return nil, ErrExportLinkInvalid
+	}
+	if !export.IsDownloadable(now) {
+		return nil, ErrExportLinkInvalid
+	}
+	recordAudit(ctx, s.audit, s.logger, &domain.AuditEvent{
+		UserID:   export.UserID,
+		Action:   domain.AuditExportDownloaded,
+		Metadata: domain.JSONMap{"export_id": export.ID},
+		TraceID:  traceID,
+	})
+	return export, nil
+}
+
+func (s *exportService) ProcessPending(ctx context.Context) (int, error) {
+	now := time.Now().UTC()
+	staleBefore := now.Add(-s.cfg.DataExportClaimTimeout)
+	pending, err := s.exports.ListPending(ctx, staleBefore, exportBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	processed := 0
+	for i := range pending {
+		claimed, err := s.exports.Claim(ctx, pending[i].ID, now, staleBefore)
+		if err != nil {
+			return processed, err
+		}
+		if !claimed {
+			continue
+		}
+		pending[i].Status = domain.ExportRunning
+		pending[i].ClaimedAt = &now
+		s.build(ctx, &pending[i])
+		processed++
+	}
+	return processed, nil
+}
+
+func (s *exportService) PurgeExpired(ctx context.Context, now time.Time) (int64, error) {
+	return s.exports.DeleteExpired(ctx, now)
+}
+
+func (s *exportService) build(ctx context.Context, export *domain.DataExport) {
+	archive, err := s.buildArchive(ctx, export.UserID)
+	now := time.Now().UTC()
+	export.CompletedAt = &now
+	if err != nil {
+		msg := err.Error()
+		export.Status = domain.ExportFailed
+		export.Error = &msg
+		s.logger.Error().Err(err).Str("user_id", export.UserID).Str("export_id", export.ID).Msg("data export failed")
+	} else {
+		expiresAt := now.Add(s.cfg.DataExportTTL)
+		export.Status = domain.ExportReady
+		export.Archive = archive
+		export.ExpiresAt = &expiresAt
+	}
+	if err := s.exports.Update(ctx, export); err != nil {
+		s.logger.Error().Err(err).Str("user_id", export.UserID).Str("export_id", export.ID).Msg("data export not saved")
+		// Leaving the export running would hide it from the user until the
+		// claim times out; try to record the failure instead. The archive
+		// may be what made the first save fail.
+		msg := "export could not be saved"
+		export.Status = domain.ExportFailed
+		export.Error = &msg
+		export.Archive = nil
+		export.ExpiresAt = nil
+		if err := s.exports.Update(ctx, export); err != nil {
+			s.logger.Error().Err(err).Str("user_id", export.UserID).Str("export_id", export.ID).Msg("data export failure not saved")
+		}
+	}
+}
+
+func (s *exportService) buildArchive(ctx context.Context, userID string) ([]byte, error) {
+	user, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	identities, err := s.identities.FindByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var emails []domain.UserEmail
+	if s.emails != nil {
+		if emails, err = s.emails.List(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	var preferences map[string]interface{}
+	if s.preferences != nil {
+		if preferences, err = s.preferences.Get(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	var auditEvents []domain.AuditEvent
+	if s.audit != nil {
+		if auditEvents, err = s.audit.ListByUserID(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	doc := ExportArchive{
+		FormatVersion: ExportFormatVersion,
+		GeneratedAt:   time.Now().UTC(),
+		User: ExportUser{
+			ID:               user.ID,
+			Email:            user.Email,
+			Username:         user.Username,
+			HasPassword:      user.HasPassword(),
+			IsActive:         user.IsActive,
+			CreatedAt:        user.CreatedAt,
+			UpdatedAt:        user.UpdatedAt,
+			SuspendedAt:      user.SuspendedAt,
+			SuspendedUntil:   user.SuspendedUntil,
+			SuspensionReason: user.SuspensionReason,
+			DeletedAt:        user.DeletedAt,
+			PurgeAt:          user.PurgeAt,
+			Phone:            user.Phone,
+			PhoneVerifiedAt:  user.PhoneVerifiedAt,
+			PhoneMFA:         user.PhoneMFA,
+		},
+		Profile:     user.Profile,
+		Emails:      emails,
+		Identities:  identities,
+		Preferences: preferences,
+		Sessions:    ExportSessions{TokensRevokedAt: user.TokensRevokedAt},
+		AuditEvents: auditEvents,
+	}
+	for _, identity := range identities {
+		doc.Sessions.LastSignIns = append(doc.Sessions.LastSignIns, ExportIdentityUse{Provider: identity.Provider, LastUsedAt: identity.LastUsedAt})
+	}
+
+	payload, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create(exportFileName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,368 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/events"
+	"github.com/example/user-service/internal/ports/broker"
+	"github.com/example/user-service/internal/ports/rbac"
+	"github.com/example/user-service/internal/repo"
+	pkglog "github.com/example/user-service/pkg/log"
+)
+
+// maxTokenGroups caps the group IDs carried in an access token.
+const maxTokenGroups = 100
+
+var (
+	ErrGroupNotFound       = errors.New("group not found")
+	ErrGroupForbidden      = errors.New("not allowed to manage this group")
+	ErrGroupLastOwner      = errors.New("group must keep at least one owner")
+	ErrGroupMemberNotFound = errors.New("user is not a direct member of this group")
+	ErrGroupUserAbsent     = errors.New("user does not belong to this tenant")
+	ErrGroupCycle          = errors.New("group cannot be nested in its own subgroup")
+	ErrSubgroupNotFound    = errors.New("group is not a direct subgroup")
+)
+
+// GroupService manages the groups of the tenant in ctx. Changes require an
+// owner of the group or domain.PermGroupsWrite; any user of the tenant may
+// read groups and their members.
+type GroupService interface {
+	// Create makes actorID the first owner of the new group.
+	Create(ctx context.Context, traceID, actorID, name string, description *string) (*domain.Group, error)
+	Get(ctx context.Context, groupID string) (*domain.Group, error)
+	// Update changes the fields that are not nil.
+	Update(ctx context.Context, traceID, actorID, groupID string, name, description *string) (*domain.Group, error)
+	Delete(ctx context.Context, traceID, actorID, groupID string) error
+	// AddMember adds userID or changes its role.
+	AddMember(ctx context.Context, traceID, actorID, groupID, userID string, role domain.GroupRole) (*domain.GroupMember, error)
+	// RemoveMember is also open to the member itself.
+	RemoveMember(ctx context.Context, traceID, actorID, groupID, userID string) error
+	// AddSubgroup requires ownership of both groups.
+	AddSubgroup(ctx context.Context, traceID, actorID, parentID, childID string) error
+	RemoveSubgroup(ctx context.Context, traceID, actorID, parentID, childID string) error
+	ListSubgroups(ctx context.Context, groupID string) ([]domain.Group, error)
+	// ListMembers and ListUserGroups include memberships through nested
+	// subgroups when effective is set. ListMembers shows the email of other
+	// members only as their visibility settings allow, unless actorID owns
+	// the group or holds domain.PermUsersRead.
+	ListMembers(ctx context.Context, actorID, groupID string, effective bool, offset, limit int) ([]repo.GroupMemberEntry, error)
+	ListUserGroups(ctx context.Context, userID string, effective bool, offset, limit int) ([]domain.Group, error)
+	// GroupIDs returns the IDs of every group userID is an effective member
+	// of, up to maxTokenGroups.
+	GroupIDs(ctx context.Context, userID string) ([]string, error)
+}
+
+type groupService struct {
+	logger    pkglog.Logger
+	groups    repo.GroupRepository
+	users     repo.UserRepository
+	rbac      rbac.Client
+	audit     repo.AuditRepository
+	publisher broker.Publisher
+}
+
+func NewGroupService(logger pkglog.Logger, groups repo.GroupRepository, users repo.UserRepository, rbacClient rbac.Client, audit repo.AuditRepository, publisher broker.Publisher) GroupService {
+	return &groupService{logger: logger, groups: groups, users: users, rbac: rbacClient, audit: audit, publisher: publisher}
+}
+
+func (s *groupService) Create(ctx context.Context, traceID, actorID, name string, description *string) (*domain.Group, error) {
+	name, err := domain.NormalizeGroupName(name)
+	if err != nil {
+		return nil, err
+	}
+	group := &domain.Group{Name: name, Description: trimDescription(description), CreatedBy: actorID}
+	owner := &domain.GroupMember{UserID: actorID, Role: domain.GroupRoleOwner}
+	if err := s.groups.Create(ctx, group, owner); err != nil {
+		return nil, err
+	}
+	s.logger.Info().Str("trace_id", traceID).Str("actor_id", actorID).Str("group_id", group.ID).Msg("group created")
+	s.memberAdded(ctx, traceID, actorID, owner)
+	return group, nil
+}
+
+func (s *groupService) Get(ctx context.Context, groupID string) (*domain.Group, error) {
+	return s.find(ctx, groupID)
+}
+
+func (s *groupService) Update(ctx context.Context, traceID, actorID, groupID string, name, description *string) (*domain.Group, error) {
+	group, err := s.find(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.authorize(ctx, actorID, group.ID); err != nil {
+		return nil, err
+	}
+	if name != nil {
+		normalized, err := domain.NormalizeGroupName(*name)
+		if err != nil {
+			return nil, err
+		}
+		group.Name = normalized
+	}
+	if description != nil {
+		group.Description = trimDescription(description)
+	}
+	if err := s.groups.Update(ctx, group); err != nil {
+		return nil, err
+	}
+	s.logger.Info().Str("trace_id", traceID).Str("actor_id", actorID).Str("group_id", group.ID).Msg("group updated")
+	return group, nil
+}
+
+func (s *groupService) Delete(ctx context.Context, traceID, actorID, groupID string) error {
+	group, err := s.find(ctx, groupID)
+	if err != nil {
+		return err
+	}
+	if err := s.authorize(ctx, actorID, group.ID); err != nil {
+		return err
+	}
+	if err := s.groups.Delete(ctx, group.ID); err != nil {
+		return err
+	}
+	s.logger.Info().Str("trace_id", traceID).Str("actor_id", actorID).Str("group_id", group.ID).Msg("group deleted")
+	return nil
+}
+
+func (s *groupService) AddMember(ctx context.Context, traceID, actorID, groupID, userID string, role domain.GroupRole) (*domain.GroupMember, error) {
+	if role == "" {
+		role = domain.GroupRoleMember
+	}
+	if !role.IsValid() {
+		return nil, domain.ErrGroupRoleInvalid
+	}
+	group, err := s.find(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.authorize(ctx, actorID, group.ID); err != nil {
+		return nil, err
+	}
+	if _, err := s.users.FindByID(ctx, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrGroupUserAbsent
+		}
+		return nil, err
+	}
+	member := &domain.GroupMember{GroupID: group.ID, UserID: userID, Role: role}
+	created, err := s.groups.SaveMember(ctx, member)
+	if err != nil {
+		if errors.Is(err, repo.ErrGroupLastOwner) {
+			return nil, ErrGroupLastOwner
+		}
+		return nil, err
+	}
+	if created {
+		s.memberAdded(ctx, traceID, actorID, member)
+	} else {
+		s.logger.Info().Str("trace_id", traceID).Str("group_id", group.ID).Str("user_id", userID).Str("role", string(role)).Msg("group role changed")
+	}
+	return member, nil
+}
+
+func (s *groupService) RemoveMember(ctx context.Context, traceID, actorID, groupID, userID string) error {
+	group, err := s.find(ctx, groupID)
+	if err != nil {
+		return err
+	}
+	if actorID != userID {
+		if err := s.authorize(ctx, actorID, group.ID); err != nil {
+			return err
+		}
+	}
+	if err := s.groups.DeleteMember(ctx, group.ID, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrGroupMemberNotFound
+		}
+		if errors.Is(err, repo.ErrGroupLastOwner) {
+			return ErrGroupLastOwner
+		}
+		return err
+	}
+	s.logger.Info().Str("trace_id", traceID).Str("actor_id", actorID).Str("group_id", group.ID).Str("user_id", userID).Msg("group member removed")
+	recordAudit(ctx, s.audit, s.logger, &domain.AuditEvent{UserID: userID, ActorID: actorID, Action: domain.AuditGroupMemberRemoved, Metadata: domain.JSONMap{"group_id": group.ID}, TraceID: traceID})
+	if s.publisher != nil {
+		_ = s.publisher.Publish(ctx, "group.member_removed", events.NewGroupMemberEvent("group.member_removed", group.ID, userID, "", traceID))
+	}
+	return nil
+}
+
+func (s *groupService) AddSubgroup(ctx context.Context, traceID, actorID, parentID, childID string) error {
+	parent, err := s.find(ctx, parentID)
+	if err != nil {
+		return err
+	}
+	child, err := s.find(ctx, childID)
+	if err != nil {
+		return err
+	}
+	if err := s.authorize(ctx, actorID, parent.ID); err != nil {
+		return err
+	}
+	if err := s.authorize(ctx, actorID, child.ID); err != nil {
+		return err
+	}
+	if err := s.groups.Nest(ctx, parent.ID, child.ID); err != nil {
+		if errors.Is(err, repo.ErrGroupCycle) {
+			return ErrGroupCycle
+		}
+		return err
+	}
+	s.logger.Info().Str("trace_id", traceID).Str("actor_id", actorID).Str("group_id", parent.ID).Str("subgroup_id", child.ID).Msg("subgroup added")
+	return nil
+}
+
+func (s *groupService) RemoveSubgroup(ctx context.Context, traceID, actorID, parentID, childID string) error {
+	parent, err := s.find(ctx, parentID)
+	if err != nil {
+		return err
+	}
+	if err := s.authorize(ctx, actorID, parent.ID); err != nil {
+		return err
+	}
+	if err := s.groups.Unnest(ctx, parent.ID, childID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSubgroupNotFound
+		}
+		return err
+	}
+	s.logger.Info().Str("trace_id", traceID).Str("actor_id", actorID).Str("group_id", parent.ID).Str("subgroup_id", childID).Msg("subgroup removed")
+	return nil
+}
+
+func (s *groupService) ListSubgroups(ctx context.Context, groupID string) ([]domain.Group, error) {
+	group, err := s.find(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	return s.groups.ListSubgroups(ctx, group.ID)
+}
+
+func (s *groupService) ListMembers(ctx context.Context, actorID, groupID string, effective bool, offset, limit int) ([]repo.GroupMemberEntry, error) {
+	group, err := s.find(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	offset, limit = memberPage(offset, limit)
+	members, err := s.groups.ListMembers(ctx, group.ID, effective, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	full, err := s.seesMemberDetails(ctx, actorID, group.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !full {
+		for i := range members {
+			if member := &members[i]; member.UserID != actorID {
+				user := &domain.User{ID: member.UserID, Email: member.Email, Profile: &domain.UserProfile{Visibility: member.Visibility}}
+				member.Email = user.Public().Email
+			}
+		}
+	}
+	return members, nil
+}
+
+// seesMemberDetails reports whether actorID owns the group or holds
+// domain.PermUsersRead and so sees members as an admin would.
+func (s *groupService) seesMemberDetails(ctx context.Context, actorID, groupID string) (bool, error) {
+	member, err := s.groups.FindMember(ctx, groupID, actorID)
+	if err == nil && member.Role == domain.GroupRoleOwner {
+		return true, nil
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+	if s.rbac == nil {
+		return false, nil
+	}
+	return s.rbac.CheckPermission(ctx, actorID, domain.PermUsersRead)
+}
+
+func (s *groupService) ListUserGroups(ctx context.Context, userID string, effective bool, offset, limit int) ([]domain.Group, error) {
+	offset, limit = memberPage(offset, limit)
+	return s.groups.ListUserGroups(ctx, userID, effective, offset, limit)
+}
+
+func (s *groupService) GroupIDs(ctx context.Context, userID string) ([]string, error) {
+	groups, err := s.groups.ListUserGroups(ctx, userID, true, 0, maxTokenGroups)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(groups))
+	for _, group := range groups {
+		ids = append(ids, group.ID)
+	}
+	return ids, nil
+}
+
+func (s *groupService) find(ctx context.Context, groupID string) (*domain.Group, error) {
+	if !uuidPattern.MatchString(groupID) {
+		return nil, ErrGroupNotFound
+	}
+	group, err := s.groups.FindByID(ctx, groupID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrGroupNotFound
+	}
+	return group, err
+}
+
+// authorize fails unless actorID owns the group or holds
+// domain.PermGroupsWrite. Ownership is never inherited through nesting.
+func (s *groupService) authorize(ctx context.Context, actorID, groupID string) error {
+	member, err := s.groups.FindMember(ctx, groupID, actorID)
+	if err == nil && member.Role == domain.GroupRoleOwner {
+		return nil
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if s.rbac != nil {
+		allowed, err := s.rbac.CheckPermission(ctx, actorID, domain.PermGroupsWrite)
+		if err != nil {
+			return err
+		}
+		if allowed {
+			return nil
+		}
+	}
+	return ErrGroupForbidden
+}
+
+func (s *groupService) memberAdded(ctx context.Context, traceID, actorID string, member *domain.GroupMember) {
+	s.logger.Info().Str("trace_id", traceID).Str("actor_id", actorID).Str("group_id", member.GroupID).Str("user_id", member.UserID).Str("role", string(member.Role)).Msg("group member added")
+	recordAudit(ctx, s.audit, s.logger, &domain.AuditEvent{UserID: member.UserID, ActorID: actorID, Action: domain.AuditGroupMemberAdded, Metadata: domain.JSONMap{"group_id": member.GroupID, "role": string(member.Role)}, TraceID: traceID})
+	if s.publisher != nil {
+		_ = s.publisher.Publish(ctx, "group.member_added", events.NewGroupMemberEvent("group.member_added", member.GroupID, member.UserID, string(member.Role), traceID))
+	}
+}
+
+func trimDescription(description *string) *string {
+	if description == nil {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*description)
+	if trimmed == "" {
+		return nil
+	}
+	return &trimmed
+}
+
+// memberPage clamps list paging to the defaults shared with organizations.
+func memberPage(offset, limit int) (int, int) {
+	if limit <= 0 {
+		limit = defaultMemberPageSize
+	}
+	if limit > maxMemberPageSize {
+		limit = maxMemberPageSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return offset, limit
+}
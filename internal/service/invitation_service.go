@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/user-service/config"
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/events"
+	"github.com/example/user-service/internal/ports/broker"
+	"github.com/example/user-service/internal/ports/rbac"
+	"github.com/example/user-service/internal/repo"
+	"github.com/example/user-service/internal/tenant"
+	pkglog "github.com/example/user-service/pkg/log"
+	"github.com/example/user-service/pkg/signedtoken"
+)
+
+const invitationTokenPurpose = "invitation"
+
+var (
+	ErrInvitationNotFound      = errors.New("invitation not found")
+	ErrInvitationExists        = errors.New("an open invitation already exists for this email")
+	ErrInvitationClosed        = errors.New("invitation was already accepted or revoked")
+	ErrInvitationInvalid       = errors.New("invitation invalid or expired")
+	ErrInvitationAccountExists = errors.New("an account already exists for the invited email")
+	ErrInvitationEmailMismatch = errors.New("provider email does not match the invitation")
+	ErrInvitationRoleForbidden = errors.New("not allowed to invite with this role")
+	ErrInvitationOrgForbidden  = errors.New("cannot invite into another organization")
+)
+
+// InvitationService lets admins invite people without an account. The invitee
+// accepts through AuthService.AcceptInvitation or an OAuth sign-in carrying
+// the invitation token.
+type InvitationService interface {
+	// Create invites email into orgID, or into the tenant of ctx when orgID is
+	// empty, and publishes user.invited with the accept link. Scoped callers
+	// can only invite into their own tenant, and roles outside
+	// config.Config.InvitationRoles require domain.PermRolesAssign.
+	Create(ctx context.Context, traceID, actorID, email, orgID, role string) (*domain.Invitation, error)
+	List(ctx context.Context, status domain.InvitationStatus, offset, limit int) ([]domain.Invitation, error)
+	// Resend issues a new link with a fresh expiry; earlier links stop
+	// working.
+	Resend(ctx context.Context, traceID, actorID, invitationID string) (*domain.Invitation, error)
+	Revoke(ctx context.Context, traceID, actorID, invitationID string) (*domain.Invitation, error)
+}
+
+type invitationService struct {
+	cfg         *config.Config
+	logger      pkglog.Logger
+	invitations repo.InvitationRepository
+	users       repo.UserRepository
+	orgs        repo.OrganizationRepository
+	rbac        rbac.Client
+	publisher   broker.Publisher
+	signer      *signedtoken.Signer
+}
+
+func NewInvitationService(cfg *config.Config, logger pkglog.Logger, invitations repo.InvitationRepository, users repo.UserRepository, orgs repo.OrganizationRepository, rbacClient rbac.Client, publisher broker.Publisher) InvitationService {
+	return &invitationService{
+		cfg:         cfg,
+		logger:      logger,
+		invitations: invitations,
+		users:       users,
+		orgs:        orgs,
+		rbac:        rbacClient,
+		publisher:   publisher,
+		signer:      signedtoken.NewSigner([]byte(cfg.SignedTokenSecret)),
+	}
+}
+
+func (s *invitationService) Create(ctx context.Context, traceID, actorID, email, orgID, role string) (*domain.Invitation, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if err := validateEmail(email); err != nil {
+		return nil, err
+	}
+	role, err := domain.NormalizeInvitationRole(role)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.authorizeRole(ctx, actorID, role); err != nil {
+		return nil, err
+	}
+	if scoped, ok := tenant.From(ctx); ok {
+		if orgID == "" {
+			orgID = scoped
+		} else if orgID != scoped {
+			return nil, ErrInvitationOrgForbidden
+		}
+	}
+	if orgID != "" {
+		if !uuidPattern.MatchString(orgID) {
+			return nil, ErrOrgNotFound
+		}
+		if _, err := s.orgs.FindByID(ctx, orgID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrOrgNotFound
+			}
+			return nil, err
+		}
+	}
+	inviteeCtx := invitationTenant(ctx, orgID)
+	if _, err := s.users.FindByEmail(inviteeCtx, email); err == nil {
+		return nil, ErrInvitationAccountExists
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if _, err := s.invitations.FindPending(inviteeCtx, email, now); err == nil {
+		return nil, ErrInvitationExists
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	invitation := &domain.Invitation{
+		Email:      email,
+		Role:       role,
+		InvitedBy:  actorID,
+		Sends:      1,
+		LastSentAt: now,
+		ExpiresAt:  now.Add(s.cfg.InvitationTTL),
+	}
+	if orgID != "" {
+		invitation.OrgID = &orgID
+	}
+	if err := s.invitations.Create(ctx, invitation); err != nil {
+		return nil, err
+	}
+	s.logger.Info().Str("trace_id", traceID).Str("actor_id", actorID).Str("invitation_id", invitation.ID).Str("role", role).Msg("invitation created")
+	s.send(ctx, traceID, invitation)
+	return invitation, nil
+}
+
+func (s *invitationService) List(ctx context.Context, status domain.InvitationStatus, offset, limit int) ([]domain.Invitation, error) {
+	if status != "" && !status.IsValid() {
+		return nil, errors.New("unknown invitation status")
+	}
+	offset, limit = memberPage(offset, limit)
+	return s.invitations.List(ctx, status, time.Now().UTC(), offset, limit)
+}
+
+func (s *invitationService) Resend(ctx context.Context, traceID, actorID, invitationID string) (*domain.Invitation, error) {
+	invitation, err := s.find(ctx, invitationID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if status := invitation.Status(now); status != domain.InvitationPending && status != domain.InvitationExpired {
+		return nil, ErrInvitationClosed
+	}
+	invitation.Sends++
+	invitation.LastSentAt = now
+	invitation.ExpiresAt = now.Add(s.cfg.InvitationTTL)
+	if err := s.invitations.Update(ctx, invitation); err != nil {
+		return nil, err
+	}
+	s.logger.Info().Str("trace_id", traceID).Str("actor_id", actorID).Str("invitation_id", invitation.ID).Int("sends", invitation.Sends).Msg("invitation resent")
+	s.send(ctx, traceID, invitation)
+	return invitation, nil
+}
+
+func (s *invitationService) Revoke(ctx context.Context, traceID, actorID, invitationID string) (*domain.Invitation, error) {
+	invitation, err := s.find(ctx, invitationID)
+	if err != nil {
+		return nil, err
+	}
+	if invitation.AcceptedAt != nil || invitation.RevokedAt != nil {
+		return nil, ErrInvitationClosed
+	}
+	now := time.Now().UTC()
+	invitation.RevokedAt = &now
+	if err := s.invitations.Update(ctx, invitation); err != nil {
+		return nil, err
+	}
+	s.logger.Info().Str("trace_id", traceID).Str("actor_id", actorID).Str("invitation_id", invitation.ID).Msg("invitation revoked")
+	return invitation, nil
+}
+
+// authorizeRole fails unless role is one of config.Config.InvitationRoles or
+// actorID holds domain.PermRolesAssign, so that users:write alone does not
+// hand out admin roles.
+func (s *invitationService) authorizeRole(ctx context.Context, actorID, role string) error {
+	if slices.Contains(s.cfg.InvitationRoles, role) {
+		return nil
+	}
+	if s.rbac != nil {
+		allowed, err := s.rbac.CheckPermission(ctx, actorID, domain.PermRolesAssign)
+		if err != nil {
+			return err
+		}
+		if allowed {
+			return nil
+		}
+	}
+	return ErrInvitationRoleForbidden
+}
+
+// find loads an invitation of the tenant of ctx.
+func (s *invitationService) find(ctx context.Context, invitationID string) (*domain.Invitation, error) {
+	if !uuidPattern.MatchString(invitationID) {
+		return nil, ErrInvitationNotFound
+	}
+	invitation, err := s.invitations.FindByID(ctx, invitationID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvitationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if orgID, scoped := tenant.From(ctx); scoped && orgID != stringOrEmpty(invitation.OrgID) {
+		return nil, ErrInvitationNotFound
+	}
+	return invitation, nil
+}
+
+func (s *invitationService) send(ctx context.Context, traceID string, invitation *domain.Invitation) {
+	if s.publisher == nil {
+		return
+	}
+	token := s.signer.Sign(invitationTokenPurpose, invitationSubject(invitation), invitation.ExpiresAt)
+	acceptURL := strings.TrimRight(s.cfg.AppPublicURL, "/") + "/invitations/accept?" + url.Values{"token": {token}}.Encode()
+	_ = s.publisher.Publish(ctx, "user.invited", events.NewInvitationEvent(invitation.ID, invitation.Email, stringOrEmpty(invitation.OrgID), invitation.Role, acceptURL, invitation.ExpiresAt, traceID))
+}
+
+// invitationSubject binds a link to the send it was issued for, so that
+// resending invalidates earlier links.
+func invitationSubject(invitation *domain.Invitation) string {
+	return invitation.ID + ":" + strconv.Itoa(invitation.Sends)
+}
+
+// openInvitation returns the pending invitation a token was issued for.
+func openInvitation(ctx context.Context, signer *signedtoken.Signer, invitations repo.InvitationRepository, token string) (*domain.Invitation, error) {
+	now := time.Now()
+	subject, err := signer.Verify(invitationTokenPurpose, token, now)
+	if err != nil {
+		return nil, ErrInvitationInvalid
+	}
+	id, _, ok := strings.Cut(subject, ":")
+	if !ok {
+		return nil, ErrInvitationInvalid
+	}
+	invitation, err := invitations.FindByID(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvitationInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if invitationSubject(invitation) != subject || invitation.Status(now) != domain.InvitationPending {
+		return nil, ErrInvitationInvalid
+	}
+	return invitation, nil
+}
+
+// invitationTenant returns ctx in the tenant an invitee signs up in.
+func invitationTenant(ctx context.Context, orgID string) context.Context {
+	if orgID == "" {
+		return tenant.With(ctx, tenant.Default)
+	}
+	return tenant.With(ctx, orgID)
+}
+
+func stringOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
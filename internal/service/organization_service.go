@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/ports/rbac"
+	"github.com/example/user-service/internal/repo"
+	"github.com/example/user-service/internal/tenant"
+	pkglog "github.com/example/user-service/pkg/log"
+)
+
+const (
+	defaultMemberPageSize = 50
+	maxMemberPageSize     = 200
+)
+
+var (
+	ErrOrgNotFound   = errors.New("organization not found")
+	ErrOrgSlugTaken  = errors.New("organization slug already taken")
+	ErrOrgForbidden  = errors.New("not allowed in this organization")
+	ErrOrgLastOwner  = errors.New("organization must keep at least one owner")
+	ErrOrgUserAbsent = errors.New("user does not belong to this organization")
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+type OrganizationService interface {
+	// Resolve finds an organization by ID or slug.
+	Resolve(ctx context.Context, ref string) (*domain.Organization, error)
+	Create(ctx context.Context, traceID, actorID, slug, name string) (*domain.Organization, error)
+	// Get and ListMembers are open to members of the organization and to
+	// holders of domain.PermOrgsWrite.
+	Get(ctx context.Context, actorID, orgID string) (*domain.Organization, error)
+	ListMembers(ctx context.Context, actorID, orgID string, offset, limit int) ([]repo.OrgMember, error)
+	// SetMemberRole requires an admin of the organization; granting or
+	// revoking owner requires an owner.
+	SetMemberRole(ctx context.Context, traceID, actorID, orgID, userID string, role domain.OrgRole) (*domain.OrgMembership, error)
+}
+
+type organizationService struct {
+	logger pkglog.Logger
+	orgs   repo.OrganizationRepository
+	users  repo.UserRepository
+	rbac   rbac.Client
+	audit  repo.AuditRepository
+}
+
+func NewOrganizationService(logger pkglog.Logger, orgs repo.OrganizationRepository, users repo.UserRepository, rbacClient rbac.Client, audit repo.AuditRepository) OrganizationService {
+	return &organizationService{logger: logger, orgs: orgs, users: users, rbac: rbacClient, audit: audit}
+}
+
+func (s *organizationService) Resolve(ctx context.Context, ref string) (*domain.Organization, error) {
+	var org *domain.Organization
+	var err error
+	if uuidPattern.MatchString(ref) {
+		org, err = s.orgs.FindByID(ctx, ref)
+	} else {
+		org, err = s.orgs.FindBySlug(ctx, strings.ToLower(ref))
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrOrgNotFound
+	}
+	return org, err
+}
+
+func (s *organizationService) Create(ctx context.Context, traceID, actorID, slug, name string) (*domain.Organization, error) {
+	slug, err := domain.NormalizeOrgSlug(slug)
+	if err != nil {
+		return nil, err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > 200 {
+		return nil, domain.ErrOrgNameInvalid
+	}
+	org := &domain.Organization{Slug: slug, Name: name}
+	if err := s.orgs.Create(ctx, org); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrOrgSlugTaken
+		}
+		return nil, err
+	}
+	s.logger.Info().Str("trace_id", traceID).Str("actor_id", actorID).Str("org_id", org.ID).Msg("organization created")
+	return org, nil
+}
+
+func (s *organizationService) Get(ctx context.Context, actorID, orgID string) (*domain.Organization, error) {
+	org, err := s.find(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.authorize(ctx, actorID, org.ID, domain.OrgRoleMember); err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+func (s *organizationService) ListMembers(ctx context.Context, actorID, orgID string, offset, limit int) ([]repo.OrgMember, error) {
+	org, err := s.find(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.authorize(ctx, actorID, org.ID, domain.OrgRoleMember); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = defaultMemberPageSize
+	}
+	if limit > maxMemberPageSize {
+		limit = maxMemberPageSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return s.orgs.ListMembers(tenant.With(ctx, org.ID), org.ID, offset, limit)
+}
+
+func (s *organizationService) SetMemberRole(ctx context.Context, traceID, actorID, orgID, userID string, role domain.OrgRole) (*domain.OrgMembership, error) {
+	if !role.IsValid() {
+		return nil, domain.ErrOrgRoleInvalid
+	}
+	org, err := s.find(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	actorRole, err := s.authorize(ctx, actorID, org.ID, domain.OrgRoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	orgCtx := tenant.With(ctx, org.ID)
+	if _, err := s.users.FindByID(orgCtx, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrgUserAbsent
+		}
+		return nil, err
+	}
+	current, err := s.role(orgCtx, org.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if (role == domain.OrgRoleOwner || current == domain.OrgRoleOwner) && !actorRole.AtLeast(domain.OrgRoleOwner) {
+		return nil, ErrOrgForbidden
+	}
+	if current == domain.OrgRoleOwner && role != domain.OrgRoleOwner {
+		owners, err := s.orgs.CountMembersWithRole(orgCtx, org.ID, domain.OrgRoleOwner)
+		if err != nil {
+			return nil, err
+		}
+		if owners <= 1 {
+			return nil, ErrOrgLastOwner
+		}
+	}
+	membership := &domain.OrgMembership{OrgID: org.ID, UserID: userID, Role: role}
+	if err := s.orgs.SaveMembership(orgCtx, membership); err != nil {
+		return nil, err
+	}
+	s.logger.Info().Str("trace_id", traceID).Str("org_id", org.ID).Str("user_id", userID).Str("role", string(role)).Msg("organization role changed")
+	recordAudit(ctx, s.audit, s.logger, &domain.AuditEvent{UserID: userID, ActorID: actorID, Action: domain.AuditOrgRoleChanged, Metadata: domain.JSONMap{"org_id": org.ID, "role": string(role), "previous_role": string(current)}, TraceID: traceID})
+	return membership, nil
+}
+
+func (s *organizationService) find(ctx context.Context, orgID string) (*domain.Organization, error) {
+	org, err := s.orgs.FindByID(ctx, orgID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrOrgNotFound
+	}
+	return org, err
+}
+
+// authorize returns the role of actorID in the organization, treating holders
+// of domain.PermOrgsWrite as owners, and fails unless it is at least min.
+func (s *organizationService) authorize(ctx context.Context, actorID, orgID string, min domain.OrgRole) (domain.OrgRole, error) {
+	if s.rbac != nil {
+		allowed, err := s.rbac.CheckPermission(ctx, actorID, domain.PermOrgsWrite)
+		if err != nil {
+			return "", err
+		}
+		if allowed {
+			return domain.OrgRoleOwner, nil
+		}
+	}
+	orgCtx := tenant.With(ctx, orgID)
+	if _, err := s.users.FindByID(orgCtx, actorID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrOrgForbidden
+		}
+		return "", err
+	}
+	role, err := s.role(orgCtx, orgID, actorID)
+	if err != nil {
+		return "", err
+	}
+	if !role.AtLeast(min) {
+		return "", ErrOrgForbidden
+	}
+	return role, nil
+}
+
+// role returns the role of a user of the organization; users without a
+// membership row are members.
+func (s *organizationService) role(ctx context.Context, orgID, userID string) (domain.OrgRole, error) {
+	membership, err := s.orgs.FindMembership(ctx, orgID, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return domain.OrgRoleMember, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return membership.Role, nil
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"github.com/example/user-service/config"
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/repo"
+	pkglog "github.com/example/user-service/pkg/log"
+)
+
+const (
+	// phoneCodeDigits is longer than the 4 digits of email codes because a
+	// texted code alone signs in.
+	phoneCodeDigits   = 6
+	maxPhoneCodeTries = 5
+	// maxPhoneCodesPerHour limits the texts to one number, whatever their
+	// purpose.
+	maxPhoneCodesPerHour = 5
+)
+
+var (
+	ErrPhoneTaken       = errors.New("phone number already in use")
+	ErrPhoneRequired    = errors.New("verified phone number required")
+	ErrPhoneCodeInvalid = errors.New("code invalid or expired")
+	ErrPhoneCodeLimit   = errors.New("too many codes sent to this number, try again later")
+)
+
+// SMSSender delivers text messages. The service only ships development
+// stand-ins in internal/ports/sms; gateways plug in here.
+type SMSSender interface {
+	Send(ctx context.Context, phone, message string) error
+}
+
+// PhoneService manages the verified phone of a user and the codes texted to
+// it for verification, sign-in and MFA.
+type PhoneService interface {
+	// StartVerification texts a code to phone and returns the verification
+	// ID. The phone is stored on the user once Verify succeeds.
+	StartVerification(ctx context.Context, userID, phone string) (string, error)
+	Verify(ctx context.Context, userID, id, code string) (*domain.User, error)
+	// Remove clears the phone and turns SMS MFA off.
+	Remove(ctx context.Context, userID string) (*domain.User, error)
+	SetMFA(ctx context.Context, userID string, enabled bool) (*domain.User, error)
+	// SendCode texts a code for purpose to the verified phone of user.
+	SendCode(ctx context.Context, user *domain.User, purpose domain.PhoneCodePurpose) (*domain.PhoneCode, error)
+	// CheckCode consumes code id if it was issued for purpose and matches.
+	CheckCode(ctx context.Context, purpose domain.PhoneCodePurpose, id, code string) (*domain.PhoneCode, error)
+	// RedeemCode consumes code id issued for purpose when accept takes
+	// something else, such as a recovery code, in place of the texted
+	// digits. Each call counts as an attempt at the code.
+	RedeemCode(ctx context.Context, purpose domain.PhoneCodePurpose, id string, accept func(*domain.PhoneCode) error) (*domain.PhoneCode, error)
+}
+
+type phoneService struct {
+	cfg    *config.Config
+	logger pkglog.Logger
+	users  repo.UserRepository
+	codes  repo.PhoneCodeRepository
+	sms    SMSSender
+}
+
+func NewPhoneService(cfg *config.Config, logger pkglog.Logger, users repo.UserRepository, codes repo.PhoneCodeRepository, sms SMSSender) PhoneService {
+	return &phoneService{cfg: cfg, logger: logger, users: users, codes: codes, sms: sms}
+}
+
+func (s *phoneService) StartVerification(ctx context.Context, userID, phone string) (string, error) {
+	phone, err := domain.NormalizePhone(phone)
+	if err != nil {
+		return "", err
+	}
+	if owner, err := s.users.FindByPhone(ctx, phone); err == nil && owner.ID != userID {
+		return "", ErrPhoneTaken
+	} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+	code, err := s.issue(ctx, domain.PhoneCodeVerify, userID, phone)
+	if err != nil {
+		return "", err
+	}
+	return code.ID, nil
+}
+
+func (s *phoneService) Verify(ctx context.Context, userID, id, code string) (*domain.User, error) {
+	verification, err := s.check(ctx, domain.PhoneCodeVerify, userID, id, code)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	user.Phone = &verification.Phone
+	user.PhoneVerifiedAt = &now
+	if err := s.users.Update(ctx, user); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrPhoneTaken
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// Remove checks the remaining login methods under the same user row lock an
+// identity unlink takes, so the two cannot each remove the other's fallback.
+func (s *phoneService) Remove(ctx context.Context, userID string) (*domain.User, error) {
+	return s.users.UpdateLocked(ctx, userID, func(user *domain.User, identities int64) error {
+		user.Phone = nil
+		user.PhoneVerifiedAt = nil
+		user.PhoneMFA = false
+		return checkLoginMethodsLeft(user, identities)
+	})
+}
+
+func (s *phoneService) SetMFA(ctx context.Context, userID string, enabled bool) (*domain.User, error) {
+	user, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled && user.Phone == nil {
+		return nil, ErrPhoneRequired
+	}
+	user.PhoneMFA = enabled
+	if err := s.users.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	s.logger.Info().Str("user_id", userID).Bool("enabled", enabled).Msg("sms mfa changed")
+	return user, nil
+}
+
+func (s *phoneService) SendCode(ctx context.Context, user *domain.User, purpose domain.PhoneCodePurpose) (*domain.PhoneCode, error) {
+	if user.Phone == nil {
+		return nil, ErrPhoneRequired
+	}
+	return s.issue(ctx, purpose, user.ID, *user.Phone)
+}
+
+func (s *phoneService) CheckCode(ctx context.Context, purpose domain.PhoneCodePurpose, id, code string) (*domain.PhoneCode, error) {
+	return s.check(ctx, purpose, "", id, code)
+}
+
+// check is CheckCode limited to codes of userID unless it is empty.
+func (s *phoneService) check(ctx context.Context, purpose domain.PhoneCodePurpose, userID, id, code string) (*domain.PhoneCode, error) {
+	code = strings.TrimSpace(code)
+	if err := validateDigitCode(code, phoneCodeDigits); err != nil {
+		return nil, err
+	}
+	return s.redeem(ctx, purpose, userID, id, func(stored *domain.PhoneCode) error {
+		if bcrypt.CompareHashAndPassword([]byte(stored.CodeHash), []byte(code)) != nil {
+			return ErrPhoneCodeInvalid
+		}
+		return nil
+	})
+}
+
+func (s *phoneService) RedeemCode(ctx context.Context, purpose domain.PhoneCodePurpose, id string, accept func(*domain.PhoneCode) error) (*domain.PhoneCode, error) {
+	return s.redeem(ctx, purpose, "", id, accept)
+}
+
+// redeem consumes pending code id of purpose, limited to codes of userID
+// unless it is empty, once accept approves it.
+func (s *phoneService) redeem(ctx context.Context, purpose domain.PhoneCodePurpose, userID, id string, accept func(*domain.PhoneCode) error) (*domain.PhoneCode, error) {
+	if !uuidPattern.MatchString(id) {
+		return nil, ErrPhoneCodeInvalid
+	}
+	stored, err := s.codes.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPhoneCodeInvalid
+		}
+		return nil, err
+	}
+	if (userID != "" && stored.UserID != userID) || stored.Purpose != purpose || !stored.IsPending(time.Now().UTC()) {
+		return nil, ErrPhoneCodeInvalid
+	}
+	// The attempt is counted before the comparison, so concurrent guesses
+	// cannot get past the limit.
+	claimed, err := s.codes.ClaimAttempt(ctx, stored.ID, maxPhoneCodeTries)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, ErrPhoneCodeInvalid
+	}
+	if err := accept(stored); err != nil {
+		return nil, err
+	}
+	if err := s.codes.Consume(ctx, stored.ID, time.Now().UTC()); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPhoneCodeInvalid
+		}
+		return nil, err
+	}
+	return stored, nil
+}
+
+func (s *phoneService) issue(ctx context.Context, purpose domain.PhoneCodePurpose, userID, phone string) (*domain.PhoneCode, error) {
+	now := time.Now().UTC()
+	sent, err := s.codes.CountSince(ctx, phone, now.Add(-time.Hour))
+	if err != nil {
+		return nil, err
+	}
+	if sent >= maxPhoneCodesPerHour {
+		return nil, ErrPhoneCodeLimit
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return nil, err
+	}
+	code := fmt.Sprintf("%0*d", phoneCodeDigits, n.Int64())
+	hash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	stored := &domain.PhoneCode{
+		Purpose:   purpose,
+		UserID:    userID,
+		Phone:     phone,
+		CodeHash:  string(hash),
+		ExpiresAt: now.Add(s.cfg.PhoneCodeTTL),
+	}
+	if err := s.codes.Create(ctx, stored); err != nil {
+		return nil, err
+	}
+	if err := s.sms.Send(ctx, phone, fmt.Sprintf("Your %s code is %s", s.cfg.AppName, code)); err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// decoyCodeID looks like a code ID and lets unknown numbers go through the
+// SMS sign-in without revealing that they are unknown.
+func decoyCodeID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
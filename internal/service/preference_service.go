@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/events"
+	"github.com/example/user-service/internal/ports/broker"
+	"github.com/example/user-service/internal/repo"
+	"github.com/example/user-service/pkg/jsonschema"
+	pkglog "github.com/example/user-service/pkg/log"
+)
+
+// MaxPreferenceBatch caps the users of one batch read.
+const MaxPreferenceBatch = 500
+
+var ErrPreferenceBatchTooLarge = fmt.Errorf("at most %d user IDs per request", MaxPreferenceBatch)
+
+type PreferenceService interface {
+	Definitions() []domain.PreferenceDefinition
+	// Get returns every registered preference of userID.
+	Get(ctx context.Context, userID string) (map[string]interface{}, error)
+	// Update sets the given preferences; a nil value resets a preference to
+	// its default. It returns a *ValidationError listing every unknown key
+	// and invalid value, and publishes user.preferences_changed when a
+	// resolved value changed.
+	Update(ctx context.Context, traceID, userID string, changes map[string]interface{}) (map[string]interface{}, error)
+	// GetMany resolves the preferences of several users for internal callers,
+	// limited to keys when it is not empty. IDs without stored preferences,
+	// including unknown ones, resolve to the defaults.
+	GetMany(ctx context.Context, userIDs, keys []string) (map[string]map[string]interface{}, error)
+}
+
+type preferenceService struct {
+	logger    pkglog.Logger
+	prefs     repo.PreferenceRepository
+	publisher broker.Publisher
+}
+
+func NewPreferenceService(logger pkglog.Logger, prefs repo.PreferenceRepository, publisher broker.Publisher) PreferenceService {
+	return &preferenceService{logger: logger, prefs: prefs, publisher: publisher}
+}
+
+func (s *preferenceService) Definitions() []domain.PreferenceDefinition {
+	return domain.PreferenceDefinitions()
+}
+
+func (s *preferenceService) Get(ctx context.Context, userID string) (map[string]interface{}, error) {
+	prefs, err := s.prefs.Find(ctx, userID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return prefs.Resolve(), nil
+}
+
+func (s *preferenceService) Update(ctx context.Context, traceID, userID string, changes map[string]interface{}) (map[string]interface{}, error) {
+	var fieldErrs []jsonschema.FieldError
+	for key, value := range changes {
+		definition, ok := domain.LookupPreference(key)
+		if !ok {
+			fieldErrs = append(fieldErrs, jsonschema.FieldError{Field: key, Message: domain.ErrPreferenceUnknown.Error()})
+			continue
+		}
+		if value != nil {
+			if err := definition.Validate(value); err != nil {
+				fieldErrs = append(fieldErrs, jsonschema.FieldError{Field: key, Message: err.Error()})
+			}
+		}
+	}
+	if len(fieldErrs) > 0 {
+		return nil, &ValidationError{Fields: fieldErrs}
+	}
+
+	var before map[string]interface{}
+	prefs, err := s.prefs.Modify(ctx, userID, func(prefs *domain.UserPreferences) error {
+		before = prefs.Resolve()
+		for key, value := range changes {
+			if value == nil {
+				delete(prefs.Values, key)
+			} else {
+				prefs.Values[key] = value
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	after := prefs.Resolve()
+	changed := map[string]interface{}{}
+	for key, value := range after {
+		if !reflect.DeepEqual(before[key], value) {
+			changed[key] = value
+		}
+	}
+	if len(changed) > 0 {
+		s.logger.Info().Str("trace_id", traceID).Str("user_id", userID).Int("changed", len(changed)).Msg("preferences changed")
+		if s.publisher != nil {
+			_ = s.publisher.Publish(ctx, "user.preferences_changed", events.NewUserPreferencesEvent(userID, changed, traceID))
+		}
+	}
+	return after, nil
+}
+
+func (s *preferenceService) GetMany(ctx context.Context, userIDs, keys []string) (map[string]map[string]interface{}, error) {
+	if len(userIDs) > MaxPreferenceBatch {
+		return nil, ErrPreferenceBatchTooLarge
+	}
+	var fieldErrs []jsonschema.FieldError
+	for _, userID := range userIDs {
+		if !uuidPattern.MatchString(userID) {
+			fieldErrs = append(fieldErrs, jsonschema.FieldError{Field: "user_ids", Message: "contains an invalid ID: " + userID})
+			break
+		}
+	}
+	for _, key := range keys {
+		if _, ok := domain.LookupPreference(key); !ok {
+			fieldErrs = append(fieldErrs, jsonschema.FieldError{Field: key, Message: domain.ErrPreferenceUnknown.Error()})
+		}
+	}
+	if len(fieldErrs) > 0 {
+		return nil, &ValidationError{Fields: fieldErrs}
+	}
+	result := make(map[string]map[string]interface{}, len(userIDs))
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+	stored, err := s.prefs.FindMany(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	byUser := make(map[string]*domain.UserPreferences, len(stored))
+	for i := range stored {
+		byUser[stored[i].UserID] = &stored[i]
+	}
+	for _, userID := range userIDs {
+		resolved := byUser[userID].Resolve()
+		if len(keys) > 0 {
+			selected := make(map[string]interface{}, len(keys))
+			for _, key := range keys {
+				selected[key] = resolved[key]
+			}
+			resolved = selected
+		}
+		result[userID] = resolved
+	}
+	return result, nil
+}
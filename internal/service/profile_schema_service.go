@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"gorm.io/gorm"
+
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/repo"
+	"github.com/example/user-service/pkg/jsonschema"
+	pkglog "github.com/example/user-service/pkg/log"
+)
+
+// ErrInvalidProfileSchema is returned when a published schema cannot be
+// compiled or does not describe an object.
+var ErrInvalidProfileSchema = errors.New("invalid profile schema")
+
+// ProfileSchemaService manages the JSON Schema that custom profile attributes
+// are validated against.
+type ProfileSchemaService interface {
+	// Current returns the schema in force or gorm.ErrRecordNotFound.
+	Current(ctx context.Context) (*domain.ProfileSchema, error)
+	Publish(ctx context.Context, traceID, actorID string, raw []byte) (*domain.ProfileSchema, error)
+	// ValidateAttributes checks a complete attributes bag. Without a published
+	// schema no attributes are accepted.
+	ValidateAttributes(ctx context.Context, attributes map[string]interface{}) ([]jsonschema.FieldError, error)
+}
+
+type profileSchemaService struct {
+	logger  pkglog.Logger
+	schemas repo.ProfileSchemaRepository
+
+	mu       sync.Mutex
+	version  int
+	compiled *jsonschema.Schema
+}
+
+func NewProfileSchemaService(logger pkglog.Logger, schemas repo.ProfileSchemaRepository) ProfileSchemaService {
+	return &profileSchemaService{logger: logger, schemas: schemas}
+}
+
+func (s *profileSchemaService) Current(ctx context.Context) (*domain.ProfileSchema, error) {
+	return s.schemas.Latest(ctx)
+}
+
+func (s *profileSchemaService) Publish(ctx context.Context, traceID, actorID string, raw []byte) (*domain.ProfileSchema, error) {
+	compiled, err := compileProfileSchema(raw)
+	if err != nil {
+		return nil, err
+	}
+	var doc domain.JSONMap
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProfileSchema, err)
+	}
+	schema := &domain.ProfileSchema{Schema: doc, CreatedBy: actorID}
+	if err := s.schemas.Create(ctx, schema); err != nil {
+		return nil, err
+	}
+	s.remember(schema.Version, compiled)
+	s.logger.Info().Str("trace_id", traceID).Str("actor_id", actorID).Int("version", schema.Version).Msg("profile schema published")
+	return schema, nil
+}
+
+func (s *profileSchemaService) ValidateAttributes(ctx context.Context, attributes map[string]interface{}) ([]jsonschema.FieldError, error) {
+	schema, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if schema == nil {
+		return rejectAttributes(attributes), nil
+	}
+	return schema.Validate(map[string]interface{}(attributes)), nil
+}
+
+// load returns the compiled schema in force, or nil when none was published.
+// The latest version is looked up on every call so that a schema published by
+// another instance takes effect immediately; compilation is only repeated
+// when the version changes.
+func (s *profileSchemaService) load(ctx context.Context) (*jsonschema.Schema, error) {
+	latest, err := s.schemas.Latest(ctx)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	if s.compiled != nil && s.version == latest.Version {
+		compiled := s.compiled
+		s.mu.Unlock()
+		return compiled, nil
+	}
+	s.mu.Unlock()
+
+	raw, err := json.Marshal(latest.Schema)
+	if err != nil {
+		return nil, err
+	}
+	compiled, err := compileProfileSchema(raw)
+	if err != nil {
+		return nil, fmt.Errorf("stored profile schema version %d: %w", latest.Version, err)
+	}
+	s.remember(latest.Version, compiled)
+	return compiled, nil
+}
+
+func (s *profileSchemaService) remember(version int, compiled *jsonschema.Schema) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if version >= s.version {
+		s.version = version
+		s.compiled = compiled
+	}
+}
+
+func compileProfileSchema(raw []byte) (*jsonschema.Schema, error) {
+	compiled, err := jsonschema.Compile(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProfileSchema, err)
+	}
+	if compiled.Type != "object" {
+		return nil, fmt.Errorf("%w: top-level type must be object", ErrInvalidProfileSchema)
+	}
+	return compiled, nil
+}
+
+// rejectAttributes reports every attribute as unknown, which is the outcome
+// when no schema has been published.
+func rejectAttributes(attributes map[string]interface{}) []jsonschema.FieldError {
+	var fieldErrs []jsonschema.FieldError
+	for _, key := range sortedKeys(attributes) {
+		fieldErrs = append(fieldErrs, jsonschema.FieldError{Field: key, Message: "is not allowed"})
+	}
+	return fieldErrs
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/events"
+	"github.com/example/user-service/internal/ports/broker"
+	"github.com/example/user-service/internal/repo"
+	pkglog "github.com/example/user-service/pkg/log"
+)
+
+// recoveryCodeAlphabet leaves out characters that are easily confused when
+// read off paper, such as 0/o and 1/l/i.
+const recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// recoveryCodeLength is the number of random characters in a code, shown in
+// two halves separated by a dash.
+const recoveryCodeLength = 10
+
+var (
+	ErrRecoveryCodeInvalid      = errors.New("recovery code invalid or already used")
+	ErrRecoveryCodesUnavailable = errors.New("recovery codes not configured")
+)
+
+// RecoveryCodeService manages the single-use codes that replace the second
+// factor when the user has lost their device.
+type RecoveryCodeService interface {
+	// Generate replaces the codes of userID with a new set and returns it in
+	// plain text. The codes cannot be retrieved again. It publishes
+	// user.recovery_codes_generated so the user learns of a regeneration
+	// they did not make.
+	Generate(ctx context.Context, traceID, userID string) ([]string, error)
+	Remaining(ctx context.Context, userID string) (int64, error)
+	// Use consumes one code of user and publishes user.recovery_code_used so
+	// the user is told about it.
+	Use(ctx context.Context, traceID string, user *domain.User, code string) error
+}
+
+type recoveryCodeService struct {
+	logger    pkglog.Logger
+	codes     repo.RecoveryCodeRepository
+	users     repo.UserRepository
+	publisher broker.Publisher
+}
+
+func NewRecoveryCodeService(logger pkglog.Logger, codes repo.RecoveryCodeRepository, users repo.UserRepository, publisher broker.Publisher) RecoveryCodeService {
+	return &recoveryCodeService{logger: logger, codes: codes, users: users, publisher: publisher}
+}
+
+func (s *recoveryCodeService) Generate(ctx context.Context, traceID, userID string) ([]string, error) {
+	user, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]string, 0, domain.RecoveryCodeCount)
+	stored := make([]domain.RecoveryCode, 0, domain.RecoveryCodeCount)
+	for len(plain) < domain.RecoveryCodeCount {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		plain = append(plain, code)
+		stored = append(stored, domain.RecoveryCode{UserID: userID, CodeHash: hashRecoveryCode(code)})
+	}
+	if err := s.codes.Replace(ctx, userID, stored); err != nil {
+		return nil, err
+	}
+	s.logger.Info().Str("trace_id", traceID).Str("user_id", userID).Msg("recovery codes generated")
+	if s.publisher != nil {
+		_ = s.publisher.Publish(ctx, "user.recovery_codes_generated", events.NewUserEvent("user.recovery_codes_generated", user.ID, user.Email, traceID))
+	}
+	return plain, nil
+}
+
+func (s *recoveryCodeService) Remaining(ctx context.Context, userID string) (int64, error) {
+	return s.codes.CountUnused(ctx, userID)
+}
+
+func (s *recoveryCodeService) Use(ctx context.Context, traceID string, user *domain.User, code string) error {
+	err := s.codes.Use(ctx, user.ID, hashRecoveryCode(code), time.Now())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrRecoveryCodeInvalid
+	}
+	if err != nil {
+		return err
+	}
+	remaining, err := s.codes.CountUnused(ctx, user.ID)
+	if err != nil {
+		s.logger.Error().Err(err).Str("trace_id", traceID).Str("user_id", user.ID).Msg("recovery code count failed")
+	}
+	s.logger.Info().Str("trace_id", traceID).Str("user_id", user.ID).Int64("remaining", remaining).Msg("recovery code used")
+	if s.publisher != nil {
+		_ = s.publisher.Publish(ctx, "user.recovery_code_used", events.NewRecoveryCodeUsedEvent(user.ID, user.Email, remaining, traceID))
+	}
+	return nil
+}
+
+func newRecoveryCode() (string, error) {
+	code := make([]byte, 0, recoveryCodeLength+1)
+	max := big.NewInt(int64(len(recoveryCodeAlphabet)))
+	for i := 0; i < recoveryCodeLength; i++ {
+		if i == recoveryCodeLength/2 {
+			code = append(code, '-')
+		}
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code = append(code, recoveryCodeAlphabet[n.Int64()])
+	}
+	return string(code), nil
+}
+
+// hashRecoveryCode uses a plain digest: unlike the 6-digit phone codes,
+// recovery codes carry about 50 random bits, so they cannot be guessed from
+// the hash and can be looked up by it.
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(domain.NormalizeRecoveryCode(code)))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/example/user-service/internal/repo"
+)
+
+const (
+	minSearchTermLength = 3
+	maxSearchTermLength = 100
+	defaultSearchLimit  = 20
+	maxSearchLimit      = 100
+)
+
+var ErrSearchTermInvalid = errors.New("search term must be 3-100 characters")
+
+// SearchService finds users by partial or mistyped email, username or display
+// name within the tenant of ctx.
+type SearchService interface {
+	Search(ctx context.Context, term string, limit int) ([]repo.UserSearchHit, error)
+}
+
+type searchService struct {
+	search repo.UserSearchRepository
+}
+
+func NewSearchService(search repo.UserSearchRepository) SearchService {
+	return &searchService{search: search}
+}
+
+func (s *searchService) Search(ctx context.Context, term string, limit int) ([]repo.UserSearchHit, error) {
+	term, err := normalizeSearchTerm(term)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	return s.search.Search(ctx, term, limit)
+}
+
+// normalizeSearchTerm lowercases term, drops control characters and collapses
+// whitespace. Wildcards are kept; the repository matches them literally.
+func normalizeSearchTerm(term string) (string, error) {
+	term = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return unicode.ToLower(r)
+	}, term)
+	term = strings.Join(strings.Fields(term), " ")
+	if n := utf8.RuneCountInString(term); n < minSearchTermLength || n > maxSearchTermLength {
+		return "", ErrSearchTermInvalid
+	}
+	return term, nil
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/example/user-service/internal/repo"
+)
+
+var ErrSessionRevoked = errors.New("session revoked")
+
+// SessionValidator checks that a token is still usable for its subject.
+type SessionValidator interface {
+	ValidateSession(ctx context.Context, userID string, issuedAt time.Time) error
+}
+
+type sessionValidator struct {
+	users repo.UserRepository
+}
+
+func NewSessionValidator(users repo.UserRepository) SessionValidator {
+	return &sessionValidator{users: users}
+}
+
+func (v *sessionValidator) ValidateSession(ctx context.Context, userID string, issuedAt time.Time) error {
+	user, err := v.users.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.IsActive {
+		return ErrUserInactive
+	}
+	if user.TokenRevoked(issuedAt) {
+		return ErrSessionRevoked
+	}
+	return nil
+}
@@ -2,56 +2,392 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
+	"sort"
 	"strings"
+	"time"
+	"unicode/utf8"
 
+	"golang.org/x/text/language"
+
+	"github.com/example/user-service/config"
 	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/ports/rbac"
 	"github.com/example/user-service/internal/ports/tarantool"
 	"github.com/example/user-service/internal/repo"
+	"github.com/example/user-service/pkg/jsonschema"
+	"github.com/example/user-service/pkg/patch"
+)
+
+// ErrLastLoginMethod is returned when removing an identity or the phone would
+// leave the account without any way to sign in.
+var ErrLastLoginMethod = errors.New("cannot remove the last login method")
+
+// ErrRevisionMismatch is returned when a conditional update names a revision
+// that is no longer current.
+var ErrRevisionMismatch = errors.New("user was modified since the given revision")
+
+// maxProfileUpdateAttempts bounds how often an unconditional profile update
+// is re-applied to a freshly read profile after losing a race.
+const maxProfileUpdateAttempts = 3
+
+// Length limits for the standard profile fields, counted in characters.
+const (
+	maxNameLength    = 100
+	maxCompanyLength = 200
+	maxBioLength     = 1000
 )
 
+// ProfileUpdate is a JSON Merge Patch of the profile: absent fields are left
+// untouched, and null or an empty string clears a standard field. Attributes
+// is merged into the stored bag, where a null value removes the attribute,
+// and the result must satisfy the published profile schema.
+type ProfileUpdate struct {
+	DisplayName patch.Field[string]
+	AvatarURL   patch.Field[string]
+	FirstName   patch.Field[string]
+	LastName    patch.Field[string]
+	Locale      patch.Field[string]
+	Timezone    patch.Field[string]
+	Bio         patch.Field[string]
+	Company     patch.Field[string]
+	// Attributes set to null removes every custom attribute.
+	Attributes patch.Field[map[string]interface{}]
+	// Visibility sets public or private per field of
+	// domain.DefaultFieldVisibility; null restores the default of a field,
+	// or of all fields when Visibility itself is null.
+	Visibility patch.Field[map[string]*string]
+	// Revisions, when set, applies the update only if the current
+	// domain.User.Revision is one of them.
+	Revisions []string
+}
+
+// UserView is a user as seen by a particular requester. Exactly one of Full
+// and Public is set.
+type UserView struct {
+	Full   *domain.User
+	Public *domain.PublicUser
+	// Revision identifies this representation; the full and public views of
+	// the same user differ.
+	Revision string
+}
+
+func (v *UserView) MarshalJSON() ([]byte, error) {
+	if v.Full != nil {
+		return json.Marshal(v.Full)
+	}
+	return json.Marshal(v.Public)
+}
+
 type UserService interface {
 	GetMe(ctx context.Context, userID string) (*domain.User, error)
-	GetByID(ctx context.Context, requesterID, targetID string) (*domain.User, error)
-	UpdateProfile(ctx context.Context, userID string, displayName, avatarURL *string) (*domain.UserProfile, error)
+	// GetByID and GetByUsername return the full user to the user themself and
+	// to holders of users:read, and the public projection to everyone else.
+	GetByID(ctx context.Context, requesterID, targetID string) (*UserView, error)
+	GetByUsername(ctx context.Context, requesterID, name string) (*UserView, error)
+	// ChangeUsername claims a new username. Renames are limited to one per
+	// UsernameChangeInterval and the released name stays reserved for its
+	// previous owner during UsernameReleaseCooldown.
+	ChangeUsername(ctx context.Context, userID, username string) (*domain.User, error)
+	// UpdateProfile returns a *ValidationError listing every invalid field.
+	UpdateProfile(ctx context.Context, userID string, update ProfileUpdate) (*domain.UserProfile, error)
+	UploadAvatar(ctx context.Context, userID string, data []byte) (*domain.UserProfile, error)
 	StartEmailChange(ctx context.Context, userID, newEmail string) (string, error)
 	VerifyEmailChange(ctx context.Context, userID, uuid, code string) (*domain.User, error)
 	AttachIdentity(ctx context.Context, userID string, provider domain.IdentityProvider, providerUserID, email string, displayName, avatarURL *string) (*domain.UserIdentity, *domain.UserProfile, error)
+	ListIdentities(ctx context.Context, userID string) ([]domain.UserIdentity, error)
 	RemoveIdentity(ctx context.Context, userID string, provider domain.IdentityProvider, providerUserID string) error
 }
 
 type userService struct {
+	cfg        *config.Config
 	users      repo.UserRepository
 	profiles   repo.UserProfileRepository
 	identities repo.UserIdentityRepository
+	usernames  repo.UsernameHistoryRepository
 	tarantool  tarantool.Client
+	rbac       rbac.Client
+	avatars    AvatarStore
+	schemas    ProfileSchemaService
 }
 
-func NewUserService(users repo.UserRepository, profiles repo.UserProfileRepository, identities repo.UserIdentityRepository, tarantool tarantool.Client) UserService {
-	return &userService{users: users, profiles: profiles, identities: identities, tarantool: tarantool}
+func NewUserService(cfg *config.Config, users repo.UserRepository, profiles repo.UserProfileRepository, identities repo.UserIdentityRepository, usernames repo.UsernameHistoryRepository, tarantool tarantool.Client, rbacClient rbac.Client, avatars AvatarStore, schemas ProfileSchemaService) UserService {
+	return &userService{cfg: cfg, users: users, profiles: profiles, identities: identities, usernames: usernames, tarantool: tarantool, rbac: rbacClient, avatars: avatars, schemas: schemas}
 }
 
 func (s *userService) GetMe(ctx context.Context, userID string) (*domain.User, error) {
 	return s.users.FindByID(ctx, userID)
 }
 
-func (s *userService) GetByID(ctx context.Context, requesterID, targetID string) (*domain.User, error) {
-	return s.users.FindByID(ctx, targetID)
+func (s *userService) GetByID(ctx context.Context, requesterID, targetID string) (*UserView, error) {
+	user, err := s.users.FindByID(ctx, targetID)
+	if err != nil {
+		return nil, err
+	}
+	return s.viewFor(ctx, requesterID, user), nil
+}
+
+// viewFor decides how much of user the requester may see. An RBAC failure
+// falls back to the public projection.
+func (s *userService) viewFor(ctx context.Context, requesterID string, user *domain.User) *UserView {
+	if requesterID == user.ID {
+		return &UserView{Full: user, Revision: user.Revision()}
+	}
+	if s.rbac != nil {
+		if allowed, err := s.rbac.CheckPermission(ctx, requesterID, domain.PermUsersRead); err == nil && allowed {
+			return &UserView{Full: user, Revision: user.Revision()}
+		}
+	}
+	return &UserView{Public: user.Public(), Revision: "public." + user.Revision()}
+}
+
+func (s *userService) UpdateProfile(ctx context.Context, userID string, update ProfileUpdate) (*domain.UserProfile, error) {
+	if avatarURL := update.AvatarURL.Value; avatarURL != nil && *avatarURL != "" && !s.isOwnAvatarURL(*avatarURL) {
+		return nil, ErrAvatarURLNotAllowed
+	}
+	fieldErrs := NormalizeProfileUpdate(&update)
+	visibility := derefMap(update.Visibility.Value)
+	for _, field := range sortedKeys(visibility) {
+		if _, ok := domain.DefaultFieldVisibility[field]; !ok {
+			fieldErrs = append(fieldErrs, jsonschema.FieldError{Field: "visibility." + field, Message: "is not a configurable field"})
+		} else if value := visibility[field]; value != nil && !domain.FieldVisibility(*value).IsValid() {
+			fieldErrs = append(fieldErrs, jsonschema.FieldError{Field: "visibility." + field, Message: "must be public or private"})
+		}
+	}
+	for attempt := 1; ; attempt++ {
+		profile, err := s.profileAtRevision(ctx, userID, update.Revisions)
+		if err != nil {
+			return nil, err
+		}
+		// The attribute merge depends on the stored bag, so it is validated
+		// against each profile read.
+		var attributes domain.JSONMap
+		var attrErrs []jsonschema.FieldError
+		if update.Attributes.Present {
+			attributes = domain.JSONMap{}
+			if !update.Attributes.IsNull() {
+				attributes = profile.MergedAttributes(*update.Attributes.Value)
+			}
+			attrErrs, err = s.validateAttributes(ctx, attributes)
+			if err != nil {
+				return nil, err
+			}
+			for i := range attrErrs {
+				attrErrs[i].Field = "attributes." + attrErrs[i].Field
+			}
+		}
+		if errs := slices.Concat(fieldErrs, attrErrs); len(errs) > 0 {
+			return nil, &ValidationError{Fields: errs}
+		}
+
+		previousAvatar := &domain.UserProfile{AvatarURL: profile.AvatarURL, AvatarThumbnails: profile.AvatarThumbnails}
+		applyProfileUpdate(profile, update, attributes)
+		err = s.profiles.Update(ctx, profile)
+		if errors.Is(err, repo.ErrVersionConflict) {
+			// A conditional update must not be merged into changes the
+			// client has not seen; an unconditional one is re-applied.
+			if len(update.Revisions) > 0 {
+				return nil, ErrRevisionMismatch
+			}
+			if attempt < maxProfileUpdateAttempts {
+				continue
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if update.AvatarURL.Present && s.avatars != nil && !sameAvatar(previousAvatar.AvatarURL, profile.AvatarURL) {
+			// The profile no longer references the old files; one left
+			// behind only wastes storage.
+			_ = s.avatars.Remove(ctx, previousAvatar)
+		}
+		return profile, nil
+	}
+}
+
+func sameAvatar(a, b *string) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
+
+// profileAtRevision loads the profile to update, failing with
+// ErrRevisionMismatch unless the user is at one of revisions.
+func (s *userService) profileAtRevision(ctx context.Context, userID string, revisions []string) (*domain.UserProfile, error) {
+	if len(revisions) == 0 {
+		return s.profiles.FindByUserID(ctx, userID)
+	}
+	user, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Profile == nil || !slices.Contains(revisions, user.Revision()) {
+		return nil, ErrRevisionMismatch
+	}
+	return user.Profile, nil
+}
+
+func applyProfileUpdate(profile *domain.UserProfile, update ProfileUpdate, attributes domain.JSONMap) {
+	setProfileField(&profile.DisplayName, update.DisplayName)
+	if update.AvatarURL.Present {
+		setProfileField(&profile.AvatarURL, update.AvatarURL)
+		profile.AvatarThumbnails = nil
+	}
+	setProfileField(&profile.FirstName, update.FirstName)
+	setProfileField(&profile.LastName, update.LastName)
+	setProfileField(&profile.Locale, update.Locale)
+	setProfileField(&profile.Timezone, update.Timezone)
+	setProfileField(&profile.Bio, update.Bio)
+	setProfileField(&profile.Company, update.Company)
+	if attributes != nil {
+		profile.Attributes = attributes
+	}
+	switch {
+	case update.Visibility.IsNull():
+		profile.Visibility = nil
+	case update.Visibility.Present:
+		if profile.Visibility == nil {
+			profile.Visibility = domain.JSONMap{}
+		}
+		for field, visibility := range *update.Visibility.Value {
+			if visibility == nil {
+				delete(profile.Visibility, field)
+			} else {
+				profile.Visibility[field] = *visibility
+			}
+		}
+	}
+}
+
+// fieldNames lists the request fields present in the update.
+func (u ProfileUpdate) fieldNames() []string {
+	var names []string
+	for _, field := range []struct {
+		name  string
+		value patch.Field[string]
+	}{
+		{"display_name", u.DisplayName}, {"avatar_url", u.AvatarURL},
+		{"first_name", u.FirstName}, {"last_name", u.LastName},
+		{"locale", u.Locale}, {"timezone", u.Timezone},
+		{"bio", u.Bio}, {"company", u.Company},
+	} {
+		if field.value.Present {
+			names = append(names, field.name)
+		}
+	}
+	if u.Attributes.IsNull() {
+		names = append(names, "attributes")
+	}
+	for _, key := range sortedKeys(derefMap(u.Attributes.Value)) {
+		names = append(names, "attributes."+key)
+	}
+	if u.Visibility.IsNull() {
+		names = append(names, "visibility")
+	}
+	for _, key := range sortedKeys(derefMap(u.Visibility.Value)) {
+		names = append(names, "visibility."+key)
+	}
+	return names
+}
+
+// derefMap returns the map a patch member points at, or nil.
+func derefMap[V any](m *map[string]V) map[string]V {
+	if m == nil {
+		return nil
+	}
+	return *m
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *userService) validateAttributes(ctx context.Context, attributes map[string]interface{}) ([]jsonschema.FieldError, error) {
+	if s.schemas == nil {
+		return rejectAttributes(attributes), nil
+	}
+	return s.schemas.ValidateAttributes(ctx, attributes)
 }
 
-func (s *userService) UpdateProfile(ctx context.Context, userID string, displayName, avatarURL *string) (*domain.UserProfile, error) {
+// NormalizeProfileUpdate trims the standard fields, canonicalizes the locale
+// and reports fields that are out of bounds.
+func NormalizeProfileUpdate(update *ProfileUpdate) []jsonschema.FieldError {
+	var fieldErrs []jsonschema.FieldError
+	checkLength := func(field string, value *string, max int) {
+		if value == nil {
+			return
+		}
+		*value = strings.TrimSpace(*value)
+		if utf8.RuneCountInString(*value) > max {
+			fieldErrs = append(fieldErrs, jsonschema.FieldError{Field: field, Message: fmt.Sprintf("must be at most %d characters", max)})
+		}
+	}
+	checkLength("first_name", update.FirstName.Value, maxNameLength)
+	checkLength("last_name", update.LastName.Value, maxNameLength)
+	checkLength("company", update.Company.Value, maxCompanyLength)
+	checkLength("bio", update.Bio.Value, maxBioLength)
+
+	if update.Locale.Value != nil {
+		if raw := strings.TrimSpace(*update.Locale.Value); raw != "" {
+			tag, err := language.Parse(raw)
+			if err != nil || tag == language.Und {
+				fieldErrs = append(fieldErrs, jsonschema.FieldError{Field: "locale", Message: "must be a BCP 47 language tag"})
+			} else {
+				update.Locale = patch.Set(tag.String())
+			}
+		} else {
+			update.Locale = patch.Set(raw)
+		}
+	}
+	if update.Timezone.Value != nil {
+		*update.Timezone.Value = strings.TrimSpace(*update.Timezone.Value)
+		if tz := *update.Timezone.Value; tz != "" {
+			if _, err := time.LoadLocation(tz); err != nil || tz == "Local" {
+				fieldErrs = append(fieldErrs, jsonschema.FieldError{Field: "timezone", Message: "must be an IANA time zone name"})
+			}
+		}
+	}
+	return fieldErrs
+}
+
+// setProfileField applies a patch member; an empty value clears the field
+// like null does.
+func setProfileField(field **string, value patch.Field[string]) {
+	if value.Value != nil && *value.Value == "" {
+		value = patch.Null[string]()
+	}
+	value.Apply(field)
+}
+
+func (s *userService) UploadAvatar(ctx context.Context, userID string, data []byte) (*domain.UserProfile, error) {
+	if s.avatars == nil {
+		return nil, errors.New("avatar storage not configured")
+	}
 	profile, err := s.profiles.FindByUserID(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
-	profile.Update(displayName, avatarURL)
+	stored, err := s.avatars.Store(ctx, userID, data)
+	if err != nil {
+		return nil, err
+	}
+	profile.SetAvatar(stored.URL, stored.Thumbnails)
 	if err := s.profiles.Update(ctx, profile); err != nil {
 		return nil, err
 	}
 	return profile, nil
 }
 
+func (s *userService) isOwnAvatarURL(rawURL string) bool {
+	return s.avatars != nil && s.avatars.IsOwnURL(rawURL)
+}
+
 func (s *userService) StartEmailChange(ctx context.Context, userID, newEmail string) (string, error) {
 	if !strings.Contains(newEmail, "@") {
 		return "", fmt.Errorf("invalid email")
@@ -84,7 +420,7 @@ func (s *userService) VerifyEmailChange(ctx context.Context, userID, uuid, code
 
 func (s *userService) AttachIdentity(ctx context.Context, userID string, provider domain.IdentityProvider, providerUserID, email string, displayName, avatarURL *string) (*domain.UserIdentity, *domain.UserProfile, error) {
 	if !provider.IsValid() {
-		return nil, nil, fmt.Errorf("unsupported provider")
+		return nil, nil, ErrUnsupportedProvider
 	}
 	user, err := s.users.FindByID(ctx, userID)
 	if err != nil {
@@ -95,9 +431,11 @@ func (s *userService) AttachIdentity(ctx context.Context, userID string, provide
 	}
 	if existing, err := s.identities.FindByProviderUserID(ctx, provider, providerUserID); err == nil {
 		if existing.UserID != userID {
-			return nil, nil, errors.New("identity already linked to another user")
+			return nil, nil, ErrIdentityTaken
 		}
 		return existing, user.Profile, nil
+	} else if errors.Is(err, repo.ErrIdentityInOtherTenant) {
+		return nil, nil, ErrIdentityOtherTenant
 	}
 
 	identity := &domain.UserIdentity{
@@ -116,6 +454,10 @@ func (s *userService) AttachIdentity(ctx context.Context, userID string, provide
 	if err != nil {
 		return nil, nil, err
 	}
+	if avatarURL != nil && !s.isOwnAvatarURL(*avatarURL) {
+		// Provider avatars are never hotlinked into the profile.
+		avatarURL = nil
+	}
 	profile.Update(displayName, avatarURL)
 	if err := s.profiles.Update(ctx, profile); err != nil {
 		return nil, nil, err
@@ -126,7 +468,7 @@ func (s *userService) AttachIdentity(ctx context.Context, userID string, provide
 
 func (s *userService) RemoveIdentity(ctx context.Context, userID string, provider domain.IdentityProvider, providerUserID string) error {
 	if !provider.IsValid() {
-		return fmt.Errorf("unsupported provider")
+		return ErrUnsupportedProvider
 	}
 	identity, err := s.identities.FindByProviderUserID(ctx, provider, providerUserID)
 	if err != nil {
@@ -135,5 +477,19 @@ func (s *userService) RemoveIdentity(ctx context.Context, userID string, provide
 	if identity.UserID != userID {
 		return errors.New("identity does not belong to user")
 	}
-	return s.identities.Delete(ctx, identity)
+	return s.identities.Delete(ctx, identity, checkLoginMethodsLeft)
+}
+
+// checkLoginMethodsLeft refuses a change that leaves user without a way to
+// sign in; identities counts the provider accounts still linked to it.
+func checkLoginMethodsLeft(user *domain.User, identities int64) error {
+	// A verified phone signs in on its own through SMS codes.
+	if !user.HasPassword() && user.Phone == nil && identities == 0 {
+		return ErrLastLoginMethod
+	}
+	return nil
+}
+
+func (s *userService) ListIdentities(ctx context.Context, userID string) ([]domain.UserIdentity, error) {
+	return s.identities.FindByUserID(ctx, userID)
 }
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/user-service/internal/domain"
+)
+
+var (
+	ErrUsernameTaken         = errors.New("username is taken")
+	ErrUsernameChangeTooSoon = errors.New("username was changed too recently")
+)
+
+// UsernameChangeTooSoonError is returned while a rename is rate limited.
+type UsernameChangeTooSoonError struct {
+	RetryAt time.Time
+}
+
+func (e *UsernameChangeTooSoonError) Error() string {
+	return ErrUsernameChangeTooSoon.Error()
+}
+
+func (e *UsernameChangeTooSoonError) Unwrap() error {
+	return ErrUsernameChangeTooSoon
+}
+
+func (s *userService) GetByUsername(ctx context.Context, requesterID, name string) (*UserView, error) {
+	user, err := s.users.FindByUsername(ctx, domain.CanonicalUsername(name))
+	if err != nil {
+		return nil, err
+	}
+	return s.viewFor(ctx, requesterID, user), nil
+}
+
+func (s *userService) ChangeUsername(ctx context.Context, userID, username string) (*domain.User, error) {
+	name, err := domain.NormalizeUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	canonical := domain.CanonicalUsername(name)
+	if domain.IsReservedUsername(canonical) {
+		return nil, domain.ErrUsernameReserved
+	}
+	user, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.UsernameCanonical != nil && *user.UsernameCanonical == canonical {
+		// Only the spelling changes; that neither frees the old handle nor
+		// counts against the rename limit.
+		user.Username = &name
+		if err := s.users.Update(ctx, user); err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+
+	now := time.Now().UTC()
+	if user.UsernameChangedAt != nil && user.Username != nil {
+		if retryAt := user.UsernameChangedAt.Add(s.cfg.UsernameChangeInterval); now.Before(retryAt) {
+			return nil, &UsernameChangeTooSoonError{RetryAt: retryAt}
+		}
+	}
+	if owner, err := s.users.FindByUsername(ctx, canonical); err == nil && owner.ID != user.ID {
+		return nil, ErrUsernameTaken
+	} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	held, err := s.usernames.IsHeld(ctx, canonical, user.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	if held {
+		return nil, ErrUsernameTaken
+	}
+
+	hold := user.ReleaseUsername(now, s.cfg.UsernameReleaseCooldown)
+	user.SetUsername(name, now)
+	if err := s.users.UpdateUsername(ctx, user, hold); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrUsernameTaken
+		}
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,21 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/example/user-service/pkg/jsonschema"
+)
+
+// ValidationError reports every invalid field of a request so that clients
+// can show all problems at once.
+type ValidationError struct {
+	Fields []jsonschema.FieldError
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		parts = append(parts, field.Field+" "+field.Message)
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
@@ -0,0 +1,23 @@
+// Package tenant carries the organization a request acts in through its
+// context. Repositories scope their queries to it.
+package tenant
+
+import "context"
+
+// Default is the tenant of users that belong to no organization.
+const Default = ""
+
+type contextKey struct{}
+
+// With returns a context scoped to orgID, or to the default tenant when orgID
+// is Default.
+func With(ctx context.Context, orgID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, orgID)
+}
+
+// From returns the tenant of ctx. scoped is false for contexts that never had
+// a tenant, such as background jobs and CLI commands, which see every tenant.
+func From(ctx context.Context) (orgID string, scoped bool) {
+	orgID, scoped = ctx.Value(contextKey{}).(string)
+	return orgID, scoped
+}
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS account_link (
+    id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id uuid NOT NULL REFERENCES "user"(id) ON DELETE CASCADE,
+    provider text NOT NULL,
+    provider_user_id text NOT NULL,
+    email text NOT NULL,
+    metadata jsonb,
+    verification_uuid text,
+    attempts integer NOT NULL DEFAULT 0,
+    expires_at timestamptz NOT NULL,
+    consumed_at timestamptz,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_link_user_id ON account_link(user_id);
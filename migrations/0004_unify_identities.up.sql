@@ -0,0 +1,20 @@
+ALTER TABLE user_identity ADD COLUMN IF NOT EXISTS metadata jsonb;
+
+-- Copy OAuth links into user_identity. Rows that collide with an existing
+-- identity (same provider account, or a second account of the same provider
+-- for one user) are dropped; the oldest link wins.
+INSERT INTO user_identity (user_id, provider, provider_user_id, email, metadata, created_at, updated_at)
+SELECT DISTINCT ON (p.user_id, lower(p.provider_type))
+    p.user_id,
+    lower(p.provider_type),
+    p.provider_user_id,
+    u.email,
+    p.metadata,
+    p.created_at,
+    p.updated_at
+FROM user_provider p
+JOIN "user" u ON u.id = p.user_id
+ORDER BY p.user_id, lower(p.provider_type), p.created_at
+ON CONFLICT DO NOTHING;
+
+DROP TABLE IF EXISTS user_provider;
@@ -0,0 +1 @@
+ALTER TABLE user_identity DROP COLUMN IF EXISTS last_used_at;
@@ -0,0 +1 @@
+ALTER TABLE user_identity ADD COLUMN IF NOT EXISTS last_used_at timestamptz;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_user_email_id;
+DROP INDEX IF EXISTS idx_user_created_at_id;
@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS idx_user_created_at_id ON "user" (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_user_email_id ON "user" (email, id);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_user_suspended_until;
+
+ALTER TABLE "user" DROP COLUMN IF EXISTS tokens_revoked_at;
+ALTER TABLE "user" DROP COLUMN IF EXISTS suspension_reason;
+ALTER TABLE "user" DROP COLUMN IF EXISTS suspended_until;
+ALTER TABLE "user" DROP COLUMN IF EXISTS suspended_at;
@@ -0,0 +1,6 @@
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS suspended_at timestamptz;
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS suspended_until timestamptz;
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS suspension_reason text;
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS tokens_revoked_at timestamptz;
+
+CREATE INDEX IF NOT EXISTS idx_user_suspended_until ON "user" (suspended_until) WHERE suspended_until IS NOT NULL;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_user_purge_at;
+
+ALTER TABLE "user" DROP COLUMN IF EXISTS purge_at;
+ALTER TABLE "user" DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,4 @@
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS purge_at timestamptz;
+
+CREATE INDEX IF NOT EXISTS idx_user_purge_at ON "user" (purge_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS audit_event (
+    id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id uuid NOT NULL REFERENCES "user"(id) ON DELETE CASCADE,
+    actor_id text,
+    action text NOT NULL,
+    metadata jsonb,
+    trace_id text,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_event_user_id ON audit_event(user_id, created_at);
+
+CREATE TABLE IF NOT EXISTS data_export (
+    id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id uuid NOT NULL REFERENCES "user"(id) ON DELETE CASCADE,
+    requested_by text,
+    status text NOT NULL,
+    archive bytea,
+    error text,
+    expires_at timestamptz,
+    completed_at timestamptz,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_data_export_user_id ON data_export(user_id);
+CREATE INDEX IF NOT EXISTS idx_data_export_pending ON data_export(created_at) WHERE status = 'pending';
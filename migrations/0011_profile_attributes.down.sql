@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS profile_schema;
+
+ALTER TABLE user_profile
+    DROP COLUMN IF EXISTS attributes,
+    DROP COLUMN IF EXISTS company,
+    DROP COLUMN IF EXISTS bio,
+    DROP COLUMN IF EXISTS timezone,
+    DROP COLUMN IF EXISTS locale,
+    DROP COLUMN IF EXISTS last_name,
+    DROP COLUMN IF EXISTS first_name;
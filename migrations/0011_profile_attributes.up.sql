@@ -0,0 +1,16 @@
+ALTER TABLE user_profile
+    ADD COLUMN IF NOT EXISTS first_name text,
+    ADD COLUMN IF NOT EXISTS last_name text,
+    ADD COLUMN IF NOT EXISTS locale text,
+    ADD COLUMN IF NOT EXISTS timezone text,
+    ADD COLUMN IF NOT EXISTS bio text,
+    ADD COLUMN IF NOT EXISTS company text,
+    ADD COLUMN IF NOT EXISTS attributes jsonb DEFAULT '{}'::jsonb;
+
+CREATE TABLE IF NOT EXISTS profile_schema (
+    id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    version integer NOT NULL UNIQUE,
+    schema jsonb NOT NULL,
+    created_by text,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
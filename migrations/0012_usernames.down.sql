@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS username_history;
+DROP INDEX IF EXISTS idx_user_username_canonical;
+
+ALTER TABLE "user"
+    DROP COLUMN IF EXISTS username_changed_at,
+    DROP COLUMN IF EXISTS username_canonical,
+    DROP COLUMN IF EXISTS username;
@@ -0,0 +1,19 @@
+ALTER TABLE "user"
+    ADD COLUMN IF NOT EXISTS username text,
+    ADD COLUMN IF NOT EXISTS username_canonical text,
+    ADD COLUMN IF NOT EXISTS username_changed_at timestamptz;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_username_canonical ON "user"(username_canonical) WHERE username_canonical IS NOT NULL;
+
+-- No foreign key: holds outlive purged accounts so their handles cannot be
+-- taken over right away.
+CREATE TABLE IF NOT EXISTS username_history (
+    id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id uuid NOT NULL,
+    username text NOT NULL,
+    username_canonical text NOT NULL,
+    released_at timestamptz NOT NULL,
+    available_at timestamptz NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_username_history_canonical ON username_history(username_canonical, available_at);
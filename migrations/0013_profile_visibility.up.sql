@@ -0,0 +1 @@
+ALTER TABLE user_profile ADD COLUMN IF NOT EXISTS visibility jsonb;
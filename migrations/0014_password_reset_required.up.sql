@@ -0,0 +1 @@
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS password_reset_required boolean NOT NULL DEFAULT false;
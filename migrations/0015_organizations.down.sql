@@ -0,0 +1,14 @@
+DROP POLICY IF EXISTS org_membership_tenant_isolation ON org_membership;
+DROP POLICY IF EXISTS user_tenant_isolation ON "user";
+ALTER TABLE "user" NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE "user" DISABLE ROW LEVEL SECURITY;
+
+DROP TABLE IF EXISTS org_membership;
+
+DROP INDEX IF EXISTS idx_user_tenant_username_canonical;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_username_canonical ON "user"(username_canonical) WHERE username_canonical IS NOT NULL;
+DROP INDEX IF EXISTS idx_user_tenant_email;
+ALTER TABLE "user" ADD CONSTRAINT user_email_key UNIQUE (email);
+
+ALTER TABLE "user" DROP COLUMN IF EXISTS org_id;
+DROP TABLE IF EXISTS organization;
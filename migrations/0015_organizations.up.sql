@@ -0,0 +1,48 @@
+CREATE TABLE IF NOT EXISTS organization (
+    id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    slug text NOT NULL UNIQUE,
+    name text NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+-- Users without an organization belong to the default tenant.
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS org_id uuid REFERENCES organization(id);
+
+-- Emails and usernames are unique per tenant.
+ALTER TABLE "user" DROP CONSTRAINT IF EXISTS user_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_tenant_email
+    ON "user"(COALESCE(org_id, '00000000-0000-0000-0000-000000000000'::uuid), email);
+DROP INDEX IF EXISTS idx_user_username_canonical;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_tenant_username_canonical
+    ON "user"(COALESCE(org_id, '00000000-0000-0000-0000-000000000000'::uuid), username_canonical)
+    WHERE username_canonical IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS org_membership (
+    org_id uuid NOT NULL REFERENCES organization(id) ON DELETE CASCADE,
+    user_id uuid NOT NULL REFERENCES "user"(id) ON DELETE CASCADE,
+    role text NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (org_id, user_id)
+);
+
+-- app.tenant is set per transaction by the repositories: empty for
+-- background jobs, 'default' for the default tenant, otherwise an
+-- organization ID. FORCE applies the policies to the table owner as well.
+ALTER TABLE "user" ENABLE ROW LEVEL SECURITY;
+ALTER TABLE "user" FORCE ROW LEVEL SECURITY;
+CREATE POLICY user_tenant_isolation ON "user"
+    USING (
+        COALESCE(current_setting('app.tenant', true), '') = ''
+        OR (current_setting('app.tenant', true) = 'default' AND org_id IS NULL)
+        OR org_id::text = current_setting('app.tenant', true)
+    );
+
+ALTER TABLE org_membership ENABLE ROW LEVEL SECURITY;
+ALTER TABLE org_membership FORCE ROW LEVEL SECURITY;
+CREATE POLICY org_membership_tenant_isolation ON org_membership
+    USING (
+        COALESCE(current_setting('app.tenant', true), '') = ''
+        OR org_id::text = current_setting('app.tenant', true)
+    );
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS group_nesting;
+DROP TABLE IF EXISTS group_member;
+DROP TABLE IF EXISTS user_group;
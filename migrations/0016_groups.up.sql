@@ -0,0 +1,41 @@
+CREATE TABLE IF NOT EXISTS user_group (
+    id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    org_id uuid REFERENCES organization(id) ON DELETE CASCADE,
+    name text NOT NULL,
+    description text,
+    created_by text,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_group_org ON user_group(org_id, created_at);
+
+CREATE TABLE IF NOT EXISTS group_member (
+    group_id uuid NOT NULL REFERENCES user_group(id) ON DELETE CASCADE,
+    user_id uuid NOT NULL REFERENCES "user"(id) ON DELETE CASCADE,
+    role text NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (group_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_group_member_user ON group_member(user_id);
+
+CREATE TABLE IF NOT EXISTS group_nesting (
+    parent_id uuid NOT NULL REFERENCES user_group(id) ON DELETE CASCADE,
+    child_id uuid NOT NULL REFERENCES user_group(id) ON DELETE CASCADE,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (parent_id, child_id),
+    CHECK (parent_id <> child_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_group_nesting_child ON group_nesting(child_id);
+
+-- Same tenant isolation as "user", see migration 0015.
+ALTER TABLE user_group ENABLE ROW LEVEL SECURITY;
+ALTER TABLE user_group FORCE ROW LEVEL SECURITY;
+CREATE POLICY user_group_tenant_isolation ON user_group
+    USING (
+        COALESCE(current_setting('app.tenant', true), '') = ''
+        OR (current_setting('app.tenant', true) = 'default' AND org_id IS NULL)
+        OR org_id::text = current_setting('app.tenant', true)
+    );
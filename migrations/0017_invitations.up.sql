@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS invitation (
+    id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    email text NOT NULL,
+    org_id uuid REFERENCES organization(id) ON DELETE CASCADE,
+    role text NOT NULL,
+    invited_by text,
+    sends integer NOT NULL DEFAULT 1,
+    last_sent_at timestamptz NOT NULL,
+    expires_at timestamptz NOT NULL,
+    accepted_at timestamptz,
+    accepted_by uuid REFERENCES "user"(id) ON DELETE SET NULL,
+    revoked_at timestamptz,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_invitation_email ON invitation(email, org_id);
+CREATE INDEX IF NOT EXISTS idx_invitation_org ON invitation(org_id, created_at DESC);
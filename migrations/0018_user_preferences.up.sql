@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS user_preference (
+    user_id uuid PRIMARY KEY REFERENCES "user"(id) ON DELETE CASCADE,
+    preferences jsonb NOT NULL DEFAULT '{}'::jsonb,
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_user_profile_display_name_trgm;
+DROP INDEX IF EXISTS idx_user_username_trgm;
+DROP INDEX IF EXISTS idx_user_email_trgm;
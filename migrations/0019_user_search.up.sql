@@ -0,0 +1,5 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_user_email_trgm ON "user" USING gin (email gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_user_username_trgm ON "user" USING gin (username_canonical gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_user_profile_display_name_trgm ON user_profile USING gin (lower(display_name) gin_trgm_ops);
@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS user_email (
+    id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id uuid NOT NULL REFERENCES "user"(id) ON DELETE CASCADE,
+    org_id uuid REFERENCES organization(id) ON DELETE CASCADE,
+    email text NOT NULL,
+    is_primary boolean NOT NULL DEFAULT false,
+    verified_at timestamptz,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+-- Verified addresses are unique per tenant across all users, like
+-- "user".email. Unverified ones only per user, so that adding someone
+-- else's address does not lock them out of it.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_email_tenant_verified
+    ON user_email(COALESCE(org_id, '00000000-0000-0000-0000-000000000000'::uuid), email)
+    WHERE verified_at IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_email_user_email ON user_email(user_id, email);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_email_primary ON user_email(user_id) WHERE is_primary;
+
+INSERT INTO user_email (user_id, org_id, email, is_primary, verified_at, created_at)
+SELECT id, org_id, email, true, created_at, created_at FROM "user"
+ON CONFLICT DO NOTHING;
+
+-- Same tenant isolation as "user", see migration 0015.
+ALTER TABLE user_email ENABLE ROW LEVEL SECURITY;
+ALTER TABLE user_email FORCE ROW LEVEL SECURITY;
+CREATE POLICY user_email_tenant_isolation ON user_email
+    USING (
+        COALESCE(current_setting('app.tenant', true), '') = ''
+        OR (current_setting('app.tenant', true) = 'default' AND org_id IS NULL)
+        OR org_id::text = current_setting('app.tenant', true)
+    );
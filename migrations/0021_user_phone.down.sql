@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS phone_code;
+DROP INDEX IF EXISTS idx_user_tenant_phone;
+ALTER TABLE "user" DROP COLUMN IF EXISTS phone_mfa;
+ALTER TABLE "user" DROP COLUMN IF EXISTS phone_verified_at;
+ALTER TABLE "user" DROP COLUMN IF EXISTS phone;
@@ -0,0 +1,23 @@
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS phone text;
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS phone_verified_at timestamptz;
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS phone_mfa boolean NOT NULL DEFAULT false;
+
+-- Phones are unique per tenant, like emails. Only verified numbers are
+-- stored here; pending ones live in phone_code.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_tenant_phone
+    ON "user"(COALESCE(org_id, '00000000-0000-0000-0000-000000000000'::uuid), phone)
+    WHERE phone IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS phone_code (
+    id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    purpose text NOT NULL,
+    user_id uuid NOT NULL REFERENCES "user"(id) ON DELETE CASCADE,
+    phone text NOT NULL,
+    code_hash text NOT NULL,
+    attempts integer NOT NULL DEFAULT 0,
+    expires_at timestamptz NOT NULL,
+    consumed_at timestamptz,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_phone_code_phone ON phone_code(phone, created_at);
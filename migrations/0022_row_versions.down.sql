@@ -0,0 +1,2 @@
+ALTER TABLE user_profile DROP COLUMN IF EXISTS version;
+ALTER TABLE "user" DROP COLUMN IF EXISTS version;
@@ -0,0 +1,4 @@
+-- Incremented on every write; conditional updates compare it to detect
+-- concurrent edits.
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS version bigint NOT NULL DEFAULT 1;
+ALTER TABLE user_profile ADD COLUMN IF NOT EXISTS version bigint NOT NULL DEFAULT 1;
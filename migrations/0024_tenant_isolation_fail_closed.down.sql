@@ -0,0 +1,26 @@
+ALTER POLICY user_email_tenant_isolation ON user_email
+    USING (
+        COALESCE(current_setting('app.tenant', true), '') = ''
+        OR (current_setting('app.tenant', true) = 'default' AND org_id IS NULL)
+        OR org_id::text = current_setting('app.tenant', true)
+    );
+
+ALTER POLICY user_group_tenant_isolation ON user_group
+    USING (
+        COALESCE(current_setting('app.tenant', true), '') = ''
+        OR (current_setting('app.tenant', true) = 'default' AND org_id IS NULL)
+        OR org_id::text = current_setting('app.tenant', true)
+    );
+
+ALTER POLICY org_membership_tenant_isolation ON org_membership
+    USING (
+        COALESCE(current_setting('app.tenant', true), '') = ''
+        OR org_id::text = current_setting('app.tenant', true)
+    );
+
+ALTER POLICY user_tenant_isolation ON "user"
+    USING (
+        COALESCE(current_setting('app.tenant', true), '') = ''
+        OR (current_setting('app.tenant', true) = 'default' AND org_id IS NULL)
+        OR org_id::text = current_setting('app.tenant', true)
+    );
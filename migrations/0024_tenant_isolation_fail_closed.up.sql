@@ -0,0 +1,30 @@
+-- An unset or empty app.tenant used to let every row through, so a query
+-- that missed the tenant setup read across tenants. It now matches nothing;
+-- background jobs and CLI commands set app.tenant to '*' to act on every
+-- tenant. Data migrations on these tables must do the same.
+ALTER POLICY user_tenant_isolation ON "user"
+    USING (
+        current_setting('app.tenant', true) = '*'
+        OR (current_setting('app.tenant', true) = 'default' AND org_id IS NULL)
+        OR org_id::text = current_setting('app.tenant', true)
+    );
+
+ALTER POLICY org_membership_tenant_isolation ON org_membership
+    USING (
+        current_setting('app.tenant', true) = '*'
+        OR org_id::text = current_setting('app.tenant', true)
+    );
+
+ALTER POLICY user_group_tenant_isolation ON user_group
+    USING (
+        current_setting('app.tenant', true) = '*'
+        OR (current_setting('app.tenant', true) = 'default' AND org_id IS NULL)
+        OR org_id::text = current_setting('app.tenant', true)
+    );
+
+ALTER POLICY user_email_tenant_isolation ON user_email
+    USING (
+        current_setting('app.tenant', true) = '*'
+        OR (current_setting('app.tenant', true) = 'default' AND org_id IS NULL)
+        OR org_id::text = current_setting('app.tenant', true)
+    );
@@ -0,0 +1,8 @@
+-- "user" forces row-level security; see every tenant while removing the
+-- audit rows of purged accounts.
+SELECT set_config('app.tenant', '*', true);
+
+DELETE FROM audit_event a WHERE NOT EXISTS (SELECT 1 FROM "user" u WHERE u.id = a.user_id);
+
+ALTER TABLE audit_event
+    ADD CONSTRAINT audit_event_user_id_fkey FOREIGN KEY (user_id) REFERENCES "user"(id) ON DELETE CASCADE;
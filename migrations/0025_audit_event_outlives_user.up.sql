@@ -0,0 +1,3 @@
+-- Audit rows record what admins did to an account and must survive the
+-- purge of that account, so user_id no longer references "user".
+ALTER TABLE audit_event DROP CONSTRAINT IF EXISTS audit_event_user_id_fkey;
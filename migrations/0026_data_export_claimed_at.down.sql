@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_data_export_running;
+ALTER TABLE data_export DROP COLUMN IF EXISTS claimed_at;
@@ -0,0 +1,5 @@
+-- claimed_at records when a worker took an export, so that exports left
+-- running by a worker that died can be picked up again.
+ALTER TABLE data_export ADD COLUMN claimed_at timestamptz;
+
+CREATE INDEX idx_data_export_running ON data_export(claimed_at) WHERE status = 'running';
@@ -0,0 +1,68 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const profileSchema = `{
+	"type": "object",
+	"additionalProperties": false,
+	"required": ["department"],
+	"properties": {
+		"department": {"type": "string", "enum": ["sales", "support"]},
+		"employee_id": {"type": "integer", "minimum": 1},
+		"website": {"type": "string", "format": "uri"},
+		"nickname": {"type": "string", "maxLength": 3, "pattern": "^[a-z]+$"},
+		"tags": {"type": "array", "maxItems": 2, "items": {"type": "string"}}
+	}
+}`
+
+func decode(t *testing.T, raw string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestValidate(t *testing.T) {
+	schema, err := Compile([]byte(profileSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := schema.Validate(decode(t, `{"department":"sales","employee_id":7,"tags":["a"]}`)); errs != nil {
+		t.Fatalf("expected valid document, got %v", errs)
+	}
+
+	errs := schema.Validate(decode(t, `{"employee_id":1.5,"website":"not a url","nickname":"ABCD","tags":["a",2,"c"],"extra":true}`))
+	want := []FieldError{
+		{Field: "department", Message: "is required"},
+		{Field: "employee_id", Message: "must be of type integer"},
+		{Field: "extra", Message: "is not allowed"},
+		{Field: "nickname", Message: "must be at most 3 characters"},
+		{Field: "nickname", Message: "must match pattern ^[a-z]+$"},
+		{Field: "tags", Message: "must have at most 2 items"},
+		{Field: "tags[1]", Message: "must be of type string"},
+		{Field: "website", Message: "must be a valid uri"},
+	}
+	if !reflect.DeepEqual(errs, want) {
+		t.Fatalf("unexpected errors:\n got %v\nwant %v", errs, want)
+	}
+}
+
+func TestCompileRejectsUnsupportedKeywords(t *testing.T) {
+	for _, raw := range []string{
+		`{"type": "object", "oneOf": []}`,
+		`{"type": "tuple"}`,
+		`{"type": "string", "format": "hostname"}`,
+		`{"properties": {"a": {"type": "string", "pattern": "("}}}`,
+	} {
+		if _, err := Compile([]byte(raw)); !errors.Is(err, ErrInvalidSchema) {
+			t.Errorf("%s: expected ErrInvalidSchema, got %v", raw, err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// Package passwordhash verifies password hashes produced by this service
+// (bcrypt) and by systems users were imported from (argon2i and argon2id in
+// PHC string format).
+package passwordhash
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrUnsupportedHash = errors.New("unsupported password hash")
+
+// Upper bounds for imported argon2 parameters. Verifying costs what the hash
+// asks for, so a crafted hash could otherwise make one sign-in allocate
+// gigabytes or run for minutes.
+const (
+	maxArgon2Memory  = 256 * 1024 // KiB
+	maxArgon2Time    = 16
+	maxArgon2Threads = 16
+	maxArgon2KeyLen  = 64
+)
+
+type argon2Params struct {
+	variant string
+	memory  uint32
+	time    uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+// Validate checks that hash is in a supported format without verifying any
+// password against it.
+func Validate(hash string) error {
+	switch {
+	case isBcrypt(hash):
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return fmt.Errorf("%w: %v", ErrUnsupportedHash, err)
+		}
+		return nil
+	case strings.HasPrefix(hash, "$argon2"):
+		_, err := parseArgon2(hash)
+		return err
+	}
+	return ErrUnsupportedHash
+}
+
+// Verify reports whether password matches hash. An error means the hash
+// itself is unusable.
+func Verify(hash, password string) (bool, error) {
+	if isBcrypt(hash) {
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	params, err := parseArgon2(hash)
+	if err != nil {
+		return false, err
+	}
+	var key []byte
+	if params.variant == "argon2id" {
+		key = argon2.IDKey([]byte(password), params.salt, params.time, params.memory, params.threads, uint32(len(params.key)))
+	} else {
+		key = argon2.Key([]byte(password), params.salt, params.time, params.memory, params.threads, uint32(len(params.key)))
+	}
+	return subtle.ConstantTimeCompare(key, params.key) == 1, nil
+}
+
+// NeedsUpgrade reports whether hash should be replaced by a fresh bcrypt
+// hash the next time the plain password is known.
+func NeedsUpgrade(hash string) bool {
+	return !isBcrypt(hash)
+}
+
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// parseArgon2 reads $argon2id$v=19$m=65536,t=3,p=4$<salt>$<key> with
+// unpadded standard base64 salt and key.
+func parseArgon2(hash string) (*argon2Params, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" {
+		return nil, fmt.Errorf("%w: malformed argon2 hash", ErrUnsupportedHash)
+	}
+	params := &argon2Params{variant: parts[1]}
+	if params.variant != "argon2id" && params.variant != "argon2i" {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedHash, params.variant)
+	}
+	if parts[2] != "v=19" {
+		return nil, fmt.Errorf("%w: argon2 version %s", ErrUnsupportedHash, parts[2])
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return nil, fmt.Errorf("%w: argon2 parameters: %v", ErrUnsupportedHash, err)
+	}
+	if params.memory == 0 || params.time == 0 || params.threads == 0 {
+		return nil, fmt.Errorf("%w: argon2 parameters must be positive", ErrUnsupportedHash)
+	}
+	if params.memory > maxArgon2Memory || params.time > maxArgon2Time || params.threads > maxArgon2Threads {
+		return nil, fmt.Errorf("%w: argon2 parameters exceed m=%d,t=%d,p=%d", ErrUnsupportedHash, maxArgon2Memory, maxArgon2Time, maxArgon2Threads)
+	}
+	var err error
+	if params.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, fmt.Errorf("%w: argon2 salt: %v", ErrUnsupportedHash, err)
+	}
+	if params.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(params.key) == 0 || len(params.key) > maxArgon2KeyLen {
+		return nil, fmt.Errorf("%w: argon2 key", ErrUnsupportedHash)
+	}
+	return params, nil
+}
@@ -0,0 +1,56 @@
+package passwordhash
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func argon2idHash(password string) string {
+	salt := []byte("0123456789abcdef")
+	key := argon2.IDKey([]byte(password), salt, 1, 64, 1, 32)
+	return fmt.Sprintf("$argon2id$v=19$m=64,t=1,p=1$%s$%s", base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+func TestVerify(t *testing.T) {
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, hash := range []string{string(bcryptHash), argon2idHash("secret")} {
+		if err := Validate(hash); err != nil {
+			t.Fatalf("Validate(%q): %v", hash, err)
+		}
+		if ok, err := Verify(hash, "secret"); !ok || err != nil {
+			t.Errorf("Verify(%q, secret) = %v, %v", hash, ok, err)
+		}
+		if ok, err := Verify(hash, "wrong"); ok || err != nil {
+			t.Errorf("Verify(%q, wrong) = %v, %v", hash, ok, err)
+		}
+	}
+	if NeedsUpgrade(string(bcryptHash)) || !NeedsUpgrade(argon2idHash("secret")) {
+		t.Error("only non-bcrypt hashes need an upgrade")
+	}
+}
+
+func TestValidateRejectsUnknownHashes(t *testing.T) {
+	for _, hash := range []string{
+		"plaintext",
+		"$1$md5crypt",
+		"$argon2d$v=19$m=64,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=16$m=64,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=0,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=4194304,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=64,t=1000,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=64$c2FsdA$a2V5",
+		"$2a$10$short",
+	} {
+		if err := Validate(hash); !errors.Is(err, ErrUnsupportedHash) {
+			t.Errorf("Validate(%q) = %v; want ErrUnsupportedHash", hash, err)
+		}
+	}
+}
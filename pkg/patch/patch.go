@@ -0,0 +1,74 @@
+// Package patch decodes JSON Merge Patch (RFC 7396) documents into typed
+// request structs. A member that is absent leaves the target unchanged, null
+// clears it and any other value replaces it; nested objects are merged by the
+// caller.
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// MediaType is the content type of merge patch documents.
+const MediaType = "application/merge-patch+json"
+
+// Field is one member of a merge patch document. Its zero value is an absent
+// member.
+type Field[T any] struct {
+	// Present reports whether the member appeared in the document.
+	Present bool
+	// Value is nil when the member was null.
+	Value *T
+}
+
+// Set returns a member replacing the target with value.
+func Set[T any](value T) Field[T] {
+	return Field[T]{Present: true, Value: &value}
+}
+
+// Null returns a member clearing the target.
+func Null[T any]() Field[T] {
+	return Field[T]{Present: true}
+}
+
+// FromPointer returns an absent member for nil and a replacing one otherwise.
+func FromPointer[T any](value *T) Field[T] {
+	if value == nil {
+		return Field[T]{}
+	}
+	return Set(*value)
+}
+
+// IsNull reports whether the member clears the target.
+func (f Field[T]) IsNull() bool {
+	return f.Present && f.Value == nil
+}
+
+// Apply writes the member to target, leaving it alone when absent.
+func (f Field[T]) Apply(target **T) {
+	if !f.Present {
+		return
+	}
+	if f.Value == nil {
+		*target = nil
+		return
+	}
+	value := *f.Value
+	*target = &value
+}
+
+// UnmarshalJSON is only called for members present in the document,
+// including null ones.
+func (f *Field[T]) UnmarshalJSON(data []byte) error {
+	f.Present = true
+	f.Value = nil
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return nil
+	}
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	f.Value = &value
+	return nil
+}
@@ -0,0 +1,63 @@
+package patch
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type document struct {
+	Name  Field[string]            `json:"name"`
+	Count Field[int]               `json:"count"`
+	Tags  Field[map[string]string] `json:"tags"`
+}
+
+func TestUnmarshalDistinguishesAbsentAndNull(t *testing.T) {
+	var doc document
+	if err := json.Unmarshal([]byte(`{"name":null,"count":3}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if !doc.Name.IsNull() {
+		t.Errorf("name: expected null, got %+v", doc.Name)
+	}
+	if !doc.Count.Present || doc.Count.Value == nil || *doc.Count.Value != 3 {
+		t.Errorf("count: expected 3, got %+v", doc.Count)
+	}
+	if doc.Tags.Present {
+		t.Errorf("tags: expected absent, got %+v", doc.Tags)
+	}
+}
+
+func TestUnmarshalRejectsWrongType(t *testing.T) {
+	var doc document
+	if err := json.Unmarshal([]byte(`{"count":"three"}`), &doc); err == nil {
+		t.Error("expected an error for a string count")
+	}
+}
+
+func TestApply(t *testing.T) {
+	old := "old"
+	target := &old
+
+	Field[string]{}.Apply(&target)
+	if target == nil || *target != "old" {
+		t.Errorf("absent member changed the target to %v", target)
+	}
+	Set("new").Apply(&target)
+	if target == nil || *target != "new" {
+		t.Errorf("expected new, got %v", target)
+	}
+	Null[string]().Apply(&target)
+	if target != nil {
+		t.Errorf("expected nil, got %q", *target)
+	}
+}
+
+func TestFromPointer(t *testing.T) {
+	if FromPointer[string](nil).Present {
+		t.Error("nil pointer should be an absent member")
+	}
+	value := "x"
+	if f := FromPointer(&value); !f.Present || *f.Value != "x" {
+		t.Errorf("expected x, got %+v", f)
+	}
+}
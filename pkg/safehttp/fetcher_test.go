@@ -0,0 +1,116 @@
+package safehttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestFetcher(maxBytes int64) *Fetcher {
+	f := NewFetcher(Options{
+		AllowedSchemes:      []string{"http"},
+		AllowedContentTypes: []string{"image/png"},
+		MaxRedirects:        2,
+		MaxBytes:            maxBytes,
+		Timeout:             2 * time.Second,
+	})
+	// httptest listens on loopback; allow it so the other policies can be exercised.
+	f.allowAddr = func(addr netip.Addr) bool { return addr.IsLoopback() }
+	return f
+}
+
+func TestFetcher_BlocksInternalAddresses(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte("png"))
+	}))
+	defer server.Close()
+
+	f := NewFetcher(Options{AllowedSchemes: []string{"http", "https"}, MaxRedirects: 2, Timeout: time.Second})
+	_, err := f.Get(context.Background(), server.URL)
+	require.ErrorIs(t, err, ErrAddressNotAllowed)
+	// Hostnames are checked after resolution, at dial time.
+	_, err = f.Get(context.Background(), strings.Replace(server.URL, "127.0.0.1", "localhost", 1))
+	require.ErrorIs(t, err, ErrAddressNotAllowed)
+
+	for _, target := range []string{
+		"http://169.254.169.254/latest/meta-data",
+		"http://10.0.0.5/",
+		"http://[::1]/",
+		"http://[::ffff:127.0.0.1]/",
+		"http://100.64.0.1/",
+	} {
+		_, err := f.Get(context.Background(), target)
+		require.ErrorIs(t, err, ErrAddressNotAllowed, target)
+	}
+
+	_, err = f.Get(context.Background(), "file:///etc/passwd")
+	require.ErrorIs(t, err, ErrSchemeNotAllowed)
+}
+
+func TestFetcher_RechecksRedirects(t *testing.T) {
+	t.Parallel()
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/metadata":
+			http.Redirect(w, r, "http://169.254.169.254/latest/meta-data", http.StatusFound)
+		case "/scheme":
+			http.Redirect(w, r, "ftp://example.com/avatar.png", http.StatusFound)
+		default:
+			http.Redirect(w, r, server.URL+"/loop", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	f := newTestFetcher(1024)
+	_, err := f.Get(context.Background(), server.URL+"/metadata")
+	require.ErrorIs(t, err, ErrAddressNotAllowed)
+	_, err = f.Get(context.Background(), server.URL+"/scheme")
+	require.ErrorIs(t, err, ErrSchemeNotAllowed)
+	_, err = f.Get(context.Background(), server.URL+"/loop")
+	require.ErrorIs(t, err, ErrTooManyRedirects)
+	require.True(t, IsPolicyViolation(err))
+}
+
+func TestFetcher_ContentTypeAndSizeLimits(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/html":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte("<html></html>"))
+		case "/big":
+			w.Header().Set("Content-Type", "image/png")
+			// Stream without Content-Length so only the read limit can catch it.
+			w.(http.Flusher).Flush()
+			_, _ = w.Write([]byte(strings.Repeat("x", 64)))
+		default:
+			w.Header().Set("Content-Type", "image/png; charset=binary")
+			_, _ = w.Write([]byte(strings.Repeat("x", 16)))
+		}
+	}))
+	defer server.Close()
+
+	f := newTestFetcher(32)
+	_, err := f.Get(context.Background(), server.URL+"/html")
+	require.ErrorIs(t, err, ErrContentTypeNotAllowed)
+
+	_, err = f.Get(context.Background(), server.URL+"/big")
+	require.ErrorIs(t, err, ErrBodyTooLarge)
+
+	resp, err := f.Get(context.Background(), server.URL+"/ok")
+	require.NoError(t, err)
+	require.Equal(t, "image/png", resp.ContentType)
+	require.Len(t, resp.Body, 16)
+}
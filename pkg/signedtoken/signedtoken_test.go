@@ -0,0 +1,30 @@
+package signedtoken
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	signer := NewSigner([]byte("secret"))
+	now := time.Unix(1700000000, 0)
+	token := signer.Sign("restore", "user-1", now.Add(time.Minute))
+
+	subject, err := signer.Verify("restore", token, now)
+	if err != nil || subject != "user-1" {
+		t.Fatalf("expected user-1, got %q (%v)", subject, err)
+	}
+	if _, err := signer.Verify("export", token, now); !errors.Is(err, ErrSignature) {
+		t.Fatalf("expected signature error for other purpose, got %v", err)
+	}
+	if _, err := NewSigner([]byte("other")).Verify("restore", token, now); !errors.Is(err, ErrSignature) {
+		t.Fatalf("expected signature error for other secret, got %v", err)
+	}
+	if _, err := signer.Verify("restore", token, now.Add(time.Minute)); !errors.Is(err, ErrExpired) {
+		t.Fatalf("expected expiry error, got %v", err)
+	}
+	if _, err := signer.Verify("restore", "garbage", now); !errors.Is(err, ErrMalformed) {
+		t.Fatalf("expected malformed error, got %v", err)
+	}
+}
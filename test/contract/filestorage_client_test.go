@@ -0,0 +1,68 @@
+package contract
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/example/user-service/internal/ports/filestorage"
+)
+
+const contractFileURL = "https://files.example.com/user-1-original.png"
+
+func TestFileStorageClientContract(t *testing.T) {
+	handler := &mockFileStorageHandler{t: t}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	client := filestorage.NewHTTPClient(server.URL, 2*time.Second)
+	ctx := context.Background()
+
+	url, err := client.Upload(ctx, "user-1-original.png", "image/png", []byte("png-bytes"))
+	require.NoError(t, err)
+	require.Equal(t, contractFileURL, url)
+
+	require.NoError(t, client.Delete(ctx, url))
+	require.Equal(t, []string{contractFileURL}, handler.deleted)
+
+	// Deleting a file that is already gone is not an error.
+	require.NoError(t, client.Delete(ctx, "https://files.example.com/missing.png"))
+}
+
+type mockFileStorageHandler struct {
+	t       *testing.T
+	deleted []string
+}
+
+func (h *mockFileStorageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/files" {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		file, header, err := r.FormFile("file")
+		require.NoError(h.t, err)
+		defer file.Close()
+		data, _ := io.ReadAll(file)
+		require.Equal(h.t, "user-1-original.png", header.Filename)
+		require.Equal(h.t, "png-bytes", string(data))
+		require.Equal(h.t, "image/png", r.FormValue("content_type"))
+		writeJSON(w, http.StatusOK, map[string]string{"url": contractFileURL})
+	case http.MethodDelete:
+		target := r.URL.Query().Get("url")
+		if target != contractFileURL {
+			http.NotFound(w, r)
+			return
+		}
+		h.deleted = append(h.deleted, target)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
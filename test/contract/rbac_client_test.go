@@ -47,11 +47,15 @@ func TestRBACClientContract(t *testing.T) {
 	permitted, err := client.CheckPermission(ctx, testUserID, testPerm)
 	require.NoError(t, err)
 	require.Equal(t, testPermBool, permitted)
+
+	require.NoError(t, client.RevokeRoles(ctx, testUserID))
+	require.True(t, handler.revokeCalled)
 }
 
 type mockRBACHandler struct {
 	t            *testing.T
 	assignCalled bool
+	revokeCalled bool
 }
 
 func newMockRBACHandler(t *testing.T) *mockRBACHandler {
@@ -72,6 +76,16 @@ func (h *mockRBACHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		require.Equal(h.t, testUserID, payload.Value.UserID)
 		require.Equal(h.t, testRoleKey, payload.Value.Role)
 		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	case "/revoke_roles":
+		h.revokeCalled = true
+		var payload struct {
+			Value struct {
+				UserID string `json:"user_id"`
+			} `json:"value"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		require.Equal(h.t, testUserID, payload.Value.UserID)
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
 	case "/get_role_by_user_id":
 		require.Equal(h.t, testUserID, r.URL.Query().Get("user_id"))
 		writeJSON(w, http.StatusOK, map[string]string{"role": testRoleKey})
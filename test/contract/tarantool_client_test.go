@@ -17,6 +17,7 @@ import (
 const (
 	contractSignupCode      = "contract-code-123"
 	contractEmailChangeCode = "contract-code-456"
+	contractAccountLinkCode = "contract-code-789"
 )
 
 func TestTarantoolClientContract(t *testing.T) {
@@ -43,6 +44,14 @@ func TestTarantoolClientContract(t *testing.T) {
 	changeResult, err := client.VerifyEmailChange(ctx, changeUUID, contractEmailChangeCode)
 	require.NoError(t, err)
 	require.Equal(t, "new@example.com", changeResult.Email)
+
+	linkUUID, err := client.StartAccountLink(ctx, "user-1", "owner@example.com")
+	require.NoError(t, err)
+	require.NotEmpty(t, linkUUID)
+
+	linkResult, err := client.VerifyAccountLink(ctx, linkUUID, contractAccountLinkCode)
+	require.NoError(t, err)
+	require.Equal(t, "owner@example.com", linkResult.Email)
 }
 
 type contractServer struct {
@@ -50,6 +59,8 @@ type contractServer struct {
 	signupPassword    string
 	emailChangeUUID   string
 	emailChangeTarget string
+	accountLinkUUID   string
+	accountLinkEmail  string
 }
 
 func newContractServer() *contractServer {
@@ -66,6 +77,10 @@ func (s *contractServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		s.handleStartEmailChange(w, r)
 	case "/verify-email-change":
 		s.handleVerifyEmailChange(w, r)
+	case "/start-account-link":
+		s.handleStartAccountLink(w, r)
+	case "/verify-account-link":
+		s.handleVerifyAccountLink(w, r)
 	default:
 		http.NotFound(w, r)
 	}
@@ -126,3 +141,31 @@ func (s *contractServer) handleVerifyEmailChange(w http.ResponseWriter, r *http.
 	}
 	writeJSON(w, http.StatusOK, map[string]string{"email": s.emailChangeTarget})
 }
+
+func (s *contractServer) handleStartAccountLink(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Value struct {
+			UserID string `json:"user_id"`
+			Email  string `json:"email"`
+		} `json:"value"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&payload)
+	s.accountLinkEmail = payload.Value.Email
+	s.accountLinkUUID = fmt.Sprintf("%s-link", payload.Value.UserID)
+	writeJSON(w, http.StatusOK, map[string]string{"uuid": s.accountLinkUUID})
+}
+
+func (s *contractServer) handleVerifyAccountLink(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Value struct {
+			UUID string `json:"uuid"`
+			Code string `json:"code"`
+		} `json:"value"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&payload)
+	if payload.Value.UUID != s.accountLinkUUID || payload.Value.Code != contractAccountLinkCode {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"email": s.accountLinkEmail})
+}
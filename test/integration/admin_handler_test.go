@@ -0,0 +1,227 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/ports/http/handlers"
+	"github.com/example/user-service/internal/repo"
+	"github.com/example/user-service/internal/service"
+	"github.com/example/user-service/pkg/jsonschema"
+)
+
+type adminServiceStub struct {
+	lastFilter *repo.UserListFilter
+	err        error
+}
+
+func (s *adminServiceStub) ListUsers(ctx context.Context, filter repo.UserListFilter) (*repo.UserPage, error) {
+	s.lastFilter = &filter
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &repo.UserPage{Users: []domain.User{{ID: "user-1"}}, NextCursor: "next"}, nil
+}
+
+func (s *adminServiceStub) GetUser(ctx context.Context, userID string) (*domain.User, error) {
+	return &domain.User{ID: userID}, nil
+}
+
+func (s *adminServiceStub) Suspend(ctx context.Context, traceID, actorID, userID, reason string, until *time.Time) (*domain.User, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &domain.User{ID: userID, SuspensionReason: &reason, SuspendedUntil: until}, nil
+}
+
+func (s *adminServiceStub) Unsuspend(ctx context.Context, traceID, actorID, userID string) (*domain.User, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &domain.User{ID: userID, IsActive: true}, nil
+}
+
+func (s *adminServiceStub) LiftExpiredSuspensions(ctx context.Context, now time.Time) (int, error) {
+	return 0, nil
+}
+
+func (s *adminServiceStub) FindUser(ctx context.Context, ref string) (*domain.User, error) {
+	return &domain.User{ID: ref}, nil
+}
+
+func (s *adminServiceStub) Describe(ctx context.Context, userID string) (*service.UserDetails, error) {
+	return &service.UserDetails{User: &domain.User{ID: userID}}, nil
+}
+
+func (s *adminServiceStub) SetActive(ctx context.Context, traceID, actorID, userID string, active bool) (*domain.User, error) {
+	return &domain.User{ID: userID, IsActive: active}, s.err
+}
+
+func (s *adminServiceStub) ForcePasswordReset(ctx context.Context, traceID, actorID, userID string) (*domain.User, error) {
+	return &domain.User{ID: userID, PasswordResetRequired: true}, s.err
+}
+
+func (s *adminServiceStub) UnlinkIdentity(ctx context.Context, traceID, actorID, userID string, provider domain.IdentityProvider, providerUserID string) error {
+	return s.err
+}
+
+func (s *adminServiceStub) AssignRole(ctx context.Context, traceID, actorID, userID, role string) error {
+	return s.err
+}
+
+func (s *adminServiceStub) RevokeSessions(ctx context.Context, traceID, actorID, userID string) (*domain.User, error) {
+	return &domain.User{ID: userID}, s.err
+}
+
+func TestAdminHandlerListUsersParsesQuery(t *testing.T) {
+	e := echo.New()
+	stub := &adminServiceStub{}
+	handler := handlers.NewAdminHandler(stub, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/users?email_prefix=ali&is_active=true&provider=github&created_from=2024-01-01T00:00:00Z&sort=email&limit=10&cursor=abc", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	err := handler.ListUsers(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	require.NotNil(t, stub.lastFilter)
+	assert.Equal(t, "ali", stub.lastFilter.EmailPrefix)
+	require.NotNil(t, stub.lastFilter.IsActive)
+	assert.True(t, *stub.lastFilter.IsActive)
+	assert.Equal(t, "github", stub.lastFilter.Provider)
+	require.NotNil(t, stub.lastFilter.CreatedFrom)
+	assert.Equal(t, "email", stub.lastFilter.SortBy)
+	assert.False(t, stub.lastFilter.Descending)
+	assert.Equal(t, 10, stub.lastFilter.Limit)
+	assert.Equal(t, "abc", stub.lastFilter.Cursor)
+	assert.Contains(t, rec.Body.String(), `"next_cursor":"next"`)
+}
+
+func TestAdminHandlerListUsersBadRequest(t *testing.T) {
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/users?limit=abc", nil)
+	rec := httptest.NewRecorder()
+	err := handlers.NewAdminHandler(&adminServiceStub{}, nil).ListUsers(e.NewContext(req, rec))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/users?cursor=broken", nil)
+	rec = httptest.NewRecorder()
+	err = handlers.NewAdminHandler(&adminServiceStub{err: repo.ErrInvalidCursor}, nil).ListUsers(e.NewContext(req, rec))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/users?sort=password_hash", nil)
+	rec = httptest.NewRecorder()
+	err = handlers.NewAdminHandler(&adminServiceStub{err: service.ErrInvalidFilter}, nil).ListUsers(e.NewContext(req, rec))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestAdminHandlerSuspend(t *testing.T) {
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/users/user-1/suspend", bytes.NewReader([]byte(`{"reason":"spam","until":"2030-01-01T00:00:00Z"}`)))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("user-1")
+
+	err := handlers.NewAdminHandler(&adminServiceStub{}, nil).Suspend(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"suspension_reason":"spam"`)
+
+	req = httptest.NewRequest(http.MethodPost, "/admin/users/user-1/unsuspend", nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("user-1")
+
+	err = handlers.NewAdminHandler(&adminServiceStub{err: service.ErrUserNotSuspended}, nil).Unsuspend(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
+
+func (s *adminServiceStub) UpdateProfile(ctx context.Context, traceID, actorID, userID string, update service.ProfileUpdate) (*domain.UserProfile, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &domain.UserProfile{UserID: userID, FirstName: update.FirstName.Value}, nil
+}
+
+type profileSchemaServiceStub struct {
+	published []byte
+}
+
+func (s *profileSchemaServiceStub) Current(ctx context.Context) (*domain.ProfileSchema, error) {
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (s *profileSchemaServiceStub) Publish(ctx context.Context, traceID, actorID string, raw []byte) (*domain.ProfileSchema, error) {
+	if !bytes.Contains(raw, []byte(`"type"`)) {
+		return nil, service.ErrInvalidProfileSchema
+	}
+	s.published = raw
+	return &domain.ProfileSchema{Version: 1, CreatedBy: actorID}, nil
+}
+
+func (s *profileSchemaServiceStub) ValidateAttributes(ctx context.Context, attributes map[string]interface{}) ([]jsonschema.FieldError, error) {
+	return nil, nil
+}
+
+func TestAdminHandlerUpdateProfileReportsFieldErrors(t *testing.T) {
+	e := echo.New()
+	stub := &adminServiceStub{err: &service.ValidationError{Fields: []jsonschema.FieldError{
+		{Field: "timezone", Message: "must be an IANA time zone name"},
+		{Field: "attributes.department", Message: "is required"},
+	}}}
+
+	req := httptest.NewRequest(http.MethodPatch, "/admin/users/user-1/profile", bytes.NewReader([]byte(`{"timezone":"Mars/Base"}`)))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("user-1")
+
+	err := handlers.NewAdminHandler(stub, nil).UpdateProfile(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"code":"validation_failed"`)
+	assert.Contains(t, rec.Body.String(), `{"field":"attributes.department","message":"is required"}`)
+}
+
+func TestAdminHandlerProfileSchema(t *testing.T) {
+	e := echo.New()
+	schemas := &profileSchemaServiceStub{}
+	handler := handlers.NewAdminHandler(&adminServiceStub{}, schemas)
+
+	rec := httptest.NewRecorder()
+	err := handler.GetProfileSchema(e.NewContext(httptest.NewRequest(http.MethodGet, "/admin/profile-schema", nil), rec))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = httptest.NewRecorder()
+	err = handler.PublishProfileSchema(e.NewContext(httptest.NewRequest(http.MethodPut, "/admin/profile-schema", bytes.NewReader([]byte(`{"type":"object"}`))), rec))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.JSONEq(t, `{"type":"object"}`, string(schemas.published))
+
+	rec = httptest.NewRecorder()
+	err = handler.PublishProfileSchema(e.NewContext(httptest.NewRequest(http.MethodPut, "/admin/profile-schema", bytes.NewReader([]byte(`{}`))), rec))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -19,6 +19,8 @@ import (
 type authServiceStub struct {
 	lastProvider  string
 	lastOAuthInfo *service.OAuthUserInfo
+	oauthErr      error
+	signInErr     error
 }
 
 func (authServiceStub) StartSignup(ctx context.Context, traceID, email, password string) (string, error) {
@@ -29,16 +31,79 @@ func (authServiceStub) VerifySignup(ctx context.Context, traceID, uuid, code str
 	return &domain.User{ID: "user-1", Email: "user@example.com"}, &service.Tokens{AccessToken: "token", RefreshToken: "refresh"}, nil
 }
 
-func (authServiceStub) SignIn(ctx context.Context, traceID, email, password string) (*domain.User, *service.Tokens, error) {
+func (s *authServiceStub) SignIn(ctx context.Context, traceID, email, password string) (*domain.User, *service.Tokens, error) {
+	if s.signInErr != nil {
+		return nil, nil, s.signInErr
+	}
 	return &domain.User{ID: "user-1", Email: "user@example.com"}, &service.Tokens{AccessToken: "token"}, nil
 }
 
 func (s *authServiceStub) HandleOAuthCallback(ctx context.Context, traceID, provider string, info service.OAuthUserInfo) (*domain.User, *service.Tokens, error) {
 	s.lastProvider = provider
 	s.lastOAuthInfo = &info
+	if s.oauthErr != nil {
+		return nil, nil, s.oauthErr
+	}
+	return &domain.User{ID: "user-1", Email: "user@example.com"}, &service.Tokens{AccessToken: "token"}, nil
+}
+
+func (authServiceStub) ConfirmAccountLink(ctx context.Context, traceID, linkID, code, password string) (*domain.User, *service.Tokens, error) {
+	return &domain.User{ID: "user-1", Email: "user@example.com"}, &service.Tokens{AccessToken: "token"}, nil
+}
+
+func (authServiceStub) RestoreAccount(ctx context.Context, traceID, restoreToken string) (*domain.User, *service.Tokens, error) {
+	if restoreToken != "restore-token" {
+		return nil, nil, service.ErrRestoreTokenInvalid
+	}
+	return &domain.User{ID: "user-1", Email: "user@example.com", IsActive: true}, &service.Tokens{AccessToken: "token"}, nil
+}
+
+func (authServiceStub) AcceptInvitation(ctx context.Context, traceID, token, password string) (*domain.User, *service.Tokens, error) {
+	if token != "invite-token" {
+		return nil, nil, service.ErrInvitationInvalid
+	}
+	return &domain.User{ID: "user-2", Email: "invitee@example.com", IsActive: true}, &service.Tokens{AccessToken: "token"}, nil
+}
+
+func (authServiceStub) StartSMSSignIn(ctx context.Context, traceID, phone string) (string, error) {
+	if _, err := domain.NormalizePhone(phone); err != nil {
+		return "", err
+	}
+	return "uuid-sms", nil
+}
+
+func (authServiceStub) VerifySMSSignIn(ctx context.Context, traceID, codeID, code string) (*domain.User, *service.Tokens, error) {
+	return &domain.User{ID: "user-1", Email: "user@example.com"}, &service.Tokens{AccessToken: "token"}, nil
+}
+
+func (authServiceStub) VerifyMFA(ctx context.Context, traceID, challengeID, code string) (*domain.User, *service.Tokens, error) {
+	if challengeID != "challenge-1" || code != "1234" {
+		return nil, nil, service.ErrPhoneCodeInvalid
+	}
+	return &domain.User{ID: "user-1", Email: "user@example.com"}, &service.Tokens{AccessToken: "token"}, nil
+}
+
+func (authServiceStub) SignInWithRecoveryCode(ctx context.Context, traceID, challengeID, code string) (*domain.User, *service.Tokens, error) {
+	if challengeID != "challenge-1" {
+		return nil, nil, service.ErrPhoneCodeInvalid
+	}
+	if code != "abcde-23456" {
+		return nil, nil, service.ErrRecoveryCodeInvalid
+	}
 	return &domain.User{ID: "user-1", Email: "user@example.com"}, &service.Tokens{AccessToken: "token"}, nil
 }
 
+func (authServiceStub) RequestPasswordReset(ctx context.Context, traceID, email string) error {
+	return nil
+}
+
+func (authServiceStub) ResetPassword(ctx context.Context, traceID, token, password string) error {
+	if token != "valid" {
+		return service.ErrResetTokenInvalid
+	}
+	return nil
+}
+
 func TestAuthHandlerSignup(t *testing.T) {
 	e := echo.New()
 	handler := handlers.NewAuthHandler(&authServiceStub{})
@@ -101,3 +166,161 @@ func TestAuthHandlerOAuthCallback(t *testing.T) {
 	assert.Equal(t, "abc123", stub.lastOAuthInfo.ProviderUserID)
 	assert.Equal(t, "user@example.com", stub.lastOAuthInfo.Email)
 }
+
+func TestAuthHandlerOAuthCallback_LinkRequired(t *testing.T) {
+	e := echo.New()
+	stub := &authServiceStub{oauthErr: &service.AccountLinkRequiredError{LinkID: "link-1", Methods: []string{service.LinkMethodCode}}}
+	handler := handlers.NewAuthHandler(stub)
+
+	payload, _ := json.Marshal(map[string]interface{}{"provider_user_id": "abc123", "email": "user@example.com"})
+	req := httptest.NewRequest(http.MethodPost, "/auth/oauth/google/callback", bytes.NewReader(payload))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("provider")
+	c.SetParamValues("google")
+
+	err := handler.OAuthCallback(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	var body struct {
+		Error struct {
+			Code    string                 `json:"code"`
+			Details map[string]interface{} `json:"details"`
+		} `json:"error"`
+	}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "account_link_required", body.Error.Code)
+	assert.Equal(t, "link-1", body.Error.Details["link_id"])
+}
+
+func TestAuthHandlerSignIn_PendingDeletion(t *testing.T) {
+	e := echo.New()
+	handler := handlers.NewAuthHandler(&authServiceStub{signInErr: &service.AccountPendingDeletionError{RestoreToken: "restore-token"}})
+
+	reqBody, _ := json.Marshal(map[string]string{"email": "user@example.com", "password": "password123"})
+	req := httptest.NewRequest(http.MethodPost, "/auth/signin", bytes.NewReader(reqBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+
+	err := handler.SignIn(e.NewContext(req, rec))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"restore_token":"restore-token"`)
+
+	reqBody, _ = json.Marshal(map[string]string{"restore_token": "restore-token"})
+	req = httptest.NewRequest(http.MethodPost, "/auth/restore", bytes.NewReader(reqBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec = httptest.NewRecorder()
+
+	err = handler.RestoreAccount(e.NewContext(req, rec))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	reqBody, _ = json.Marshal(map[string]string{"restore_token": "stale"})
+	req = httptest.NewRequest(http.MethodPost, "/auth/restore", bytes.NewReader(reqBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec = httptest.NewRecorder()
+
+	err = handler.RestoreAccount(e.NewContext(req, rec))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestAuthHandlerAcceptInvitation(t *testing.T) {
+	e := echo.New()
+	handler := handlers.NewAuthHandler(&authServiceStub{})
+
+	for token, status := range map[string]int{"invite-token": http.StatusCreated, "revoked": http.StatusGone} {
+		reqBody, _ := json.Marshal(map[string]string{"token": token, "password": "password123"})
+		req := httptest.NewRequest(http.MethodPost, "/auth/invitations/accept", bytes.NewReader(reqBody))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+
+		assert.NoError(t, handler.AcceptInvitation(e.NewContext(req, rec)))
+		assert.Equal(t, status, rec.Code, token)
+	}
+}
+
+func TestAuthHandlerSignIn_MFARequired(t *testing.T) {
+	e := echo.New()
+	handler := handlers.NewAuthHandler(&authServiceStub{signInErr: &service.MFARequiredError{ChallengeID: "challenge-1", Methods: []string{"sms"}}})
+
+	reqBody, _ := json.Marshal(map[string]string{"email": "user@example.com", "password": "password123"})
+	req := httptest.NewRequest(http.MethodPost, "/auth/signin", bytes.NewReader(reqBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+
+	err := handler.SignIn(e.NewContext(req, rec))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"mfa_required"`)
+	assert.Contains(t, rec.Body.String(), `"challenge_id":"challenge-1"`)
+
+	for code, status := range map[string]int{"0000": http.StatusUnauthorized, "1234": http.StatusOK} {
+		reqBody, _ = json.Marshal(map[string]string{"challenge_id": "challenge-1", "code": code})
+		req = httptest.NewRequest(http.MethodPost, "/auth/mfa/verify", bytes.NewReader(reqBody))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec = httptest.NewRecorder()
+
+		err = handler.VerifyMFA(e.NewContext(req, rec))
+		assert.NoError(t, err)
+		assert.Equal(t, status, rec.Code, code)
+	}
+}
+
+func TestAuthHandlerStartSMSSignIn_InvalidPhone(t *testing.T) {
+	e := echo.New()
+	handler := handlers.NewAuthHandler(&authServiceStub{})
+
+	reqBody, _ := json.Marshal(map[string]string{"phone": "0151 1234"})
+	req := httptest.NewRequest(http.MethodPost, "/auth/sms/start", bytes.NewReader(reqBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+
+	err := handler.StartSMSSignIn(e.NewContext(req, rec))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"invalid_phone"`)
+}
+
+func TestAuthHandlerSignInWithRecoveryCode(t *testing.T) {
+	e := echo.New()
+	handler := handlers.NewAuthHandler(&authServiceStub{})
+	cases := []struct {
+		challengeID, code string
+		status            int
+		errCode           string
+	}{
+		{"challenge-1", "abcde-23456", http.StatusOK, ""},
+		{"challenge-1", "used0-00000", http.StatusUnauthorized, "invalid_recovery_code"},
+		{"challenge-2", "abcde-23456", http.StatusUnauthorized, "signin_failed"},
+	}
+	for _, tc := range cases {
+		reqBody, _ := json.Marshal(map[string]string{"challenge_id": tc.challengeID, "recovery_code": tc.code})
+		req := httptest.NewRequest(http.MethodPost, "/auth/recovery", bytes.NewReader(reqBody))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+
+		assert.NoError(t, handler.SignInWithRecoveryCode(e.NewContext(req, rec)))
+		assert.Equal(t, tc.status, rec.Code, tc.code)
+		if tc.errCode != "" {
+			assert.Contains(t, rec.Body.String(), `"`+tc.errCode+`"`, tc.code)
+		}
+	}
+}
+
+func TestAuthHandlerResetPassword(t *testing.T) {
+	e := echo.New()
+	handler := handlers.NewAuthHandler(&authServiceStub{})
+	for token, status := range map[string]int{"valid": http.StatusNoContent, "stale": http.StatusUnauthorized} {
+		reqBody, _ := json.Marshal(map[string]string{"token": token, "password": "newpassword1"})
+		req := httptest.NewRequest(http.MethodPost, "/auth/password/reset", bytes.NewReader(reqBody))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+
+		assert.NoError(t, handler.ResetPassword(e.NewContext(req, rec)))
+		assert.Equal(t, status, rec.Code, token)
+	}
+}
@@ -0,0 +1,75 @@
+package integration
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/ports/http/handlers"
+	"github.com/example/user-service/internal/service"
+)
+
+type exportServiceStub struct{}
+
+func (exportServiceStub) RequestExport(ctx context.Context, traceID, actorID, userID string) (*domain.DataExport, error) {
+	return &domain.DataExport{ID: "export-1", UserID: userID, RequestedBy: actorID, Status: domain.ExportPending}, nil
+}
+
+func (exportServiceStub) GetExport(ctx context.Context, exportID string) (*domain.DataExport, error) {
+	return &domain.DataExport{ID: exportID, UserID: "user-1", Status: domain.ExportReady}, nil
+}
+
+func (exportServiceStub) DownloadURL(export *domain.DataExport) string {
+	return "https://users.example.com/exports/download?token=t"
+}
+
+func (exportServiceStub) Download(ctx context.Context, traceID, token string) (*domain.DataExport, error) {
+	if token != "t" {
+		return nil, service.ErrExportLinkInvalid
+	}
+	return &domain.DataExport{ID: "export-1", Archive: []byte("PK")}, nil
+}
+
+func (exportServiceStub) ProcessPending(ctx context.Context) (int, error) { return 0, nil }
+
+func (exportServiceStub) PurgeExpired(ctx context.Context, now time.Time) (int64, error) {
+	return 0, nil
+}
+
+func TestExportHandlerGetOwnHidesOtherUsers(t *testing.T) {
+	e := echo.New()
+	handler := handlers.NewExportHandler(exportServiceStub{})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/me/export/export-1", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("user_id", "user-2")
+	c.SetParamNames("export_id")
+	c.SetParamValues("export-1")
+
+	assert.NoError(t, handler.GetOwn(c))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestExportHandlerDownload(t *testing.T) {
+	e := echo.New()
+	handler := handlers.NewExportHandler(exportServiceStub{})
+
+	req := httptest.NewRequest(http.MethodGet, "/exports/download?token=t", nil)
+	rec := httptest.NewRecorder()
+	assert.NoError(t, handler.Download(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/zip", rec.Header().Get(echo.HeaderContentType))
+	assert.Contains(t, rec.Header().Get(echo.HeaderContentDisposition), "user-data-export-1.zip")
+
+	req = httptest.NewRequest(http.MethodGet, "/exports/download?token=expired", nil)
+	rec = httptest.NewRecorder()
+	assert.NoError(t, handler.Download(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusGone, rec.Code)
+}
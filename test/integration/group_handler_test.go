@@ -0,0 +1,134 @@
+package integration
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/ports/http/handlers"
+	"github.com/example/user-service/internal/repo"
+	"github.com/example/user-service/internal/service"
+)
+
+type groupServiceStub struct {
+	lastUserID string
+	effective  bool
+}
+
+func (s *groupServiceStub) Create(ctx context.Context, traceID, actorID, name string, description *string) (*domain.Group, error) {
+	if strings.TrimSpace(name) == "" {
+		return nil, domain.ErrGroupNameInvalid
+	}
+	return &domain.Group{ID: "group-1", Name: name, CreatedBy: actorID}, nil
+}
+
+func (s *groupServiceStub) Get(ctx context.Context, groupID string) (*domain.Group, error) {
+	return nil, service.ErrGroupNotFound
+}
+
+func (s *groupServiceStub) Update(ctx context.Context, traceID, actorID, groupID string, name, description *string) (*domain.Group, error) {
+	return nil, service.ErrGroupForbidden
+}
+
+func (s *groupServiceStub) Delete(ctx context.Context, traceID, actorID, groupID string) error {
+	return nil
+}
+
+func (s *groupServiceStub) AddMember(ctx context.Context, traceID, actorID, groupID, userID string, role domain.GroupRole) (*domain.GroupMember, error) {
+	return &domain.GroupMember{GroupID: groupID, UserID: userID, Role: role}, nil
+}
+
+func (s *groupServiceStub) RemoveMember(ctx context.Context, traceID, actorID, groupID, userID string) error {
+	return service.ErrGroupLastOwner
+}
+
+func (s *groupServiceStub) AddSubgroup(ctx context.Context, traceID, actorID, parentID, childID string) error {
+	return service.ErrGroupCycle
+}
+
+func (s *groupServiceStub) RemoveSubgroup(ctx context.Context, traceID, actorID, parentID, childID string) error {
+	return nil
+}
+
+func (s *groupServiceStub) ListSubgroups(ctx context.Context, groupID string) ([]domain.Group, error) {
+	return nil, nil
+}
+
+func (s *groupServiceStub) ListMembers(ctx context.Context, actorID, groupID string, effective bool, offset, limit int) ([]repo.GroupMemberEntry, error) {
+	return nil, nil
+}
+
+func (s *groupServiceStub) ListUserGroups(ctx context.Context, userID string, effective bool, offset, limit int) ([]domain.Group, error) {
+	s.lastUserID, s.effective = userID, effective
+	return []domain.Group{{ID: "group-1", Name: "Support"}}, nil
+}
+
+func (s *groupServiceStub) GroupIDs(ctx context.Context, userID string) ([]string, error) {
+	return nil, nil
+}
+
+func TestGroupHandlerCreate(t *testing.T) {
+	e := echo.New()
+	handler := handlers.NewGroupHandler(&groupServiceStub{})
+
+	req := httptest.NewRequest(http.MethodPost, "/groups", strings.NewReader(`{"name":"Support"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("user_id", "user-1")
+	assert.NoError(t, handler.Create(c))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"created_by":"user-1"`)
+
+	req = httptest.NewRequest(http.MethodPost, "/groups", strings.NewReader(`{}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec = httptest.NewRecorder()
+	assert.NoError(t, handler.Create(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestGroupHandlerErrors(t *testing.T) {
+	e := echo.New()
+	handler := handlers.NewGroupHandler(&groupServiceStub{})
+	cases := []struct {
+		name   string
+		call   func(echo.Context) error
+		status int
+	}{
+		{"get", handler.Get, http.StatusNotFound},
+		{"update", handler.Update, http.StatusForbidden},
+		{"remove member", handler.RemoveMember, http.StatusConflict},
+		{"add subgroup", handler.AddSubgroup, http.StatusConflict},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodPatch, "/groups/group-1", strings.NewReader(`{}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.Set("user_id", "user-1")
+		assert.NoError(t, tc.call(c), tc.name)
+		assert.Equal(t, tc.status, rec.Code, tc.name)
+	}
+}
+
+func TestGroupHandlerListOwn(t *testing.T) {
+	e := echo.New()
+	groups := &groupServiceStub{}
+	handler := handlers.NewGroupHandler(groups)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/me/groups?effective=true", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("user_id", "user-7")
+	assert.NoError(t, handler.ListOwn(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "user-7", groups.lastUserID)
+	assert.True(t, groups.effective)
+	assert.Contains(t, rec.Body.String(), `"groups":[`)
+}
@@ -0,0 +1,39 @@
+package integration
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/example/user-service/config"
+	authmw "github.com/example/user-service/internal/ports/http/middleware"
+)
+
+func TestInternalMiddleware(t *testing.T) {
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusNoContent) }
+	cases := []struct {
+		name   string
+		tokens []string
+		header string
+		status int
+	}{
+		{"accepted", []string{"old", "current"}, "current", http.StatusNoContent},
+		{"missing", []string{"current"}, "", http.StatusUnauthorized},
+		{"wrong", []string{"current"}, "guess", http.StatusUnauthorized},
+		{"disabled", nil, "anything", http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		e := echo.New()
+		e.POST("/internal/preferences/batch", ok, authmw.NewInternalMiddleware(&config.Config{InternalAPITokens: tc.tokens}).Handler)
+		req := httptest.NewRequest(http.MethodPost, "/internal/preferences/batch", nil)
+		if tc.header != "" {
+			req.Header.Set(authmw.InternalHeader, tc.header)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, tc.status, rec.Code, tc.name)
+	}
+}
@@ -0,0 +1,46 @@
+package integration
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/example/user-service/config"
+	authmw "github.com/example/user-service/internal/ports/http/middleware"
+	"github.com/example/user-service/internal/service"
+	pkglog "github.com/example/user-service/pkg/log"
+)
+
+func TestRequireRecentSignInRejectsOldTokens(t *testing.T) {
+	cfg := &config.Config{JWTSecret: "secret", ReauthMaxAge: 5 * time.Minute}
+	signer, err := service.NewJWTSigner(cfg)
+	require.NoError(t, err)
+	fresh, err := signer.SignAccessToken("user-1", map[string]interface{}{}, time.Hour)
+	require.NoError(t, err)
+	stale, err := signer.SignAccessToken("user-1", map[string]interface{}{"iat": time.Now().Add(-10 * time.Minute).Unix()}, time.Hour)
+	require.NoError(t, err)
+
+	e := echo.New()
+	authMW := authmw.NewAuthMiddleware(cfg, pkglog.New("test"), nil, nil)
+	e.PUT("/me/phone/mfa", func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	}, authMW.Handler, authMW.RequireRecentSignIn)
+
+	request := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/me/phone/mfa", nil)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusNoContent, request(fresh).Code)
+	rec := request(stale)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "reauthentication_required")
+}
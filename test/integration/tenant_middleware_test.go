@@ -0,0 +1,107 @@
+package integration
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/example/user-service/config"
+	"github.com/example/user-service/internal/domain"
+	authmw "github.com/example/user-service/internal/ports/http/middleware"
+	"github.com/example/user-service/internal/service"
+	"github.com/example/user-service/internal/tenant"
+	pkglog "github.com/example/user-service/pkg/log"
+)
+
+type tenantResolverStub struct{}
+
+func (tenantResolverStub) Resolve(ctx context.Context, ref string) (*domain.Organization, error) {
+	if ref == "acme" || ref == "org-acme" {
+		return &domain.Organization{ID: "org-acme", Slug: "acme"}, nil
+	}
+	return nil, service.ErrOrgNotFound
+}
+
+func tenantEcho(cfg *config.Config) (*echo.Echo, *string) {
+	e := echo.New()
+	seen := new(string)
+	tenantMW := authmw.NewTenantMiddleware(cfg, tenantResolverStub{})
+	authMW := authmw.NewAuthMiddleware(cfg, pkglog.New("test"), nil, nil)
+	record := func(c echo.Context) error {
+		orgID, scoped := tenant.From(c.Request().Context())
+		if !scoped {
+			orgID = "unscoped"
+		}
+		*seen = orgID
+		return c.NoContent(http.StatusNoContent)
+	}
+	e.GET("/public", record, tenantMW.Handler)
+	e.GET("/private", record, tenantMW.Handler, authMW.Handler)
+	return e, seen
+}
+
+func TestTenantMiddlewareResolvesHeaderAndHost(t *testing.T) {
+	cfg := &config.Config{TenantBaseDomain: "users.example.com"}
+	e, seen := tenantEcho(cfg)
+
+	cases := []struct {
+		host, header string
+		status       int
+		tenant       string
+	}{
+		{host: "users.example.com", status: http.StatusNoContent, tenant: tenant.Default},
+		{host: "acme.users.example.com:8443", status: http.StatusNoContent, tenant: "org-acme"},
+		{host: "users.example.com", header: "acme", status: http.StatusNoContent, tenant: "org-acme"},
+		{host: "deep.acme.users.example.com", status: http.StatusNoContent, tenant: tenant.Default},
+		{host: "globex.users.example.com", status: http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		*seen = "none"
+		req := httptest.NewRequest(http.MethodGet, "/public", nil)
+		req.Host = tc.host
+		if tc.header != "" {
+			req.Header.Set(authmw.TenantHeader, tc.header)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, tc.status, rec.Code, tc.host)
+		if tc.status == http.StatusNoContent {
+			assert.Equal(t, tc.tenant, *seen, tc.host)
+		}
+	}
+}
+
+func TestAuthMiddlewareScopesToTokenTenant(t *testing.T) {
+	cfg := &config.Config{JWTSecret: "secret"}
+	signer, err := service.NewJWTSigner(cfg)
+	require.NoError(t, err)
+	acmeToken, err := signer.SignAccessToken("user-1", map[string]interface{}{"org_id": "org-acme"}, time.Minute)
+	require.NoError(t, err)
+	defaultToken, err := signer.SignAccessToken("user-2", map[string]interface{}{}, time.Minute)
+	require.NoError(t, err)
+	e, seen := tenantEcho(cfg)
+
+	request := func(token, header string) int {
+		req := httptest.NewRequest(http.MethodGet, "/private", nil)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		if header != "" {
+			req.Header.Set(authmw.TenantHeader, header)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusNoContent, request(acmeToken, ""))
+	assert.Equal(t, "org-acme", *seen)
+	assert.Equal(t, http.StatusNoContent, request(acmeToken, "acme"))
+	assert.Equal(t, http.StatusForbidden, request(defaultToken, "acme"))
+	assert.Equal(t, http.StatusNoContent, request(defaultToken, ""))
+	assert.Equal(t, tenant.Default, *seen)
+}
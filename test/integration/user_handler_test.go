@@ -0,0 +1,254 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/ports/http/handlers"
+	"github.com/example/user-service/internal/repo"
+	"github.com/example/user-service/internal/service"
+)
+
+type userServiceStub struct {
+	user       *domain.User
+	lastUpdate *service.ProfileUpdate
+	uploads    int
+	changeErr  error
+	identities []domain.UserIdentity
+}
+
+func newUserServiceStub() *userServiceStub {
+	return &userServiceStub{user: &domain.User{ID: "user-1", Email: "user@example.com", Version: 3, Profile: &domain.UserProfile{UserID: "user-1", Version: 7}}}
+}
+
+func (s *userServiceStub) GetMe(ctx context.Context, userID string) (*domain.User, error) {
+	return s.user, nil
+}
+
+func (s *userServiceStub) GetByID(ctx context.Context, requesterID, targetID string) (*service.UserView, error) {
+	return &service.UserView{Public: s.user.Public(), Revision: "public." + s.user.Revision()}, nil
+}
+
+func (s *userServiceStub) GetByUsername(ctx context.Context, requesterID, name string) (*service.UserView, error) {
+	return s.GetByID(ctx, requesterID, name)
+}
+
+func (s *userServiceStub) ChangeUsername(ctx context.Context, userID, username string) (*domain.User, error) {
+	if s.changeErr != nil {
+		return nil, s.changeErr
+	}
+	return s.user, nil
+}
+
+func (s *userServiceStub) UpdateProfile(ctx context.Context, userID string, update service.ProfileUpdate) (*domain.UserProfile, error) {
+	s.lastUpdate = &update
+	if len(update.Revisions) > 0 && !slices.Contains(update.Revisions, s.user.Revision()) {
+		return nil, service.ErrRevisionMismatch
+	}
+	return s.user.Profile, nil
+}
+
+func (s *userServiceStub) UploadAvatar(ctx context.Context, userID string, data []byte) (*domain.UserProfile, error) {
+	s.uploads++
+	return s.user.Profile, nil
+}
+
+func (s *userServiceStub) StartEmailChange(ctx context.Context, userID, newEmail string) (string, error) {
+	return "uuid", nil
+}
+
+func (s *userServiceStub) VerifyEmailChange(ctx context.Context, userID, uuid, code string) (*domain.User, error) {
+	return s.user, nil
+}
+
+func (s *userServiceStub) AttachIdentity(ctx context.Context, userID string, provider domain.IdentityProvider, providerUserID, email string, displayName, avatarURL *string) (*domain.UserIdentity, *domain.UserProfile, error) {
+	return nil, nil, nil
+}
+
+func (s *userServiceStub) ListIdentities(ctx context.Context, userID string) ([]domain.UserIdentity, error) {
+	return s.identities, nil
+}
+
+func (s *userServiceStub) RemoveIdentity(ctx context.Context, userID string, provider domain.IdentityProvider, providerUserID string) error {
+	return nil
+}
+
+func TestUserHandlerGetMeConditional(t *testing.T) {
+	e := echo.New()
+	handler := handlers.NewUserHandler(newUserServiceStub(), nil, 0)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/users/me", nil), rec)
+	c.Set("user_id", "user-1")
+	assert.NoError(t, handler.GetMe(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"3.7"`, rec.Header().Get("ETag"))
+
+	for _, header := range []string{`"3.7"`, `W/"3.7"`, `"1.1", "3.7"`, `*`} {
+		req := httptest.NewRequest(http.MethodGet, "/users/me", nil)
+		req.Header.Set("If-None-Match", header)
+		rec = httptest.NewRecorder()
+		c = e.NewContext(req, rec)
+		c.Set("user_id", "user-1")
+		assert.NoError(t, handler.GetMe(c))
+		assert.Equal(t, http.StatusNotModified, rec.Code, header)
+		assert.Empty(t, rec.Body.String(), header)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/users/me", nil)
+	req.Header.Set("If-None-Match", `"3.6"`)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.Set("user_id", "user-1")
+	assert.NoError(t, handler.GetMe(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestUserHandlerGetByIDETagDiffersFromOwnView(t *testing.T) {
+	e := echo.New()
+	handler := handlers.NewUserHandler(newUserServiceStub(), nil, 0)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/user-1", nil)
+	req.Header.Set("If-None-Match", `"3.7"`)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("user_id", "user-2")
+	c.SetParamNames("id")
+	c.SetParamValues("user-1")
+	assert.NoError(t, handler.GetByID(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"public.3.7"`, rec.Header().Get("ETag"))
+}
+
+func TestUserHandlerUpdateProfileIfMatch(t *testing.T) {
+	e := echo.New()
+	cases := []struct {
+		name      string
+		ifMatch   string
+		status    int
+		revisions []string
+	}{
+		{"absent", "", http.StatusOK, nil},
+		{"any", "*", http.StatusOK, nil},
+		{"current", `"3.7"`, http.StatusOK, []string{"3.7"}},
+		{"stale", `"3.6"`, http.StatusPreconditionFailed, []string{"3.6"}},
+		{"weak", `W/"3.7"`, http.StatusPreconditionFailed, nil},
+	}
+	for _, tc := range cases {
+		users := newUserServiceStub()
+		handler := handlers.NewUserHandler(users, nil, 0)
+		req := httptest.NewRequest(http.MethodPatch, "/users/me", strings.NewReader(`{"display_name":"Ada"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if tc.ifMatch != "" {
+			req.Header.Set("If-Match", tc.ifMatch)
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.Set("user_id", "user-1")
+		assert.NoError(t, handler.UpdateProfile(c), tc.name)
+		assert.Equal(t, tc.status, rec.Code, tc.name)
+		if tc.status == http.StatusPreconditionFailed {
+			assert.Contains(t, rec.Body.String(), `"precondition_failed"`, tc.name)
+		}
+		if users.lastUpdate != nil {
+			assert.Equal(t, tc.revisions, users.lastUpdate.Revisions, tc.name)
+		}
+	}
+}
+
+func TestUserHandlerUpdateProfileMergePatch(t *testing.T) {
+	e := echo.New()
+	for _, contentType := range []string{echo.MIMEApplicationJSON, "application/merge-patch+json; charset=utf-8"} {
+		users := newUserServiceStub()
+		handler := handlers.NewUserHandler(users, nil, 0)
+		req := httptest.NewRequest(http.MethodPatch, "/users/me", strings.NewReader(`{"display_name":null,"bio":"Hi","visibility":{"email":null}}`))
+		req.Header.Set(echo.HeaderContentType, contentType)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.Set("user_id", "user-1")
+		assert.NoError(t, handler.UpdateProfile(c), contentType)
+		assert.Equal(t, http.StatusOK, rec.Code, contentType)
+
+		update := users.lastUpdate
+		if !assert.NotNil(t, update, contentType) {
+			continue
+		}
+		assert.True(t, update.DisplayName.IsNull(), contentType)
+		assert.Equal(t, "Hi", *update.Bio.Value, contentType)
+		assert.False(t, update.AvatarURL.Present, contentType)
+		assert.Contains(t, *update.Visibility.Value, "email", contentType)
+		assert.Nil(t, (*update.Visibility.Value)["email"], contentType)
+	}
+}
+
+func TestUserHandlerUploadAvatarRejectsOversizedBody(t *testing.T) {
+	e := echo.New()
+	users := newUserServiceStub()
+	handler := handlers.NewUserHandler(users, nil, 1024)
+
+	upload := func(size int) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, err := form.CreateFormFile("file", "avatar.png")
+		assert.NoError(t, err)
+		_, _ = part.Write(bytes.Repeat([]byte{0x89}, size))
+		assert.NoError(t, form.Close())
+		req := httptest.NewRequest(http.MethodPut, "/users/me/avatar", &body)
+		req.Header.Set(echo.HeaderContentType, form.FormDataContentType())
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.Set("user_id", "user-1")
+		assert.NoError(t, handler.UploadAvatar(c))
+		return rec
+	}
+
+	rec := upload(1 << 20)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	assert.Contains(t, rec.Body.String(), "avatar_too_large")
+	assert.Zero(t, users.uploads)
+
+	rec = upload(512)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 1, users.uploads)
+}
+
+func TestUserHandlerChangeUsernameVersionConflict(t *testing.T) {
+	e := echo.New()
+	users := newUserServiceStub()
+	users.changeErr = fmt.Errorf("update user: %w", repo.ErrVersionConflict)
+	handler := handlers.NewUserHandler(users, nil, 0)
+
+	req := httptest.NewRequest(http.MethodPut, "/users/me/username", strings.NewReader(`{"username":"ada"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("user_id", "user-1")
+	assert.NoError(t, handler.ChangeUsername(c))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"conflict"`)
+}
+
+func TestUserHandlerListIdentitiesNamesTheProviderAccount(t *testing.T) {
+	e := echo.New()
+	users := newUserServiceStub()
+	users.identities = []domain.UserIdentity{{ID: "identity-1", UserID: "user-1", Provider: domain.ProviderGitHub, ProviderUserID: "gh-42", Email: "user@example.com"}}
+	handler := handlers.NewUserHandler(users, nil, 0)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/users/me/identities", nil), rec)
+	c.Set("user_id", "user-1")
+	assert.NoError(t, handler.ListIdentities(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"provider_user_id":"gh-42"`)
+}
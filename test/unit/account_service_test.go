@@ -0,0 +1,89 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/example/user-service/config"
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/service"
+	pkglog "github.com/example/user-service/pkg/log"
+)
+
+func TestAccountService_RequestDeletion(t *testing.T) {
+	users := newUserRepoStub()
+	cfg := &config.Config{AccountDeletionGrace: 48 * time.Hour}
+	svc := service.NewAccountService(cfg, pkglog.New("test"), users, nil, nil, newFakeRBACClient(), fakePublisher{})
+
+	before := time.Now().UTC()
+	user, err := svc.RequestDeletion(context.Background(), "trace", "user-1")
+	require.NoError(t, err)
+	assert.False(t, user.IsActive)
+	require.NotNil(t, user.PurgeAt)
+	assert.WithinDuration(t, before.Add(48*time.Hour), *user.PurgeAt, time.Second)
+	assert.True(t, user.TokenRevoked(before.Truncate(time.Second)))
+
+	again, err := svc.RequestDeletion(context.Background(), "trace", "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, user.PurgeAt, again.PurgeAt)
+}
+
+func TestAccountService_PurgeExpired(t *testing.T) {
+	users := newUserRepoStub()
+	users.history = &fakeUsernameHistory{}
+	users.users["user-1"].SetUsername("ada", time.Now().UTC())
+	storage := &fileStorageStub{}
+	rbacClient := newFakeRBACClient()
+	rbacClient.assignments["user-1"] = "user"
+	publisher := &recordingPublisher{}
+	cfg := &config.Config{AccountDeletionGrace: time.Hour, UsernameReleaseCooldown: 24 * time.Hour}
+	svc := service.NewAccountService(cfg, pkglog.New("test"), users, nil, newTestAvatarStore(storage), rbacClient, publisher)
+
+	avatar := "https://files.example.com/user-1-original.png"
+	users.users["user-1"].Profile = &domain.UserProfile{
+		UserID:           "user-1",
+		AvatarURL:        &avatar,
+		AvatarThumbnails: domain.JSONMap{"64": "https://files.example.com/user-1-64.png", "128": "https://cdn.other.com/x.png"},
+	}
+	_, err := svc.RequestDeletion(context.Background(), "trace", "user-1")
+	require.NoError(t, err)
+
+	purged, err := svc.PurgeExpired(context.Background(), time.Now().UTC())
+	require.NoError(t, err)
+	assert.Equal(t, 0, purged)
+
+	purged, err = svc.PurgeExpired(context.Background(), time.Now().UTC().Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	assert.NotContains(t, users.users, "user-1")
+	assert.NotContains(t, rbacClient.assignments, "user-1")
+	assert.ElementsMatch(t, []string{avatar, "https://files.example.com/user-1-64.png"}, storage.deleted)
+	assert.Equal(t, []string{"user.deleted"}, publisher.keys)
+	require.Len(t, users.history.holds, 1)
+	assert.Equal(t, "ada", users.history.holds[0].UsernameCanonical)
+	assert.Equal(t, "user-1", users.history.holds[0].UserID)
+}
+
+func TestAccountService_PurgeExpiredSkipsFailures(t *testing.T) {
+	users := newUserRepoStub()
+	users.users["user-2"] = &domain.User{ID: "user-2", Email: "other@example.com"}
+	users.users["user-3"] = &domain.User{ID: "user-3", Email: "third@example.com"}
+	users.deleteErrs = map[string]error{"user-2": errors.New("database unavailable")}
+	cfg := &config.Config{AccountDeletionGrace: time.Hour}
+	svc := service.NewAccountService(cfg, pkglog.New("test"), users, nil, nil, nil, nil)
+	for _, id := range []string{"user-1", "user-2", "user-3"} {
+		_, err := svc.RequestDeletion(context.Background(), "trace", id)
+		require.NoError(t, err)
+	}
+
+	purged, err := svc.PurgeExpired(context.Background(), time.Now().UTC().Add(2*time.Hour))
+	assert.Error(t, err)
+	assert.Equal(t, 2, purged, "the failed account does not stop the others")
+	assert.Len(t, users.users, 1)
+	assert.Contains(t, users.users, "user-2")
+}
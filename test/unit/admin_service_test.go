@@ -0,0 +1,183 @@
+package unit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/repo"
+	"github.com/example/user-service/internal/service"
+	pkglog "github.com/example/user-service/pkg/log"
+)
+
+type recordingPublisher struct {
+	keys     []string
+	payloads []interface{}
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, routingKey string, payload interface{}) error {
+	p.keys = append(p.keys, routingKey)
+	p.payloads = append(p.payloads, payload)
+	return nil
+}
+func (p *recordingPublisher) Close() error { return nil }
+
+func TestAdminService_ListUsersDefaults(t *testing.T) {
+	users := newUserRepoStub()
+	svc := service.NewAdminService(pkglog.New("test"), users, nil, nil, nil, fakePublisher{})
+
+	_, err := svc.ListUsers(context.Background(), repo.UserListFilter{Limit: 1000})
+	require.NoError(t, err)
+	require.NotNil(t, users.lastFilter)
+	assert.Equal(t, repo.UserSortCreatedAt, users.lastFilter.SortBy)
+	assert.Equal(t, 200, users.lastFilter.Limit)
+
+	_, err = svc.ListUsers(context.Background(), repo.UserListFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 50, users.lastFilter.Limit)
+}
+
+func TestAdminService_ListUsersRejectsInvalidFilter(t *testing.T) {
+	svc := service.NewAdminService(pkglog.New("test"), newUserRepoStub(), nil, nil, nil, fakePublisher{})
+	now := time.Now()
+
+	_, err := svc.ListUsers(context.Background(), repo.UserListFilter{SortBy: "password_hash"})
+	assert.ErrorIs(t, err, service.ErrInvalidFilter)
+
+	_, err = svc.ListUsers(context.Background(), repo.UserListFilter{Provider: "myspace"})
+	assert.ErrorIs(t, err, service.ErrUnsupportedProvider)
+
+	_, err = svc.ListUsers(context.Background(), repo.UserListFilter{CreatedFrom: &now, CreatedTo: &now})
+	assert.ErrorIs(t, err, service.ErrInvalidFilter)
+}
+
+func TestAdminService_SuspendAndUnsuspend(t *testing.T) {
+	users := newUserRepoStub()
+	publisher := &recordingPublisher{}
+	svc := service.NewAdminService(pkglog.New("test"), users, nil, nil, nil, publisher)
+
+	_, err := svc.Suspend(context.Background(), "trace", "admin-1", "user-1", " ", nil)
+	assert.ErrorIs(t, err, service.ErrSuspensionReasonRequired)
+
+	past := time.Now().Add(-time.Hour)
+	_, err = svc.Suspend(context.Background(), "trace", "admin-1", "user-1", "spam", &past)
+	assert.ErrorIs(t, err, service.ErrInvalidSuspensionExpiry)
+
+	user, err := svc.Suspend(context.Background(), "trace", "admin-1", "user-1", "spam", nil)
+	require.NoError(t, err)
+	assert.False(t, user.IsActive)
+	assert.True(t, user.IsSuspended())
+	require.NotNil(t, user.TokensRevokedAt)
+	assert.True(t, user.TokenRevoked(time.Now().Add(-time.Minute)))
+
+	validator := service.NewSessionValidator(users)
+	assert.ErrorIs(t, validator.ValidateSession(context.Background(), "user-1", time.Now().Add(time.Minute)), service.ErrUserInactive)
+
+	user, err = svc.Unsuspend(context.Background(), "trace", "admin-1", "user-1")
+	require.NoError(t, err)
+	assert.True(t, user.IsActive)
+	assert.False(t, user.IsSuspended())
+	assert.ErrorIs(t, validator.ValidateSession(context.Background(), "user-1", time.Now().Add(-time.Minute)), service.ErrSessionRevoked)
+	assert.NoError(t, validator.ValidateSession(context.Background(), "user-1", time.Now().Add(time.Minute)))
+
+	_, err = svc.Unsuspend(context.Background(), "trace", "admin-1", "user-1")
+	assert.ErrorIs(t, err, service.ErrUserNotSuspended)
+	assert.Equal(t, []string{"user.suspended", "user.reactivated"}, publisher.keys)
+}
+
+func TestAdminService_LiftExpiredSuspensions(t *testing.T) {
+	users := newUserRepoStub()
+	svc := service.NewAdminService(pkglog.New("test"), users, nil, nil, nil, fakePublisher{})
+
+	until := time.Now().Add(time.Hour)
+	_, err := svc.Suspend(context.Background(), "trace", "admin-1", "user-1", "cooldown", &until)
+	require.NoError(t, err)
+
+	lifted, err := svc.LiftExpiredSuspensions(context.Background(), time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 0, lifted)
+
+	lifted, err = svc.LiftExpiredSuspensions(context.Background(), until.Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, 1, lifted)
+	assert.True(t, users.users["user-1"].IsActive)
+}
+
+func TestAdminService_LiftExpiredSuspensionsSkipsFailures(t *testing.T) {
+	users := newUserRepoStub()
+	expired := time.Now().Add(-time.Minute)
+	for _, id := range []string{"user-1", "user-2", "user-3"} {
+		user := &domain.User{ID: id, Email: id + "@example.com"}
+		user.Suspend("cooldown", &expired, expired.Add(-time.Hour))
+		users.users[id] = user
+	}
+	users.updateErrs = map[string]error{"user-2": repo.ErrVersionConflict}
+	svc := service.NewAdminService(pkglog.New("test"), users, nil, nil, nil, fakePublisher{})
+
+	lifted, err := svc.LiftExpiredSuspensions(context.Background(), time.Now())
+	assert.Error(t, err)
+	assert.Equal(t, 2, lifted)
+	assert.True(t, users.users["user-1"].IsActive)
+	assert.True(t, users.users["user-3"].IsActive)
+	assert.False(t, users.users["user-2"].IsActive)
+}
+
+func TestAdminService_SupportActionsAreAudited(t *testing.T) {
+	users := newUserRepoStub()
+	users.users["user-1"].IsActive = true
+	rbacClient := newFakeRBACClient()
+	audit := &fakeAuditRepo{}
+	publisher := &recordingPublisher{}
+	svc := service.NewAdminService(pkglog.New("test"), users, service.NewUserService(nil, users, newProfileRepoStub(), identityRepoStub{}, nil, tarantoolStub{}, nil, nil, nil), rbacClient, audit, publisher)
+	ctx := context.Background()
+
+	user, err := svc.FindUser(ctx, " user-1 ")
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", user.ID)
+
+	user, err = svc.SetActive(ctx, "trace", "cli:ops", "user-1", false)
+	require.NoError(t, err)
+	assert.False(t, user.IsActive)
+	user, err = svc.SetActive(ctx, "trace", "cli:ops", "user-1", true)
+	require.NoError(t, err)
+	assert.True(t, user.IsActive)
+
+	user, err = svc.ForcePasswordReset(ctx, "trace", "cli:ops", "user-1")
+	require.NoError(t, err)
+	assert.True(t, user.PasswordResetRequired)
+	assert.True(t, user.TokenRevoked(time.Now().Add(-time.Minute)))
+
+	assert.ErrorIs(t, svc.AssignRole(ctx, "trace", "cli:ops", "user-1", " "), service.ErrRoleRequired)
+	require.NoError(t, svc.AssignRole(ctx, "trace", "cli:ops", "user-1", "support"))
+	assert.Equal(t, "support", rbacClient.assignments["user-1"])
+
+	_, err = svc.RevokeSessions(ctx, "trace", "cli:ops", "user-1")
+	require.NoError(t, err)
+
+	details, err := svc.Describe(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, "support", details.Role)
+
+	var actions []string
+	for _, event := range audit.events {
+		assert.Equal(t, "cli:ops", event.ActorID)
+		actions = append(actions, event.Action)
+	}
+	assert.Equal(t, []string{"user.deactivated", "user.activated", "user.password_reset_required", "user.role_assigned", "user.sessions_revoked"}, actions)
+	assert.Equal(t, []string{"user.deactivated", "user.activated"}, publisher.keys)
+}
+
+func TestAdminService_SetActiveKeepsSuspendedUsersInactive(t *testing.T) {
+	users := newUserRepoStub()
+	svc := service.NewAdminService(pkglog.New("test"), users, nil, nil, nil, fakePublisher{})
+
+	_, err := svc.Suspend(context.Background(), "trace", "admin-1", "user-1", "spam", nil)
+	require.NoError(t, err)
+	_, err = svc.SetActive(context.Background(), "trace", "admin-1", "user-1", true)
+	assert.ErrorIs(t, err, service.ErrUserNotActivatable)
+	assert.False(t, users.users["user-1"].IsActive)
+}
@@ -3,23 +3,29 @@ package unit
 import (
 	"context"
 	"errors"
+	"net/url"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 
 	"github.com/example/user-service/config"
 	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/events"
 	"github.com/example/user-service/internal/ports/tarantool"
+	"github.com/example/user-service/internal/repo"
 	"github.com/example/user-service/internal/service"
 	pkglog "github.com/example/user-service/pkg/log"
 )
 
 type fakeUserRepo struct {
 	users map[string]*domain.User
+	// identities, when set, is counted for UpdateLocked.
+	identities *fakeIdentityRepo
 }
 
 func newFakeUserRepo() *fakeUserRepo {
@@ -32,7 +38,9 @@ func (f *fakeUserRepo) Create(ctx context.Context, user *domain.User) error {
 	return nil
 }
 
+// Update bumps Version like the versioned repository does.
 func (f *fakeUserRepo) Update(ctx context.Context, user *domain.User) error {
+	user.Version++
 	f.users[strings.ToLower(user.Email)] = user
 	return nil
 }
@@ -44,6 +52,24 @@ func (f *fakeUserRepo) FindByEmail(ctx context.Context, email string) (*domain.U
 	return nil, gorm.ErrRecordNotFound
 }
 
+func (f *fakeUserRepo) FindByUsername(ctx context.Context, canonical string) (*domain.User, error) {
+	for _, user := range f.users {
+		if user.UsernameCanonical != nil && *user.UsernameCanonical == canonical {
+			return user, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakeUserRepo) FindByPhone(ctx context.Context, phone string) (*domain.User, error) {
+	for _, user := range f.users {
+		if user.Phone != nil && *user.Phone == phone {
+			return user, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
 func (f *fakeUserRepo) FindByID(ctx context.Context, id string) (*domain.User, error) {
 	for _, user := range f.users {
 		if user.ID == id {
@@ -53,10 +79,45 @@ func (f *fakeUserRepo) FindByID(ctx context.Context, id string) (*domain.User, e
 	return nil, gorm.ErrRecordNotFound
 }
 
-func (f *fakeUserRepo) Delete(ctx context.Context, id string) error { return nil }
+// UpdateLocked applies the change to a copy so a refused change leaves the
+// stored user untouched.
+func (f *fakeUserRepo) UpdateLocked(ctx context.Context, id string, apply func(user *domain.User, identities int64) error) (*domain.User, error) {
+	user, err := f.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	var identities int64
+	if f.identities != nil {
+		linked, _ := f.identities.FindByUserID(ctx, id)
+		identities = int64(len(linked))
+	}
+	changed := *user
+	if err := apply(&changed, identities); err != nil {
+		return nil, err
+	}
+	*user = changed
+	return user, f.Update(ctx, user)
+}
+
+func (f *fakeUserRepo) UpdateUsername(ctx context.Context, user *domain.User, hold *domain.UsernameHold) error {
+	return f.Update(ctx, user)
+}
+
+func (f *fakeUserRepo) Delete(ctx context.Context, id string, hold *domain.UsernameHold) error {
+	return nil
+}
 func (f *fakeUserRepo) List(ctx context.Context, offset, limit int) ([]domain.User, int64, error) {
 	return nil, 0, nil
 }
+func (f *fakeUserRepo) ListExpiredSuspensions(ctx context.Context, now time.Time, limit int) ([]domain.User, error) {
+	return nil, nil
+}
+func (f *fakeUserRepo) ListPurgeable(ctx context.Context, now time.Time, limit int) ([]domain.User, error) {
+	return nil, nil
+}
+func (f *fakeUserRepo) ListFiltered(ctx context.Context, filter repo.UserListFilter) (*repo.UserPage, error) {
+	return &repo.UserPage{}, nil
+}
 
 type fakeProfileRepo struct {
 	profiles map[string]*domain.UserProfile
@@ -84,43 +145,84 @@ func (f *fakeProfileRepo) FindByUserID(ctx context.Context, userID string) (*dom
 	return nil, errors.New("not found")
 }
 
-type fakeProviderRepo struct {
-	providers map[string]*domain.UserProvider
+type fakeIdentityRepo struct {
+	identities map[string]*domain.UserIdentity
+	// foreignUsers holds the users of tenants other than the caller's.
+	foreignUsers map[string]bool
+	// users resolves the owner passed to the check of Delete.
+	users *fakeUserRepo
 }
 
-func newFakeProviderRepo() *fakeProviderRepo {
-	return &fakeProviderRepo{providers: map[string]*domain.UserProvider{}}
+func newFakeIdentityRepo() *fakeIdentityRepo {
+	return &fakeIdentityRepo{identities: map[string]*domain.UserIdentity{}}
 }
 
-func (f *fakeProviderRepo) key(providerType, providerUserID string) string {
-	return providerType + ":" + providerUserID
+func (f *fakeIdentityRepo) key(provider domain.IdentityProvider, providerUserID string) string {
+	return string(provider) + ":" + providerUserID
 }
 
-func (f *fakeProviderRepo) Create(ctx context.Context, provider *domain.UserProvider) error {
-	provider.ID = "provider-" + provider.ProviderUserID
-	f.providers[f.key(provider.ProviderType, provider.ProviderUserID)] = provider
+func (f *fakeIdentityRepo) Create(ctx context.Context, identity *domain.UserIdentity) error {
+	identity.ID = "identity-" + identity.ProviderUserID
+	f.identities[f.key(identity.Provider, identity.ProviderUserID)] = identity
 	return nil
 }
 
-func (f *fakeProviderRepo) Update(ctx context.Context, provider *domain.UserProvider) error {
-	f.providers[f.key(provider.ProviderType, provider.ProviderUserID)] = provider
+func (f *fakeIdentityRepo) Update(ctx context.Context, identity *domain.UserIdentity) error {
+	f.identities[f.key(identity.Provider, identity.ProviderUserID)] = identity
 	return nil
 }
 
-func (f *fakeProviderRepo) Delete(ctx context.Context, id string) error { return nil }
+func (f *fakeIdentityRepo) Touch(ctx context.Context, id string, at time.Time) error {
+	for _, identity := range f.identities {
+		if identity.ID == id {
+			identity.LastUsedAt = &at
+		}
+	}
+	return nil
+}
+
+func (f *fakeIdentityRepo) Delete(ctx context.Context, identity *domain.UserIdentity, check func(user *domain.User, remaining int64) error) error {
+	if check != nil {
+		user, err := f.users.FindByID(ctx, identity.UserID)
+		if err != nil {
+			return err
+		}
+		linked, err := f.FindByUserID(ctx, identity.UserID)
+		if err != nil {
+			return err
+		}
+		if err := check(user, int64(len(linked)-1)); err != nil {
+			return err
+		}
+	}
+	delete(f.identities, f.key(identity.Provider, identity.ProviderUserID))
+	return nil
+}
+
+func (f *fakeIdentityRepo) FindByProviderUserID(ctx context.Context, provider domain.IdentityProvider, providerUserID string) (*domain.UserIdentity, error) {
+	if identity, ok := f.identities[f.key(provider, providerUserID)]; ok {
+		if f.foreignUsers[identity.UserID] {
+			return nil, repo.ErrIdentityInOtherTenant
+		}
+		return identity, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
 
-func (f *fakeProviderRepo) FindByProvider(ctx context.Context, providerType, providerUserID string) (*domain.UserProvider, error) {
-	if provider, ok := f.providers[f.key(providerType, providerUserID)]; ok {
-		return provider, nil
+func (f *fakeIdentityRepo) FindByUserAndProvider(ctx context.Context, userID string, provider domain.IdentityProvider) (*domain.UserIdentity, error) {
+	for _, identity := range f.identities {
+		if identity.UserID == userID && identity.Provider == provider {
+			return identity, nil
+		}
 	}
 	return nil, gorm.ErrRecordNotFound
 }
 
-func (f *fakeProviderRepo) FindByUserID(ctx context.Context, userID string) ([]domain.UserProvider, error) {
-	var result []domain.UserProvider
-	for _, provider := range f.providers {
-		if provider.UserID == userID {
-			result = append(result, *provider)
+func (f *fakeIdentityRepo) FindByUserID(ctx context.Context, userID string) ([]domain.UserIdentity, error) {
+	var result []domain.UserIdentity
+	for _, identity := range f.identities {
+		if identity.UserID == userID {
+			result = append(result, *identity)
 		}
 	}
 	return result, nil
@@ -148,8 +250,9 @@ func (f *fakeRBACClient) GetPermissionsByUserID(ctx context.Context, userID stri
 	return nil, nil
 }
 
+// CheckPermission grants every permission to admins.
 func (f *fakeRBACClient) CheckPermission(ctx context.Context, userID, permission string) (bool, error) {
-	return false, nil
+	return f.assignments[userID] == "admin", nil
 }
 
 func (f *fakeRBACClient) CheckRole(ctx context.Context, userID, role string) (bool, error) {
@@ -164,6 +267,11 @@ func (f *fakeRBACClient) AssignRole(ctx context.Context, userID, role string) er
 	return nil
 }
 
+func (f *fakeRBACClient) RevokeRoles(ctx context.Context, userID string) error {
+	delete(f.assignments, userID)
+	return nil
+}
+
 type recordingRBACClient struct {
 	assignCalled bool
 	assignedUser string
@@ -201,6 +309,8 @@ func (r *recordingRBACClient) AssignRole(ctx context.Context, userID, role strin
 	return nil
 }
 
+func (r *recordingRBACClient) RevokeRoles(ctx context.Context, userID string) error { return nil }
+
 type recordingJWTSigner struct {
 	claims map[string]interface{}
 }
@@ -243,6 +353,57 @@ func (f *fakeTarantool) VerifyEmailChange(ctx context.Context, uuid, code string
 	return &tarantool.VerificationResult{Email: "new@example.com"}, nil
 }
 
+func (f *fakeTarantool) StartAccountLink(ctx context.Context, userID, email string) (string, error) {
+	f.email = email
+	return "uuid-link", nil
+}
+
+func (f *fakeTarantool) VerifyAccountLink(ctx context.Context, uuid, code string) (*tarantool.VerificationResult, error) {
+	if uuid != "uuid-link" || code != "1234" {
+		return nil, errors.New("invalid code")
+	}
+	return &tarantool.VerificationResult{Email: f.email}, nil
+}
+
+type fakeAccountLinkRepo struct {
+	links map[string]*domain.AccountLink
+}
+
+func newFakeAccountLinkRepo() *fakeAccountLinkRepo {
+	return &fakeAccountLinkRepo{links: map[string]*domain.AccountLink{}}
+}
+
+func (f *fakeAccountLinkRepo) Create(ctx context.Context, link *domain.AccountLink) error {
+	link.ID = "link-" + link.ProviderUserID
+	f.links[link.ID] = link
+	return nil
+}
+
+func (f *fakeAccountLinkRepo) FindByID(ctx context.Context, id string) (*domain.AccountLink, error) {
+	if link, ok := f.links[id]; ok {
+		return link, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakeAccountLinkRepo) ClaimAttempt(ctx context.Context, id string, max int) (bool, error) {
+	link, ok := f.links[id]
+	if !ok || link.Attempts >= max || link.ConsumedAt != nil {
+		return false, nil
+	}
+	link.Attempts++
+	return true, nil
+}
+
+func (f *fakeAccountLinkRepo) Consume(ctx context.Context, id string, at time.Time) error {
+	link, ok := f.links[id]
+	if !ok || link.ConsumedAt != nil {
+		return gorm.ErrRecordNotFound
+	}
+	link.ConsumedAt = &at
+	return nil
+}
+
 type fakePublisher struct{}
 
 func (fakePublisher) Publish(ctx context.Context, routingKey string, payload interface{}) error {
@@ -250,10 +411,13 @@ func (fakePublisher) Publish(ctx context.Context, routingKey string, payload int
 }
 func (fakePublisher) Close() error { return nil }
 
-type fakeAvatarIngestor struct{}
+type fakeAvatarQueue struct {
+	jobs []service.AvatarJob
+}
 
-func (fakeAvatarIngestor) Ingest(ctx context.Context, traceID, avatarURL string) (string, error) {
-	return avatarURL, nil
+func (f *fakeAvatarQueue) Enqueue(job service.AvatarJob) bool {
+	f.jobs = append(f.jobs, job)
+	return true
 }
 
 func TestAuthService_StartSignup(t *testing.T) {
@@ -262,9 +426,9 @@ func TestAuthService_StartSignup(t *testing.T) {
 	require.NoError(t, err)
 	users := newFakeUserRepo()
 	profiles := newFakeProfileRepo()
-	providers := newFakeProviderRepo()
+	identities := newFakeIdentityRepo()
 	tarantoolClient := &fakeTarantool{}
-	auth := service.NewAuthService(cfg, pkglog.New("test"), users, profiles, providers, tarantoolClient, newFakeRBACClient(), fakePublisher{}, signer, fakeAvatarIngestor{})
+	auth := service.NewAuthService(cfg, pkglog.New("test"), users, profiles, identities, newFakeAccountLinkRepo(), tarantoolClient, newFakeRBACClient(), fakePublisher{}, signer, &fakeAvatarQueue{}, nil, nil, nil, nil)
 
 	uuid, err := auth.StartSignup(context.Background(), "trace-1", "user@example.com", "password123")
 	require.NoError(t, err)
@@ -277,9 +441,9 @@ func TestAuthService_StartSignup_InvalidPassword(t *testing.T) {
 	require.NoError(t, err)
 	users := newFakeUserRepo()
 	profiles := newFakeProfileRepo()
-	providers := newFakeProviderRepo()
+	identities := newFakeIdentityRepo()
 	tarantoolClient := &fakeTarantool{}
-	auth := service.NewAuthService(cfg, pkglog.New("test"), users, profiles, providers, tarantoolClient, newFakeRBACClient(), fakePublisher{}, signer, fakeAvatarIngestor{})
+	auth := service.NewAuthService(cfg, pkglog.New("test"), users, profiles, identities, newFakeAccountLinkRepo(), tarantoolClient, newFakeRBACClient(), fakePublisher{}, signer, &fakeAvatarQueue{}, nil, nil, nil, nil)
 
 	_, err = auth.StartSignup(context.Background(), "trace-1", "user@example.com", "password")
 	require.Error(t, err)
@@ -293,9 +457,9 @@ func TestAuthService_StartSignup_DuplicateEmail(t *testing.T) {
 	users := newFakeUserRepo()
 	users.users[strings.ToLower("user@example.com")] = &domain.User{ID: "user-1", Email: "user@example.com"}
 	profiles := newFakeProfileRepo()
-	providers := newFakeProviderRepo()
+	identities := newFakeIdentityRepo()
 	tarantoolClient := &fakeTarantool{}
-	auth := service.NewAuthService(cfg, pkglog.New("test"), users, profiles, providers, tarantoolClient, newFakeRBACClient(), fakePublisher{}, signer, fakeAvatarIngestor{})
+	auth := service.NewAuthService(cfg, pkglog.New("test"), users, profiles, identities, newFakeAccountLinkRepo(), tarantoolClient, newFakeRBACClient(), fakePublisher{}, signer, &fakeAvatarQueue{}, nil, nil, nil, nil)
 
 	_, err = auth.StartSignup(context.Background(), "trace-1", "user@example.com", "password123")
 	require.Error(t, err)
@@ -308,10 +472,10 @@ func TestAuthService_VerifySignup(t *testing.T) {
 	require.NoError(t, err)
 	users := newFakeUserRepo()
 	profiles := newFakeProfileRepo()
-	providers := newFakeProviderRepo()
+	identities := newFakeIdentityRepo()
 	tarantoolClient := &fakeTarantool{email: "USER@EXAMPLE.COM", password: "password123"}
 	rbacClient := newFakeRBACClient()
-	auth := service.NewAuthService(cfg, pkglog.New("test"), users, profiles, providers, tarantoolClient, rbacClient, fakePublisher{}, signer, fakeAvatarIngestor{})
+	auth := service.NewAuthService(cfg, pkglog.New("test"), users, profiles, identities, newFakeAccountLinkRepo(), tarantoolClient, rbacClient, fakePublisher{}, signer, &fakeAvatarQueue{}, nil, nil, nil, nil)
 
 	user, tokens, err := auth.VerifySignup(context.Background(), "trace-1", "uuid-1", "0000")
 	require.NoError(t, err)
@@ -328,11 +492,11 @@ func TestAuthService_VerifySignup_AssignsDefaultRoleAndResolvesUserRole(t *testi
 	jwtSigner := &recordingJWTSigner{}
 	users := newFakeUserRepo()
 	profiles := newFakeProfileRepo()
-	providers := newFakeProviderRepo()
+	identities := newFakeIdentityRepo()
 	tarantoolClient := &fakeTarantool{email: "user@example.com", password: "password123"}
 	expectedRole := "member"
 	rbacClient := &recordingRBACClient{roleByUser: map[string]string{"user-1": expectedRole}}
-	auth := service.NewAuthService(cfg, pkglog.New("test"), users, profiles, providers, tarantoolClient, rbacClient, fakePublisher{}, jwtSigner, fakeAvatarIngestor{})
+	auth := service.NewAuthService(cfg, pkglog.New("test"), users, profiles, identities, newFakeAccountLinkRepo(), tarantoolClient, rbacClient, fakePublisher{}, jwtSigner, &fakeAvatarQueue{}, nil, nil, nil, nil)
 
 	user, tokens, err := auth.VerifySignup(context.Background(), "trace-1", "uuid-1", "0000")
 	require.NoError(t, err)
@@ -355,10 +519,10 @@ func TestAuthService_VerifySignup_InvalidCode(t *testing.T) {
 	require.NoError(t, err)
 	users := newFakeUserRepo()
 	profiles := newFakeProfileRepo()
-	providers := newFakeProviderRepo()
+	identities := newFakeIdentityRepo()
 	tarantoolClient := &fakeTarantool{email: "user@example.com", password: "password123"}
 	rbacClient := newFakeRBACClient()
-	auth := service.NewAuthService(cfg, pkglog.New("test"), users, profiles, providers, tarantoolClient, rbacClient, fakePublisher{}, signer, fakeAvatarIngestor{})
+	auth := service.NewAuthService(cfg, pkglog.New("test"), users, profiles, identities, newFakeAccountLinkRepo(), tarantoolClient, rbacClient, fakePublisher{}, signer, &fakeAvatarQueue{}, nil, nil, nil, nil)
 
 	_, _, err = auth.VerifySignup(context.Background(), "trace-1", "uuid-1", "12a4")
 	require.Error(t, err)
@@ -371,9 +535,9 @@ func TestAuthService_HandleOAuthCallback_CreateAndLink(t *testing.T) {
 	require.NoError(t, err)
 	users := newFakeUserRepo()
 	profiles := newFakeProfileRepo()
-	providers := newFakeProviderRepo()
+	identities := newFakeIdentityRepo()
 	tarantoolClient := &fakeTarantool{}
-	auth := service.NewAuthService(cfg, pkglog.New("test"), users, profiles, providers, tarantoolClient, newFakeRBACClient(), fakePublisher{}, signer, fakeAvatarIngestor{})
+	auth := service.NewAuthService(cfg, pkglog.New("test"), users, profiles, identities, newFakeAccountLinkRepo(), tarantoolClient, newFakeRBACClient(), fakePublisher{}, signer, &fakeAvatarQueue{}, nil, nil, nil, nil)
 
 	displayName := "OAuth User"
 	user, tokens, err := auth.HandleOAuthCallback(context.Background(), "trace-1", "google", service.OAuthUserInfo{
@@ -389,9 +553,9 @@ func TestAuthService_HandleOAuthCallback_CreateAndLink(t *testing.T) {
 	assert.NotNil(t, tokens)
 	assert.NotEmpty(t, tokens.AccessToken)
 
-	provider, err := providers.FindByProvider(context.Background(), "google", "oauth-1")
+	identity, err := identities.FindByProviderUserID(context.Background(), domain.ProviderGoogle, "oauth-1")
 	require.NoError(t, err)
-	assert.Equal(t, user.ID, provider.UserID)
+	assert.Equal(t, user.ID, identity.UserID)
 }
 
 func TestAuthService_HandleOAuthCallback_ExistingProvider(t *testing.T) {
@@ -403,11 +567,11 @@ func TestAuthService_HandleOAuthCallback_ExistingProvider(t *testing.T) {
 	existingUser := &domain.User{ID: "user-42", Email: "linked@example.com", IsActive: true}
 	users.users[strings.ToLower(existingUser.Email)] = existingUser
 
-	providers := newFakeProviderRepo()
-	providers.providers[providers.key("google", "oauth-1")] = &domain.UserProvider{ProviderType: "google", ProviderUserID: "oauth-1", UserID: existingUser.ID}
+	identities := newFakeIdentityRepo()
+	identities.identities[identities.key(domain.ProviderGoogle, "oauth-1")] = &domain.UserIdentity{Provider: domain.ProviderGoogle, ProviderUserID: "oauth-1", UserID: existingUser.ID}
 
 	tarantoolClient := &fakeTarantool{}
-	auth := service.NewAuthService(cfg, pkglog.New("test"), users, newFakeProfileRepo(), providers, tarantoolClient, newFakeRBACClient(), fakePublisher{}, signer, fakeAvatarIngestor{})
+	auth := service.NewAuthService(cfg, pkglog.New("test"), users, newFakeProfileRepo(), identities, newFakeAccountLinkRepo(), tarantoolClient, newFakeRBACClient(), fakePublisher{}, signer, &fakeAvatarQueue{}, nil, nil, nil, nil)
 
 	user, tokens, err := auth.HandleOAuthCallback(context.Background(), "trace-1", "google", service.OAuthUserInfo{
 		ProviderType:   "google",
@@ -418,7 +582,29 @@ func TestAuthService_HandleOAuthCallback_ExistingProvider(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, existingUser.ID, user.ID)
 	assert.NotNil(t, tokens)
-	assert.Equal(t, 1, len(providers.providers))
+	assert.Equal(t, 1, len(identities.identities))
+}
+
+func TestAuthService_HandleOAuthCallback_RejectsIdentityOfOtherTenant(t *testing.T) {
+	cfg := &config.Config{JWTSecret: "secret", JWTTTLMinutes: time.Minute, JWTRefreshTTLMinutes: time.Hour}
+	signer, err := service.NewJWTSigner(cfg)
+	require.NoError(t, err)
+
+	users := newFakeUserRepo()
+	identities := newFakeIdentityRepo()
+	identities.identities[identities.key(domain.ProviderGoogle, "oauth-1")] = &domain.UserIdentity{Provider: domain.ProviderGoogle, ProviderUserID: "oauth-1", UserID: "user-elsewhere"}
+	identities.foreignUsers = map[string]bool{"user-elsewhere": true}
+	auth := service.NewAuthService(cfg, pkglog.New("test"), users, newFakeProfileRepo(), identities, newFakeAccountLinkRepo(), &fakeTarantool{}, newFakeRBACClient(), fakePublisher{}, signer, &fakeAvatarQueue{}, nil, nil, nil, nil)
+
+	_, tokens, err := auth.HandleOAuthCallback(context.Background(), "trace-1", "google", service.OAuthUserInfo{
+		ProviderType:   "google",
+		ProviderUserID: "oauth-1",
+		Email:          "linked@example.com",
+	})
+
+	assert.ErrorIs(t, err, service.ErrIdentityOtherTenant)
+	assert.Nil(t, tokens)
+	assert.Empty(t, users.users, "no account is created in this tenant")
 }
 
 func TestAuthService_HandleOAuthCallback_InactiveUser(t *testing.T) {
@@ -430,11 +616,11 @@ func TestAuthService_HandleOAuthCallback_InactiveUser(t *testing.T) {
 	inactiveUser := &domain.User{ID: "user-99", Email: "inactive@example.com", IsActive: false}
 	users.users[strings.ToLower(inactiveUser.Email)] = inactiveUser
 
-	providers := newFakeProviderRepo()
-	providers.providers[providers.key("google", "inactive-1")] = &domain.UserProvider{ProviderType: "google", ProviderUserID: "inactive-1", UserID: inactiveUser.ID}
+	identities := newFakeIdentityRepo()
+	identities.identities[identities.key(domain.ProviderGoogle, "inactive-1")] = &domain.UserIdentity{Provider: domain.ProviderGoogle, ProviderUserID: "inactive-1", UserID: inactiveUser.ID}
 
 	tarantoolClient := &fakeTarantool{}
-	auth := service.NewAuthService(cfg, pkglog.New("test"), users, newFakeProfileRepo(), providers, tarantoolClient, newFakeRBACClient(), fakePublisher{}, signer, fakeAvatarIngestor{})
+	auth := service.NewAuthService(cfg, pkglog.New("test"), users, newFakeProfileRepo(), identities, newFakeAccountLinkRepo(), tarantoolClient, newFakeRBACClient(), fakePublisher{}, signer, &fakeAvatarQueue{}, nil, nil, nil, nil)
 
 	user, tokens, err := auth.HandleOAuthCallback(context.Background(), "trace-1", "google", service.OAuthUserInfo{
 		ProviderType:   "google",
@@ -446,3 +632,307 @@ func TestAuthService_HandleOAuthCallback_InactiveUser(t *testing.T) {
 	assert.Nil(t, user)
 	assert.Nil(t, tokens)
 }
+
+func TestAuthService_HandleOAuthCallback_ExistingEmailRequiresLink(t *testing.T) {
+	cfg := &config.Config{JWTSecret: "secret", JWTTTLMinutes: time.Minute, JWTRefreshTTLMinutes: time.Hour, AccountLinkTTL: time.Minute}
+	signer, err := service.NewJWTSigner(cfg)
+	require.NoError(t, err)
+
+	users := newFakeUserRepo()
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	existingUser := &domain.User{ID: "user-7", Email: "owner@example.com", IsActive: true}
+	existingUser.SetPasswordHash(string(hash))
+	users.users[existingUser.Email] = existingUser
+
+	identities := newFakeIdentityRepo()
+	links := newFakeAccountLinkRepo()
+	auth := service.NewAuthService(cfg, pkglog.New("test"), users, newFakeProfileRepo(), identities, links, &fakeTarantool{}, newFakeRBACClient(), fakePublisher{}, signer, &fakeAvatarQueue{}, nil, nil, nil, nil)
+
+	user, tokens, err := auth.HandleOAuthCallback(context.Background(), "trace-1", "google", service.OAuthUserInfo{
+		ProviderType:   "google",
+		ProviderUserID: "oauth-7",
+		Email:          existingUser.Email,
+	})
+
+	require.ErrorIs(t, err, service.ErrAccountLinkRequired)
+	assert.Nil(t, user)
+	assert.Nil(t, tokens)
+	assert.Empty(t, identities.identities)
+
+	var linkErr *service.AccountLinkRequiredError
+	require.ErrorAs(t, err, &linkErr)
+	assert.ElementsMatch(t, []string{service.LinkMethodCode, service.LinkMethodPassword}, linkErr.Methods)
+
+	_, _, err = auth.ConfirmAccountLink(context.Background(), "trace-2", linkErr.LinkID, "", "wrong-password1")
+	assert.ErrorIs(t, err, service.ErrInvalidCredentials)
+	assert.Empty(t, identities.identities)
+
+	user, tokens, err = auth.ConfirmAccountLink(context.Background(), "trace-3", linkErr.LinkID, "1234", "")
+	require.NoError(t, err)
+	assert.Equal(t, existingUser.ID, user.ID)
+	assert.NotNil(t, tokens)
+
+	identity, err := identities.FindByProviderUserID(context.Background(), domain.ProviderGoogle, "oauth-7")
+	require.NoError(t, err)
+	assert.Equal(t, existingUser.ID, identity.UserID)
+
+	_, _, err = auth.ConfirmAccountLink(context.Background(), "trace-4", linkErr.LinkID, "1234", "")
+	assert.ErrorIs(t, err, service.ErrAccountLinkExpired)
+}
+
+func TestAuthService_ConfirmAccountLinkCountsEveryFailedCheck(t *testing.T) {
+	cfg := &config.Config{JWTSecret: "secret", JWTTTLMinutes: time.Minute, JWTRefreshTTLMinutes: time.Hour, AccountLinkTTL: time.Minute}
+	signer, err := service.NewJWTSigner(cfg)
+	require.NoError(t, err)
+
+	users := newFakeUserRepo()
+	existingUser := &domain.User{ID: "user-8", Email: "owner@example.com", IsActive: true}
+	users.users[existingUser.Email] = existingUser
+	identities := newFakeIdentityRepo()
+	links := newFakeAccountLinkRepo()
+	tarantool := &fakeTarantool{}
+	auth := service.NewAuthService(cfg, pkglog.New("test"), users, newFakeProfileRepo(), identities, links, tarantool, newFakeRBACClient(), fakePublisher{}, signer, &fakeAvatarQueue{}, nil, nil, nil, nil)
+
+	_, _, err = auth.HandleOAuthCallback(context.Background(), "trace-1", "google", service.OAuthUserInfo{
+		ProviderType:   "google",
+		ProviderUserID: "oauth-8",
+		Email:          existingUser.Email,
+	})
+	var linkErr *service.AccountLinkRequiredError
+	require.ErrorAs(t, err, &linkErr)
+
+	// The code was confirmed for another address.
+	tarantool.email = "someone-else@example.com"
+	for i := 0; i < 5; i++ {
+		_, _, err = auth.ConfirmAccountLink(context.Background(), "trace-2", linkErr.LinkID, "1234", "")
+		assert.ErrorIs(t, err, service.ErrInvalidCredentials)
+	}
+	assert.Equal(t, 5, links.links[linkErr.LinkID].Attempts)
+
+	tarantool.email = existingUser.Email
+	_, _, err = auth.ConfirmAccountLink(context.Background(), "trace-3", linkErr.LinkID, "1234", "")
+	assert.ErrorIs(t, err, service.ErrAccountLinkExpired)
+	assert.Empty(t, identities.identities)
+}
+
+func TestAuthService_HandleOAuthCallback_TrustedProviderAutoLinks(t *testing.T) {
+	cfg := &config.Config{
+		JWTSecret:            "secret",
+		JWTTTLMinutes:        time.Minute,
+		JWTRefreshTTLMinutes: time.Hour,
+		OAuthLinkPolicy:      map[string]string{"google": service.LinkPolicyAuto},
+	}
+	signer, err := service.NewJWTSigner(cfg)
+	require.NoError(t, err)
+
+	users := newFakeUserRepo()
+	existingUser := &domain.User{ID: "user-8", Email: "trusted@example.com", IsActive: true}
+	users.users[existingUser.Email] = existingUser
+
+	identities := newFakeIdentityRepo()
+	auth := service.NewAuthService(cfg, pkglog.New("test"), users, newFakeProfileRepo(), identities, newFakeAccountLinkRepo(), &fakeTarantool{}, newFakeRBACClient(), fakePublisher{}, signer, &fakeAvatarQueue{}, nil, nil, nil, nil)
+
+	user, tokens, err := auth.HandleOAuthCallback(context.Background(), "trace-1", "google", service.OAuthUserInfo{
+		ProviderType:   "google",
+		ProviderUserID: "oauth-8",
+		Email:          existingUser.Email,
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, existingUser.ID, user.ID)
+	assert.NotNil(t, tokens)
+	assert.Len(t, identities.identities, 1)
+}
+
+func TestAuthService_HandleOAuthCallback_UsesAttachedIdentity(t *testing.T) {
+	cfg := &config.Config{JWTSecret: "secret", JWTTTLMinutes: time.Minute, JWTRefreshTTLMinutes: time.Hour}
+	signer, err := service.NewJWTSigner(cfg)
+	require.NoError(t, err)
+
+	users := newFakeUserRepo()
+	existingUser := &domain.User{ID: "user-9", Email: "attached@example.com", IsActive: true}
+	existingUser.SetPasswordHash("hash")
+	users.users[existingUser.Email] = existingUser
+	profiles := newFakeProfileRepo()
+	profiles.profiles[existingUser.ID] = &domain.UserProfile{ID: "profile-9", UserID: existingUser.ID}
+	identities := newFakeIdentityRepo()
+	identities.users = users
+
+	userSvc := service.NewUserService(nil, users, profiles, identities, nil, &fakeTarantool{}, nil, nil, nil)
+	_, _, err = userSvc.AttachIdentity(context.Background(), existingUser.ID, domain.ProviderGitHub, "gh-9", existingUser.Email, nil, nil)
+	require.NoError(t, err)
+
+	auth := service.NewAuthService(cfg, pkglog.New("test"), users, profiles, identities, newFakeAccountLinkRepo(), &fakeTarantool{}, newFakeRBACClient(), fakePublisher{}, signer, &fakeAvatarQueue{}, nil, nil, nil, nil)
+	user, tokens, err := auth.HandleOAuthCallback(context.Background(), "trace-1", "github", service.OAuthUserInfo{
+		ProviderUserID: "gh-9",
+		Email:          existingUser.Email,
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, existingUser.ID, user.ID)
+	assert.NotNil(t, tokens)
+
+	require.NoError(t, userSvc.RemoveIdentity(context.Background(), existingUser.ID, domain.ProviderGitHub, "gh-9"))
+	_, _, err = auth.HandleOAuthCallback(context.Background(), "trace-2", "github", service.OAuthUserInfo{
+		ProviderUserID: "gh-9",
+		Email:          existingUser.Email,
+	})
+	assert.ErrorIs(t, err, service.ErrAccountLinkRequired)
+}
+
+func TestUserService_RemoveIdentity_KeepsLastLoginMethod(t *testing.T) {
+	users := newFakeUserRepo()
+	oauthOnly := &domain.User{ID: "user-10", Email: "oauth-only@example.com", IsActive: true}
+	users.users[oauthOnly.Email] = oauthOnly
+	identities := newFakeIdentityRepo()
+	identities.users = users
+	lastUsed := time.Now().UTC()
+	identities.identities[identities.key(domain.ProviderGoogle, "g-10")] = &domain.UserIdentity{ID: "identity-g-10", UserID: oauthOnly.ID, Provider: domain.ProviderGoogle, ProviderUserID: "g-10", Email: oauthOnly.Email, LastUsedAt: &lastUsed}
+	identities.identities[identities.key(domain.ProviderGitHub, "gh-10")] = &domain.UserIdentity{ID: "identity-gh-10", UserID: oauthOnly.ID, Provider: domain.ProviderGitHub, ProviderUserID: "gh-10", Email: oauthOnly.Email}
+	svc := service.NewUserService(nil, users, newFakeProfileRepo(), identities, nil, &fakeTarantool{}, nil, nil, nil)
+
+	listed, err := svc.ListIdentities(context.Background(), oauthOnly.ID)
+	require.NoError(t, err)
+	assert.Len(t, listed, 2)
+
+	require.NoError(t, svc.RemoveIdentity(context.Background(), oauthOnly.ID, domain.ProviderGitHub, "gh-10"))
+
+	err = svc.RemoveIdentity(context.Background(), oauthOnly.ID, domain.ProviderGoogle, "g-10")
+	assert.ErrorIs(t, err, service.ErrLastLoginMethod)
+	listed, err = svc.ListIdentities(context.Background(), oauthOnly.ID)
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.Equal(t, &lastUsed, listed[0].LastUsedAt)
+
+	phone := "+4915112345678"
+	oauthOnly.Phone = &phone
+	assert.NoError(t, svc.RemoveIdentity(context.Background(), oauthOnly.ID, domain.ProviderGoogle, "g-10"), "the verified phone still signs in")
+}
+
+func TestAuthService_HandleOAuthCallback_QueuesAvatarIngestion(t *testing.T) {
+	cfg := &config.Config{JWTSecret: "secret", JWTTTLMinutes: time.Minute, JWTRefreshTTLMinutes: time.Hour}
+	signer, err := service.NewJWTSigner(cfg)
+	require.NoError(t, err)
+	profiles := newFakeProfileRepo()
+	avatars := &fakeAvatarQueue{}
+	auth := service.NewAuthService(cfg, pkglog.New("test"), newFakeUserRepo(), profiles, newFakeIdentityRepo(), newFakeAccountLinkRepo(), &fakeTarantool{}, newFakeRBACClient(), fakePublisher{}, signer, avatars, nil, nil, nil, nil)
+
+	avatarURL := "https://lh3.googleusercontent.com/a/photo.jpg"
+	user, _, err := auth.HandleOAuthCallback(context.Background(), "trace-1", "google", service.OAuthUserInfo{
+		ProviderUserID: "oauth-avatar",
+		Email:          "avatar@example.com",
+		AvatarURL:      &avatarURL,
+	})
+
+	require.NoError(t, err)
+	require.Len(t, avatars.jobs, 1)
+	assert.Equal(t, service.AvatarJob{TraceID: "trace-1", UserID: user.ID, SourceURL: avatarURL}, avatars.jobs[0])
+	assert.Nil(t, profiles.profiles[user.ID].AvatarURL)
+}
+
+func TestAuthService_SignIn_PendingDeletionOffersRestore(t *testing.T) {
+	cfg := &config.Config{JWTSecret: "secret", SignedTokenSecret: "restore-secret", JWTTTLMinutes: time.Minute, JWTRefreshTTLMinutes: time.Hour, AccountRestoreTTL: time.Minute}
+	signer, err := service.NewJWTSigner(cfg)
+	require.NoError(t, err)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	users := newFakeUserRepo()
+	user := &domain.User{ID: "user-7", Email: "leaving@example.com", IsActive: true}
+	user.SetPasswordHash(string(hash))
+	user.ScheduleDeletion(time.Now().UTC(), time.Hour)
+	users.users[user.Email] = user
+
+	auth := service.NewAuthService(cfg, pkglog.New("test"), users, newFakeProfileRepo(), newFakeIdentityRepo(), newFakeAccountLinkRepo(), &fakeTarantool{}, newFakeRBACClient(), fakePublisher{}, signer, &fakeAvatarQueue{}, nil, nil, nil, nil)
+
+	_, _, err = auth.SignIn(context.Background(), "trace-1", user.Email, "wrong-password")
+	assert.ErrorIs(t, err, service.ErrInvalidCredentials)
+
+	_, _, err = auth.SignIn(context.Background(), "trace-1", user.Email, "password123")
+	var pending *service.AccountPendingDeletionError
+	require.ErrorAs(t, err, &pending)
+	require.NotEmpty(t, pending.RestoreToken)
+	assert.Equal(t, *user.PurgeAt, pending.PurgeAt)
+
+	restored, tokens, err := auth.RestoreAccount(context.Background(), "trace-1", pending.RestoreToken)
+	require.NoError(t, err)
+	require.NotNil(t, tokens)
+	assert.True(t, restored.IsActive)
+	assert.False(t, restored.IsPendingDeletion())
+
+	// A token belongs to one deletion request and cannot be replayed.
+	user.ScheduleDeletion(time.Now().UTC().Add(time.Second), time.Hour)
+	_, _, err = auth.RestoreAccount(context.Background(), "trace-1", pending.RestoreToken)
+	assert.ErrorIs(t, err, service.ErrRestoreTokenInvalid)
+}
+
+func TestAuthService_SignIn_PasswordResetRequired(t *testing.T) {
+	cfg := &config.Config{JWTSecret: "secret", JWTTTLMinutes: time.Minute, JWTRefreshTTLMinutes: time.Hour}
+	signer, err := service.NewJWTSigner(cfg)
+	require.NoError(t, err)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	users := newFakeUserRepo()
+	user := &domain.User{ID: "user-8", Email: "locked@example.com", IsActive: true}
+	user.SetPasswordHash(string(hash))
+	user.RequirePasswordReset(time.Now().UTC())
+	users.users[user.Email] = user
+
+	auth := service.NewAuthService(cfg, pkglog.New("test"), users, newFakeProfileRepo(), newFakeIdentityRepo(), newFakeAccountLinkRepo(), &fakeTarantool{}, newFakeRBACClient(), fakePublisher{}, signer, &fakeAvatarQueue{}, nil, nil, nil, nil)
+
+	_, _, err = auth.SignIn(context.Background(), "trace-1", user.Email, "wrong-password")
+	assert.ErrorIs(t, err, service.ErrInvalidCredentials)
+	_, _, err = auth.SignIn(context.Background(), "trace-1", user.Email, "password123")
+	assert.ErrorIs(t, err, service.ErrPasswordResetRequired)
+}
+
+func TestAuthService_SignIn_TokenCarriesTenant(t *testing.T) {
+	cfg := &config.Config{JWTSecret: "secret", JWTTTLMinutes: time.Minute, JWTRefreshTTLMinutes: time.Hour}
+	jwtSigner := &recordingJWTSigner{}
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	users := newFakeUserRepo()
+	orgID := "org-acme"
+	user := &domain.User{ID: "user-9", Email: "ada@acme.test", IsActive: true, OrgID: &orgID}
+	user.SetPasswordHash(string(hash))
+	users.users[user.Email] = user
+
+	auth := service.NewAuthService(cfg, pkglog.New("test"), users, newFakeProfileRepo(), newFakeIdentityRepo(), newFakeAccountLinkRepo(), &fakeTarantool{}, newFakeRBACClient(), fakePublisher{}, jwtSigner, &fakeAvatarQueue{}, nil, nil, nil, nil)
+
+	_, _, err = auth.SignIn(context.Background(), "trace-1", user.Email, "password123")
+	require.NoError(t, err)
+	assert.Equal(t, orgID, jwtSigner.claims["org_id"])
+}
+
+func TestAuthService_PasswordResetClearsRequirement(t *testing.T) {
+	cfg := &config.Config{JWTSecret: "secret", SignedTokenSecret: "secret", JWTTTLMinutes: time.Minute, JWTRefreshTTLMinutes: time.Hour, PasswordResetTTL: time.Hour, AppPublicURL: "https://users.example.com"}
+	users := newFakeUserRepo()
+	user := &domain.User{ID: "user-1", Email: "user@example.com", IsActive: true, Version: 1}
+	user.SetPasswordHash("$2a$10$invalid")
+	user.RequirePasswordReset(time.Now().Add(-time.Hour))
+	users.users[user.Email] = user
+	publisher := &recordingPublisher{}
+	signer, err := service.NewJWTSigner(cfg)
+	require.NoError(t, err)
+	auth := service.NewAuthService(cfg, pkglog.New("test"), users, newFakeProfileRepo(), newFakeIdentityRepo(), newFakeAccountLinkRepo(), &fakeTarantool{}, newFakeRBACClient(), publisher, signer, &fakeAvatarQueue{}, nil, nil, nil, nil)
+	ctx := context.Background()
+
+	require.NoError(t, auth.RequestPasswordReset(ctx, "trace", "nobody@example.com"))
+	assert.Empty(t, publisher.keys, "unknown addresses are ignored")
+	require.NoError(t, auth.RequestPasswordReset(ctx, "trace", " User@Example.com "))
+	require.Equal(t, []string{"user.password_reset_requested"}, publisher.keys)
+	link, err := url.Parse(publisher.payloads[0].(events.PasswordResetEvent).ResetURL)
+	require.NoError(t, err)
+	token := link.Query().Get("token")
+
+	assert.ErrorIs(t, auth.ResetPassword(ctx, "trace", "forged", "newpassword1"), service.ErrResetTokenInvalid)
+	require.NoError(t, auth.ResetPassword(ctx, "trace", token, "newpassword1"))
+	assert.False(t, user.PasswordResetRequired)
+	assert.ErrorIs(t, auth.ResetPassword(ctx, "trace", token, "newpassword2"), service.ErrResetTokenInvalid, "links work once")
+
+	_, tokens, err := auth.SignIn(ctx, "trace", "user@example.com", "newpassword1")
+	require.NoError(t, err)
+	assert.NotEmpty(t, tokens.AccessToken)
+}
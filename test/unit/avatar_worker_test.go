@@ -0,0 +1,76 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/example/user-service/config"
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/events"
+	"github.com/example/user-service/internal/service"
+	pkglog "github.com/example/user-service/pkg/log"
+)
+
+type flakyAvatarIngestor struct {
+	mu       sync.Mutex
+	failures int
+	calls    int
+}
+
+func (f *flakyAvatarIngestor) Ingest(ctx context.Context, traceID, userID, avatarURL string) (*service.StoredAvatar, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.calls <= f.failures {
+		return nil, errors.New("cdn unavailable")
+	}
+	return &service.StoredAvatar{
+		URL:        "https://files.example.com/" + userID + "-original.png",
+		Thumbnails: map[string]string{"64": "https://files.example.com/" + userID + "-64.png"},
+	}, nil
+}
+
+type channelPublisher struct {
+	published chan interface{}
+}
+
+func (p channelPublisher) Publish(ctx context.Context, routingKey string, payload interface{}) error {
+	p.published <- payload
+	return nil
+}
+func (channelPublisher) Close() error { return nil }
+
+func TestAvatarWorker_RetriesAndUpdatesProfile(t *testing.T) {
+	cfg := &config.Config{AvatarWorkers: 1, AvatarQueueSize: 4, AvatarIngestAttempts: 3, AvatarRetryInterval: time.Millisecond}
+	profiles := newFakeProfileRepo()
+	profiles.profiles["user-1"] = &domain.UserProfile{ID: "profile-1", UserID: "user-1"}
+	ingestor := &flakyAvatarIngestor{failures: 2}
+	publisher := channelPublisher{published: make(chan interface{}, 1)}
+	worker := service.NewAvatarWorker(cfg, pkglog.New("test"), ingestor, profiles, publisher)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go worker.Run(ctx)
+
+	require.True(t, worker.Enqueue(service.AvatarJob{TraceID: "trace-1", UserID: "user-1", SourceURL: "https://cdn.example.com/a.png"}))
+
+	select {
+	case payload := <-publisher.published:
+		event, ok := payload.(events.UserAvatarEvent)
+		require.True(t, ok)
+		assert.Equal(t, "user.avatar_updated", event.Event)
+		assert.Equal(t, "https://files.example.com/user-1-original.png", event.AvatarURL)
+	case <-time.After(2 * time.Second):
+		t.Fatal("avatar_updated event not published")
+	}
+	assert.Equal(t, 3, ingestor.calls)
+	require.NotNil(t, profiles.profiles["user-1"].AvatarURL)
+	assert.Equal(t, "https://files.example.com/user-1-original.png", *profiles.profiles["user-1"].AvatarURL)
+	assert.Equal(t, "https://files.example.com/user-1-64.png", profiles.profiles["user-1"].AvatarThumbnails["64"])
+}
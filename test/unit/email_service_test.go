@@ -0,0 +1,184 @@
+package unit
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/service"
+	pkglog "github.com/example/user-service/pkg/log"
+)
+
+type fakeUserEmailRepo struct {
+	users  *fakeUserRepo
+	emails map[string]*domain.UserEmail
+}
+
+func newFakeUserEmailRepo(users *fakeUserRepo) *fakeUserEmailRepo {
+	return &fakeUserEmailRepo{users: users, emails: map[string]*domain.UserEmail{}}
+}
+
+func (f *fakeUserEmailRepo) put(email *domain.UserEmail) *domain.UserEmail {
+	email.ID = fmt.Sprintf("00000000-0000-0000-0000-%012d", len(f.emails)+1)
+	f.emails[email.ID] = email
+	return email
+}
+
+func (f *fakeUserEmailRepo) List(ctx context.Context, userID string) ([]domain.UserEmail, error) {
+	var result []domain.UserEmail
+	for _, email := range f.emails {
+		if email.UserID == userID {
+			result = append(result, *email)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, nil
+}
+
+func (f *fakeUserEmailRepo) FindByID(ctx context.Context, userID, id string) (*domain.UserEmail, error) {
+	if email, ok := f.emails[id]; ok && email.UserID == userID {
+		return email, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakeUserEmailRepo) Add(ctx context.Context, email *domain.UserEmail) error {
+	f.put(email)
+	return nil
+}
+
+func (f *fakeUserEmailRepo) MarkVerified(ctx context.Context, userID, address string, at time.Time) (*domain.UserEmail, error) {
+	for _, email := range f.emails {
+		if email.UserID == userID && email.Email == address {
+			email.VerifiedAt = &at
+			return email, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakeUserEmailRepo) SetPrimary(ctx context.Context, userID, id string) (*domain.User, error) {
+	user, err := f.users.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, email := range f.emails {
+		if email.UserID == userID {
+			email.IsPrimary = email.ID == id
+		}
+	}
+	delete(f.users.users, user.Email)
+	user.Email = f.emails[id].Email
+	f.users.users[user.Email] = user
+	return user, nil
+}
+
+func (f *fakeUserEmailRepo) Delete(ctx context.Context, userID, id string) error {
+	delete(f.emails, id)
+	return nil
+}
+
+type emailFixture struct {
+	users  *fakeUserRepo
+	emails *fakeUserEmailRepo
+	svc    service.EmailService
+	user   *domain.User
+}
+
+func newEmailFixture(t *testing.T) *emailFixture {
+	users := newFakeUserRepo()
+	user := &domain.User{Email: "user@example.com", IsActive: true}
+	require.NoError(t, users.Create(context.Background(), user))
+	emails := newFakeUserEmailRepo(users)
+	emails.put(domain.PrimaryEmail(user, time.Now()))
+	return &emailFixture{
+		users:  users,
+		emails: emails,
+		svc:    service.NewEmailService(pkglog.New("test"), users, emails, tarantoolStub{}),
+		user:   user,
+	}
+}
+
+func TestEmailService_AddVerifyAndPromote(t *testing.T) {
+	f := newEmailFixture(t)
+	ctx := context.Background()
+
+	uuid, err := f.svc.Add(ctx, f.user.ID, " New@Example.com ")
+	require.NoError(t, err)
+	assert.Equal(t, "uuid-change", uuid)
+
+	emails, err := f.svc.List(ctx, f.user.ID)
+	require.NoError(t, err)
+	require.Len(t, emails, 2)
+	added := emails[1]
+	assert.Equal(t, "new@example.com", added.Email)
+	assert.False(t, added.IsVerified())
+
+	_, err = f.svc.MakePrimary(ctx, f.user.ID, added.ID)
+	assert.ErrorIs(t, err, service.ErrEmailUnverified)
+
+	verified, err := f.svc.Verify(ctx, f.user.ID, uuid, "1234")
+	require.NoError(t, err)
+	assert.True(t, verified.IsVerified())
+
+	user, err := f.svc.MakePrimary(ctx, f.user.ID, added.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "new@example.com", user.Email)
+
+	assert.ErrorIs(t, f.svc.Remove(ctx, f.user.ID, added.ID), service.ErrEmailPrimary)
+	require.NoError(t, f.svc.Remove(ctx, f.user.ID, emails[0].ID))
+}
+
+func TestEmailService_AddRejectsTakenAddresses(t *testing.T) {
+	f := newEmailFixture(t)
+	ctx := context.Background()
+	f.users.users["other@example.com"] = &domain.User{ID: "user-2", Email: "other@example.com"}
+
+	_, err := f.svc.Add(ctx, f.user.ID, "other@example.com")
+	assert.ErrorIs(t, err, service.ErrEmailTaken)
+	_, err = f.svc.Add(ctx, f.user.ID, "user@example.com")
+	assert.ErrorIs(t, err, service.ErrEmailTaken)
+	_, err = f.svc.Add(ctx, f.user.ID, "not-an-address")
+	assert.Error(t, err)
+}
+
+func TestEmailService_AddPendingAgainResendsCode(t *testing.T) {
+	f := newEmailFixture(t)
+	ctx := context.Background()
+
+	_, err := f.svc.Add(ctx, f.user.ID, "new@example.com")
+	require.NoError(t, err)
+	_, err = f.svc.Add(ctx, f.user.ID, "new@example.com")
+	require.NoError(t, err)
+	assert.Len(t, f.emails.emails, 2)
+}
+
+func TestEmailService_AddEnforcesLimit(t *testing.T) {
+	f := newEmailFixture(t)
+	ctx := context.Background()
+	for i := 1; i < service.MaxUserEmails; i++ {
+		_, err := f.svc.Add(ctx, f.user.ID, fmt.Sprintf("alias%d@example.com", i))
+		require.NoError(t, err)
+	}
+
+	_, err := f.svc.Add(ctx, f.user.ID, "one-more@example.com")
+	assert.ErrorIs(t, err, service.ErrEmailLimit)
+}
+
+func TestEmailService_UnknownAddress(t *testing.T) {
+	f := newEmailFixture(t)
+	ctx := context.Background()
+
+	_, err := f.svc.MakePrimary(ctx, f.user.ID, "not-a-uuid")
+	assert.ErrorIs(t, err, service.ErrEmailNotFound)
+	assert.ErrorIs(t, f.svc.Remove(ctx, "user-2", "00000000-0000-0000-0000-000000000001"), service.ErrEmailNotFound)
+	_, err = f.svc.Verify(ctx, f.user.ID, "uuid-change", "1234")
+	assert.ErrorIs(t, err, service.ErrEmailNotFound)
+}
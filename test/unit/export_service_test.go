@@ -0,0 +1,253 @@
+package unit
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/example/user-service/config"
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/service"
+	"github.com/example/user-service/internal/tenant"
+	pkglog "github.com/example/user-service/pkg/log"
+)
+
+type fakeAuditRepo struct {
+	events []domain.AuditEvent
+}
+
+func (f *fakeAuditRepo) Record(ctx context.Context, event *domain.AuditEvent) error {
+	event.CreatedAt = time.Now().UTC()
+	f.events = append(f.events, *event)
+	return nil
+}
+
+func (f *fakeAuditRepo) ListByUserID(ctx context.Context, userID string) ([]domain.AuditEvent, error) {
+	var result []domain.AuditEvent
+	for _, event := range f.events {
+		if event.UserID == userID {
+			result = append(result, event)
+		}
+	}
+	return result, nil
+}
+
+type fakeExportRepo struct {
+	exports    map[string]*domain.DataExport
+	updateErrs []error
+}
+
+func newFakeExportRepo() *fakeExportRepo {
+	return &fakeExportRepo{exports: map[string]*domain.DataExport{}}
+}
+
+func (f *fakeExportRepo) Create(ctx context.Context, export *domain.DataExport) error {
+	export.ID = "export-1"
+	export.CreatedAt = time.Now().UTC()
+	copied := *export
+	f.exports[export.ID] = &copied
+	return nil
+}
+
+func (f *fakeExportRepo) Update(ctx context.Context, export *domain.DataExport) error {
+	if len(f.updateErrs) > 0 {
+		err := f.updateErrs[0]
+		f.updateErrs = f.updateErrs[1:]
+		if err != nil {
+			return err
+		}
+	}
+	copied := *export
+	f.exports[export.ID] = &copied
+	return nil
+}
+
+func (f *fakeExportRepo) FindByID(ctx context.Context, id string) (*domain.DataExport, error) {
+	if export, ok := f.exports[id]; ok {
+		copied := *export
+		return &copied, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func claimable(export *domain.DataExport, staleBefore time.Time) bool {
+	if export.Status == domain.ExportPending {
+		return true
+	}
+	return export.Status == domain.ExportRunning && export.ClaimedAt != nil && export.ClaimedAt.Before(staleBefore)
+}
+
+func (f *fakeExportRepo) ListPending(ctx context.Context, staleBefore time.Time, limit int) ([]domain.DataExport, error) {
+	var result []domain.DataExport
+	for _, export := range f.exports {
+		if claimable(export, staleBefore) {
+			result = append(result, *export)
+		}
+	}
+	return result, nil
+}
+
+func (f *fakeExportRepo) Claim(ctx context.Context, id string, now, staleBefore time.Time) (bool, error) {
+	export, ok := f.exports[id]
+	if !ok || !claimable(export, staleBefore) {
+		return false, nil
+	}
+	export.Status = domain.ExportRunning
+	export.ClaimedAt = &now
+	return true, nil
+}
+
+func (f *fakeExportRepo) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	var deleted int64
+	for id, export := range f.exports {
+		if export.ExpiresAt != nil && !export.ExpiresAt.After(now) {
+			delete(f.exports, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func TestExportService_BuildsVersionedArchive(t *testing.T) {
+	cfg := &config.Config{SignedTokenSecret: "secret", AppPublicURL: "https://users.example.com", DataExportTTL: time.Hour}
+	users := newUserRepoStub()
+	display := "Ada"
+	phone := "+4915112345678"
+	users.users["user-1"].Profile = &domain.UserProfile{UserID: "user-1", DisplayName: &display}
+	users.users["user-1"].Phone = &phone
+	users.users["user-1"].PhoneMFA = true
+	identities := newFakeIdentityRepo()
+	identities.identities[identities.key(domain.ProviderGitHub, "gh-1")] = &domain.UserIdentity{
+		UserID:         "user-1",
+		Provider:       domain.ProviderGitHub,
+		ProviderUserID: "gh-1",
+		Metadata:       domain.JSONMap{"login": "ada"},
+	}
+	audit := &fakeAuditRepo{}
+	exports := newFakeExportRepo()
+	emails := newFakeUserEmailRepo(newFakeUserRepo())
+	emails.put(&domain.UserEmail{UserID: "user-1", Email: "user@example.com", IsPrimary: true})
+	emails.put(&domain.UserEmail{UserID: "user-1", Email: "work@example.com"})
+	prefs := newFakePreferenceRepo()
+	prefs.prefs["user-1"] = &domain.UserPreferences{UserID: "user-1", Values: domain.JSONMap{"language": "de"}}
+	preferences := service.NewPreferenceService(pkglog.New("test"), prefs, nil)
+	svc := service.NewExportService(cfg, pkglog.New("test"), users, identities, emails, preferences, audit, exports)
+
+	export, err := svc.RequestExport(context.Background(), "trace", "user-1", "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, domain.ExportPending, export.Status)
+	assert.Empty(t, svc.DownloadURL(export))
+
+	processed, err := svc.ProcessPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, processed)
+
+	ready, err := svc.GetExport(context.Background(), export.ID)
+	require.NoError(t, err)
+	require.Equal(t, domain.ExportReady, ready.Status)
+
+	link, err := url.Parse(svc.DownloadURL(ready))
+	require.NoError(t, err)
+	assert.Equal(t, "users.example.com", link.Host)
+	assert.Equal(t, "/exports/download", link.Path)
+
+	downloaded, err := svc.Download(context.Background(), "trace", link.Query().Get("token"))
+	require.NoError(t, err)
+
+	zr, err := zip.NewReader(bytes.NewReader(downloaded.Archive), int64(len(downloaded.Archive)))
+	require.NoError(t, err)
+	require.Len(t, zr.File, 1)
+	f, err := zr.File[0].Open()
+	require.NoError(t, err)
+	raw, err := io.ReadAll(f)
+	require.NoError(t, err)
+
+	var archive service.ExportArchive
+	require.NoError(t, json.Unmarshal(raw, &archive))
+	assert.Equal(t, service.ExportFormatVersion, archive.FormatVersion)
+	assert.Equal(t, "user-1", archive.User.ID)
+	assert.Equal(t, &phone, archive.User.Phone)
+	assert.True(t, archive.User.PhoneMFA)
+	require.Len(t, archive.Emails, 2)
+	assert.Equal(t, "work@example.com", archive.Emails[1].Email)
+	assert.Equal(t, "de", archive.Preferences["language"])
+	assert.Equal(t, true, archive.Preferences["notifications.email"], "defaults are included")
+	require.NotNil(t, archive.Profile)
+	assert.Equal(t, "Ada", *archive.Profile.DisplayName)
+	require.Len(t, archive.Identities, 1)
+	assert.Equal(t, "ada", archive.Identities[0].Metadata["login"])
+	require.Len(t, archive.AuditEvents, 1)
+	assert.Equal(t, domain.AuditExportRequested, archive.AuditEvents[0].Action)
+
+	_, err = svc.Download(context.Background(), "trace", "tampered")
+	assert.ErrorIs(t, err, service.ErrExportLinkInvalid)
+
+	purged, err := svc.PurgeExpired(context.Background(), time.Now().Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+}
+
+func TestExportService_GetExportHidesOtherTenants(t *testing.T) {
+	cfg := &config.Config{SignedTokenSecret: "secret", AppPublicURL: "https://users.example.com", DataExportTTL: time.Hour}
+	users := newUserRepoStub()
+	orgB := "00000000-0000-0000-0000-00000000000b"
+	users.users["user-1"].OrgID = &orgB
+	exports := newFakeExportRepo()
+	svc := service.NewExportService(cfg, pkglog.New("test"), users, newFakeIdentityRepo(), nil, nil, nil, exports)
+
+	export, err := svc.RequestExport(tenant.With(context.Background(), orgB), "trace", "admin-b", "user-1")
+	require.NoError(t, err)
+
+	_, err = svc.GetExport(tenant.With(context.Background(), "00000000-0000-0000-0000-00000000000a"), export.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	found, err := svc.GetExport(tenant.With(context.Background(), orgB), export.ID)
+	require.NoError(t, err)
+	assert.Equal(t, export.ID, found.ID)
+}
+
+func TestExportService_ReclaimsStaleRunningExports(t *testing.T) {
+	cfg := &config.Config{SignedTokenSecret: "secret", AppPublicURL: "https://users.example.com", DataExportTTL: time.Hour, DataExportClaimTimeout: 15 * time.Minute}
+	users := newUserRepoStub()
+	exports := newFakeExportRepo()
+	stale := time.Now().UTC().Add(-time.Hour)
+	fresh := time.Now().UTC().Add(-time.Minute)
+	exports.exports["stale"] = &domain.DataExport{ID: "stale", UserID: "user-1", Status: domain.ExportRunning, ClaimedAt: &stale}
+	exports.exports["fresh"] = &domain.DataExport{ID: "fresh", UserID: "user-1", Status: domain.ExportRunning, ClaimedAt: &fresh}
+	svc := service.NewExportService(cfg, pkglog.New("test"), users, newFakeIdentityRepo(), newFakeUserEmailRepo(newFakeUserRepo()), nil, &fakeAuditRepo{}, exports)
+
+	processed, err := svc.ProcessPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, processed)
+	assert.Equal(t, domain.ExportReady, exports.exports["stale"].Status)
+	assert.Equal(t, domain.ExportRunning, exports.exports["fresh"].Status, "a live claim is left to its worker")
+}
+
+func TestExportService_FailsExportThatCannotBeSaved(t *testing.T) {
+	cfg := &config.Config{SignedTokenSecret: "secret", AppPublicURL: "https://users.example.com", DataExportTTL: time.Hour, DataExportClaimTimeout: 15 * time.Minute}
+	exports := newFakeExportRepo()
+	exports.updateErrs = []error{errors.New("value too large")}
+	svc := service.NewExportService(cfg, pkglog.New("test"), newUserRepoStub(), newFakeIdentityRepo(), newFakeUserEmailRepo(newFakeUserRepo()), nil, &fakeAuditRepo{}, exports)
+
+	export, err := svc.RequestExport(context.Background(), "trace", "user-1", "user-1")
+	require.NoError(t, err)
+
+	processed, err := svc.ProcessPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, processed)
+
+	failed := exports.exports[export.ID]
+	assert.Equal(t, domain.ExportFailed, failed.Status)
+	assert.Empty(t, failed.Archive)
+	require.NotNil(t, failed.Error)
+}
@@ -0,0 +1,297 @@
+package unit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"github.com/example/user-service/config"
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/repo"
+	"github.com/example/user-service/internal/service"
+	"github.com/example/user-service/internal/tenant"
+	pkglog "github.com/example/user-service/pkg/log"
+)
+
+type fakeGroupRepo struct {
+	groups  map[string]*domain.Group
+	members map[string]domain.GroupMember
+	nesting map[string][]string
+	// visibility is the profile visibility of users by ID.
+	visibility map[string]domain.JSONMap
+}
+
+func newFakeGroupRepo() *fakeGroupRepo {
+	return &fakeGroupRepo{groups: map[string]*domain.Group{}, members: map[string]domain.GroupMember{}, nesting: map[string][]string{}, visibility: map[string]domain.JSONMap{}}
+}
+
+func (f *fakeGroupRepo) Create(ctx context.Context, group *domain.Group, owner *domain.GroupMember) error {
+	group.ID = fmt.Sprintf("00000000-0000-0000-0000-%012d", len(f.groups)+1)
+	f.groups[group.ID] = group
+	owner.GroupID = group.ID
+	f.members[group.ID+"/"+owner.UserID] = *owner
+	return nil
+}
+
+func (f *fakeGroupRepo) Update(ctx context.Context, group *domain.Group) error {
+	f.groups[group.ID] = group
+	return nil
+}
+
+func (f *fakeGroupRepo) Delete(ctx context.Context, id string) error {
+	delete(f.groups, id)
+	return nil
+}
+
+func (f *fakeGroupRepo) FindByID(ctx context.Context, id string) (*domain.Group, error) {
+	if group, ok := f.groups[id]; ok {
+		return group, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakeGroupRepo) FindMember(ctx context.Context, groupID, userID string) (*domain.GroupMember, error) {
+	if member, ok := f.members[groupID+"/"+userID]; ok {
+		return &member, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakeGroupRepo) SaveMember(ctx context.Context, member *domain.GroupMember) (bool, error) {
+	if member.Role != domain.GroupRoleOwner && f.isLastOwner(member.GroupID, member.UserID) {
+		return false, repo.ErrGroupLastOwner
+	}
+	_, exists := f.members[member.GroupID+"/"+member.UserID]
+	f.members[member.GroupID+"/"+member.UserID] = *member
+	return !exists, nil
+}
+
+func (f *fakeGroupRepo) DeleteMember(ctx context.Context, groupID, userID string) error {
+	if _, ok := f.members[groupID+"/"+userID]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	if f.isLastOwner(groupID, userID) {
+		return repo.ErrGroupLastOwner
+	}
+	delete(f.members, groupID+"/"+userID)
+	return nil
+}
+
+func (f *fakeGroupRepo) isLastOwner(groupID, userID string) bool {
+	var owners []string
+	for _, member := range f.members {
+		if member.GroupID == groupID && member.Role == domain.GroupRoleOwner {
+			owners = append(owners, member.UserID)
+		}
+	}
+	return len(owners) == 1 && owners[0] == userID
+}
+
+// ListMembers ignores nesting and paging. Emails are derived from the user
+// ID and profile visibility is taken from visibility.
+func (f *fakeGroupRepo) ListMembers(ctx context.Context, groupID string, effective bool, offset, limit int) ([]repo.GroupMemberEntry, error) {
+	var entries []repo.GroupMemberEntry
+	for _, member := range f.members {
+		if member.GroupID == groupID {
+			entries = append(entries, repo.GroupMemberEntry{UserID: member.UserID, Email: member.UserID + "@example.com", Role: member.Role, Visibility: f.visibility[member.UserID]})
+		}
+	}
+	return entries, nil
+}
+
+// ListUserGroups ignores paging and follows nesting upwards when effective.
+func (f *fakeGroupRepo) ListUserGroups(ctx context.Context, userID string, effective bool, offset, limit int) ([]domain.Group, error) {
+	seen := map[string]bool{}
+	var queue []string
+	for _, member := range f.members {
+		if member.UserID == userID {
+			queue = append(queue, member.GroupID)
+		}
+	}
+	var groups []domain.Group
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		groups = append(groups, *f.groups[id])
+		if effective {
+			for parent, children := range f.nesting {
+				for _, child := range children {
+					if child == id {
+						queue = append(queue, parent)
+					}
+				}
+			}
+		}
+	}
+	return groups, nil
+}
+
+func (f *fakeGroupRepo) Nest(ctx context.Context, parentID, childID string) error {
+	if parentID == childID {
+		return repo.ErrGroupCycle
+	}
+	for _, grandchild := range f.nesting[childID] {
+		if grandchild == parentID {
+			return repo.ErrGroupCycle
+		}
+	}
+	f.nesting[parentID] = append(f.nesting[parentID], childID)
+	return nil
+}
+
+func (f *fakeGroupRepo) Unnest(ctx context.Context, parentID, childID string) error {
+	for i, child := range f.nesting[parentID] {
+		if child == childID {
+			f.nesting[parentID] = append(f.nesting[parentID][:i], f.nesting[parentID][i+1:]...)
+			return nil
+		}
+	}
+	return gorm.ErrRecordNotFound
+}
+
+func (f *fakeGroupRepo) ListSubgroups(ctx context.Context, groupID string) ([]domain.Group, error) {
+	return nil, nil
+}
+
+func groupFixture(t *testing.T) (service.GroupService, *fakeGroupRepo, *recordingPublisher, *fakeAuditRepo) {
+	t.Helper()
+	groups := newFakeGroupRepo()
+	users := newUserRepoStub()
+	for _, id := range []string{"owner-1", "member-1"} {
+		users.users[id] = &domain.User{ID: id, Email: id + "@example.com"}
+	}
+	otherOrg := "org-other"
+	users.users["outsider"] = &domain.User{ID: "outsider", Email: "outsider@example.com", OrgID: &otherOrg}
+	rbacClient := newFakeRBACClient()
+	rbacClient.assignments["admin-1"] = "admin"
+	publisher := &recordingPublisher{}
+	audit := &fakeAuditRepo{}
+	return service.NewGroupService(pkglog.New("test"), groups, users, rbacClient, audit, publisher), groups, publisher, audit
+}
+
+func TestGroupService_MembershipRequiresOwner(t *testing.T) {
+	svc, groups, publisher, audit := groupFixture(t)
+	ctx := tenant.With(context.Background(), tenant.Default)
+
+	group, err := svc.Create(ctx, "trace", "owner-1", "  Platform team ", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Platform team", group.Name)
+	owner, err := groups.FindMember(ctx, group.ID, "owner-1")
+	require.NoError(t, err)
+	assert.Equal(t, domain.GroupRoleOwner, owner.Role)
+
+	_, err = svc.AddMember(ctx, "trace", "member-1", group.ID, "member-1", domain.GroupRoleMember)
+	assert.ErrorIs(t, err, service.ErrGroupForbidden)
+	_, err = svc.AddMember(ctx, "trace", "owner-1", group.ID, "outsider", domain.GroupRoleMember)
+	assert.ErrorIs(t, err, service.ErrGroupUserAbsent)
+
+	member, err := svc.AddMember(ctx, "trace", "owner-1", group.ID, "member-1", "")
+	require.NoError(t, err)
+	assert.Equal(t, domain.GroupRoleMember, member.Role)
+	// Changing the role of an existing member is not a new membership.
+	_, err = svc.AddMember(ctx, "trace", "admin-1", group.ID, "member-1", domain.GroupRoleMember)
+	require.NoError(t, err)
+
+	// Members may leave on their own.
+	require.NoError(t, svc.RemoveMember(ctx, "trace", "member-1", group.ID, "member-1"))
+	assert.ErrorIs(t, svc.RemoveMember(ctx, "trace", "owner-1", group.ID, "member-1"), service.ErrGroupMemberNotFound)
+
+	assert.Equal(t, []string{"group.member_added", "group.member_added", "group.member_removed"}, publisher.keys)
+	require.Len(t, audit.events, 3)
+	assert.Equal(t, domain.AuditGroupMemberRemoved, audit.events[2].Action)
+}
+
+func TestGroupService_ListMembersHidesPrivateEmails(t *testing.T) {
+	svc, groups, _, _ := groupFixture(t)
+	ctx := context.Background()
+	group, err := svc.Create(ctx, "trace", "owner-1", "Ops", nil)
+	require.NoError(t, err)
+	_, err = svc.AddMember(ctx, "trace", "owner-1", group.ID, "member-1", domain.GroupRoleMember)
+	require.NoError(t, err)
+	groups.visibility["owner-1"] = domain.JSONMap{"email": "public"}
+
+	emails := func(actorID string) map[string]string {
+		members, err := svc.ListMembers(ctx, actorID, group.ID, false, 0, 0)
+		require.NoError(t, err)
+		result := map[string]string{}
+		for _, member := range members {
+			result[member.UserID] = member.Email
+		}
+		return result
+	}
+	assert.Equal(t, map[string]string{"owner-1": "owner-1@example.com", "member-1": ""}, emails("viewer-1"))
+	assert.Equal(t, "member-1@example.com", emails("member-1")["member-1"], "members see their own email")
+	assert.Equal(t, "member-1@example.com", emails("owner-1")["member-1"], "owners see every email")
+	assert.Equal(t, "member-1@example.com", emails("admin-1")["member-1"], "users:read sees every email")
+}
+
+func TestGroupService_KeepsLastOwner(t *testing.T) {
+	svc, _, _, _ := groupFixture(t)
+	ctx := context.Background()
+	group, err := svc.Create(ctx, "trace", "owner-1", "Ops", nil)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, svc.RemoveMember(ctx, "trace", "owner-1", group.ID, "owner-1"), service.ErrGroupLastOwner)
+	_, err = svc.AddMember(ctx, "trace", "owner-1", group.ID, "owner-1", domain.GroupRoleMember)
+	assert.ErrorIs(t, err, service.ErrGroupLastOwner)
+
+	_, err = svc.AddMember(ctx, "trace", "owner-1", group.ID, "member-1", domain.GroupRoleOwner)
+	require.NoError(t, err)
+	assert.NoError(t, svc.RemoveMember(ctx, "trace", "owner-1", group.ID, "owner-1"))
+}
+
+func TestGroupService_NestedGroups(t *testing.T) {
+	svc, _, _, _ := groupFixture(t)
+	ctx := context.Background()
+	parent, err := svc.Create(ctx, "trace", "owner-1", "Engineering", nil)
+	require.NoError(t, err)
+	child, err := svc.Create(ctx, "trace", "member-1", "Backend", nil)
+	require.NoError(t, err)
+
+	// Nesting needs ownership of both groups.
+	assert.ErrorIs(t, svc.AddSubgroup(ctx, "trace", "owner-1", parent.ID, child.ID), service.ErrGroupForbidden)
+	require.NoError(t, svc.AddSubgroup(ctx, "trace", "admin-1", parent.ID, child.ID))
+	assert.ErrorIs(t, svc.AddSubgroup(ctx, "trace", "admin-1", child.ID, parent.ID), service.ErrGroupCycle)
+
+	direct, err := svc.ListUserGroups(ctx, "member-1", false, 0, 0)
+	require.NoError(t, err)
+	assert.Len(t, direct, 1)
+	ids, err := svc.GroupIDs(ctx, "member-1")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{parent.ID, child.ID}, ids)
+
+	require.NoError(t, svc.RemoveSubgroup(ctx, "trace", "owner-1", parent.ID, child.ID))
+	assert.ErrorIs(t, svc.RemoveSubgroup(ctx, "trace", "owner-1", parent.ID, child.ID), service.ErrSubgroupNotFound)
+	_, err = svc.Get(ctx, "not-a-uuid")
+	assert.ErrorIs(t, err, service.ErrGroupNotFound)
+}
+
+func TestAuthService_SignIn_GroupsClaim(t *testing.T) {
+	cfg := &config.Config{JWTSecret: "secret", JWTTTLMinutes: time.Minute, JWTRefreshTTLMinutes: time.Hour, JWTGroupsClaim: true}
+	jwtSigner := &recordingJWTSigner{}
+	users := newFakeUserRepo()
+	user := &domain.User{ID: "member-1", Email: "member-1@example.com", IsActive: true}
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	user.SetPasswordHash(string(hash))
+	users.users[user.Email] = user
+	groups, _, _, _ := groupFixture(t)
+	group, err := groups.Create(context.Background(), "trace", "member-1", "Support", nil)
+	require.NoError(t, err)
+
+	auth := service.NewAuthService(cfg, pkglog.New("test"), users, newFakeProfileRepo(), newFakeIdentityRepo(), newFakeAccountLinkRepo(), &fakeTarantool{}, newFakeRBACClient(), fakePublisher{}, jwtSigner, &fakeAvatarQueue{}, groups, nil, nil, nil)
+
+	_, _, err = auth.SignIn(context.Background(), "trace-1", user.Email, "password123")
+	require.NoError(t, err)
+	assert.Equal(t, []string{group.ID}, jwtSigner.claims["groups"])
+}
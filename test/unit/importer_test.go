@@ -0,0 +1,170 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/importer"
+	pkglog "github.com/example/user-service/pkg/log"
+)
+
+type fakeImportStore struct {
+	committed []domain.User
+	batches   int
+}
+
+func (s *fakeImportStore) Begin(ctx context.Context) (importer.Batch, error) {
+	s.batches++
+	return &fakeImportBatch{store: s}, nil
+}
+
+type fakeImportBatch struct {
+	store   *fakeImportStore
+	pending []domain.User
+}
+
+func (b *fakeImportBatch) Insert(ctx context.Context, user *domain.User, identities []domain.UserIdentity) error {
+	for _, existing := range append(b.store.committed, b.pending...) {
+		if existing.Email == user.Email {
+			return importer.ErrDuplicate
+		}
+	}
+	user.ID = "id-" + user.Email
+	b.pending = append(b.pending, *user)
+	return nil
+}
+
+func (b *fakeImportBatch) Commit() error {
+	b.store.committed = append(b.store.committed, b.pending...)
+	return nil
+}
+
+func (b *fakeImportBatch) Rollback() error { return nil }
+
+func bcryptHash(t *testing.T) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	return string(hash)
+}
+
+func reportRows(t *testing.T, report *bytes.Buffer) map[int]string {
+	t.Helper()
+	rows := map[int]string{}
+	dec := json.NewDecoder(report)
+	for dec.More() {
+		var entry importer.ReportEntry
+		require.NoError(t, dec.Decode(&entry))
+		rows[entry.Row] = entry.Error
+	}
+	return rows
+}
+
+func TestImporter_CSV(t *testing.T) {
+	hash := bcryptHash(t)
+	input := strings.Join([]string{
+		"email,username,password_hash,is_active,first_name,locale,identities",
+		"Ada@Example.com,ada_l," + hash + ",true,Ada,en-gb,google:123",
+		"grace@example.com,,,false,,,github:42",
+		"bad-email,,," + "true,,,",
+		"ada@example.com,,," + "true,,,google:999",
+		"linus@example.com,,plaintext,true,,,",
+		"nobody@example.com,,,true,,,",
+		"eve@example.com,,,true,,xx-!!,google:7",
+	}, "\n")
+	store := &fakeImportStore{}
+	var report bytes.Buffer
+	im := importer.New(pkglog.New("test"), store, nil, nil, &report)
+
+	summary, err := im.Run(context.Background(), strings.NewReader(input), importer.Options{Format: importer.FormatCSV, BatchSize: 3})
+	require.NoError(t, err)
+	assert.Equal(t, importer.Summary{Read: 7, Imported: 2, Failed: 5}, *summary)
+	assert.Equal(t, 3, store.batches)
+
+	require.Len(t, store.committed, 2)
+	ada := store.committed[0]
+	assert.Equal(t, "ada@example.com", ada.Email)
+	assert.Equal(t, "adal", *ada.UsernameCanonical)
+	assert.Equal(t, "en-GB", *ada.Profile.Locale)
+	assert.False(t, store.committed[1].IsActive)
+
+	failures := reportRows(t, &report)
+	assert.Equal(t, []int{3, 4, 5, 6, 7}, keys(failures))
+	assert.Equal(t, importer.ErrDuplicate.Error(), failures[4])
+	assert.Contains(t, failures[5], "unsupported password hash")
+	assert.Contains(t, failures[7], "locale")
+}
+
+func TestImporter_JSONLDryRunAndCheckpoint(t *testing.T) {
+	hash := bcryptHash(t)
+	lines := []string{
+		`{"email":"one@example.com","password_hash":"` + hash + `","profile":{"company":"Acme"}}`,
+		`{"email":"two@example.com","identities":[{"provider":"github","provider_user_id":"2"}]}`,
+		`{"email":"three@example.com","unknown":true}`,
+		`{"email":"four@example.com","password_hash":"` + hash + `"}`,
+	}
+	input := strings.Join(lines, "\n")
+	checkpoint := filepath.Join(t.TempDir(), "import.checkpoint")
+
+	store := &fakeImportStore{}
+	var report bytes.Buffer
+	im := importer.New(pkglog.New("test"), store, nil, nil, &report)
+	summary, err := im.Run(context.Background(), strings.NewReader(input), importer.Options{Format: importer.FormatJSONL, BatchSize: 2, DryRun: true, Checkpoint: checkpoint, Source: "users.jsonl"})
+	require.NoError(t, err)
+	assert.Equal(t, 3, summary.Imported)
+	assert.Empty(t, store.committed)
+	assert.NoFileExists(t, checkpoint)
+
+	// The first run stops after two rows; the rerun resumes with row three.
+	summary, err = im.Run(context.Background(), strings.NewReader(strings.Join(lines[:2], "\n")), importer.Options{Format: importer.FormatJSONL, BatchSize: 2, Checkpoint: checkpoint, Source: "users.jsonl"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, summary.Imported)
+	data, err := os.ReadFile(checkpoint)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"source":"users.jsonl","row":2}`, string(data))
+
+	summary, err = im.Run(context.Background(), strings.NewReader(input), importer.Options{Format: importer.FormatJSONL, BatchSize: 2, Checkpoint: checkpoint, Source: "users.jsonl"})
+	require.NoError(t, err)
+	assert.Equal(t, importer.Summary{Read: 4, Skipped: 2, Imported: 1, Failed: 1}, *summary)
+	assert.Len(t, store.committed, 3)
+
+	_, err = im.Run(context.Background(), strings.NewReader(input), importer.Options{Format: importer.FormatJSONL, Checkpoint: checkpoint, Source: "other.jsonl"})
+	assert.Error(t, err)
+}
+
+func TestImporter_EmitsEventsOnlyWhenRequested(t *testing.T) {
+	hash := bcryptHash(t)
+	input := `{"email":"one@example.com","password_hash":"` + hash + `"}`
+	publisher := &recordingPublisher{}
+	var report bytes.Buffer
+
+	im := importer.New(pkglog.New("test"), &fakeImportStore{}, nil, publisher, &report)
+	_, err := im.Run(context.Background(), strings.NewReader(input), importer.Options{Format: importer.FormatJSONL})
+	require.NoError(t, err)
+	assert.Empty(t, publisher.keys)
+
+	im = importer.New(pkglog.New("test"), &fakeImportStore{}, nil, publisher, &report)
+	_, err = im.Run(context.Background(), strings.NewReader(input), importer.Options{Format: importer.FormatJSONL, EmitEvents: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user.created"}, publisher.keys)
+}
+
+func keys(m map[int]string) []int {
+	var result []int
+	for i := 1; i <= 100; i++ {
+		if _, ok := m[i]; ok {
+			result = append(result, i)
+		}
+	}
+	return result
+}
@@ -0,0 +1,234 @@
+package unit
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/example/user-service/config"
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/events"
+	"github.com/example/user-service/internal/repo"
+	"github.com/example/user-service/internal/service"
+	"github.com/example/user-service/internal/tenant"
+	pkglog "github.com/example/user-service/pkg/log"
+)
+
+type fakeInvitationRepo struct {
+	invitations map[string]*domain.Invitation
+	users       *fakeUserRepo
+	// revokeOnAccept simulates a Revoke racing with the acceptance.
+	revokeOnAccept bool
+}
+
+func newFakeInvitationRepo() *fakeInvitationRepo {
+	return &fakeInvitationRepo{invitations: map[string]*domain.Invitation{}}
+}
+
+func (f *fakeInvitationRepo) Create(ctx context.Context, invitation *domain.Invitation) error {
+	invitation.ID = fmt.Sprintf("00000000-0000-0000-0000-%012d", len(f.invitations)+1)
+	f.invitations[invitation.ID] = invitation
+	return nil
+}
+
+func (f *fakeInvitationRepo) Update(ctx context.Context, invitation *domain.Invitation) error {
+	f.invitations[invitation.ID] = invitation
+	return nil
+}
+
+func (f *fakeInvitationRepo) FindByID(ctx context.Context, id string) (*domain.Invitation, error) {
+	if invitation, ok := f.invitations[id]; ok {
+		copied := *invitation
+		return &copied, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakeInvitationRepo) FindPending(ctx context.Context, email string, now time.Time) (*domain.Invitation, error) {
+	for _, invitation := range f.invitations {
+		if invitation.Email == email && invitation.Status(now) == domain.InvitationPending {
+			return invitation, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakeInvitationRepo) List(ctx context.Context, status domain.InvitationStatus, now time.Time, offset, limit int) ([]domain.Invitation, error) {
+	var result []domain.Invitation
+	for _, invitation := range f.invitations {
+		if status == "" || invitation.Status(now) == status {
+			result = append(result, *invitation)
+		}
+	}
+	return result, nil
+}
+
+func (f *fakeInvitationRepo) AcceptNewUser(ctx context.Context, id string, user *domain.User, identities []domain.UserIdentity, now time.Time, grant func(userID string) error) error {
+	invitation, ok := f.invitations[id]
+	if ok && f.revokeOnAccept {
+		invitation.RevokedAt = &now
+	}
+	if !ok || invitation.AcceptedAt != nil || invitation.RevokedAt != nil {
+		return repo.ErrInvitationClosed
+	}
+	if err := f.users.Create(ctx, user); err != nil {
+		return err
+	}
+	if err := grant(user.ID); err != nil {
+		delete(f.users.users, user.Email)
+		return err
+	}
+	invitation.AcceptedAt = &now
+	invitation.AcceptedBy = &user.ID
+	return nil
+}
+
+type invitationFixture struct {
+	invitations service.InvitationService
+	auth        service.AuthService
+	repo        *fakeInvitationRepo
+	users       *fakeUserRepo
+	rbac        *fakeRBACClient
+	publisher   *recordingPublisher
+}
+
+func newInvitationFixture(t *testing.T) *invitationFixture {
+	t.Helper()
+	cfg := &config.Config{JWTSecret: "secret", SignedTokenSecret: "secret", JWTTTLMinutes: time.Minute, JWTRefreshTTLMinutes: time.Hour, InvitationTTL: time.Hour, InvitationRoles: []string{"member", "support"}, AppPublicURL: "https://users.example.com/"}
+	f := &invitationFixture{repo: newFakeInvitationRepo(), users: newFakeUserRepo(), rbac: newFakeRBACClient(), publisher: &recordingPublisher{}}
+	f.repo.users = f.users
+	signer, err := service.NewJWTSigner(cfg)
+	require.NoError(t, err)
+	f.invitations = service.NewInvitationService(cfg, pkglog.New("test"), f.repo, f.users, newFakeOrgRepo(), f.rbac, f.publisher)
+	f.auth = service.NewAuthService(cfg, pkglog.New("test"), f.users, newFakeProfileRepo(), newFakeIdentityRepo(), newFakeAccountLinkRepo(), &fakeTarantool{}, f.rbac, f.publisher, signer, &fakeAvatarQueue{}, nil, f.repo, nil, nil)
+	return f
+}
+
+// lastToken returns the token of the most recently sent invitation link.
+func (f *invitationFixture) lastToken(t *testing.T) string {
+	t.Helper()
+	for i := len(f.publisher.payloads) - 1; i >= 0; i-- {
+		if event, ok := f.publisher.payloads[i].(events.InvitationEvent); ok {
+			link, err := url.Parse(event.AcceptURL)
+			require.NoError(t, err)
+			assert.Equal(t, "/invitations/accept", link.Path)
+			return link.Query().Get("token")
+		}
+	}
+	t.Fatal("no invitation sent")
+	return ""
+}
+
+func TestInvitationService_AcceptAssignsRole(t *testing.T) {
+	f := newInvitationFixture(t)
+	ctx := tenant.With(context.Background(), tenant.Default)
+
+	invitation, err := f.invitations.Create(ctx, "trace", "admin-1", " Invitee@Example.com ", "", "support")
+	require.NoError(t, err)
+	assert.Equal(t, "invitee@example.com", invitation.Email)
+	_, err = f.invitations.Create(ctx, "trace", "admin-1", "invitee@example.com", "", "support")
+	assert.ErrorIs(t, err, service.ErrInvitationExists)
+	token := f.lastToken(t)
+
+	user, tokens, err := f.auth.AcceptInvitation(context.Background(), "trace", token, "password123")
+	require.NoError(t, err)
+	require.NotNil(t, tokens)
+	assert.Equal(t, "invitee@example.com", user.Email)
+	assert.Equal(t, "support", f.rbac.assignments[user.ID])
+	assert.NotNil(t, f.repo.invitations[invitation.ID].AcceptedAt)
+	assert.Contains(t, f.publisher.keys, "user.created")
+
+	_, _, err = f.auth.AcceptInvitation(context.Background(), "trace", token, "password123")
+	assert.ErrorIs(t, err, service.ErrInvitationInvalid)
+	_, err = f.invitations.Revoke(ctx, "trace", "admin-1", invitation.ID)
+	assert.ErrorIs(t, err, service.ErrInvitationClosed)
+}
+
+func TestInvitationService_AcceptRacingRevokeCreatesNothing(t *testing.T) {
+	f := newInvitationFixture(t)
+	ctx := context.Background()
+
+	_, err := f.invitations.Create(ctx, "trace", "admin-1", "invitee@example.com", "", "support")
+	require.NoError(t, err)
+	f.repo.revokeOnAccept = true
+
+	_, _, err = f.auth.AcceptInvitation(ctx, "trace", f.lastToken(t), "password123")
+	assert.ErrorIs(t, err, service.ErrInvitationInvalid)
+	assert.Empty(t, f.users.users, "no account without the invitation")
+	assert.Empty(t, f.rbac.assignments)
+	assert.NotContains(t, f.publisher.keys, "user.created")
+}
+
+func TestInvitationService_ResendAndRevokeInvalidateLinks(t *testing.T) {
+	f := newInvitationFixture(t)
+	ctx := context.Background()
+
+	invitation, err := f.invitations.Create(ctx, "trace", "admin-1", "invitee@example.com", "", "member")
+	require.NoError(t, err)
+	first := f.lastToken(t)
+	resent, err := f.invitations.Resend(ctx, "trace", "admin-1", invitation.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, resent.Sends)
+	second := f.lastToken(t)
+
+	_, _, err = f.auth.AcceptInvitation(ctx, "trace", first, "password123")
+	assert.ErrorIs(t, err, service.ErrInvitationInvalid)
+
+	_, err = f.invitations.Revoke(ctx, "trace", "admin-1", invitation.ID)
+	require.NoError(t, err)
+	_, _, err = f.auth.AcceptInvitation(ctx, "trace", second, "password123")
+	assert.ErrorIs(t, err, service.ErrInvitationInvalid)
+	_, err = f.invitations.Resend(ctx, "trace", "admin-1", invitation.ID)
+	assert.ErrorIs(t, err, service.ErrInvitationClosed)
+}
+
+func TestInvitationService_OAuthRequiresInvitedEmail(t *testing.T) {
+	f := newInvitationFixture(t)
+	ctx := context.Background()
+
+	_, err := f.invitations.Create(ctx, "trace", "admin-1", "invitee@example.com", "", "member")
+	require.NoError(t, err)
+	token := f.lastToken(t)
+
+	info := service.OAuthUserInfo{ProviderType: "google", ProviderUserID: "g-1", Email: "someone@example.com", InvitationToken: token}
+	_, _, err = f.auth.HandleOAuthCallback(ctx, "trace", "google", info)
+	assert.ErrorIs(t, err, service.ErrInvitationEmailMismatch)
+
+	info.Email = "Invitee@example.com"
+	user, _, err := f.auth.HandleOAuthCallback(ctx, "trace", "google", info)
+	require.NoError(t, err)
+	assert.Equal(t, "member", f.rbac.assignments[user.ID])
+	assert.False(t, user.HasPassword())
+}
+
+func TestInvitationService_RejectsExistingAccounts(t *testing.T) {
+	f := newInvitationFixture(t)
+	f.users.users["taken@example.com"] = &domain.User{ID: "user-9", Email: "taken@example.com"}
+
+	_, err := f.invitations.Create(context.Background(), "trace", "admin-1", "taken@example.com", "", "member")
+	assert.ErrorIs(t, err, service.ErrInvitationAccountExists)
+	_, err = f.invitations.Create(context.Background(), "trace", "admin-1", "new@example.com", "", " ")
+	assert.ErrorIs(t, err, domain.ErrInvitationRoleInvalid)
+	_, err = f.invitations.Create(context.Background(), "trace", "admin-1", "new@example.com", "00000000-0000-0000-0000-000000000099", "member")
+	assert.ErrorIs(t, err, service.ErrOrgNotFound)
+}
+
+func TestInvitationService_RestrictsRoleAndOrganization(t *testing.T) {
+	f := newInvitationFixture(t)
+	ctx := tenant.With(context.Background(), "00000000-0000-0000-0000-00000000000a")
+
+	_, err := f.invitations.Create(ctx, "trace", "admin-1", "new@example.com", "", "admin")
+	assert.ErrorIs(t, err, service.ErrInvitationRoleForbidden)
+	_, err = f.invitations.Create(ctx, "trace", "admin-1", "new@example.com", "00000000-0000-0000-0000-00000000000b", "member")
+	assert.ErrorIs(t, err, service.ErrInvitationOrgForbidden)
+
+	f.rbac.assignments["admin-1"] = "admin"
+	_, err = f.invitations.Create(tenant.With(context.Background(), tenant.Default), "trace", "admin-1", "new@example.com", "", "admin")
+	assert.NoError(t, err, "roles:assign allows any role")
+}
@@ -0,0 +1,159 @@
+package unit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/repo"
+	"github.com/example/user-service/internal/service"
+	pkglog "github.com/example/user-service/pkg/log"
+)
+
+type fakeOrgRepo struct {
+	orgs        map[string]*domain.Organization
+	memberships map[string]domain.OrgMembership
+}
+
+func newFakeOrgRepo() *fakeOrgRepo {
+	return &fakeOrgRepo{orgs: map[string]*domain.Organization{}, memberships: map[string]domain.OrgMembership{}}
+}
+
+func (f *fakeOrgRepo) Create(ctx context.Context, org *domain.Organization) error {
+	for _, existing := range f.orgs {
+		if existing.Slug == org.Slug {
+			return gorm.ErrDuplicatedKey
+		}
+	}
+	if org.ID == "" {
+		org.ID = "org-" + org.Slug
+	}
+	f.orgs[org.ID] = org
+	return nil
+}
+
+func (f *fakeOrgRepo) FindByID(ctx context.Context, id string) (*domain.Organization, error) {
+	if org, ok := f.orgs[id]; ok {
+		return org, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakeOrgRepo) FindBySlug(ctx context.Context, slug string) (*domain.Organization, error) {
+	for _, org := range f.orgs {
+		if org.Slug == slug {
+			return org, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakeOrgRepo) FindMembership(ctx context.Context, orgID, userID string) (*domain.OrgMembership, error) {
+	if membership, ok := f.memberships[orgID+"/"+userID]; ok {
+		return &membership, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakeOrgRepo) SaveMembership(ctx context.Context, membership *domain.OrgMembership) error {
+	f.memberships[membership.OrgID+"/"+membership.UserID] = *membership
+	return nil
+}
+
+func (f *fakeOrgRepo) CountMembersWithRole(ctx context.Context, orgID string, role domain.OrgRole) (int64, error) {
+	var count int64
+	for _, membership := range f.memberships {
+		if membership.OrgID == orgID && membership.Role == role {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (f *fakeOrgRepo) ListMembers(ctx context.Context, orgID string, offset, limit int) ([]repo.OrgMember, error) {
+	return nil, nil
+}
+
+func orgFixture(t *testing.T) (service.OrganizationService, *fakeOrgRepo, *userRepoStub, *fakeAuditRepo) {
+	t.Helper()
+	orgs := newFakeOrgRepo()
+	users := newUserRepoStub()
+	audit := &fakeAuditRepo{}
+	svc := service.NewOrganizationService(pkglog.New("test"), orgs, users, newFakeRBACClient(), audit)
+
+	org, err := svc.Create(context.Background(), "trace", "platform", " Acme ", "Acme Corp")
+	require.NoError(t, err)
+	for _, id := range []string{"owner-1", "member-1", "member-2"} {
+		users.users[id] = &domain.User{ID: id, Email: id + "@acme.test", OrgID: &org.ID}
+	}
+	orgs.memberships[org.ID+"/owner-1"] = domain.OrgMembership{OrgID: org.ID, UserID: "owner-1", Role: domain.OrgRoleOwner}
+	return svc, orgs, users, audit
+}
+
+func TestOrganizationService_CreateAndResolve(t *testing.T) {
+	svc, _, _, _ := orgFixture(t)
+
+	_, err := svc.Create(context.Background(), "trace", "platform", "acme", "Duplicate")
+	assert.ErrorIs(t, err, service.ErrOrgSlugTaken)
+	_, err = svc.Create(context.Background(), "trace", "platform", "a", "Too short")
+	assert.ErrorIs(t, err, domain.ErrOrgSlugInvalid)
+
+	org, err := svc.Resolve(context.Background(), "ACME")
+	require.NoError(t, err)
+	assert.Equal(t, "org-acme", org.ID)
+	_, err = svc.Resolve(context.Background(), "unknown")
+	assert.ErrorIs(t, err, service.ErrOrgNotFound)
+}
+
+func TestOrganizationService_RolesFollowMembership(t *testing.T) {
+	svc, orgs, _, audit := orgFixture(t)
+	ctx := context.Background()
+
+	// Users of other tenants are not members.
+	_, err := svc.Get(ctx, "user-1", "org-acme")
+	assert.ErrorIs(t, err, service.ErrOrgForbidden)
+	_, err = svc.Get(ctx, "member-1", "org-acme")
+	assert.NoError(t, err)
+
+	_, err = svc.SetMemberRole(ctx, "trace", "member-1", "org-acme", "member-2", domain.OrgRoleAdmin)
+	assert.ErrorIs(t, err, service.ErrOrgForbidden)
+
+	_, err = svc.SetMemberRole(ctx, "trace", "owner-1", "org-acme", "member-1", domain.OrgRoleAdmin)
+	require.NoError(t, err)
+	assert.Equal(t, domain.OrgRoleAdmin, orgs.memberships["org-acme/member-1"].Role)
+
+	// Admins manage members but not owners.
+	_, err = svc.SetMemberRole(ctx, "trace", "member-1", "org-acme", "member-2", domain.OrgRoleAdmin)
+	assert.NoError(t, err)
+	_, err = svc.SetMemberRole(ctx, "trace", "member-1", "org-acme", "member-2", domain.OrgRoleOwner)
+	assert.ErrorIs(t, err, service.ErrOrgForbidden)
+	_, err = svc.SetMemberRole(ctx, "trace", "member-1", "org-acme", "user-1", domain.OrgRoleMember)
+	assert.ErrorIs(t, err, service.ErrOrgUserAbsent)
+
+	_, err = svc.SetMemberRole(ctx, "trace", "owner-1", "org-acme", "owner-1", domain.OrgRoleAdmin)
+	assert.ErrorIs(t, err, service.ErrOrgLastOwner)
+
+	require.Len(t, audit.events, 2)
+	assert.Equal(t, domain.AuditOrgRoleChanged, audit.events[0].Action)
+	assert.Equal(t, "member", audit.events[0].Metadata["previous_role"])
+}
+
+func TestOrganizationService_PlatformPermissionActsAsOwner(t *testing.T) {
+	orgs := newFakeOrgRepo()
+	users := newUserRepoStub()
+	rbacClient := newFakeRBACClient()
+	rbacClient.assignments["platform"] = "admin"
+	svc := service.NewOrganizationService(pkglog.New("test"), orgs, users, rbacClient, &fakeAuditRepo{})
+
+	org, err := svc.Create(context.Background(), "trace", "platform", "globex", "Globex")
+	require.NoError(t, err)
+	users.users["first"] = &domain.User{ID: "first", OrgID: &org.ID}
+
+	membership, err := svc.SetMemberRole(context.Background(), "trace", "platform", org.ID, "first", domain.OrgRoleOwner)
+	require.NoError(t, err)
+	assert.Equal(t, domain.OrgRoleOwner, membership.Role)
+}
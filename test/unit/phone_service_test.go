@@ -0,0 +1,298 @@
+package unit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"github.com/example/user-service/config"
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/service"
+	pkglog "github.com/example/user-service/pkg/log"
+)
+
+type fakePhoneCodeRepo struct {
+	codes []*domain.PhoneCode
+}
+
+func (f *fakePhoneCodeRepo) Create(ctx context.Context, code *domain.PhoneCode) error {
+	code.ID = fmt.Sprintf("00000000-0000-0000-0000-%012d", len(f.codes)+1)
+	code.CreatedAt = time.Now()
+	f.codes = append(f.codes, code)
+	return nil
+}
+
+func (f *fakePhoneCodeRepo) FindByID(ctx context.Context, id string) (*domain.PhoneCode, error) {
+	for _, code := range f.codes {
+		if code.ID == id {
+			copied := *code
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakePhoneCodeRepo) CountSince(ctx context.Context, phone string, since time.Time) (int64, error) {
+	var count int64
+	for _, code := range f.codes {
+		if code.Phone == phone && !code.CreatedAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (f *fakePhoneCodeRepo) ClaimAttempt(ctx context.Context, id string, max int) (bool, error) {
+	for _, code := range f.codes {
+		if code.ID == id && code.Attempts < max && code.ConsumedAt == nil {
+			code.Attempts++
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *fakePhoneCodeRepo) Consume(ctx context.Context, id string, at time.Time) error {
+	for _, code := range f.codes {
+		if code.ID == id && code.ConsumedAt == nil {
+			code.ConsumedAt = &at
+			return nil
+		}
+	}
+	return gorm.ErrRecordNotFound
+}
+
+type recordingSMSSender struct {
+	phones   []string
+	messages []string
+}
+
+func (r *recordingSMSSender) Send(ctx context.Context, phone, message string) error {
+	r.phones = append(r.phones, phone)
+	r.messages = append(r.messages, message)
+	return nil
+}
+
+// lastCode is the code at the end of the last message.
+func (r *recordingSMSSender) lastCode() string {
+	msg := r.messages[len(r.messages)-1]
+	return msg[len(msg)-6:]
+}
+
+type phoneFixture struct {
+	cfg    *config.Config
+	users  *fakeUserRepo
+	codes  *fakePhoneCodeRepo
+	sms    *recordingSMSSender
+	phones service.PhoneService
+	user   *domain.User
+}
+
+func newPhoneFixture(t *testing.T) *phoneFixture {
+	cfg := &config.Config{AppName: "user-service", PhoneCodeTTL: 10 * time.Minute, JWTSecret: "secret", JWTTTLMinutes: time.Minute, JWTRefreshTTLMinutes: time.Hour}
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	users := newFakeUserRepo()
+	user := &domain.User{ID: "user-1", Email: "user@example.com", IsActive: true}
+	user.SetPasswordHash(string(hash))
+	users.users[user.Email] = user
+	codes := &fakePhoneCodeRepo{}
+	sms := &recordingSMSSender{}
+	return &phoneFixture{
+		cfg:    cfg,
+		users:  users,
+		codes:  codes,
+		sms:    sms,
+		phones: service.NewPhoneService(cfg, pkglog.New("test"), users, codes, sms),
+		user:   user,
+	}
+}
+
+func (f *phoneFixture) auth(t *testing.T) service.AuthService {
+	signer, err := service.NewJWTSigner(f.cfg)
+	require.NoError(t, err)
+	return service.NewAuthService(f.cfg, pkglog.New("test"), f.users, newFakeProfileRepo(), newFakeIdentityRepo(), newFakeAccountLinkRepo(), &fakeTarantool{}, newFakeRBACClient(), fakePublisher{}, signer, &fakeAvatarQueue{}, nil, nil, f.phones, nil)
+}
+
+func (f *phoneFixture) verifyPhone(t *testing.T, phone string) {
+	id, err := f.phones.StartVerification(context.Background(), f.user.ID, phone)
+	require.NoError(t, err)
+	_, err = f.phones.Verify(context.Background(), f.user.ID, id, f.sms.lastCode())
+	require.NoError(t, err)
+}
+
+func TestPhoneService_VerifyStoresNormalizedPhone(t *testing.T) {
+	f := newPhoneFixture(t)
+	ctx := context.Background()
+
+	id, err := f.phones.StartVerification(ctx, f.user.ID, "+49 151 1234 5678")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"+4915112345678"}, f.sms.phones)
+	assert.Nil(t, f.user.Phone, "phone is only stored once verified")
+
+	_, err = f.phones.Verify(ctx, "user-2", id, f.sms.lastCode())
+	assert.ErrorIs(t, err, service.ErrPhoneCodeInvalid)
+
+	user, err := f.phones.Verify(ctx, f.user.ID, id, f.sms.lastCode())
+	require.NoError(t, err)
+	require.NotNil(t, user.Phone)
+	assert.Equal(t, "+4915112345678", *user.Phone)
+	assert.NotNil(t, user.PhoneVerifiedAt)
+
+	_, err = f.phones.Verify(ctx, f.user.ID, id, f.sms.lastCode())
+	assert.ErrorIs(t, err, service.ErrPhoneCodeInvalid, "codes are single use")
+}
+
+func TestPhoneService_WrongCodesExhaustVerification(t *testing.T) {
+	f := newPhoneFixture(t)
+	ctx := context.Background()
+
+	id, err := f.phones.StartVerification(ctx, f.user.ID, "+4915112345678")
+	require.NoError(t, err)
+	wrong := "000000"
+	if f.sms.lastCode() == wrong {
+		wrong = "111111"
+	}
+	for i := 0; i < 5; i++ {
+		_, err = f.phones.Verify(ctx, f.user.ID, id, wrong)
+		assert.ErrorIs(t, err, service.ErrPhoneCodeInvalid)
+	}
+	_, err = f.phones.Verify(ctx, f.user.ID, id, f.sms.lastCode())
+	assert.ErrorIs(t, err, service.ErrPhoneCodeInvalid)
+}
+
+func TestPhoneService_RejectsTakenPhoneAndLimitsTexts(t *testing.T) {
+	f := newPhoneFixture(t)
+	ctx := context.Background()
+	taken := "+14155552671"
+	f.users.users["other@example.com"] = &domain.User{ID: "user-2", Email: "other@example.com", Phone: &taken}
+
+	_, err := f.phones.StartVerification(ctx, f.user.ID, "+1 415 555 2671")
+	assert.ErrorIs(t, err, service.ErrPhoneTaken)
+	_, err = f.phones.StartVerification(ctx, f.user.ID, "015112345678")
+	assert.ErrorIs(t, err, domain.ErrPhoneInvalid)
+
+	for i := 0; i < 5; i++ {
+		_, err = f.phones.StartVerification(ctx, f.user.ID, "+4915112345678")
+		require.NoError(t, err)
+	}
+	_, err = f.phones.StartVerification(ctx, f.user.ID, "+4915112345678")
+	assert.ErrorIs(t, err, service.ErrPhoneCodeLimit)
+}
+
+func TestPhoneService_MFARequiresPhone(t *testing.T) {
+	f := newPhoneFixture(t)
+	ctx := context.Background()
+
+	_, err := f.phones.SetMFA(ctx, f.user.ID, true)
+	assert.ErrorIs(t, err, service.ErrPhoneRequired)
+
+	f.verifyPhone(t, "+4915112345678")
+	user, err := f.phones.SetMFA(ctx, f.user.ID, true)
+	require.NoError(t, err)
+	assert.True(t, user.PhoneMFA)
+
+	user, err = f.phones.Remove(ctx, f.user.ID)
+	require.NoError(t, err)
+	assert.Nil(t, user.Phone)
+	assert.False(t, user.PhoneMFA)
+}
+
+func TestPhoneService_RemoveKeepsLastLoginMethod(t *testing.T) {
+	f := newPhoneFixture(t)
+	ctx := context.Background()
+	f.verifyPhone(t, "+4915112345678")
+	f.user.PasswordHash = nil
+
+	_, err := f.phones.Remove(ctx, f.user.ID)
+	assert.ErrorIs(t, err, service.ErrLastLoginMethod)
+	assert.NotNil(t, f.user.Phone)
+
+	// A linked identity still signs in once the phone is gone.
+	identities := newFakeIdentityRepo()
+	identities.users = f.users
+	f.users.identities = identities
+	identities.identities[identities.key(domain.ProviderGitHub, "gh-1")] = &domain.UserIdentity{ID: "identity-gh-1", UserID: f.user.ID, Provider: domain.ProviderGitHub, ProviderUserID: "gh-1"}
+	_, err = f.phones.Remove(ctx, f.user.ID)
+	require.NoError(t, err)
+	assert.Nil(t, f.user.Phone)
+
+	// With the phone gone, the identity is the last way to sign in.
+	users := service.NewUserService(nil, f.users, newFakeProfileRepo(), identities, nil, &fakeTarantool{}, nil, nil, nil)
+	err = users.RemoveIdentity(ctx, f.user.ID, domain.ProviderGitHub, "gh-1")
+	assert.ErrorIs(t, err, service.ErrLastLoginMethod)
+}
+
+func TestAuthService_SignIn_SMSMFA(t *testing.T) {
+	f := newPhoneFixture(t)
+	ctx := context.Background()
+	f.verifyPhone(t, "+4915112345678")
+	_, err := f.phones.SetMFA(ctx, f.user.ID, true)
+	require.NoError(t, err)
+	auth := f.auth(t)
+
+	_, tokens, err := auth.SignIn(ctx, "trace-1", f.user.Email, "password123")
+	var mfaErr *service.MFARequiredError
+	require.ErrorAs(t, err, &mfaErr)
+	assert.Nil(t, tokens)
+	assert.Equal(t, []string{"sms"}, mfaErr.Methods)
+
+	_, _, err = auth.VerifySMSSignIn(ctx, "trace-1", mfaErr.ChallengeID, f.sms.lastCode())
+	assert.ErrorIs(t, err, service.ErrPhoneCodeInvalid, "mfa codes do not sign in on their own")
+
+	user, tokens, err := auth.VerifyMFA(ctx, "trace-1", mfaErr.ChallengeID, f.sms.lastCode())
+	require.NoError(t, err)
+	assert.Equal(t, f.user.ID, user.ID)
+	assert.NotEmpty(t, tokens.AccessToken)
+}
+
+func TestAuthService_OAuthSignInRequiresSMSMFA(t *testing.T) {
+	f := newPhoneFixture(t)
+	ctx := context.Background()
+	f.verifyPhone(t, "+4915112345678")
+	_, err := f.phones.SetMFA(ctx, f.user.ID, true)
+	require.NoError(t, err)
+	identities := newFakeIdentityRepo()
+	require.NoError(t, identities.Create(ctx, &domain.UserIdentity{UserID: f.user.ID, Provider: domain.IdentityProvider("google"), ProviderUserID: "google-1"}))
+	signer, err := service.NewJWTSigner(f.cfg)
+	require.NoError(t, err)
+	auth := service.NewAuthService(f.cfg, pkglog.New("test"), f.users, newFakeProfileRepo(), identities, newFakeAccountLinkRepo(), &fakeTarantool{}, newFakeRBACClient(), fakePublisher{}, signer, &fakeAvatarQueue{}, nil, nil, f.phones, nil)
+
+	_, tokens, err := auth.HandleOAuthCallback(ctx, "trace-1", "google", service.OAuthUserInfo{ProviderUserID: "google-1", Email: f.user.Email})
+	var mfaErr *service.MFARequiredError
+	require.ErrorAs(t, err, &mfaErr, "a linked identity is only the first factor")
+	assert.Nil(t, tokens)
+
+	user, tokens, err := auth.VerifyMFA(ctx, "trace-1", mfaErr.ChallengeID, f.sms.lastCode())
+	require.NoError(t, err)
+	assert.Equal(t, f.user.ID, user.ID)
+	assert.NotEmpty(t, tokens.AccessToken)
+}
+
+func TestAuthService_SMSSignIn(t *testing.T) {
+	f := newPhoneFixture(t)
+	ctx := context.Background()
+	f.verifyPhone(t, "+4915112345678")
+	auth := f.auth(t)
+	sent := len(f.sms.messages)
+
+	decoy, err := auth.StartSMSSignIn(ctx, "trace-1", "+14155552671")
+	require.NoError(t, err)
+	assert.NotEmpty(t, decoy)
+	assert.Len(t, f.sms.messages, sent, "unknown numbers get no text")
+	_, _, err = auth.VerifySMSSignIn(ctx, "trace-1", decoy, "123456")
+	assert.ErrorIs(t, err, service.ErrPhoneCodeInvalid)
+
+	id, err := auth.StartSMSSignIn(ctx, "trace-1", "0049 151 12345678")
+	require.NoError(t, err)
+	user, tokens, err := auth.VerifySMSSignIn(ctx, "trace-1", id, f.sms.lastCode())
+	require.NoError(t, err)
+	assert.Equal(t, f.user.ID, user.ID)
+	assert.NotEmpty(t, tokens.AccessToken)
+}
@@ -0,0 +1,111 @@
+package unit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/events"
+	"github.com/example/user-service/internal/service"
+	pkglog "github.com/example/user-service/pkg/log"
+)
+
+const (
+	prefUserA = "00000000-0000-0000-0000-00000000000a"
+	prefUserB = "00000000-0000-0000-0000-00000000000b"
+)
+
+type fakePreferenceRepo struct {
+	prefs map[string]*domain.UserPreferences
+}
+
+func newFakePreferenceRepo() *fakePreferenceRepo {
+	return &fakePreferenceRepo{prefs: map[string]*domain.UserPreferences{}}
+}
+
+func (f *fakePreferenceRepo) Find(ctx context.Context, userID string) (*domain.UserPreferences, error) {
+	if prefs, ok := f.prefs[userID]; ok {
+		return prefs, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakePreferenceRepo) FindMany(ctx context.Context, userIDs []string) ([]domain.UserPreferences, error) {
+	var result []domain.UserPreferences
+	for _, userID := range userIDs {
+		if prefs, ok := f.prefs[userID]; ok {
+			result = append(result, *prefs)
+		}
+	}
+	return result, nil
+}
+
+func (f *fakePreferenceRepo) Modify(ctx context.Context, userID string, fn func(*domain.UserPreferences) error) (*domain.UserPreferences, error) {
+	prefs, ok := f.prefs[userID]
+	if !ok {
+		prefs = &domain.UserPreferences{UserID: userID, Values: domain.JSONMap{}}
+	}
+	if err := fn(prefs); err != nil {
+		return nil, err
+	}
+	f.prefs[userID] = prefs
+	return prefs, nil
+}
+
+func TestPreferenceService_UpdatePublishesChanges(t *testing.T) {
+	publisher := &recordingPublisher{}
+	svc := service.NewPreferenceService(pkglog.New("test"), newFakePreferenceRepo(), publisher)
+	ctx := context.Background()
+
+	prefs, err := svc.Get(ctx, prefUserA)
+	require.NoError(t, err)
+	assert.Equal(t, "en", prefs["language"])
+
+	_, err = svc.Update(ctx, "trace", prefUserA, map[string]interface{}{"language": "english", "theme": "dark", "marketing_opt_in": true})
+	var validationErr *service.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Len(t, validationErr.Fields, 2)
+	assert.Empty(t, publisher.keys)
+
+	prefs, err = svc.Update(ctx, "trace", prefUserA, map[string]interface{}{"language": "de", "notifications.email": true})
+	require.NoError(t, err)
+	assert.Equal(t, "de", prefs["language"])
+	require.Equal(t, []string{"user.preferences_changed"}, publisher.keys)
+	// Setting a preference to its current value is not a change.
+	assert.Equal(t, map[string]interface{}{"language": "de"}, publisher.payloads[0].(events.UserPreferencesEvent).Changes)
+
+	prefs, err = svc.Update(ctx, "trace", prefUserA, map[string]interface{}{"language": nil})
+	require.NoError(t, err)
+	assert.Equal(t, "en", prefs["language"])
+	assert.Equal(t, map[string]interface{}{"language": "en"}, publisher.payloads[1].(events.UserPreferencesEvent).Changes)
+
+	_, err = svc.Update(ctx, "trace", prefUserA, map[string]interface{}{"language": nil})
+	require.NoError(t, err)
+	assert.Len(t, publisher.keys, 2)
+}
+
+func TestPreferenceService_GetMany(t *testing.T) {
+	repo := newFakePreferenceRepo()
+	repo.prefs[prefUserA] = &domain.UserPreferences{UserID: prefUserA, Values: domain.JSONMap{"notifications.sms": true}}
+	svc := service.NewPreferenceService(pkglog.New("test"), repo, nil)
+	ctx := context.Background()
+
+	prefs, err := svc.GetMany(ctx, []string{prefUserA, prefUserB}, []string{"notifications.sms"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]interface{}{
+		prefUserA: {"notifications.sms": true},
+		prefUserB: {"notifications.sms": false},
+	}, prefs)
+
+	_, err = svc.GetMany(ctx, []string{prefUserA}, []string{"unknown"})
+	var validationErr *service.ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	_, err = svc.GetMany(ctx, []string{"user-1"}, nil)
+	assert.ErrorAs(t, err, &validationErr)
+	_, err = svc.GetMany(ctx, make([]string, service.MaxPreferenceBatch+1), nil)
+	assert.ErrorIs(t, err, service.ErrPreferenceBatchTooLarge)
+}
@@ -0,0 +1,186 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/service"
+	"github.com/example/user-service/pkg/jsonschema"
+	pkglog "github.com/example/user-service/pkg/log"
+	"github.com/example/user-service/pkg/patch"
+)
+
+type fakeProfileSchemaRepo struct {
+	schemas []domain.ProfileSchema
+}
+
+func (f *fakeProfileSchemaRepo) Latest(ctx context.Context) (*domain.ProfileSchema, error) {
+	if len(f.schemas) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	latest := f.schemas[len(f.schemas)-1]
+	return &latest, nil
+}
+
+func (f *fakeProfileSchemaRepo) Create(ctx context.Context, schema *domain.ProfileSchema) error {
+	schema.Version = len(f.schemas) + 1
+	f.schemas = append(f.schemas, *schema)
+	return nil
+}
+
+const departmentSchema = `{
+	"type": "object",
+	"additionalProperties": false,
+	"required": ["department"],
+	"properties": {
+		"department": {"type": "string", "enum": ["sales", "support"]},
+		"employee_id": {"type": "integer", "minimum": 1}
+	}
+}`
+
+func attributes(t *testing.T, raw string) map[string]interface{} {
+	t.Helper()
+	var attrs map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(raw), &attrs))
+	return attrs
+}
+
+func fieldNames(err error) []string {
+	var validationErr *service.ValidationError
+	if !errors.As(err, &validationErr) {
+		return nil
+	}
+	var names []string
+	for _, field := range validationErr.Fields {
+		names = append(names, field.Field)
+	}
+	return names
+}
+
+func TestProfileSchemaService_Publish(t *testing.T) {
+	repo := &fakeProfileSchemaRepo{}
+	svc := service.NewProfileSchemaService(pkglog.New("test"), repo)
+
+	_, err := svc.Publish(context.Background(), "trace", "admin-1", []byte(`{"type":"object","oneOf":[]}`))
+	assert.ErrorIs(t, err, service.ErrInvalidProfileSchema)
+	_, err = svc.Publish(context.Background(), "trace", "admin-1", []byte(`{"type":"string"}`))
+	assert.ErrorIs(t, err, service.ErrInvalidProfileSchema)
+
+	schema, err := svc.Publish(context.Background(), "trace", "admin-1", []byte(departmentSchema))
+	require.NoError(t, err)
+	assert.Equal(t, 1, schema.Version)
+	assert.Equal(t, "admin-1", schema.CreatedBy)
+
+	fieldErrs, err := svc.ValidateAttributes(context.Background(), attributes(t, `{"department":"legal"}`))
+	require.NoError(t, err)
+	assert.Equal(t, []jsonschema.FieldError{{Field: "department", Message: `must be one of ["sales","support"]`}}, fieldErrs)
+}
+
+func TestProfileSchemaService_RejectsAttributesWithoutSchema(t *testing.T) {
+	svc := service.NewProfileSchemaService(pkglog.New("test"), &fakeProfileSchemaRepo{})
+
+	fieldErrs, err := svc.ValidateAttributes(context.Background(), attributes(t, `{"b":1,"a":2}`))
+	require.NoError(t, err)
+	assert.Equal(t, []jsonschema.FieldError{{Field: "a", Message: "is not allowed"}, {Field: "b", Message: "is not allowed"}}, fieldErrs)
+
+	fieldErrs, err = svc.ValidateAttributes(context.Background(), map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Empty(t, fieldErrs)
+}
+
+func TestUserService_UpdateProfile_StandardFields(t *testing.T) {
+	profiles := newProfileRepoStub()
+	svc := service.NewUserService(nil, newUserRepoStub(), profiles, identityRepoStub{}, nil, tarantoolStub{}, nil, nil, nil)
+	first, locale, timezone, company := "  Ada ", "en-us", "Europe/Berlin", "Analytical Engines"
+
+	profile, err := svc.UpdateProfile(context.Background(), "user-1", service.ProfileUpdate{FirstName: patch.Set(first), Locale: patch.Set(locale), Timezone: patch.Set(timezone), Company: patch.Set(company)})
+	require.NoError(t, err)
+	assert.Equal(t, "Ada", *profile.FirstName)
+	assert.Equal(t, "en-US", *profile.Locale)
+	assert.Equal(t, "Europe/Berlin", *profile.Timezone)
+
+	empty := ""
+	profile, err = svc.UpdateProfile(context.Background(), "user-1", service.ProfileUpdate{Company: patch.Set(empty)})
+	require.NoError(t, err)
+	assert.Nil(t, profile.Company)
+	assert.Equal(t, "Ada", *profile.FirstName)
+}
+
+func TestUserService_UpdateProfile_ClearingAvatarRemovesFiles(t *testing.T) {
+	profiles := newProfileRepoStub()
+	storage := &fileStorageStub{}
+	avatar := "https://files.example.com/user-1-original.png"
+	profiles.profiles["user-1"].SetAvatar(avatar, map[string]string{"64": "https://files.example.com/user-1-64.png"})
+	svc := service.NewUserService(nil, newUserRepoStub(), profiles, identityRepoStub{}, nil, tarantoolStub{}, nil, newTestAvatarStore(storage), nil)
+
+	_, err := svc.UpdateProfile(context.Background(), "user-1", service.ProfileUpdate{Company: patch.Set("Acme")})
+	require.NoError(t, err)
+	assert.Empty(t, storage.deleted, "other fields leave the avatar alone")
+
+	profile, err := svc.UpdateProfile(context.Background(), "user-1", service.ProfileUpdate{AvatarURL: patch.Null[string]()})
+	require.NoError(t, err)
+	assert.Nil(t, profile.AvatarURL)
+	assert.ElementsMatch(t, []string{avatar, "https://files.example.com/user-1-64.png"}, storage.deleted)
+}
+
+func TestUserService_UpdateProfile_ReportsEveryInvalidField(t *testing.T) {
+	schemas := service.NewProfileSchemaService(pkglog.New("test"), &fakeProfileSchemaRepo{})
+	_, err := schemas.Publish(context.Background(), "trace", "admin-1", []byte(departmentSchema))
+	require.NoError(t, err)
+	profiles := newProfileRepoStub()
+	svc := service.NewUserService(nil, newUserRepoStub(), profiles, identityRepoStub{}, nil, tarantoolStub{}, nil, nil, schemas)
+	locale, timezone, bio := "!!", "Mars/Olympus", string(make([]rune, 1001))
+
+	_, err = svc.UpdateProfile(context.Background(), "user-1", service.ProfileUpdate{
+		Locale:     patch.Set(locale),
+		Timezone:   patch.Set(timezone),
+		Bio:        patch.Set(bio),
+		Attributes: patch.Set(attributes(t, `{"employee_id":0,"badge":"x"}`)),
+	})
+	assert.Equal(t, []string{"bio", "locale", "timezone", "attributes.department", "attributes.badge", "attributes.employee_id"}, fieldNames(err))
+	assert.Nil(t, profiles.profiles["user-1"].Attributes)
+}
+
+func TestUserService_UpdateProfile_MergesAttributes(t *testing.T) {
+	schemas := service.NewProfileSchemaService(pkglog.New("test"), &fakeProfileSchemaRepo{})
+	_, err := schemas.Publish(context.Background(), "trace", "admin-1", []byte(departmentSchema))
+	require.NoError(t, err)
+	svc := service.NewUserService(nil, newUserRepoStub(), newProfileRepoStub(), identityRepoStub{}, nil, tarantoolStub{}, nil, nil, schemas)
+
+	_, err = svc.UpdateProfile(context.Background(), "user-1", service.ProfileUpdate{Attributes: patch.Set(attributes(t, `{"department":"sales","employee_id":7}`))})
+	require.NoError(t, err)
+	profile, err := svc.UpdateProfile(context.Background(), "user-1", service.ProfileUpdate{Attributes: patch.Set(attributes(t, `{"employee_id":null}`))})
+	require.NoError(t, err)
+	assert.Equal(t, domain.JSONMap{"department": "sales"}, profile.Attributes)
+
+	_, err = svc.UpdateProfile(context.Background(), "user-1", service.ProfileUpdate{Attributes: patch.Set(attributes(t, `{"department":null}`))})
+	assert.Equal(t, []string{"attributes.department"}, fieldNames(err))
+	_, err = svc.UpdateProfile(context.Background(), "user-1", service.ProfileUpdate{Attributes: patch.Null[map[string]interface{}]()})
+	assert.Equal(t, []string{"attributes.department"}, fieldNames(err))
+}
+
+func TestAdminService_UpdateProfileIsAudited(t *testing.T) {
+	audit := &fakeAuditRepo{}
+	users := newUserRepoStub()
+	userSvc := service.NewUserService(nil, users, newProfileRepoStub(), identityRepoStub{}, nil, tarantoolStub{}, nil, nil, nil)
+	svc := service.NewAdminService(pkglog.New("test"), users, userSvc, nil, audit, fakePublisher{})
+	company := "Acme"
+
+	profile, err := svc.UpdateProfile(context.Background(), "trace", "admin-1", "user-1", service.ProfileUpdate{Company: patch.Set(company)})
+	require.NoError(t, err)
+	assert.Equal(t, "Acme", *profile.Company)
+	require.Len(t, audit.events, 1)
+	assert.Equal(t, domain.AuditProfileUpdated, audit.events[0].Action)
+	assert.Equal(t, "admin-1", audit.events[0].ActorID)
+	assert.Equal(t, []string{"company"}, audit.events[0].Metadata["fields"])
+
+	_, err = svc.UpdateProfile(context.Background(), "trace", "admin-1", "missing", service.ProfileUpdate{Company: patch.Set(company)})
+	assert.Error(t, err)
+}
@@ -0,0 +1,176 @@
+package unit
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/events"
+	"github.com/example/user-service/internal/service"
+	pkglog "github.com/example/user-service/pkg/log"
+)
+
+type fakeRecoveryCodeRepo struct {
+	codes []domain.RecoveryCode
+}
+
+func (f *fakeRecoveryCodeRepo) Replace(ctx context.Context, userID string, codes []domain.RecoveryCode) error {
+	kept := f.codes[:0]
+	for _, code := range f.codes {
+		if code.UserID != userID {
+			kept = append(kept, code)
+		}
+	}
+	f.codes = append(kept, codes...)
+	return nil
+}
+
+func (f *fakeRecoveryCodeRepo) CountUnused(ctx context.Context, userID string) (int64, error) {
+	var count int64
+	for _, code := range f.codes {
+		if code.UserID == userID && code.UsedAt == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (f *fakeRecoveryCodeRepo) Use(ctx context.Context, userID, codeHash string, at time.Time) error {
+	for i := range f.codes {
+		if code := &f.codes[i]; code.UserID == userID && code.CodeHash == codeHash && code.UsedAt == nil {
+			code.UsedAt = &at
+			return nil
+		}
+	}
+	return gorm.ErrRecordNotFound
+}
+
+func newRecoveryUsers() *fakeUserRepo {
+	users := newFakeUserRepo()
+	users.users["user@example.com"] = &domain.User{ID: "user-1", Email: "user@example.com", IsActive: true}
+	return users
+}
+
+func TestRecoveryCodeService_GenerateReplacesSet(t *testing.T) {
+	repo := &fakeRecoveryCodeRepo{}
+	svc := service.NewRecoveryCodeService(pkglog.New("test"), repo, newRecoveryUsers(), nil)
+	ctx := context.Background()
+
+	first, err := svc.Generate(ctx, "trace", "user-1")
+	require.NoError(t, err)
+	require.Len(t, first, domain.RecoveryCodeCount)
+	seen := map[string]bool{}
+	for _, code := range first {
+		assert.Regexp(t, regexp.MustCompile(`^[a-z2-9]{5}-[a-z2-9]{5}$`), code)
+		assert.False(t, seen[code], "codes are distinct")
+		seen[code] = true
+	}
+	for _, stored := range repo.codes {
+		assert.False(t, seen[stored.CodeHash], "only hashes are stored")
+	}
+
+	second, err := svc.Generate(ctx, "trace", "user-1")
+	require.NoError(t, err)
+	remaining, err := svc.Remaining(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(domain.RecoveryCodeCount), remaining)
+
+	user := &domain.User{ID: "user-1", Email: "user@example.com"}
+	assert.ErrorIs(t, svc.Use(ctx, "trace", user, first[0]), service.ErrRecoveryCodeInvalid, "old set is invalidated")
+	assert.NoError(t, svc.Use(ctx, "trace", user, second[0]))
+}
+
+func TestRecoveryCodeService_UseIsSingleAndPublishes(t *testing.T) {
+	repo := &fakeRecoveryCodeRepo{}
+	publisher := &recordingPublisher{}
+	svc := service.NewRecoveryCodeService(pkglog.New("test"), repo, newRecoveryUsers(), publisher)
+	ctx := context.Background()
+	codes, err := svc.Generate(ctx, "trace", "user-1")
+	require.NoError(t, err)
+	user := &domain.User{ID: "user-1", Email: "user@example.com"}
+
+	assert.ErrorIs(t, svc.Use(ctx, "trace", &domain.User{ID: "user-2"}, codes[0]), service.ErrRecoveryCodeInvalid)
+	require.NoError(t, svc.Use(ctx, "trace", user, "  "+strings.ToUpper(codes[0])+" "))
+	assert.ErrorIs(t, svc.Use(ctx, "trace", user, codes[0]), service.ErrRecoveryCodeInvalid)
+
+	require.Equal(t, []string{"user.recovery_codes_generated", "user.recovery_code_used"}, publisher.keys)
+	assert.Equal(t, "user@example.com", publisher.payloads[0].(events.UserEvent).Email)
+	event := publisher.payloads[1].(events.RecoveryCodeUsedEvent)
+	assert.Equal(t, "user@example.com", event.Email)
+	assert.Equal(t, int64(domain.RecoveryCodeCount-1), event.Remaining)
+}
+
+func TestAuthService_SignInWithRecoveryCode(t *testing.T) {
+	f := newPhoneFixture(t)
+	f.verifyPhone(t, "+4915112345678")
+	f.user.PhoneMFA = true
+	recovery := service.NewRecoveryCodeService(pkglog.New("test"), &fakeRecoveryCodeRepo{}, f.users, nil)
+	signer, err := service.NewJWTSigner(f.cfg)
+	require.NoError(t, err)
+	auth := service.NewAuthService(f.cfg, pkglog.New("test"), f.users, newFakeProfileRepo(), newFakeIdentityRepo(), newFakeAccountLinkRepo(), &fakeTarantool{}, newFakeRBACClient(), fakePublisher{}, signer, &fakeAvatarQueue{}, nil, nil, f.phones, recovery)
+	ctx := context.Background()
+
+	var mfaErr *service.MFARequiredError
+	_, _, err = auth.SignIn(ctx, "trace", "user@example.com", "password123")
+	require.ErrorAs(t, err, &mfaErr)
+	assert.Equal(t, []string{"sms"}, mfaErr.Methods, "no codes generated yet")
+
+	codes, err := recovery.Generate(ctx, "trace", f.user.ID)
+	require.NoError(t, err)
+	_, _, err = auth.SignIn(ctx, "trace", "user@example.com", "password123")
+	require.ErrorAs(t, err, &mfaErr)
+	assert.Equal(t, []string{"sms", "recovery_code"}, mfaErr.Methods)
+
+	_, _, err = auth.SignInWithRecoveryCode(ctx, "trace", "00000000-0000-0000-0000-000000000000", codes[0])
+	assert.ErrorIs(t, err, service.ErrPhoneCodeInvalid)
+	_, _, err = auth.SignInWithRecoveryCode(ctx, "trace", mfaErr.ChallengeID, "wrong-code")
+	assert.ErrorIs(t, err, service.ErrRecoveryCodeInvalid)
+	user, tokens, err := auth.SignInWithRecoveryCode(ctx, "trace", mfaErr.ChallengeID, codes[0])
+	require.NoError(t, err, "an unknown challenge does not use up the code")
+	assert.Equal(t, f.user.ID, user.ID)
+	assert.NotEmpty(t, tokens.AccessToken)
+
+	_, _, err = auth.SignInWithRecoveryCode(ctx, "trace", mfaErr.ChallengeID, codes[1])
+	assert.ErrorIs(t, err, service.ErrPhoneCodeInvalid, "the challenge is used up")
+	_, _, err = auth.VerifyMFA(ctx, "trace", mfaErr.ChallengeID, f.sms.lastCode())
+	assert.ErrorIs(t, err, service.ErrPhoneCodeInvalid)
+
+	_, _, err = auth.SignIn(ctx, "trace", "user@example.com", "password123")
+	require.ErrorAs(t, err, &mfaErr)
+	_, _, err = auth.SignInWithRecoveryCode(ctx, "trace", mfaErr.ChallengeID, codes[0])
+	assert.ErrorIs(t, err, service.ErrRecoveryCodeInvalid)
+}
+
+func TestAuthService_OAuthOnlySignInWithRecoveryCode(t *testing.T) {
+	f := newPhoneFixture(t)
+	ctx := context.Background()
+	f.verifyPhone(t, "+4915112345678")
+	_, err := f.phones.SetMFA(ctx, f.user.ID, true)
+	require.NoError(t, err)
+	f.user.PasswordHash = nil
+	identities := newFakeIdentityRepo()
+	require.NoError(t, identities.Create(ctx, &domain.UserIdentity{UserID: f.user.ID, Provider: domain.IdentityProvider("google"), ProviderUserID: "google-1"}))
+	recovery := service.NewRecoveryCodeService(pkglog.New("test"), &fakeRecoveryCodeRepo{}, f.users, nil)
+	codes, err := recovery.Generate(ctx, "trace", f.user.ID)
+	require.NoError(t, err)
+	signer, err := service.NewJWTSigner(f.cfg)
+	require.NoError(t, err)
+	auth := service.NewAuthService(f.cfg, pkglog.New("test"), f.users, newFakeProfileRepo(), identities, newFakeAccountLinkRepo(), &fakeTarantool{}, newFakeRBACClient(), fakePublisher{}, signer, &fakeAvatarQueue{}, nil, nil, f.phones, recovery)
+
+	_, _, err = auth.HandleOAuthCallback(ctx, "trace", "google", service.OAuthUserInfo{ProviderUserID: "google-1", Email: f.user.Email})
+	var mfaErr *service.MFARequiredError
+	require.ErrorAs(t, err, &mfaErr)
+	assert.Equal(t, []string{"sms", "recovery_code"}, mfaErr.Methods)
+
+	user, tokens, err := auth.SignInWithRecoveryCode(ctx, "trace", mfaErr.ChallengeID, codes[0])
+	require.NoError(t, err)
+	assert.Equal(t, f.user.ID, user.ID)
+	assert.NotEmpty(t, tokens.AccessToken)
+}
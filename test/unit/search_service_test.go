@@ -0,0 +1,57 @@
+package unit
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/example/user-service/internal/repo"
+	"github.com/example/user-service/internal/service"
+)
+
+type fakeUserSearchRepo struct {
+	term  string
+	limit int
+	calls int
+}
+
+func (f *fakeUserSearchRepo) Search(ctx context.Context, term string, limit int) ([]repo.UserSearchHit, error) {
+	f.term, f.limit = term, limit
+	f.calls++
+	return []repo.UserSearchHit{{UserID: "user-1", Email: "jane@example.com", Score: 0.8}}, nil
+}
+
+func TestSearchService_NormalizesTerm(t *testing.T) {
+	search := &fakeUserSearchRepo{}
+	svc := service.NewSearchService(search)
+
+	hits, err := svc.Search(context.Background(), "  Jane\tDOE\n ", 0)
+	require.NoError(t, err)
+	assert.Len(t, hits, 1)
+	assert.Equal(t, "jane doe", search.term)
+	assert.Equal(t, 20, search.limit)
+}
+
+func TestSearchService_RejectsShortAndLongTerms(t *testing.T) {
+	search := &fakeUserSearchRepo{}
+	svc := service.NewSearchService(search)
+
+	for _, term := range []string{"", "ab", " a \n\t ", strings.Repeat("x", 101)} {
+		_, err := svc.Search(context.Background(), term, 10)
+		assert.ErrorIs(t, err, service.ErrSearchTermInvalid, "%q", term)
+	}
+	assert.Zero(t, search.calls)
+}
+
+func TestSearchService_ClampsLimitAndKeepsWildcards(t *testing.T) {
+	search := &fakeUserSearchRepo{}
+	svc := service.NewSearchService(search)
+
+	_, err := svc.Search(context.Background(), "%%%_\\", 1000)
+	require.NoError(t, err)
+	assert.Equal(t, "%%%_\\", search.term)
+	assert.Equal(t, 100, search.limit)
+}
@@ -1,20 +1,34 @@
 package unit
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"image"
+	"image/color"
+	"image/png"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 
+	"github.com/example/user-service/config"
 	"github.com/example/user-service/internal/domain"
 	"github.com/example/user-service/internal/ports/tarantool"
+	"github.com/example/user-service/internal/repo"
 	"github.com/example/user-service/internal/service"
+	"github.com/example/user-service/internal/tenant"
+	"github.com/example/user-service/pkg/patch"
 )
 
 type userRepoStub struct {
-	users map[string]*domain.User
+	users      map[string]*domain.User
+	lastFilter *repo.UserListFilter
+	deleteErrs map[string]error
+	updateErrs map[string]error
+	history    *fakeUsernameHistory
 }
 
 func newUserRepoStub() *userRepoStub {
@@ -23,22 +37,106 @@ func newUserRepoStub() *userRepoStub {
 
 func (r *userRepoStub) Create(ctx context.Context, user *domain.User) error { return nil }
 func (r *userRepoStub) Update(ctx context.Context, user *domain.User) error {
+	if err := r.updateErrs[user.ID]; err != nil {
+		return err
+	}
 	r.users[user.ID] = user
 	return nil
 }
+func (r *userRepoStub) UpdateLocked(ctx context.Context, id string, apply func(user *domain.User, identities int64) error) (*domain.User, error) {
+	user, err := r.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := apply(user, 0); err != nil {
+		return nil, err
+	}
+	return user, r.Update(ctx, user)
+}
+
+func (r *userRepoStub) UpdateUsername(ctx context.Context, user *domain.User, hold *domain.UsernameHold) error {
+	r.recordHold(hold)
+	return r.Update(ctx, user)
+}
+
+// recordHold keeps hold in history, the way the repository writes it in the
+// same transaction as the rename or purge.
+func (r *userRepoStub) recordHold(hold *domain.UsernameHold) {
+	if hold != nil && r.history != nil {
+		r.history.holds = append(r.history.holds, *hold)
+	}
+}
+
 func (r *userRepoStub) FindByEmail(ctx context.Context, email string) (*domain.User, error) {
 	return nil, errors.New("not found")
 }
+func (r *userRepoStub) FindByUsername(ctx context.Context, canonical string) (*domain.User, error) {
+	for _, user := range r.users {
+		if user.UsernameCanonical != nil && *user.UsernameCanonical == canonical {
+			return user, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *userRepoStub) FindByPhone(ctx context.Context, phone string) (*domain.User, error) {
+	for _, user := range r.users {
+		if user.Phone != nil && *user.Phone == phone {
+			return user, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
 func (r *userRepoStub) FindByID(ctx context.Context, id string) (*domain.User, error) {
 	if user, ok := r.users[id]; ok {
+		if orgID, scoped := tenant.From(ctx); scoped && orgID != stringValue(user.OrgID) {
+			return nil, gorm.ErrRecordNotFound
+		}
 		return user, nil
 	}
 	return nil, errors.New("not found")
 }
-func (r *userRepoStub) Delete(ctx context.Context, id string) error { return nil }
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+func (r *userRepoStub) Delete(ctx context.Context, id string, hold *domain.UsernameHold) error {
+	if err := r.deleteErrs[id]; err != nil {
+		return err
+	}
+	delete(r.users, id)
+	r.recordHold(hold)
+	return nil
+}
 func (r *userRepoStub) List(ctx context.Context, offset, limit int) ([]domain.User, int64, error) {
 	return nil, 0, nil
 }
+func (r *userRepoStub) ListExpiredSuspensions(ctx context.Context, now time.Time, limit int) ([]domain.User, error) {
+	var expired []domain.User
+	for _, user := range r.users {
+		if user.IsSuspended() && user.SuspendedUntil != nil && !user.SuspendedUntil.After(now) && len(expired) < limit {
+			expired = append(expired, *user)
+		}
+	}
+	return expired, nil
+}
+func (r *userRepoStub) ListPurgeable(ctx context.Context, now time.Time, limit int) ([]domain.User, error) {
+	var purgeable []domain.User
+	for _, user := range r.users {
+		if user.IsPendingDeletion() && !user.PurgeAt.After(now) && len(purgeable) < limit {
+			purgeable = append(purgeable, *user)
+		}
+	}
+	return purgeable, nil
+}
+func (r *userRepoStub) ListFiltered(ctx context.Context, filter repo.UserListFilter) (*repo.UserPage, error) {
+	r.lastFilter = &filter
+	return &repo.UserPage{}, nil
+}
 
 type profileRepoStub struct {
 	profiles map[string]*domain.UserProfile
@@ -69,7 +167,14 @@ func (identityRepoStub) FindByProviderUserID(ctx context.Context, provider domai
 func (identityRepoStub) FindByUserAndProvider(ctx context.Context, userID string, provider domain.IdentityProvider) (*domain.UserIdentity, error) {
 	return nil, errors.New("not found")
 }
-func (identityRepoStub) Delete(ctx context.Context, identity *domain.UserIdentity) error { return nil }
+func (identityRepoStub) FindByUserID(ctx context.Context, userID string) ([]domain.UserIdentity, error) {
+	return nil, nil
+}
+func (identityRepoStub) Touch(ctx context.Context, id string, at time.Time) error        { return nil }
+func (identityRepoStub) Update(ctx context.Context, identity *domain.UserIdentity) error { return nil }
+func (identityRepoStub) Delete(ctx context.Context, identity *domain.UserIdentity, check func(user *domain.User, remaining int64) error) error {
+	return nil
+}
 
 type tarantoolStub struct{}
 
@@ -86,25 +191,185 @@ func (tarantoolStub) VerifyEmailChange(ctx context.Context, uuid, code string) (
 	return &tarantool.VerificationResult{Email: "new@example.com"}, nil
 }
 
+func (tarantoolStub) StartAccountLink(ctx context.Context, userID, email string) (string, error) {
+	return "uuid-link", nil
+}
+func (tarantoolStub) VerifyAccountLink(ctx context.Context, uuid, code string) (*tarantool.VerificationResult, error) {
+	return &tarantool.VerificationResult{Email: "user@example.com"}, nil
+}
+
+type fileStorageStub struct {
+	uploads map[string]string
+	deleted []string
+}
+
+func (f *fileStorageStub) Upload(ctx context.Context, fileName, contentType string, data []byte) (string, error) {
+	if f.uploads == nil {
+		f.uploads = map[string]string{}
+	}
+	f.uploads[fileName] = contentType
+	return "https://files.example.com/" + fileName, nil
+}
+
+func (f *fileStorageStub) Delete(ctx context.Context, fileURL string) error {
+	f.deleted = append(f.deleted, fileURL)
+	return nil
+}
+
+func newTestAvatarStore(storage *fileStorageStub) service.AvatarStore {
+	cfg := &config.Config{
+		FileStoragePublicURL: "https://files.example.com",
+		AvatarMaxBytes:       1 << 20,
+		AvatarMaxPixels:      1 << 20,
+		AvatarThumbnailSizes: []int{16, 32},
+	}
+	return service.NewAvatarStore(cfg, storage)
+}
+
 func TestUserService_UpdateProfile(t *testing.T) {
 	users := newUserRepoStub()
 	profiles := newProfileRepoStub()
-	svc := service.NewUserService(users, profiles, identityRepoStub{}, tarantoolStub{})
+	svc := service.NewUserService(nil, users, profiles, identityRepoStub{}, nil, tarantoolStub{}, nil, newTestAvatarStore(&fileStorageStub{}), nil)
 	display := "New Name"
-	avatar := "http://avatar"
+	avatar := "https://files.example.com/avatar.png"
 
-	profile, err := svc.UpdateProfile(context.Background(), "user-1", &display, &avatar)
+	profile, err := svc.UpdateProfile(context.Background(), "user-1", service.ProfileUpdate{DisplayName: patch.Set(display), AvatarURL: patch.Set(avatar)})
 	require.NoError(t, err)
 	assert.Equal(t, &display, profile.DisplayName)
 	assert.Equal(t, &avatar, profile.AvatarURL)
 }
 
+// racingProfileRepo reports a version conflict for the first conflicts
+// updates, as if another writer got there first.
+type racingProfileRepo struct {
+	*profileRepoStub
+	conflicts int
+	updates   int
+}
+
+func (p *racingProfileRepo) Update(ctx context.Context, profile *domain.UserProfile) error {
+	p.updates++
+	if p.updates <= p.conflicts {
+		return repo.ErrVersionConflict
+	}
+	return p.profileRepoStub.Update(ctx, profile)
+}
+
+func TestUserService_UpdateProfile_NullClears(t *testing.T) {
+	profiles := newProfileRepoStub()
+	display, avatar, company := "Ada", "https://files.example.com/avatar.png", "Acme"
+	profiles.profiles["user-1"].DisplayName = &display
+	profiles.profiles["user-1"].AvatarURL = &avatar
+	profiles.profiles["user-1"].AvatarThumbnails = domain.JSONMap{"32": "https://files.example.com/avatar_32.png"}
+	profiles.profiles["user-1"].Company = &company
+	profiles.profiles["user-1"].Visibility = domain.JSONMap{"email": "public", "bio": "private"}
+	svc := service.NewUserService(nil, newUserRepoStub(), profiles, identityRepoStub{}, nil, tarantoolStub{}, nil, newTestAvatarStore(&fileStorageStub{}), nil)
+
+	bio := "private"
+	profile, err := svc.UpdateProfile(context.Background(), "user-1", service.ProfileUpdate{
+		DisplayName: patch.Null[string](),
+		AvatarURL:   patch.Null[string](),
+		Visibility:  patch.Set(map[string]*string{"email": nil, "bio": &bio}),
+	})
+	require.NoError(t, err)
+	assert.Nil(t, profile.DisplayName)
+	assert.Nil(t, profile.AvatarURL)
+	assert.Nil(t, profile.AvatarThumbnails)
+	assert.Equal(t, &company, profile.Company, "absent fields are left alone")
+	assert.Equal(t, domain.JSONMap{"bio": "private"}, profile.Visibility)
+
+	profile, err = svc.UpdateProfile(context.Background(), "user-1", service.ProfileUpdate{Visibility: patch.Null[map[string]*string]()})
+	require.NoError(t, err)
+	assert.Nil(t, profile.Visibility)
+}
+
+func TestUserService_UpdateProfile_Revisions(t *testing.T) {
+	users := newUserRepoStub()
+	profiles := newProfileRepoStub()
+	users.users["user-1"].Version = 2
+	users.users["user-1"].Profile = profiles.profiles["user-1"]
+	profiles.profiles["user-1"].Version = 5
+	svc := service.NewUserService(nil, users, profiles, identityRepoStub{}, nil, tarantoolStub{}, nil, nil, nil)
+	display := "New Name"
+
+	_, err := svc.UpdateProfile(context.Background(), "user-1", service.ProfileUpdate{DisplayName: patch.Set(display), Revisions: []string{"2.4"}})
+	assert.ErrorIs(t, err, service.ErrRevisionMismatch)
+	assert.Nil(t, profiles.profiles["user-1"].DisplayName)
+
+	profile, err := svc.UpdateProfile(context.Background(), "user-1", service.ProfileUpdate{DisplayName: patch.Set(display), Revisions: []string{"1.1", "2.5"}})
+	require.NoError(t, err)
+	assert.Equal(t, &display, profile.DisplayName)
+}
+
+func TestUserService_UpdateProfile_VersionConflict(t *testing.T) {
+	users := newUserRepoStub()
+	profiles := &racingProfileRepo{profileRepoStub: newProfileRepoStub(), conflicts: 1}
+	users.users["user-1"].Profile = profiles.profiles["user-1"]
+	svc := service.NewUserService(nil, users, profiles, identityRepoStub{}, nil, tarantoolStub{}, nil, nil, nil)
+	display := "New Name"
+
+	// Without a revision the update is re-applied to the fresh profile.
+	profile, err := svc.UpdateProfile(context.Background(), "user-1", service.ProfileUpdate{DisplayName: patch.Set(display)})
+	require.NoError(t, err)
+	assert.Equal(t, &display, profile.DisplayName)
+	assert.Equal(t, 2, profiles.updates)
+
+	// With one, losing the race is reported instead.
+	profiles.conflicts, profiles.updates = 1, 0
+	_, err = svc.UpdateProfile(context.Background(), "user-1", service.ProfileUpdate{DisplayName: patch.Set(display), Revisions: []string{"0.0"}})
+	assert.ErrorIs(t, err, service.ErrRevisionMismatch)
+	assert.Equal(t, 1, profiles.updates)
+}
+
 func TestUserService_VerifyEmailChange(t *testing.T) {
 	users := newUserRepoStub()
 	profiles := newProfileRepoStub()
-	svc := service.NewUserService(users, profiles, identityRepoStub{}, tarantoolStub{})
+	svc := service.NewUserService(nil, users, profiles, identityRepoStub{}, nil, tarantoolStub{}, nil, nil, nil)
 
 	user, err := svc.VerifyEmailChange(context.Background(), "user-1", "uuid", "code")
 	require.NoError(t, err)
 	assert.Equal(t, "new@example.com", user.Email)
 }
+
+func TestUserService_UpdateProfile_RejectsForeignAvatarURL(t *testing.T) {
+	svc := service.NewUserService(nil, newUserRepoStub(), newProfileRepoStub(), identityRepoStub{}, nil, tarantoolStub{}, nil, newTestAvatarStore(&fileStorageStub{}), nil)
+	avatar := "http://169.254.169.254/latest/meta-data"
+
+	_, err := svc.UpdateProfile(context.Background(), "user-1", service.ProfileUpdate{AvatarURL: patch.Set(avatar)})
+	assert.ErrorIs(t, err, service.ErrAvatarURLNotAllowed)
+}
+
+func TestUserService_UploadAvatar(t *testing.T) {
+	storage := &fileStorageStub{}
+	profiles := newProfileRepoStub()
+	svc := service.NewUserService(nil, newUserRepoStub(), profiles, identityRepoStub{}, nil, tarantoolStub{}, nil, newTestAvatarStore(storage), nil)
+
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 20; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 6), G: 128, B: 64, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+
+	profile, err := svc.UploadAvatar(context.Background(), "user-1", buf.Bytes())
+	require.NoError(t, err)
+	require.NotNil(t, profile.AvatarURL)
+	assert.Equal(t, "https://files.example.com/user-1-original.png", *profile.AvatarURL)
+	assert.Equal(t, "https://files.example.com/user-1-16.png", profile.AvatarThumbnails["16"])
+	assert.Equal(t, "https://files.example.com/user-1-32.png", profile.AvatarThumbnails["32"])
+	assert.Equal(t, "image/png", storage.uploads["user-1-32.png"])
+}
+
+func TestUserService_UploadAvatar_Validation(t *testing.T) {
+	svc := service.NewUserService(nil, newUserRepoStub(), newProfileRepoStub(), identityRepoStub{}, nil, tarantoolStub{}, nil, newTestAvatarStore(&fileStorageStub{}), nil)
+
+	_, err := svc.UploadAvatar(context.Background(), "user-1", []byte("<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>"))
+	assert.ErrorIs(t, err, service.ErrUnsupportedImage)
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 2048, 1024))))
+	_, err = svc.UploadAvatar(context.Background(), "user-1", buf.Bytes())
+	assert.ErrorIs(t, err, service.ErrImageTooLarge)
+}
@@ -0,0 +1,115 @@
+package unit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/example/user-service/config"
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/service"
+	pkglog "github.com/example/user-service/pkg/log"
+)
+
+type fakeUsernameHistory struct {
+	holds []domain.UsernameHold
+}
+
+func (f *fakeUsernameHistory) IsHeld(ctx context.Context, canonical, userID string, now time.Time) (bool, error) {
+	for _, hold := range f.holds {
+		if hold.UsernameCanonical == canonical && hold.UserID != userID && hold.AvailableAt.After(now) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func newUsernameService(users *userRepoStub, history *fakeUsernameHistory) service.UserService {
+	cfg := &config.Config{UsernameChangeInterval: time.Hour, UsernameReleaseCooldown: 24 * time.Hour}
+	users.history = history
+	return service.NewUserService(cfg, users, newProfileRepoStub(), identityRepoStub{}, history, tarantoolStub{}, nil, nil, nil)
+}
+
+func TestUserService_ChangeUsername(t *testing.T) {
+	users := newUserRepoStub()
+	users.users["user-2"] = &domain.User{ID: "user-2", Email: "other@example.com"}
+	history := &fakeUsernameHistory{}
+	svc := newUsernameService(users, history)
+
+	user, err := svc.ChangeUsername(context.Background(), "user-1", "Ada_L")
+	require.NoError(t, err)
+	assert.Equal(t, "Ada_L", *user.Username)
+	assert.Equal(t, "adal", *user.UsernameCanonical)
+
+	_, err = svc.ChangeUsername(context.Background(), "user-2", "ada.l")
+	assert.ErrorIs(t, err, service.ErrUsernameTaken)
+	_, err = svc.ChangeUsername(context.Background(), "user-2", "Support")
+	assert.ErrorIs(t, err, domain.ErrUsernameReserved)
+	_, err = svc.ChangeUsername(context.Background(), "user-2", "x")
+	assert.ErrorIs(t, err, domain.ErrUsernameInvalid)
+
+	// Changing only the spelling is not a rename.
+	user, err = svc.ChangeUsername(context.Background(), "user-1", "ada_l")
+	require.NoError(t, err)
+	assert.Equal(t, "ada_l", *user.Username)
+
+	_, err = svc.ChangeUsername(context.Background(), "user-1", "grace")
+	var tooSoon *service.UsernameChangeTooSoonError
+	require.ErrorAs(t, err, &tooSoon)
+	assert.True(t, tooSoon.RetryAt.After(time.Now()))
+
+	found, err := svc.GetByUsername(context.Background(), "user-2", "ADA.L")
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", found.Public.ID)
+}
+
+func TestUserService_ChangeUsernameHoldsReleasedName(t *testing.T) {
+	users := newUserRepoStub()
+	changedAt := time.Now().UTC().Add(-2 * time.Hour)
+	users.users["user-1"].SetUsername("ada", changedAt)
+	users.users["user-2"] = &domain.User{ID: "user-2", Email: "other@example.com"}
+	history := &fakeUsernameHistory{}
+	svc := newUsernameService(users, history)
+
+	_, err := svc.ChangeUsername(context.Background(), "user-1", "grace")
+	require.NoError(t, err)
+	require.Len(t, history.holds, 1)
+	assert.Equal(t, "ada", history.holds[0].UsernameCanonical)
+
+	_, err = svc.ChangeUsername(context.Background(), "user-2", "Ada")
+	assert.ErrorIs(t, err, service.ErrUsernameTaken)
+
+	// The previous owner may take the name back once the rename limit allows.
+	users.users["user-1"].UsernameChangedAt = &changedAt
+	user, err := svc.ChangeUsername(context.Background(), "user-1", "ada")
+	require.NoError(t, err)
+	assert.Equal(t, "ada", *user.Username)
+}
+
+func TestAuthService_SignInWithUsername(t *testing.T) {
+	cfg := &config.Config{JWTSecret: "secret", JWTTTLMinutes: time.Minute, JWTRefreshTTLMinutes: time.Hour}
+	signer, err := service.NewJWTSigner(cfg)
+	require.NoError(t, err)
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	users := newFakeUserRepo()
+	user := &domain.User{ID: "user-3", Email: "ada@example.com", IsActive: true}
+	user.SetPasswordHash(string(hash))
+	user.SetUsername("Ada_L", time.Now().UTC())
+	users.users[user.Email] = user
+	auth := service.NewAuthService(cfg, pkglog.New("test"), users, newFakeProfileRepo(), newFakeIdentityRepo(), newFakeAccountLinkRepo(), &fakeTarantool{}, newFakeRBACClient(), fakePublisher{}, signer, &fakeAvatarQueue{}, nil, nil, nil, nil)
+
+	signedIn, tokens, err := auth.SignIn(context.Background(), "trace-1", "ada.l", "password123")
+	require.NoError(t, err)
+	require.NotNil(t, tokens)
+	assert.Equal(t, "user-3", signedIn.ID)
+
+	_, _, err = auth.SignIn(context.Background(), "trace-1", "ADA@example.com", "password123")
+	require.NoError(t, err)
+	_, _, err = auth.SignIn(context.Background(), "trace-1", "grace", "password123")
+	assert.ErrorIs(t, err, service.ErrInvalidCredentials)
+}
@@ -0,0 +1,64 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/example/user-service/internal/domain"
+	"github.com/example/user-service/internal/service"
+	"github.com/example/user-service/pkg/patch"
+)
+
+func TestUserService_GetByIDProjectsForOtherUsers(t *testing.T) {
+	users := newUserRepoStub()
+	bio, locale := "Hello", "en-GB"
+	users.users["user-1"].Profile = &domain.UserProfile{UserID: "user-1", Bio: &bio, Locale: &locale}
+	rbac := newFakeRBACClient()
+	rbac.assignments["admin-1"] = "admin"
+	svc := service.NewUserService(nil, users, newProfileRepoStub(), identityRepoStub{}, nil, tarantoolStub{}, rbac, nil, nil)
+
+	view, err := svc.GetByID(context.Background(), "user-2", "user-1")
+	require.NoError(t, err)
+	require.NotNil(t, view.Public)
+	body, err := json.Marshal(view)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id":"user-1","bio":"Hello","member_since":"0001-01-01T00:00:00Z"}`, string(body))
+
+	for _, requester := range []string{"user-1", "admin-1"} {
+		view, err = svc.GetByID(context.Background(), requester, "user-1")
+		require.NoError(t, err)
+		require.NotNil(t, view.Full, requester)
+		body, err = json.Marshal(view)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), `"email":"user@example.com"`)
+	}
+}
+
+func visibility(t *testing.T, raw string) patch.Field[map[string]*string] {
+	t.Helper()
+	var fields patch.Field[map[string]*string]
+	require.NoError(t, json.Unmarshal([]byte(raw), &fields))
+	return fields
+}
+
+func TestUserService_UpdateProfileVisibility(t *testing.T) {
+	users := newUserRepoStub()
+	profiles := newProfileRepoStub()
+	svc := service.NewUserService(nil, users, profiles, identityRepoStub{}, nil, tarantoolStub{}, nil, nil, nil)
+
+	_, err := svc.UpdateProfile(context.Background(), "user-1", service.ProfileUpdate{Visibility: visibility(t, `{"email":"friends","is_active":"public"}`)})
+	assert.Equal(t, []string{"visibility.email", "visibility.is_active"}, fieldNames(err))
+
+	profile, err := svc.UpdateProfile(context.Background(), "user-1", service.ProfileUpdate{Visibility: visibility(t, `{"email":"public","bio":"private"}`)})
+	require.NoError(t, err)
+	users.users["user-1"].Profile = profile
+
+	view, err := svc.GetByID(context.Background(), "user-2", "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, "user@example.com", view.Public.Email)
+	assert.Nil(t, view.Public.Bio)
+}